@@ -4,24 +4,38 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/handlers" // Import the CORS package
 	"github.com/gorilla/mux"
 	"github.com/wael-boudissaa/zencitiBackend/services/activite"
+	"github.com/wael-boudissaa/zencitiBackend/services/digest"
+	"github.com/wael-boudissaa/zencitiBackend/services/payment"
 	"github.com/wael-boudissaa/zencitiBackend/services/restaurant"
 	"github.com/wael-boudissaa/zencitiBackend/services/sensors"
 	"github.com/wael-boudissaa/zencitiBackend/services/user"
+	"github.com/wael-boudissaa/zencitiBackend/types"
 	"github.com/wael-boudissaa/zencitiBackend/utils"
 )
 
 type APISERVER struct {
-	addr string
-	db   *sql.DB
+	addr            string
+	db              *sql.DB
+	paymentProvider types.PaymentProvider
+	imageStore      types.ImageStore
 }
 
 func NewApiServer(addr string, db *sql.DB) *APISERVER {
 	user.NewAuth()
-	return &APISERVER{addr: addr, db: db}
+	paymentProvider, err := payment.NewProvider()
+	if err != nil {
+		log.Fatalf("Could not set up payment provider: %v", err)
+	}
+	imageStore, err := utils.NewImageStore()
+	if err != nil {
+		log.Fatalf("Could not set up image store: %v", err)
+	}
+	return &APISERVER{addr: addr, db: db, paymentProvider: paymentProvider, imageStore: imageStore}
 }
 
 func (s *APISERVER) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -32,24 +46,32 @@ func (s *APISERVER) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	subrouter.Use(utils.LogMiddleware)
 
 	userStore := user.NewStore(s.db)
-	userHandler := user.NewHandler(userStore)
+	userHandler := user.NewHandler(userStore, s.imageStore)
 	userHandler.RegisterRoutes(subrouter)
 
 	activiteStore := activite.NewStore(s.db)
-	activiteHandler := activite.NewHandler(activiteStore)
+	activiteHandler := activite.NewHandler(activiteStore, s.imageStore)
 	activiteHandler.RegisterRouter(subrouter)
 
 	restaurantStore := restaurant.NewStore(s.db)
-	restaurantHandler := restaurant.NewHandler(restaurantStore)
+	restaurantHandler := restaurant.NewHandler(restaurantStore, s.imageStore)
 	restaurantHandler.RegisterRouter(subrouter)
 
 	sensorsStore := sensors.NewStore(s.db)
 	sensorsHandler := sensors.NewHandler(sensorsStore)
 	sensorsHandler.RegisterRoutes(subrouter)
 
+	paymentStore := payment.NewStore(s.db)
+	paymentHandler := payment.NewHandler(paymentStore, s.paymentProvider)
+	paymentHandler.RegisterRoutes(subrouter)
+
+	digestStore := digest.NewStore(s.db)
+	digestHandler := digest.NewHandler(digestStore, digest.NewBuilder(restaurantStore, activiteStore))
+	digestHandler.RegisterRoutes(subrouter)
+
 	// !NOTE : SUBROUTER FOR THE COMMANDES
 
-	// Serve static files
+	// Serve static files, including the images of the local image store
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("static")))
 
 	// Use CORS
@@ -65,6 +87,11 @@ func (s *APISERVER) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Run starts the HTTP server
 func (s *APISERVER) Run() error {
+	restaurant.NewScheduler(restaurant.NewStore(s.db), time.Minute).Start()
+	restaurant.NewEmailDispatcher(restaurant.NewStore(s.db), 30*time.Second).Start()
+	payment.NewDepositSettler(payment.NewStore(s.db), s.paymentProvider, time.Minute).Start()
+	digest.NewScheduler(digest.NewStore(s.db), digest.NewBuilder(restaurant.NewStore(s.db), activite.NewStore(s.db)), 5*time.Minute).Start()
+
 	log.Println("Listening on", s.addr)
 	return http.ListenAndServe(s.addr, s)
 }
@@ -0,0 +1,17 @@
+-- Reservation lifecycle: pending -> confirmed -> completed, pending -> no_show.
+-- Per-restaurant policy used by the reservation scheduler and by CreateReservation.
+CREATE TABLE IF NOT EXISTS reservationPolicy (
+    idRestaurant        VARCHAR(255) NOT NULL PRIMARY KEY,
+    gracePeriodMinutes  INT NOT NULL DEFAULT 30,
+    slotDurationMinutes INT NOT NULL DEFAULT 120,
+    noShowLimit         INT NOT NULL DEFAULT 3,
+    noShowWindowDays    INT NOT NULL DEFAULT 90,
+    blockDays           INT NOT NULL DEFAULT 30,
+    updatedAt           DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+ALTER TABLE reservation MODIFY status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+CREATE INDEX idx_reservation_status_time ON reservation (status, timeFrom);
+CREATE INDEX idx_reservation_client_status ON reservation (idClient, status);
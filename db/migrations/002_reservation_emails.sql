@@ -0,0 +1,28 @@
+-- Client reservation emails (received, confirmed, cancelled, reminder) are queued here
+-- and sent by the email dispatcher, so pending reminders survive restarts.
+CREATE TABLE IF NOT EXISTS emailJob (
+    idJob         VARCHAR(255) NOT NULL PRIMARY KEY,
+    idReservation VARCHAR(255) NOT NULL,
+    jobType       VARCHAR(20)  NOT NULL,
+    sendAt        DATETIME     NOT NULL,
+    status        VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    attempts      INT          NOT NULL DEFAULT 0,
+    lastError     TEXT         NULL,
+    createdAt     DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sentAt        DATETIME     NULL,
+    FOREIGN KEY (idReservation) REFERENCES reservation(idReservation) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_email_job_due ON emailJob (status, sendAt);
+CREATE INDEX idx_email_job_reservation ON emailJob (idReservation, jobType);
+
+-- Per-client opt-out; a missing row means every email is wanted.
+CREATE TABLE IF NOT EXISTS clientEmailPreference (
+    idClient          VARCHAR(255) NOT NULL PRIMARY KEY,
+    reservationEmails TINYINT(1)   NOT NULL DEFAULT 1,
+    reminderEmails    TINYINT(1)   NOT NULL DEFAULT 1,
+    updatedAt         DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idClient) REFERENCES client(idClient) ON DELETE CASCADE
+);
+
+ALTER TABLE reservationPolicy ADD COLUMN reminderHoursBefore INT NOT NULL DEFAULT 3;
@@ -0,0 +1,20 @@
+-- Every change made to a reservation after booking, by the client, the restaurant or the scheduler.
+CREATE TABLE IF NOT EXISTS reservationHistory (
+    idHistory              VARCHAR(255) NOT NULL PRIMARY KEY,
+    idReservation          VARCHAR(255) NOT NULL,
+    action                 VARCHAR(30)  NOT NULL,
+    actor                  VARCHAR(20)  NOT NULL,
+    previousTimeFrom       DATETIME     NULL,
+    newTimeFrom            DATETIME     NULL,
+    previousNumberOfPeople INT          NULL,
+    newNumberOfPeople      INT          NULL,
+    previousStatus         VARCHAR(20)  NULL,
+    newStatus              VARCHAR(20)  NULL,
+    createdAt              DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idReservation) REFERENCES reservation(idReservation) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reservation_history ON reservationHistory (idReservation, createdAt);
+
+-- Clients can no longer change or cancel a reservation this many minutes before timeFrom.
+ALTER TABLE reservationPolicy ADD COLUMN modificationCutoffMinutes INT NOT NULL DEFAULT 120;
@@ -0,0 +1,17 @@
+-- Friends invited to a reservation by its host (reservation.idClient).
+CREATE TABLE IF NOT EXISTS reservationGuest (
+    idReservationGuest VARCHAR(255) NOT NULL PRIMARY KEY,
+    idReservation      VARCHAR(255) NOT NULL,
+    idClient           VARCHAR(255) NOT NULL,
+    status             VARCHAR(20)  NOT NULL DEFAULT 'invited',
+    invitedAt          DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    respondedAt        DATETIME     NULL,
+    UNIQUE KEY uq_reservation_guest (idReservation, idClient),
+    FOREIGN KEY (idReservation) REFERENCES reservation(idReservation) ON DELETE CASCADE,
+    FOREIGN KEY (idClient) REFERENCES client(idClient) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reservation_guest_client ON reservationGuest (idClient, status);
+
+-- Who added an order line; NULL means the reservation host.
+ALTER TABLE orderFood ADD COLUMN idClient VARCHAR(255) NULL;
@@ -0,0 +1,31 @@
+-- Tax and service charge applied to bills, in percent.
+CREATE TABLE IF NOT EXISTS restaurantBillingSettings (
+    idRestaurant      VARCHAR(255)  NOT NULL PRIMARY KEY,
+    taxRate           DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    serviceChargeRate DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    updatedAt         DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+-- A generated bill of a reservation and what each participant owes.
+CREATE TABLE IF NOT EXISTS bill (
+    idBill        VARCHAR(255)   NOT NULL PRIMARY KEY,
+    idReservation VARCHAR(255)   NOT NULL,
+    mode          VARCHAR(20)    NOT NULL,
+    subtotal      DECIMAL(10, 2) NOT NULL,
+    serviceCharge DECIMAL(10, 2) NOT NULL,
+    tax           DECIMAL(10, 2) NOT NULL,
+    total         DECIMAL(10, 2) NOT NULL,
+    createdAt     DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idReservation) REFERENCES reservation(idReservation) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_bill_reservation ON bill (idReservation, createdAt);
+
+CREATE TABLE IF NOT EXISTS billShare (
+    idBillShare VARCHAR(255)   NOT NULL PRIMARY KEY,
+    idBill      VARCHAR(255)   NOT NULL,
+    idClient    VARCHAR(255)   NOT NULL,
+    amount      DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (idBill) REFERENCES bill(idBill) ON DELETE CASCADE
+);
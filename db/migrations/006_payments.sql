@@ -0,0 +1,42 @@
+-- Payments for orders (targetType = 'order') and activity bookings (targetType = 'activity').
+CREATE TABLE IF NOT EXISTS payment (
+    idPayment         VARCHAR(255)   NOT NULL PRIMARY KEY,
+    targetType        VARCHAR(20)    NOT NULL,
+    idTarget          VARCHAR(255)   NOT NULL,
+    idClient          VARCHAR(255)   NOT NULL,
+    amount            DECIMAL(10, 2) NOT NULL,
+    amountRefunded    DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    currency          VARCHAR(3)     NOT NULL DEFAULT 'DZD',
+    status            VARCHAR(30)    NOT NULL DEFAULT 'requires_payment',
+    provider          VARCHAR(30)    NOT NULL,
+    providerReference VARCHAR(255)   NOT NULL,
+    createdAt         DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt         DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_payment_reference (provider, providerReference)
+);
+
+CREATE INDEX idx_payment_target ON payment (targetType, idTarget);
+CREATE INDEX idx_payment_client ON payment (idClient, createdAt);
+
+CREATE TABLE IF NOT EXISTS paymentRefund (
+    idRefund          VARCHAR(255)   NOT NULL PRIMARY KEY,
+    idPayment         VARCHAR(255)   NOT NULL,
+    amount            DECIMAL(10, 2) NOT NULL,
+    reason            VARCHAR(255)   NULL,
+    status            VARCHAR(20)    NOT NULL,
+    providerReference VARCHAR(255)   NOT NULL,
+    createdAt         DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idPayment) REFERENCES payment(idPayment) ON DELETE CASCADE
+);
+
+-- Provider callbacks already applied, so a replayed webhook is ignored.
+CREATE TABLE IF NOT EXISTS paymentEvent (
+    idEvent    VARCHAR(255) NOT NULL PRIMARY KEY,
+    idPayment  VARCHAR(255) NOT NULL,
+    type       VARCHAR(50)  NOT NULL,
+    payload    TEXT         NOT NULL,
+    receivedAt DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Activities had no price; bookings are charged this amount.
+ALTER TABLE activity ADD COLUMN price DECIMAL(10, 2) NOT NULL DEFAULT 0;
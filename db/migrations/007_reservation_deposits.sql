@@ -0,0 +1,33 @@
+-- When a reservation needs a deposit and how much of it is kept on late cancellation or no-show.
+CREATE TABLE IF NOT EXISTS depositPolicy (
+    idRestaurant               VARCHAR(255)   NOT NULL PRIMARY KEY,
+    enabled                    TINYINT(1)     NOT NULL DEFAULT 0,
+    minPartySize               INT            NOT NULL DEFAULT 0,
+    peakDays                   VARCHAR(20)    NOT NULL DEFAULT '',
+    peakStartTime              VARCHAR(5)     NOT NULL DEFAULT '',
+    peakEndTime                VARCHAR(5)     NOT NULL DEFAULT '',
+    amountPerPerson            DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    lateCancellationFeePercent DECIMAL(5, 2)  NOT NULL DEFAULT 100,
+    noShowFeePercent           DECIMAL(5, 2)  NOT NULL DEFAULT 100,
+    updatedAt                  DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+-- Deposit of a reservation, paid as a payment with targetType = 'deposit' and idTarget = idReservation.
+-- status: required -> paid -> pending_settlement -> settled, or required -> cancelled when never paid.
+CREATE TABLE IF NOT EXISTS reservationDeposit (
+    idReservation VARCHAR(255)   NOT NULL PRIMARY KEY,
+    idRestaurant  VARCHAR(255)   NOT NULL,
+    amount        DECIMAL(10, 2) NOT NULL,
+    reason        VARCHAR(20)    NOT NULL,
+    status        VARCHAR(20)    NOT NULL DEFAULT 'required',
+    idPayment     VARCHAR(255)   NULL,
+    outcome       VARCHAR(30)    NULL,
+    feeAmount     DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    refundAmount  DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    createdAt     DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    settledAt     DATETIME       NULL,
+    FOREIGN KEY (idReservation) REFERENCES reservation(idReservation) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reservation_deposit_status ON reservationDeposit (status);
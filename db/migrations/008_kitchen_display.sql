@@ -0,0 +1,7 @@
+-- Kitchen display: orders go pending -> preparing -> ready -> completed.
+-- The timestamps give the elapsed preparation time shown on the kitchen tablet.
+ALTER TABLE orderList
+    ADD COLUMN preparingAt DATETIME NULL,
+    ADD COLUMN readyAt     DATETIME NULL;
+
+CREATE INDEX idx_order_list_status ON orderList (status, createdAt);
@@ -0,0 +1,28 @@
+-- QR code ordering: each table carries a signed token (see utils.CreateTableToken).
+-- Scanning it opens a table session that walk-in guests order against, without a reservation.
+ALTER TABLE table_restaurant
+    ADD COLUMN qrToken VARCHAR(255) NULL,
+    ADD UNIQUE KEY uq_table_qr_token (qrToken);
+
+-- status: open -> closed once the bill is settled
+CREATE TABLE IF NOT EXISTS tableSession (
+    idTableSession VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRestaurant   VARCHAR(255) NOT NULL,
+    idTable        VARCHAR(255) NOT NULL,
+    status         VARCHAR(20)  NOT NULL DEFAULT 'open',
+    openedAt       DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closedAt       DATETIME     NULL,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_table_session_table ON tableSession (idTable, status);
+
+-- An order belongs either to a reservation or to a table session
+ALTER TABLE orderList
+    MODIFY COLUMN idReservation VARCHAR(255) NULL,
+    ADD COLUMN idTableSession VARCHAR(255) NULL;
+
+CREATE INDEX idx_order_list_table_session ON orderList (idTableSession);
+
+-- Walk-in guests pay a table session without a client account
+ALTER TABLE payment MODIFY COLUMN idClient VARCHAR(255) NULL;
@@ -0,0 +1,33 @@
+-- Takeaway orders are placed directly against a restaurant, without a reservation or table session.
+-- The orderList row holds the items and status, this table the pickup details.
+CREATE TABLE IF NOT EXISTS takeawayOrder (
+    idOrder      VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRestaurant VARCHAR(255) NOT NULL,
+    idClient     VARCHAR(255) NOT NULL,
+    pickupTime   DATETIME     NOT NULL,
+    FOREIGN KEY (idOrder) REFERENCES orderList(idOrder) ON DELETE CASCADE,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE,
+    FOREIGN KEY (idClient) REFERENCES client(idClient) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_takeaway_order_pickup ON takeawayOrder (idRestaurant, pickupTime);
+CREATE INDEX idx_takeaway_order_client ON takeawayOrder (idClient, pickupTime);
+
+-- Pickup windows: at most maxOrdersPerWindow takeaway orders per windowMinutes slot,
+-- between firstPickupTime and lastPickupTime ("HH:MM"), at least minLeadMinutes ahead.
+CREATE TABLE IF NOT EXISTS takeawaySettings (
+    idRestaurant       VARCHAR(255) NOT NULL PRIMARY KEY,
+    enabled            TINYINT(1)   NOT NULL DEFAULT 0,
+    windowMinutes      INT          NOT NULL DEFAULT 15,
+    maxOrdersPerWindow INT          NOT NULL DEFAULT 5,
+    minLeadMinutes     INT          NOT NULL DEFAULT 20,
+    firstPickupTime    VARCHAR(5)   NOT NULL DEFAULT '11:00',
+    lastPickupTime     VARCHAR(5)   NOT NULL DEFAULT '22:00',
+    updatedAt          DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+-- The email queue also carries order emails (takeaway ready for pickup)
+ALTER TABLE emailJob
+    MODIFY COLUMN idReservation VARCHAR(255) NULL,
+    ADD COLUMN idOrder VARCHAR(255) NULL;
@@ -0,0 +1,27 @@
+-- When a menu is served: a daily time window ("HH:MM", may cross midnight), optional weekdays
+-- (CSV of time.Weekday values, empty means every day) and an optional date range.
+CREATE TABLE IF NOT EXISTS menuSchedule (
+    idSchedule VARCHAR(255) NOT NULL PRIMARY KEY,
+    idMenu     VARCHAR(255) NOT NULL,
+    label      VARCHAR(50)  NOT NULL DEFAULT '',
+    startTime  VARCHAR(5)   NOT NULL,
+    endTime    VARCHAR(5)   NOT NULL,
+    weekdays   VARCHAR(20)  NOT NULL DEFAULT '',
+    validFrom  DATE         NULL,
+    validTo    DATE         NULL,
+    priority   INT          NOT NULL DEFAULT 0,
+    createdAt  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idMenu) REFERENCES menu(idMenu) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_menu_schedule_menu ON menuSchedule (idMenu);
+
+-- A menu forced by hand, ahead of the schedules, until the override expires or is removed.
+CREATE TABLE IF NOT EXISTS menuOverride (
+    idRestaurant VARCHAR(255) NOT NULL PRIMARY KEY,
+    idMenu       VARCHAR(255) NOT NULL,
+    until        DATETIME     NULL,
+    createdAt    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE,
+    FOREIGN KEY (idMenu) REFERENCES menu(idMenu) ON DELETE CASCADE
+);
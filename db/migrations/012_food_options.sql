@@ -0,0 +1,47 @@
+-- Choices offered on a food (size, extras, sauces). A client picks between minSelect and maxSelect options of each group.
+CREATE TABLE IF NOT EXISTS foodOptionGroup (
+    idGroup   VARCHAR(255) NOT NULL PRIMARY KEY,
+    idFood    VARCHAR(255) NOT NULL,
+    name      VARCHAR(100) NOT NULL,
+    minSelect INT          NOT NULL DEFAULT 0,
+    maxSelect INT          NOT NULL DEFAULT 1,
+    sortIndex INT          NOT NULL DEFAULT 0,
+    FOREIGN KEY (idFood) REFERENCES food(idFood) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_food_option_group_food ON foodOptionGroup (idFood, sortIndex);
+
+CREATE TABLE IF NOT EXISTS foodOption (
+    idOption   VARCHAR(255)  NOT NULL PRIMARY KEY,
+    idGroup    VARCHAR(255)  NOT NULL,
+    name       VARCHAR(100)  NOT NULL,
+    priceDelta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    available  TINYINT(1)    NOT NULL DEFAULT 1,
+    FOREIGN KEY (idGroup) REFERENCES foodOptionGroup(idGroup) ON DELETE CASCADE
+);
+
+-- Foods served as part of a combo or set menu; the combo itself is a regular food with its own price.
+CREATE TABLE IF NOT EXISTS comboItem (
+    idCombo  VARCHAR(255) NOT NULL,
+    idFood   VARCHAR(255) NOT NULL,
+    quantity INT          NOT NULL DEFAULT 1,
+    PRIMARY KEY (idCombo, idFood),
+    FOREIGN KEY (idCombo) REFERENCES food(idFood) ON DELETE CASCADE,
+    FOREIGN KEY (idFood) REFERENCES food(idFood) ON DELETE CASCADE
+);
+
+-- Order lines get an id and the unit price paid, options included. Lines created before stay NULL.
+ALTER TABLE orderFood
+    ADD COLUMN idOrderFood VARCHAR(255)  NULL,
+    ADD COLUMN unitPrice   DECIMAL(10,2) NULL,
+    ADD UNIQUE KEY uq_order_food_line (idOrderFood);
+
+-- Options chosen on an order line, copied at order time so later menu edits do not change past orders.
+CREATE TABLE IF NOT EXISTS orderFoodOption (
+    idOrderFood VARCHAR(255)  NOT NULL,
+    idOption    VARCHAR(255)  NOT NULL,
+    groupName   VARCHAR(100)  NOT NULL,
+    name        VARCHAR(100)  NOT NULL,
+    priceDelta  DECIMAL(10,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (idOrderFood, idOption)
+);
@@ -0,0 +1,51 @@
+-- Catalogue of allergens a food may contain and dietary labels it may meet.
+CREATE TABLE IF NOT EXISTS foodTag (
+    idTag VARCHAR(255) NOT NULL PRIMARY KEY,
+    name  VARCHAR(100) NOT NULL,
+    kind  VARCHAR(20)  NOT NULL, -- 'allergen' or 'dietary'
+    UNIQUE KEY uq_food_tag_name (kind, name)
+);
+
+INSERT IGNORE INTO foodTag (idTag, name, kind) VALUES
+    ('gluten', 'Gluten', 'allergen'),
+    ('crustaceans', 'Crustaceans', 'allergen'),
+    ('eggs', 'Eggs', 'allergen'),
+    ('fish', 'Fish', 'allergen'),
+    ('peanuts', 'Peanuts', 'allergen'),
+    ('soy', 'Soy', 'allergen'),
+    ('milk', 'Milk', 'allergen'),
+    ('tree-nuts', 'Tree nuts', 'allergen'),
+    ('celery', 'Celery', 'allergen'),
+    ('mustard', 'Mustard', 'allergen'),
+    ('sesame', 'Sesame', 'allergen'),
+    ('sulphites', 'Sulphites', 'allergen'),
+    ('lupin', 'Lupin', 'allergen'),
+    ('molluscs', 'Molluscs', 'allergen'),
+    ('vegetarian', 'Vegetarian', 'dietary'),
+    ('vegan', 'Vegan', 'dietary'),
+    ('halal', 'Halal', 'dietary'),
+    ('gluten-free', 'Gluten free', 'dietary');
+
+CREATE TABLE IF NOT EXISTS foodTagLink (
+    idFood VARCHAR(255) NOT NULL,
+    idTag  VARCHAR(255) NOT NULL,
+    PRIMARY KEY (idFood, idTag),
+    FOREIGN KEY (idFood) REFERENCES food(idFood) ON DELETE CASCADE,
+    FOREIGN KEY (idTag) REFERENCES foodTag(idTag) ON DELETE CASCADE
+);
+
+-- Allergens a client avoids and dietary labels they need.
+CREATE TABLE IF NOT EXISTS clientDietaryTag (
+    idClient VARCHAR(255) NOT NULL,
+    idTag    VARCHAR(255) NOT NULL,
+    PRIMARY KEY (idClient, idTag),
+    FOREIGN KEY (idClient) REFERENCES client(idClient) ON DELETE CASCADE,
+    FOREIGN KEY (idTag) REFERENCES foodTag(idTag) ON DELETE CASCADE
+);
+
+-- Whether incompatible foods are hidden from the client or only flagged.
+CREATE TABLE IF NOT EXISTS clientDietarySettings (
+    idClient         VARCHAR(255) NOT NULL PRIMARY KEY,
+    hideIncompatible TINYINT(1)   NOT NULL DEFAULT 0,
+    FOREIGN KEY (idClient) REFERENCES client(idClient) ON DELETE CASCADE
+);
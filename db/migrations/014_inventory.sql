@@ -0,0 +1,50 @@
+-- Ingredient stock of a restaurant, in the unit the kitchen counts it in (g, ml, piece...).
+CREATE TABLE IF NOT EXISTS ingredient (
+    idIngredient      VARCHAR(255)  NOT NULL PRIMARY KEY,
+    idRestaurant      VARCHAR(255)  NOT NULL,
+    name              VARCHAR(100)  NOT NULL,
+    unit              VARCHAR(20)   NOT NULL,
+    stock             DECIMAL(12,3) NOT NULL DEFAULT 0,
+    lowStockThreshold DECIMAL(12,3) NOT NULL DEFAULT 0,
+    lowStockAlertedAt DATETIME      NULL, -- set once an alert went out, cleared when restocked above the threshold
+    createdAt         DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_ingredient_name (idRestaurant, name),
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+-- How much of each ingredient one portion of a food uses.
+CREATE TABLE IF NOT EXISTS recipeItem (
+    idFood       VARCHAR(255)  NOT NULL,
+    idIngredient VARCHAR(255)  NOT NULL,
+    quantity     DECIMAL(12,3) NOT NULL,
+    PRIMARY KEY (idFood, idIngredient),
+    FOREIGN KEY (idFood) REFERENCES food(idFood) ON DELETE CASCADE,
+    FOREIGN KEY (idIngredient) REFERENCES ingredient(idIngredient) ON DELETE CASCADE
+);
+
+-- Every change of stock: restocks, consumption by orders and manual adjustments.
+CREATE TABLE IF NOT EXISTS stockMovement (
+    idMovement   VARCHAR(255)  NOT NULL PRIMARY KEY,
+    idIngredient VARCHAR(255)  NOT NULL,
+    kind         VARCHAR(20)   NOT NULL, -- 'restock', 'consumption' or 'adjustment'
+    quantity     DECIMAL(12,3) NOT NULL, -- negative when stock goes down
+    idOrder      VARCHAR(255)  NULL,
+    note         VARCHAR(255)  NOT NULL DEFAULT '',
+    createdAt    DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idIngredient) REFERENCES ingredient(idIngredient) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_stock_movement_ingredient ON stockMovement (idIngredient, createdAt);
+
+-- Foods taken off the menu because an ingredient ran out; they come back when it is restocked.
+CREATE TABLE IF NOT EXISTS foodStockHold (
+    idFood    VARCHAR(255) NOT NULL PRIMARY KEY,
+    createdAt DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idFood) REFERENCES food(idFood) ON DELETE CASCADE
+);
+
+-- Ingredients are taken out of stock once per order, when the kitchen starts it.
+ALTER TABLE orderList ADD COLUMN stockDeductedAt DATETIME NULL;
+
+-- Orders already past pending never go through the kitchen again
+UPDATE orderList SET stockDeductedAt = createdAt WHERE status <> 'pending';
@@ -0,0 +1,30 @@
+-- Food categories belong to a restaurant, which orders them and may give them an image.
+ALTER TABLE foodCategory
+    ADD COLUMN idRestaurant VARCHAR(255) NULL,
+    ADD COLUMN sortIndex    INT          NOT NULL DEFAULT 0,
+    ADD COLUMN image        VARCHAR(255) NULL;
+
+-- Every restaurant gets its own copy of the global categories, which all restaurants could use until now.
+-- Global names were never unique: categories sharing a name become a single one per restaurant, so the
+-- unique key added below cannot fail halfway through the migration.
+INSERT INTO foodCategory (idCategory, nameCategorie, idRestaurant, sortIndex)
+SELECT CONCAT(MIN(fc.idCategory), '-', r.idRestaurant), fc.nameCategorie, r.idRestaurant, 0
+FROM foodCategory fc
+CROSS JOIN restaurant r
+WHERE fc.idRestaurant IS NULL
+GROUP BY r.idRestaurant, fc.nameCategorie;
+
+UPDATE food f
+JOIN foodCategory fc ON fc.idCategory = f.idCategory AND fc.idRestaurant IS NULL
+JOIN foodCategory copy ON copy.idRestaurant = f.idRestaurant AND copy.nameCategorie = fc.nameCategorie
+SET f.idCategory = copy.idCategory;
+
+-- Every global category now has a copy in each restaurant
+DELETE FROM foodCategory WHERE idRestaurant IS NULL;
+
+ALTER TABLE foodCategory
+    MODIFY idRestaurant VARCHAR(255) NOT NULL,
+    ADD UNIQUE KEY uq_food_category_name (idRestaurant, nameCategorie),
+    ADD FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE;
+
+CREATE INDEX idx_food_category_sort ON foodCategory (idRestaurant, sortIndex);
@@ -0,0 +1,70 @@
+-- Scheduling rules of a restaurant's staff.
+CREATE TABLE IF NOT EXISTS shiftSettings (
+    idRestaurant        VARCHAR(255) NOT NULL PRIMARY KEY,
+    minRestHours        INT          NOT NULL DEFAULT 10, -- between the end of a shift and the start of the next one
+    clockInEarlyMinutes INT          NOT NULL DEFAULT 30, -- how early a clock-in still counts for the coming shift
+    updatedAt           DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+-- Weekly shift templates ("HH:MM" times, weekdays as a CSV of 0 = Sunday .. 6 = Saturday, empty for every day).
+-- An endTime before startTime ends the next day.
+CREATE TABLE IF NOT EXISTS shiftTemplate (
+    idTemplate   VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRestaurant VARCHAR(255) NOT NULL,
+    label        VARCHAR(100) NOT NULL,
+    startTime    VARCHAR(5)   NOT NULL,
+    endTime      VARCHAR(5)   NOT NULL,
+    weekdays     VARCHAR(20)  NOT NULL DEFAULT '',
+    createdAt    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE
+);
+
+-- A shift assigned to a worker, built from a template or entered by hand.
+CREATE TABLE IF NOT EXISTS workerShift (
+    idShift            VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRestaurant       VARCHAR(255) NOT NULL,
+    idRestaurantWorker VARCHAR(255) NOT NULL,
+    idTemplate         VARCHAR(255) NULL,
+    startsAt           DATETIME     NOT NULL,
+    endsAt             DATETIME     NOT NULL,
+    note               VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt          DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE,
+    FOREIGN KEY (idRestaurantWorker) REFERENCES restaurantWorkers(idRestaurantWorker) ON DELETE CASCADE,
+    FOREIGN KEY (idTemplate) REFERENCES shiftTemplate(idTemplate) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_worker_shift_worker ON workerShift (idRestaurantWorker, startsAt);
+CREATE INDEX idx_worker_shift_restaurant ON workerShift (idRestaurant, startsAt);
+
+-- A worker asking a colleague to take over one of their shifts; the restaurant accepts or rejects it.
+CREATE TABLE IF NOT EXISTS shiftSwap (
+    idSwap       VARCHAR(255) NOT NULL PRIMARY KEY,
+    idShift      VARCHAR(255) NOT NULL,
+    fromWorker   VARCHAR(255) NOT NULL,
+    toWorker     VARCHAR(255) NOT NULL,
+    status       VARCHAR(20)  NOT NULL DEFAULT 'pending', -- 'pending', 'accepted', 'rejected' or 'cancelled'
+    note         VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolvedAt   DATETIME     NULL,
+    FOREIGN KEY (idShift) REFERENCES workerShift(idShift) ON DELETE CASCADE,
+    FOREIGN KEY (fromWorker) REFERENCES restaurantWorkers(idRestaurantWorker) ON DELETE CASCADE,
+    FOREIGN KEY (toWorker) REFERENCES restaurantWorkers(idRestaurantWorker) ON DELETE CASCADE
+);
+
+-- Clock-in/clock-out records; clockOut stays NULL while the worker is in.
+CREATE TABLE IF NOT EXISTS workerClock (
+    idClock            VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRestaurantWorker VARCHAR(255) NOT NULL,
+    idRestaurant       VARCHAR(255) NOT NULL,
+    idShift            VARCHAR(255) NULL,
+    clockIn            DATETIME     NOT NULL,
+    clockOut           DATETIME     NULL,
+    FOREIGN KEY (idRestaurantWorker) REFERENCES restaurantWorkers(idRestaurantWorker) ON DELETE CASCADE,
+    FOREIGN KEY (idRestaurant) REFERENCES restaurant(idRestaurant) ON DELETE CASCADE,
+    FOREIGN KEY (idShift) REFERENCES workerShift(idShift) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_worker_clock_worker ON workerClock (idRestaurantWorker, clockIn);
+CREATE INDEX idx_worker_clock_restaurant ON workerClock (idRestaurant, clockOut);
@@ -0,0 +1,25 @@
+-- The worker who served a reservation or an order, whom the client can rate once the visit is completed.
+ALTER TABLE reservation
+    ADD COLUMN idServer VARCHAR(255) NULL,
+    ADD CONSTRAINT fk_reservation_server FOREIGN KEY (idServer) REFERENCES restaurantWorkers(idRestaurantWorker) ON DELETE SET NULL;
+
+ALTER TABLE orderList
+    ADD COLUMN idServer VARCHAR(255) NULL,
+    ADD CONSTRAINT fk_order_list_server FOREIGN KEY (idServer) REFERENCES restaurantWorkers(idRestaurantWorker) ON DELETE SET NULL;
+
+-- Worker ratings point at the visit they are about: the reservation, or the order for takeaway and walk-ins.
+-- A client rates a visit once.
+ALTER TABLE rating
+    ADD COLUMN idReservation VARCHAR(255) NULL,
+    ADD COLUMN idOrder       VARCHAR(255) NULL,
+    ADD UNIQUE KEY uq_rating_reservation (ratingType, idClient, idReservation),
+    ADD UNIQUE KEY uq_rating_order (ratingType, idClient, idOrder);
+
+CREATE INDEX idx_rating_worker ON rating (idRestaurantWorker, ratingType);
+
+-- Aggregate ratings are recomputed from the rating rows from now on
+UPDATE restaurantWorkers rw
+SET rw.rating = IFNULL((
+    SELECT AVG(r.rating) FROM rating r
+    WHERE r.idRestaurantWorker = rw.idRestaurantWorker AND r.ratingType = 'worker'
+), 0);
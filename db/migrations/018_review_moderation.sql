@@ -0,0 +1,26 @@
+-- Reviews can be answered by the restaurant and hidden by a moderator.
+-- moderationStatus: 'visible' or 'hidden'; hidden reviews leave the public lists and the averages.
+ALTER TABLE rating
+    ADD COLUMN moderationStatus VARCHAR(20)  NOT NULL DEFAULT 'visible',
+    ADD COLUMN moderationNote   VARCHAR(255) NULL,
+    ADD COLUMN moderatedAt      DATETIME     NULL,
+    ADD COLUMN reply            TEXT         NULL,
+    ADD COLUMN repliedAt        DATETIME     NULL;
+
+CREATE INDEX idx_rating_restaurant ON rating (idRestaurant, moderationStatus, createdAt);
+CREATE INDEX idx_rating_activity ON rating (idActivity, moderationStatus, createdAt);
+
+-- Clients flag abusive reviews; open flags (resolvedAt NULL) put the review in the moderation queue.
+CREATE TABLE IF NOT EXISTS ratingFlag (
+    idFlag     VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRating   VARCHAR(255) NOT NULL,
+    idClient   VARCHAR(255) NOT NULL,
+    reason     VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolvedAt DATETIME     NULL,
+    UNIQUE KEY uq_rating_flag_client (idRating, idClient),
+    FOREIGN KEY (idRating) REFERENCES rating(idRating) ON DELETE CASCADE,
+    FOREIGN KEY (idClient) REFERENCES client(idClient) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_rating_flag_open ON ratingFlag (resolvedAt, idRating);
@@ -0,0 +1,23 @@
+-- Photos attached to restaurant and activity reviews. The files live in the photo store (Cloudinary),
+-- publicId is what it needs to delete them. status: 'visible' or 'removed' by a moderator or the author.
+CREATE TABLE IF NOT EXISTS reviewPhoto (
+    idPhoto      VARCHAR(255) NOT NULL PRIMARY KEY,
+    idRating     VARCHAR(255) NOT NULL,
+    url          VARCHAR(512) NOT NULL,
+    thumbnailUrl VARCHAR(512) NOT NULL,
+    publicId     VARCHAR(255) NOT NULL,
+    sortIndex    INT          NOT NULL DEFAULT 0,
+    status       VARCHAR(20)  NOT NULL DEFAULT 'visible',
+    createdAt    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    removedAt    DATETIME     NULL,
+    FOREIGN KEY (idRating) REFERENCES rating(idRating) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_review_photo_rating ON reviewPhoto (idRating, status, sortIndex);
+
+-- A flag can point at one photo of the review rather than its text
+ALTER TABLE ratingFlag
+    ADD COLUMN idPhoto VARCHAR(255) NULL,
+    DROP INDEX uq_rating_flag_client,
+    ADD UNIQUE KEY uq_rating_flag_client (idRating, idClient, idPhoto),
+    ADD CONSTRAINT fk_rating_flag_photo FOREIGN KEY (idPhoto) REFERENCES reviewPhoto(idPhoto) ON DELETE CASCADE;
@@ -0,0 +1,26 @@
+-- Digest emails of restaurant and activity admins, sent only to those who subscribed. A missing row
+-- means no digest, with the weekly one on Mondays at 08:00 once subscribed.
+-- weeklyDay: 0 is Sunday, 1 Monday ... 6 Saturday.
+CREATE TABLE IF NOT EXISTS digestSubscription (
+    idProfile VARCHAR(255) NOT NULL PRIMARY KEY,
+    daily     TINYINT(1)   NOT NULL DEFAULT 0,
+    weekly    TINYINT(1)   NOT NULL DEFAULT 0,
+    sendHour  INT          NOT NULL DEFAULT 8,
+    weeklyDay INT          NOT NULL DEFAULT 1,
+    updatedAt DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (idProfile) REFERENCES profile(idProfile) ON DELETE CASCADE
+);
+
+-- One row per digest and day it is sent on, so a restart never sends the same digest twice.
+-- status: 'sent' or 'failed', failed ones are retried until maxDigestAttempts.
+CREATE TABLE IF NOT EXISTS digestDelivery (
+    idProfile  VARCHAR(255) NOT NULL,
+    frequency  VARCHAR(10)  NOT NULL,
+    digestDate DATE         NOT NULL,
+    status     VARCHAR(20)  NOT NULL,
+    attempts   INT          NOT NULL DEFAULT 0,
+    lastError  TEXT         NULL,
+    updatedAt  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (idProfile, frequency, digestDate),
+    FOREIGN KEY (idProfile) REFERENCES profile(idProfile) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- Reservation lifecycle: pending -> confirmed -> checked in -> completed. Pending reservations the restaurant
+-- never confirmed expire once their slot is over, confirmed ones nobody checked in to become no-shows.
+ALTER TABLE reservation ADD COLUMN checkedInAt DATETIME NULL;
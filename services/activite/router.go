@@ -14,11 +14,12 @@ import (
 )
 
 type Handler struct {
-	store types.ActiviteStore
+	store  types.ActiviteStore
+	images types.ImageStore
 }
 
-func NewHandler(s types.ActiviteStore) *Handler {
-	return &Handler{store: s}
+func NewHandler(s types.ActiviteStore, images types.ImageStore) *Handler {
+	return &Handler{store: s, images: images}
 }
 
 func (h *Handler) RegisterRouter(r *mux.Router) {
@@ -47,6 +48,11 @@ func (h *Handler) RegisterRouter(r *mux.Router) {
 	r.HandleFunc("/activity/{idActivity}/bookings", h.GetActivityBookings).Methods("GET")
 	r.HandleFunc("/activity/{idActivity}/analytics", h.GetActivityDetailedAnalytics).Methods("GET")
 	r.HandleFunc("/admin/{idAdminActivity}/bookings", h.GetAdminActivityBookings).Methods("GET")
+	r.HandleFunc("/admin/{idAdminActivity}/bookings/export", h.ExportAdminActivityBookings).Methods("GET")
+	r.HandleFunc("/activity/{idActivity}/price", h.UpdateActivityPrice).Methods("PUT")
+	r.HandleFunc("/activity/{idActivity}/image", h.SetActivityImage).Methods("PUT")
+	r.HandleFunc("/activity/{idActivity}/forecast", h.GetActivityForecast).Methods("GET")
+	r.HandleFunc("/activity/{idActivity}/forecast/accuracy", h.GetActivityForecastAccuracy).Methods("GET")
 }
 
 func (h *Handler) GetAllLocationsWithDistances(w http.ResponseWriter, r *http.Request) {
@@ -364,8 +370,13 @@ func (h *Handler) PostReviewActivity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	review.IdRating = idReview
+	review.Comment, _ = utils.FilterProfanity(review.Comment)
 	err = h.store.PostRatingActivity(review)
 	if err != nil {
+		if strings.Contains(err.Error(), "no completed booking") {
+			utils.WriteError(w, http.StatusForbidden, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -396,22 +407,29 @@ func (h *Handler) CreateActivityCategory(w http.ResponseWriter, r *http.Request)
 	}
 	defer file.Close()
 
-	// Upload image to Cloudinary
-	imageURL, err := utils.UploadImageToCloudinary(file)
+	// Save the image under the ID the category is created with
+	categoryID, err := utils.CreateAnId()
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error uploading image: %v", err))
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	image, err := utils.SaveImage(h.images, utils.ImageID("activity-categories", categoryID), file)
+	if err != nil {
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
 		return
 	}
 
 	// Create the category data
 	categoryData := types.ActivityCategoryCreation{
 		NameTypeActivity: nameTypeActivity,
-		ImageActivity:    imageURL,
+		ImageActivity:    image.URL,
+		IdTypeActivity:   categoryID,
 	}
 
 	// Create the category in the database
-	categoryID, err := h.store.CreateActivityCategory(categoryData)
+	categoryID, err = h.store.CreateActivityCategory(categoryData)
 	if err != nil {
+		utils.DeleteImages(h.images, image.PublicID)
 		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to create activity category: %v", err))
 		return
 	}
@@ -420,7 +438,7 @@ func (h *Handler) CreateActivityCategory(w http.ResponseWriter, r *http.Request)
 	response := map[string]interface{}{
 		"message":        "Activity category created successfully",
 		"idTypeActivity": categoryID,
-		"imageURL":       imageURL,
+		"imageURL":       image.URL,
 	}
 	utils.WriteJson(w, http.StatusCreated, response)
 }
@@ -480,7 +498,13 @@ func (h *Handler) GetAdminActivityBookings(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	bookings, err := h.store.GetAdminActivityBookings(idAdminActivity)
+	filter, err := utils.ParseListFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	bookings, err := h.store.GetAdminActivityBookings(idAdminActivity, filter)
 	if err != nil {
 		log.Printf("Error fetching admin activity bookings: %v", err)
 		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to fetch admin activity bookings"))
@@ -489,3 +513,195 @@ func (h *Handler) GetAdminActivityBookings(w http.ResponseWriter, r *http.Reques
 
 	utils.WriteJson(w, http.StatusOK, bookings)
 }
+
+// ExportAdminActivityBookings downloads the bookings of an admin as CSV or PDF (?format), with the filters of
+// GetAdminActivityBookings
+func (h *Handler) ExportAdminActivityBookings(w http.ResponseWriter, r *http.Request) {
+	idAdminActivity := mux.Vars(r)["idAdminActivity"]
+	if idAdminActivity == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idAdminActivity is required"))
+		return
+	}
+	format, err := utils.ExportFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter, err := utils.ParseListFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	exporter := utils.NewExporter(w, format, "bookings-"+idAdminActivity, "Activity bookings", utils.DescribeListFilter(filter),
+		[]string{"Booking", "Activity", "Time", "Client", "Email", "Phone", "Username", "Status"})
+	err = h.store.ExportAdminActivityBookings(idAdminActivity, filter, func(booking types.ActivityBookingDetail) error {
+		return exporter.WriteRow(
+			booking.IdClientActivity,
+			booking.ActivityName,
+			utils.ExportTime(booking.BookingTime),
+			booking.ClientName,
+			booking.ClientEmail,
+			booking.ClientPhone,
+			booking.ClientUsername,
+			booking.Status,
+		)
+	})
+	if err == nil {
+		err = exporter.Close()
+	}
+	// The download is already under way, the file is only cut short
+	if err != nil {
+		log.Printf("Error exporting admin activity bookings: %v", err)
+	}
+}
+
+// GetActivityForecast forecasts the bookings of an activity for the next 14 days, hour by hour, from the same
+// weekdays of the last ?weeks weeks (8 by default)
+func (h *Handler) GetActivityForecast(w http.ResponseWriter, r *http.Request) {
+	idActivity := mux.Vars(r)["idActivity"]
+	if idActivity == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idActivity is required"))
+		return
+	}
+	weeks, err := utils.ForecastWeeks(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	start := utils.Today()
+	bookings, err := h.store.GetActivityDemand(idActivity, start.AddDate(0, 0, -7*weeks), start)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, types.DemandForecast{
+		IdActivity:   idActivity,
+		From:         start.Format("2006-01-02"),
+		To:           start.AddDate(0, 0, utils.ForecastDays-1).Format("2006-01-02"),
+		HistoryWeeks: weeks,
+		Series:       []types.ForecastSeries{utils.ForecastDemand("bookings", bookings, start, utils.ForecastDays, weeks)},
+	})
+}
+
+// GetActivityForecastAccuracy forecasts the last ?days days (14 by default) as it would have been done before them,
+// and compares the forecast with the bookings there were
+func (h *Handler) GetActivityForecastAccuracy(w http.ResponseWriter, r *http.Request) {
+	idActivity := mux.Vars(r)["idActivity"]
+	if idActivity == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idActivity is required"))
+		return
+	}
+	weeks, err := utils.ForecastWeeks(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	days, err := utils.AccuracyDays(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	to := utils.Today()
+	from := to.AddDate(0, 0, -days)
+	history, err := h.store.GetActivityDemand(idActivity, from.AddDate(0, 0, -7*weeks), from)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	bookings, err := h.store.GetActivityDemand(idActivity, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, types.ForecastAccuracy{
+		IdActivity:   idActivity,
+		From:         from.Format("2006-01-02"),
+		To:           to.AddDate(0, 0, -1).Format("2006-01-02"),
+		HistoryWeeks: weeks,
+		Metrics:      []types.MetricAccuracy{utils.ForecastAccuracy(utils.ForecastDemand("bookings", history, from, days, weeks), bookings)},
+	})
+}
+
+// UpdateActivityPrice sets the price charged for a booking of the activity
+func (h *Handler) UpdateActivityPrice(w http.ResponseWriter, r *http.Request) {
+	idActivity := mux.Vars(r)["idActivity"]
+	if idActivity == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idActivity is required"))
+		return
+	}
+
+	var req struct {
+		Price float64 `json:"price"`
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Price < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("price must be positive"))
+		return
+	}
+
+	if err := h.store.UpdateActivityPrice(idActivity, req.Price); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Activity price updated successfully"})
+}
+
+// SetActivityImage replaces the image of an activity (multipart "image") and deletes the previous one
+func (h *Handler) SetActivityImage(w http.ResponseWriter, r *http.Request) {
+	idActivity := mux.Vars(r)["idActivity"]
+	if idActivity == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idActivity is required"))
+		return
+	}
+	if _, err := h.store.GetActivityImage(idActivity); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("image is required"))
+		return
+	}
+	defer file.Close()
+
+	image, err := utils.SaveImage(h.images, utils.ImageID("activities", idActivity), file)
+	if err != nil {
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
+		return
+	}
+
+	previous, err := h.store.SetActivityImage(idActivity, image.URL)
+	if err != nil {
+		// The activity was removed during the upload, the image saved under its ID has no owner left
+		if strings.Contains(err.Error(), "not found") {
+			utils.DeleteImages(h.images, image.PublicID)
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.DeleteReplacedImage(h.images, previous, image.PublicID)
+	utils.WriteJson(w, http.StatusOK, map[string]string{"image": image.URL})
+}
@@ -184,7 +184,13 @@ func (s *Store) GetAllClientActivities(idClient string) ([]types.ClientActivityI
             ca.status,
             a.nameActivity,
             a.imageActivity,
-            a.descriptionActivity
+            a.descriptionActivity,
+            IFNULL((
+                SELECT p.status FROM payment p
+                WHERE p.targetType = 'activity' AND p.idTarget = ca.idClientActivity
+                ORDER BY p.createdAt DESC
+                LIMIT 1
+            ), 'unpaid')
         FROM clientActivity ca
         JOIN activity a ON ca.idActivity = a.idActivity
         WHERE ca.idClient = ?
@@ -206,6 +212,7 @@ func (s *Store) GetAllClientActivities(idClient string) ([]types.ClientActivityI
 			&activity.ActivityName,
 			&activity.ActivityImage,
 			&activity.ActivityDescription,
+			&activity.PaymentStatus,
 		); err != nil {
 			return nil, err
 		}
@@ -345,7 +352,7 @@ func (s *Store) GetActivityFullDetails(id string) (*types.ActivityDetails, error
 
 	// 2. Get rating breakdown
 	ratingCounts := make(map[int]int)
-	ratingQuery := `SELECT rating, COUNT(*) FROM rating WHERE idActivity = ? GROUP BY rating`
+	ratingQuery := `SELECT rating, COUNT(*) FROM rating WHERE idActivity = ? AND moderationStatus = 'visible' GROUP BY rating`
 	rows, err := s.db.Query(ratingQuery, id)
 	if err == nil {
 		defer rows.Close()
@@ -366,11 +373,11 @@ func (s *Store) GetActivityFullDetails(id string) (*types.ActivityDetails, error
 
 	// 3. Get recent reviews (limit 5)
 	reviewQuery := `
-        SELECT p.firstName, p.lastName, r.rating, r.comment, r.createdAt
+        SELECT r.idRating, p.firstName, p.lastName, r.rating, r.comment, r.createdAt
         FROM rating r
         JOIN client c ON r.idClient = c.idClient
         JOIN profile p ON c.idProfile = p.idProfile
-        WHERE r.idActivity = ?
+        WHERE r.idActivity = ? AND r.moderationStatus = 'visible'
         ORDER BY r.createdAt DESC
         LIMIT 5
     `
@@ -380,15 +387,44 @@ func (s *Store) GetActivityFullDetails(id string) (*types.ActivityDetails, error
 		for reviewRows.Next() {
 			var rev types.ActivityReviewDetail
 			var first, last string
-			if err := reviewRows.Scan(&first, &last, &rev.Rating, &rev.Comment, &rev.CreatedAt); err == nil {
+			if err := reviewRows.Scan(&rev.IdRating, &first, &last, &rev.Rating, &rev.Comment, &rev.CreatedAt); err == nil {
 				rev.ReviewerName = strings.TrimSpace(first + " " + last)
 				act.RecentReviews = append(act.RecentReviews, rev)
 			}
 		}
 	}
+
+	// 4. Attach the photos of those reviews
+	for i := range act.RecentReviews {
+		photos, err := s.getReviewPhotos(act.RecentReviews[i].IdRating)
+		if err != nil {
+			return nil, err
+		}
+		act.RecentReviews[i].Photos = photos
+	}
 	return &act, nil
 }
 
+// Helper function to load the visible photos of a review
+func (s *Store) getReviewPhotos(idRating string) ([]types.ReviewPhoto, error) {
+	rows, err := s.db.Query(`SELECT idPhoto, idRating, url, thumbnailUrl, sortIndex, createdAt
+		FROM reviewPhoto WHERE idRating = ? AND status = 'visible' ORDER BY sortIndex`, idRating)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving review photos: %v", err)
+	}
+	defer rows.Close()
+
+	var photos []types.ReviewPhoto
+	for rows.Next() {
+		var photo types.ReviewPhoto
+		if err := rows.Scan(&photo.IdPhoto, &photo.IdRating, &photo.URL, &photo.ThumbnailURL, &photo.SortIndex, &photo.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning review photo: %v", err)
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
 func (s *Store) GetActiviteById(id string) (*types.Activity, error) {
 	query := `SELECT * FROM activity WHERE idActivity = ?`
 	row := s.db.QueryRow(query, id)
@@ -438,14 +474,18 @@ func (s *Store) GetActiviteTypes() (*[]types.ActivitetType, error) {
 
 // CreateActivityCategory creates a new activity category and returns the generated ID
 func (s *Store) CreateActivityCategory(category types.ActivityCategoryCreation) (string, error) {
-	// Generate a unique ID using the standard project UUID generator
-	newID, err := utils.CreateAnId()
-	if err != nil {
-		return "", fmt.Errorf("error generating ID for activity category: %v", err)
+	// Generate a unique ID using the standard project UUID generator, unless the handler already picked one
+	newID := category.IdTypeActivity
+	if newID == "" {
+		var err error
+		newID, err = utils.CreateAnId()
+		if err != nil {
+			return "", fmt.Errorf("error generating ID for activity category: %v", err)
+		}
 	}
 
 	query := `INSERT INTO typeActivity (idTypeActivity, nameTypeActivity, imageActivity) VALUES (?, ?, ?)`
-	_, err = s.db.Exec(query, newID, category.NameTypeActivity, category.ImageActivity)
+	_, err := s.db.Exec(query, newID, category.NameTypeActivity, category.ImageActivity)
 	if err != nil {
 		return "", fmt.Errorf("error creating activity category: %v", err)
 	}
@@ -500,7 +540,7 @@ func (s *Store) GetPopularActivities() (*[]types.Activity, error) {
 			a.idTypeActivity, 
 			a.capacity
 		FROM activity a
-		LEFT JOIN rating r ON a.idActivity = r.idActivity AND r.ratingType = 'activity'
+		LEFT JOIN rating r ON a.idActivity = r.idActivity AND r.ratingType = 'activity' AND r.moderationStatus = 'visible'
 		GROUP BY a.idActivity, a.nameActivity, a.descriptionActivity, a.imageActivity, 
 		         a.longitude, a.latitude, a.idAdminActivity, a.idTypeActivity, a.capacity
 		ORDER BY IFNULL(AVG(r.rating), 0) DESC, a.nameActivity ASC
@@ -568,7 +608,7 @@ func (s *Store) GetActivityStats(idActivity string) (*types.ActivityStats, error
 	}
 
 	// Get total reviews and average rating
-	reviewQuery := `SELECT COUNT(*), IFNULL(AVG(rating), 0) FROM rating WHERE idActivity = ?`
+	reviewQuery := `SELECT COUNT(*), IFNULL(AVG(rating), 0) FROM rating WHERE idActivity = ? AND moderationStatus = 'visible'`
 	err = s.db.QueryRow(reviewQuery, idActivity).Scan(&stats.TotalReviews, &stats.AverageRating)
 	if err != nil {
 		return nil, fmt.Errorf("error getting reviews stats: %v", err)
@@ -707,7 +747,7 @@ func (s *Store) GetActivityStats(idActivity string) (*types.ActivityStats, error
         FROM rating r
         JOIN client c ON r.idClient = c.idClient
         JOIN profile p ON c.idProfile = p.idProfile
-        WHERE r.idActivity = ? AND r.rating = 5
+        WHERE r.idActivity = ? AND r.rating = 5 AND r.moderationStatus = 'visible'
         ORDER BY r.createdAt DESC
         LIMIT 5
     `
@@ -925,7 +965,7 @@ func (s *Store) GetActivityStatsAdmin(idAdminActivity string) (*types.ActivitySt
 	}
 
 	// Get total reviews and average rating (adding ratingType filter)
-	reviewQuery := fmt.Sprintf(`SELECT COUNT(*), IFNULL(AVG(rating), 0) FROM rating WHERE idActivity IN (%s) AND ratingType = 'activity'`, placeholders)
+	reviewQuery := fmt.Sprintf(`SELECT COUNT(*), IFNULL(AVG(rating), 0) FROM rating WHERE idActivity IN (%s) AND ratingType = 'activity' AND moderationStatus = 'visible'`, placeholders)
 	err = s.db.QueryRow(reviewQuery, args...).Scan(&stats.TotalReviews, &stats.AverageRating)
 	if err != nil {
 		return nil, fmt.Errorf("error getting reviews stats: %v", err)
@@ -1060,7 +1100,7 @@ func (s *Store) GetActivityStatsAdmin(idAdminActivity string) (*types.ActivitySt
         JOIN client c ON r.idClient = c.idClient
         JOIN profile p ON c.idProfile = p.idProfile
         JOIN activity a ON r.idActivity = a.idActivity
-        WHERE r.idActivity IN (%s) AND r.rating >= 4 AND r.ratingType = 'activity'
+        WHERE r.idActivity IN (%s) AND r.rating >= 4 AND r.ratingType = 'activity' AND r.moderationStatus = 'visible'
         ORDER BY r.rating DESC, r.createdAt DESC
         LIMIT 5
     `, placeholders)
@@ -1088,8 +1128,19 @@ func (s *Store) GetActivityStatsAdmin(idAdminActivity string) (*types.ActivitySt
 }
 
 func (s *Store) PostRatingActivity(rating types.PostRatingActivity) error {
+	// Only clients who took part in the activity can review it
+	var completed int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM clientActivity WHERE idClient = ? AND idActivity = ? AND status = 'completed'`,
+		rating.IdClient, rating.IdActivity).Scan(&completed)
+	if err != nil {
+		return fmt.Errorf("error checking completed bookings: %v", err)
+	}
+	if completed == 0 {
+		return fmt.Errorf("client %s has no completed booking of activity %s", rating.IdClient, rating.IdActivity)
+	}
+
 	query := `INSERT INTO rating (idRating, idClient, idActivity, ratingType, rating, comment, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(query, rating.IdRating, rating.IdClient, rating.IdActivity, "activity", rating.RatingValue, rating.Comment, time.Now())
+	_, err = s.db.Exec(query, rating.IdRating, rating.IdClient, rating.IdActivity, "activity", rating.RatingValue, rating.Comment, time.Now())
 	if err != nil {
 		return fmt.Errorf("error inserting activity rating: %v", err)
 	}
@@ -1368,7 +1419,24 @@ func (s *Store) GetActivityBookings(idActivity string) ([]types.ActivityBookingD
 }
 
 // GetAdminActivityBookings returns all bookings for activities managed by an admin
-func (s *Store) GetAdminActivityBookings(idAdminActivity string) ([]types.ActivityBookingDetail, error) {
+func (s *Store) GetAdminActivityBookings(idAdminActivity string, filter types.ListFilter) ([]types.ActivityBookingDetail, error) {
+	var bookings []types.ActivityBookingDetail
+	err := s.ExportAdminActivityBookings(idAdminActivity, filter, func(booking types.ActivityBookingDetail) error {
+		// Add activity name to client name for context
+		booking.ClientName = fmt.Sprintf("%s (%s)", booking.ClientName, booking.ActivityName)
+		bookings = append(bookings, booking)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+// ExportAdminActivityBookings hands the bookings of an admin kept by the filter to fn one at a time, without
+// loading them all
+func (s *Store) ExportAdminActivityBookings(idAdminActivity string, filter types.ListFilter, fn func(types.ActivityBookingDetail) error) error {
+	where, args := utils.ListFilterClause(filter, "ca.timeActivity", "ca.status")
 	query := `
 		SELECT 
 			ca.idClientActivity,
@@ -1384,22 +1452,20 @@ func (s *Store) GetAdminActivityBookings(idAdminActivity string) ([]types.Activi
 		JOIN client c ON ca.idClient = c.idClient  
 		JOIN profile p ON c.idProfile = p.idProfile
 		JOIN activity a ON ca.idActivity = a.idActivity
-		WHERE a.idAdminActivity = ?
+		WHERE a.idAdminActivity = ?` + where + `
 		ORDER BY ca.timeActivity DESC
 	`
-	
-	rows, err := s.db.Query(query, idAdminActivity)
+
+	rows, err := s.db.Query(query, append([]interface{}{idAdminActivity}, args...)...)
 	if err != nil {
-		return nil, fmt.Errorf("error querying admin activity bookings: %v", err)
+		return fmt.Errorf("error querying admin activity bookings: %v", err)
 	}
 	defer rows.Close()
 
-	var bookings []types.ActivityBookingDetail
 	for rows.Next() {
 		var booking types.ActivityBookingDetail
 		var clientPhone sql.NullString
-		var activityName string
-		
+
 		err := rows.Scan(
 			&booking.IdClientActivity,
 			&booking.ClientName,
@@ -1409,23 +1475,21 @@ func (s *Store) GetAdminActivityBookings(idAdminActivity string) ([]types.Activi
 			&booking.BookingTime,
 			&booking.Status,
 			&booking.CreatedAt,
-			&activityName,
+			&booking.ActivityName,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning admin booking row: %v", err)
+			return fmt.Errorf("error scanning admin booking row: %v", err)
 		}
-		
+
 		if clientPhone.Valid {
 			booking.ClientPhone = clientPhone.String
 		}
-		
-		// Add activity name to client name for context
-		booking.ClientName = fmt.Sprintf("%s (%s)", booking.ClientName, activityName)
-		
-		bookings = append(bookings, booking)
-	}
 
-	return bookings, nil
+		if err := fn(booking); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 // GetActivityDetailedAnalytics returns comprehensive analytics for a specific activity
@@ -1464,7 +1528,7 @@ func (s *Store) GetActivityDetailedAnalytics(idActivity string) (*types.Activity
 	}
 
 	// Get ratings stats
-	reviewQuery := `SELECT COUNT(*), IFNULL(AVG(rating), 0) FROM rating WHERE idActivity = ? AND ratingType = 'activity'`
+	reviewQuery := `SELECT COUNT(*), IFNULL(AVG(rating), 0) FROM rating WHERE idActivity = ? AND ratingType = 'activity' AND moderationStatus = 'visible'`
 	err = s.db.QueryRow(reviewQuery, idActivity).Scan(&analytics.TotalReviews, &analytics.AverageRating)
 	if err != nil {
 		return nil, fmt.Errorf("error getting reviews stats: %v", err)
@@ -1607,7 +1671,7 @@ func (s *Store) GetActivityDetailedAnalytics(idActivity string) (*types.Activity
 		FROM rating r
 		JOIN client c ON r.idClient = c.idClient
 		JOIN profile p ON c.idProfile = p.idProfile
-		WHERE r.idActivity = ? AND r.rating >= 4 AND r.ratingType = 'activity'
+		WHERE r.idActivity = ? AND r.rating >= 4 AND r.ratingType = 'activity' AND r.moderationStatus = 'visible'
 		ORDER BY r.rating DESC, r.createdAt DESC
 		LIMIT 5
 	`
@@ -1645,3 +1709,73 @@ func (s *Store) GetActivityDetailedAnalytics(idActivity string) (*types.Activity
 
 	return analytics, nil
 }
+
+func (s *Store) UpdateActivityPrice(idActivity string, price float64) error {
+	res, err := s.db.Exec(`UPDATE activity SET price = ? WHERE idActivity = ?`, price, idActivity)
+	if err != nil {
+		return fmt.Errorf("error updating activity price: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		var exists int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM activity WHERE idActivity = ?`, idActivity).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking activity: %v", err)
+		}
+		if exists == 0 {
+			return fmt.Errorf("activity with ID %s not found", idActivity)
+		}
+	}
+	return nil
+}
+
+// GetActivityImage gets the image of an activity, failing when the activity does not exist
+func (s *Store) GetActivityImage(idActivity string) (string, error) {
+	var image sql.NullString
+	err := s.db.QueryRow(`SELECT imageActivity FROM activity WHERE idActivity = ?`, idActivity).Scan(&image)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("activity with ID %s not found", idActivity)
+		}
+		return "", fmt.Errorf("error fetching activity image: %v", err)
+	}
+	return image.String, nil
+}
+
+// SetActivityImage returns the image the activity had so that the caller can delete it
+func (s *Store) SetActivityImage(idActivity, image string) (string, error) {
+	previous, err := s.GetActivityImage(idActivity)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.db.Exec(`UPDATE activity SET imageActivity = ? WHERE idActivity = ?`, image, idActivity); err != nil {
+		return "", fmt.Errorf("error updating activity image: %v", err)
+	}
+	return previous, nil
+}
+
+// GetActivityDemand returns the bookings of an activity in [from, to) hour by hour, cancelled ones left out
+func (s *Store) GetActivityDemand(idActivity string, from, to time.Time) ([]types.DemandPoint, error) {
+	rows, err := s.db.Query(`
+		SELECT DATE_FORMAT(timeActivity, '%Y-%m-%d'), HOUR(timeActivity), COUNT(*)
+		FROM clientActivity
+		WHERE idActivity = ? AND status <> 'cancelled' AND timeActivity >= ? AND timeActivity < ?
+		GROUP BY 1, 2
+	`, idActivity, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving activity demand: %v", err)
+	}
+	defer rows.Close()
+
+	points := []types.DemandPoint{}
+	for rows.Next() {
+		var point types.DemandPoint
+		if err := rows.Scan(&point.Day, &point.Hour, &point.Value); err != nil {
+			return nil, fmt.Errorf("error scanning activity demand: %v", err)
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,127 @@
+package digest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+type Handler struct {
+	store   types.DigestStore
+	builder *Builder
+}
+
+func NewHandler(store types.DigestStore, builder *Builder) *Handler {
+	return &Handler{store: store, builder: builder}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/digests/{idProfile}/settings", h.GetDigestSettings).Methods("GET")
+	router.HandleFunc("/admin/digests/{idProfile}/settings", h.UpdateDigestSettings).Methods("PUT")
+	router.HandleFunc("/admin/digests/{idProfile}/preview", h.PreviewDigest).Methods("GET")
+}
+
+// Helper function to map digest errors to status codes
+func writeDigestError(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	utils.WriteError(w, http.StatusInternalServerError, err)
+}
+
+// GetDigestSettings returns which digests an admin receives, the defaults (none) when they never changed them
+func (h *Handler) GetDigestSettings(w http.ResponseWriter, r *http.Request) {
+	idProfile := mux.Vars(r)["idProfile"]
+	if idProfile == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idProfile is required"))
+		return
+	}
+
+	recipient, err := h.store.GetDigestRecipient(idProfile)
+	if err != nil {
+		writeDigestError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, recipient.Settings)
+}
+
+// UpdateDigestSettings subscribes an admin to the daily and weekly digests or unsubscribes them. Fields left out
+// of the body keep their current value.
+func (h *Handler) UpdateDigestSettings(w http.ResponseWriter, r *http.Request) {
+	idProfile := mux.Vars(r)["idProfile"]
+	if idProfile == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idProfile is required"))
+		return
+	}
+
+	// Only admins get digests
+	recipient, err := h.store.GetDigestRecipient(idProfile)
+	if err != nil {
+		writeDigestError(w, err)
+		return
+	}
+
+	settings := recipient.Settings
+	if err := utils.ParseJson(r, &settings); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if settings.SendHour < 0 || settings.SendHour > 23 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sendHour must be between 0 and 23"))
+		return
+	}
+	if settings.WeeklyDay < 0 || settings.WeeklyDay > 6 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("weeklyDay must be between 0 (Sunday) and 6 (Saturday)"))
+		return
+	}
+	settings.IdProfile = idProfile
+
+	if err := h.store.UpdateDigestSettings(settings); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+// PreviewDigest renders the digest an admin would get now (?frequency=daily|weekly, weekly by default) as HTML
+func (h *Handler) PreviewDigest(w http.ResponseWriter, r *http.Request) {
+	idProfile := mux.Vars(r)["idProfile"]
+	if idProfile == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idProfile is required"))
+		return
+	}
+	frequency := r.URL.Query().Get("frequency")
+	if frequency == "" {
+		frequency = "weekly"
+	}
+	if frequency != "daily" && frequency != "weekly" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("frequency must be daily or weekly"))
+		return
+	}
+
+	recipient, err := h.store.GetDigestRecipient(idProfile)
+	if err != nil {
+		writeDigestError(w, err)
+		return
+	}
+	digest, err := h.builder.Build(*recipient, frequency, time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	_, _, html, err := utils.RenderAdminDigest(*digest)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(html))
+}
@@ -0,0 +1,142 @@
+package digest
+
+import (
+	"log"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// Builder puts a digest together from the dashboards of the restaurants and activities of an admin
+type Builder struct {
+	restaurants types.RestaurantStore
+	activities  types.ActiviteStore
+}
+
+func NewBuilder(restaurants types.RestaurantStore, activities types.ActiviteStore) *Builder {
+	return &Builder{restaurants: restaurants, activities: activities}
+}
+
+// Build returns the digest an admin gets on the day of now. The revenue covers the day before for a daily digest
+// and the seven days before for a weekly one, the rest is the dashboard as it is when the digest is sent.
+func (b *Builder) Build(recipient types.DigestRecipient, frequency string, now time.Time) (*types.AdminDigest, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -1)
+	if frequency == "weekly" {
+		from = today.AddDate(0, 0, -7)
+	}
+
+	digest := types.AdminDigest{
+		Frequency:   frequency,
+		FirstName:   recipient.FirstName,
+		From:        from.Format("2006-01-02"),
+		To:          today.AddDate(0, 0, -1).Format("2006-01-02"),
+		GeneratedAt: now,
+		Restaurants: []types.RestaurantDigest{},
+	}
+
+	for _, restaurant := range recipient.Restaurants {
+		section := types.RestaurantDigest{IdRestaurant: restaurant.IdRestaurant, Name: restaurant.Name}
+		var err error
+		if section.Today, err = b.restaurants.GetRestaurantTodaySummary(restaurant.IdRestaurant); err != nil {
+			return nil, err
+		}
+		if section.Reservations, err = b.restaurants.GetReservationStatsAndList(restaurant.IdRestaurant); err != nil {
+			return nil, err
+		}
+		if section.Ratings, err = b.restaurants.GetRestaurantRatingStats(restaurant.IdRestaurant); err != nil {
+			return nil, err
+		}
+		report, err := b.restaurants.GetRevenueReport(restaurant.IdRestaurant, from, today, "day")
+		if err != nil {
+			return nil, err
+		}
+		section.Revenue = report.Summary
+		digest.Restaurants = append(digest.Restaurants, section)
+	}
+
+	if recipient.IdAdminActivity != "" {
+		stats, err := b.activities.GetActivityStatsAdmin(recipient.IdAdminActivity)
+		if err != nil {
+			return nil, err
+		}
+		digest.Activities = &types.ActivityDigest{IdAdminActivity: recipient.IdAdminActivity, Stats: stats}
+	}
+	return &digest, nil
+}
+
+// Scheduler sends the daily and weekly digests once their hour has come. What was sent is recorded in the
+// database, so a restart during the day does not send a digest twice.
+type Scheduler struct {
+	store    types.DigestStore
+	builder  *Builder
+	interval time.Duration
+}
+
+func NewScheduler(store types.DigestStore, builder *Builder, interval time.Duration) *Scheduler {
+	return &Scheduler{store: store, builder: builder, interval: interval}
+}
+
+// Start runs the check in the background, once right away and then on every tick
+func (s *Scheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.send(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *Scheduler) send(now time.Time) {
+	recipients, err := s.store.GetDigestRecipients()
+	if err != nil {
+		log.Printf("Error loading digest recipients: %v", err)
+		return
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, recipient := range recipients {
+		if recipient.Email == "" {
+			continue
+		}
+		for _, frequency := range dueDigests(recipient.Settings, now) {
+			delivered, err := s.store.DigestDelivered(recipient.IdProfile, frequency, today)
+			if err != nil {
+				log.Printf("Error checking %s digest of %s: %v", frequency, recipient.IdProfile, err)
+				continue
+			}
+			if delivered {
+				continue
+			}
+
+			digest, err := s.builder.Build(recipient, frequency, now)
+			if err == nil {
+				err = utils.SendAdminDigest(recipient.Email, *digest)
+			}
+			if err != nil {
+				log.Printf("Error sending %s digest to %s: %v", frequency, recipient.IdProfile, err)
+			}
+			if recordErr := s.store.RecordDigestDelivery(recipient.IdProfile, frequency, today, err); recordErr != nil {
+				log.Printf("Error recording %s digest of %s: %v", frequency, recipient.IdProfile, recordErr)
+			}
+		}
+	}
+}
+
+// Helper function to get the digests whose hour has come on the day of now
+func dueDigests(settings types.DigestSettings, now time.Time) []string {
+	if now.Hour() < settings.SendHour {
+		return nil
+	}
+	var due []string
+	if settings.Daily {
+		due = append(due, "daily")
+	}
+	if settings.Weekly && int(now.Weekday()) == settings.WeeklyDay {
+		due = append(due, "weekly")
+	}
+	return due
+}
@@ -0,0 +1,36 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+func TestDueDigests(t *testing.T) {
+	// 2024-05-13 is a Monday
+	monday := func(hour int) time.Time { return time.Date(2024, 5, 13, hour, 0, 0, 0, time.UTC) }
+	tuesday := time.Date(2024, 5, 14, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		settings types.DigestSettings
+		now      time.Time
+		want     []string
+	}{
+		{"not subscribed", types.DigestSettings{SendHour: 8, WeeklyDay: 1}, monday(9), nil},
+		{"before the hour", types.DigestSettings{Daily: true, Weekly: true, SendHour: 8, WeeklyDay: 1}, monday(7), nil},
+		{"at the hour", types.DigestSettings{Daily: true, SendHour: 8, WeeklyDay: 1}, monday(8), []string{"daily"}},
+		{"daily and weekly on the weekly day", types.DigestSettings{Daily: true, Weekly: true, SendHour: 8, WeeklyDay: 1}, monday(9), []string{"daily", "weekly"}},
+		{"weekly on another day", types.DigestSettings{Weekly: true, SendHour: 8, WeeklyDay: 1}, tuesday, nil},
+		{"weekly on sunday", types.DigestSettings{Weekly: true, SendHour: 0, WeeklyDay: 0}, time.Date(2024, 5, 12, 0, 30, 0, 0, time.UTC), []string{"weekly"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dueDigests(tt.settings, tt.now)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("dueDigests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
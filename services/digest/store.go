@@ -0,0 +1,154 @@
+package digest
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// A digest that failed this many times is not tried again on the same day
+const maxDigestAttempts = 3
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Restaurant admins and activity admins, with their settings or the defaults of a missing row. Digests are
+// opted into, an admin who never subscribed gets none.
+const digestRecipientsQuery = `
+	SELECT x.idProfile, x.firstName, x.email, x.idRestaurant, x.name, x.idAdminActivity,
+		IFNULL(ds.daily, 0), IFNULL(ds.weekly, 0), IFNULL(ds.sendHour, 8), IFNULL(ds.weeklyDay, 1)
+	FROM (
+		SELECT p.idProfile, p.firstName, IFNULL(p.email, '') AS email, r.idRestaurant, r.name, NULL AS idAdminActivity
+		FROM profile p
+		JOIN adminRestaurant ar ON ar.idProfile = p.idProfile
+		JOIN restaurant r ON r.idAdminRestaurant = ar.idAdminRestaurant
+		UNION ALL
+		SELECT p.idProfile, p.firstName, IFNULL(p.email, ''), NULL, NULL, aa.idAdminActivity
+		FROM profile p
+		JOIN adminActivity aa ON aa.idProfile = p.idProfile
+	) x
+	LEFT JOIN digestSubscription ds ON ds.idProfile = x.idProfile
+`
+
+// GetDigestRecipients returns every admin who can receive digests, whether they subscribed or not
+func (s *Store) GetDigestRecipients() ([]types.DigestRecipient, error) {
+	return s.queryDigestRecipients(digestRecipientsQuery + ` ORDER BY x.idProfile, x.name`)
+}
+
+func (s *Store) GetDigestRecipient(idProfile string) (*types.DigestRecipient, error) {
+	recipients, err := s.queryDigestRecipients(digestRecipientsQuery+` WHERE x.idProfile = ? ORDER BY x.name`, idProfile)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("admin with profile ID %s not found", idProfile)
+	}
+	return &recipients[0], nil
+}
+
+// Helper function to gather the rows of each profile, one per restaurant it runs and one for its activities
+func (s *Store) queryDigestRecipients(query string, args ...interface{}) ([]types.DigestRecipient, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving digest recipients: %v", err)
+	}
+	defer rows.Close()
+
+	var recipients []types.DigestRecipient
+	for rows.Next() {
+		var recipient types.DigestRecipient
+		var idRestaurant, name, idAdminActivity sql.NullString
+		err := rows.Scan(
+			&recipient.IdProfile,
+			&recipient.FirstName,
+			&recipient.Email,
+			&idRestaurant,
+			&name,
+			&idAdminActivity,
+			&recipient.Settings.Daily,
+			&recipient.Settings.Weekly,
+			&recipient.Settings.SendHour,
+			&recipient.Settings.WeeklyDay,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning digest recipient: %v", err)
+		}
+		recipient.Settings.IdProfile = recipient.IdProfile
+
+		if n := len(recipients); n == 0 || recipients[n-1].IdProfile != recipient.IdProfile {
+			recipients = append(recipients, recipient)
+		}
+		last := &recipients[len(recipients)-1]
+		if idRestaurant.Valid {
+			last.Restaurants = append(last.Restaurants, types.DigestRestaurant{IdRestaurant: idRestaurant.String, Name: name.String})
+		}
+		if idAdminActivity.Valid {
+			last.IdAdminActivity = idAdminActivity.String
+		}
+	}
+	return recipients, rows.Err()
+}
+
+func (s *Store) UpdateDigestSettings(settings types.DigestSettings) error {
+	query := `
+		INSERT INTO digestSubscription (idProfile, daily, weekly, sendHour, weeklyDay)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			daily = VALUES(daily),
+			weekly = VALUES(weekly),
+			sendHour = VALUES(sendHour),
+			weeklyDay = VALUES(weeklyDay)
+	`
+	_, err := s.db.Exec(query, settings.IdProfile, settings.Daily, settings.Weekly, settings.SendHour, settings.WeeklyDay)
+	if err != nil {
+		return fmt.Errorf("error saving digest settings: %v", err)
+	}
+	return nil
+}
+
+// DigestDelivered tells whether the digest of a day was sent or is not to be tried anymore
+func (s *Store) DigestDelivered(idProfile, frequency string, day time.Time) (bool, error) {
+	var status string
+	var attempts int
+	err := s.db.QueryRow(`
+		SELECT status, attempts FROM digestDelivery
+		WHERE idProfile = ? AND frequency = ? AND digestDate = ?
+	`, idProfile, frequency, day.Format("2006-01-02")).Scan(&status, &attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error retrieving digest delivery: %v", err)
+	}
+	return status == "sent" || attempts >= maxDigestAttempts, nil
+}
+
+// RecordDigestDelivery counts an attempt at sending the digest of a day, failed when sendErr is set
+func (s *Store) RecordDigestDelivery(idProfile, frequency string, day time.Time, sendErr error) error {
+	status := "sent"
+	var lastError *string
+	if sendErr != nil {
+		status = "failed"
+		msg := sendErr.Error()
+		lastError = &msg
+	}
+	query := `
+		INSERT INTO digestDelivery (idProfile, frequency, digestDate, status, attempts, lastError)
+		VALUES (?, ?, ?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE
+			status = VALUES(status),
+			attempts = attempts + 1,
+			lastError = VALUES(lastError)
+	`
+	if _, err := s.db.Exec(query, idProfile, frequency, day.Format("2006-01-02"), status, lastError); err != nil {
+		return fmt.Errorf("error recording digest delivery: %v", err)
+	}
+	return nil
+}
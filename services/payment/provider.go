@@ -0,0 +1,172 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// Webhooks older than this are rejected, which limits replays of captured requests
+const signatureTolerance = 5 * time.Minute
+
+// NewProvider returns the provider selected by PAYMENT_PROVIDER. Only the local simulator exists for now.
+// Webhooks are signed with PAYMENT_WEBHOOK_SECRET, which every provider requires, the simulator included.
+func NewProvider() (types.PaymentProvider, error) {
+	name := os.Getenv("PAYMENT_PROVIDER")
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("PAYMENT_WEBHOOK_SECRET must be set")
+	}
+
+	switch name {
+	case "", "simulator":
+		log.Printf("Payments go through the simulator, nothing is charged")
+		return NewSimulatorProvider(secret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %s", name)
+	}
+}
+
+// SimulatorEnabled tells whether PAYMENT_SIMULATOR_ENABLED turns on the route that lets anyone settle a payment
+// through the simulator. It is off unless set to true, even when the simulator is the provider.
+func SimulatorEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("PAYMENT_SIMULATOR_ENABLED"))
+	return err == nil && enabled
+}
+
+// SimulatorProvider is a deterministic provider for development and testing.
+// References depend only on the payment id and the outcome only on the test
+// payment method, so the same calls always give the same results.
+type SimulatorProvider struct {
+	secret string
+}
+
+func NewSimulatorProvider(secret string) *SimulatorProvider {
+	return &SimulatorProvider{secret: secret}
+}
+
+func (p *SimulatorProvider) Name() string {
+	return "simulator"
+}
+
+func (p *SimulatorProvider) CreateIntent(idPayment string, amount float64, currency string) (*types.ProviderIntent, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+	reference := "sim_pi_" + p.digest("intent", idPayment)[:24]
+	return &types.ProviderIntent{
+		Reference:    reference,
+		ClientSecret: reference + "_secret_" + p.digest("secret", reference)[:16],
+		Status:       "requires_payment",
+	}, nil
+}
+
+func (p *SimulatorProvider) Refund(idRefund string, reference string, amount float64) (*types.ProviderRefund, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+	return &types.ProviderRefund{
+		Reference: "sim_re_" + p.digest("refund", reference+":"+idRefund)[:24],
+		Status:    "succeeded",
+	}, nil
+}
+
+// Simulate plays the customer side of a payment with a test payment method and
+// returns the signed webhook the provider would send:
+//   - pm_card_success succeeds
+//   - pm_card_declined and pm_card_insufficient_funds fail
+func (p *SimulatorProvider) Simulate(reference string, amount float64, paymentMethod string) ([]byte, string, error) {
+	event := types.PaymentWebhookEvent{
+		Reference: reference,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	switch paymentMethod {
+	case "pm_card_success":
+		event.Type = "payment.succeeded"
+	case "pm_card_declined":
+		event.Type = "payment.failed"
+		event.Reason = "card_declined"
+	case "pm_card_insufficient_funds":
+		event.Type = "payment.failed"
+		event.Reason = "insufficient_funds"
+	default:
+		return nil, "", fmt.Errorf("unknown test payment method %s", paymentMethod)
+	}
+	event.IdEvent = "sim_evt_" + p.digest("event", reference+":"+paymentMethod)[:24]
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", fmt.Errorf("error encoding webhook: %v", err)
+	}
+	return payload, p.Sign(payload, time.Now()), nil
+}
+
+// Sign builds the signature header of a webhook payload: t=<unix time>,v1=<hex hmac>
+func (p *SimulatorProvider) Sign(payload []byte, at time.Time) string {
+	timestamp := at.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, p.mac(timestamp, payload))
+}
+
+func (p *SimulatorProvider) VerifyWebhook(payload []byte, signature string) (*types.PaymentWebhookEvent, error) {
+	var timestamp int64
+	var provided string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signature timestamp")
+			}
+			timestamp = t
+		case "v1":
+			provided = value
+		}
+	}
+	if timestamp == 0 || provided == "" {
+		return nil, fmt.Errorf("invalid signature header")
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > signatureTolerance || age < -signatureTolerance {
+		return nil, fmt.Errorf("invalid signature: timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(provided), []byte(p.mac(timestamp, payload))) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	var event types.PaymentWebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+	if event.IdEvent == "" || event.Reference == "" || event.Type == "" {
+		return nil, fmt.Errorf("invalid webhook payload: id, type and reference are required")
+	}
+	return &event, nil
+}
+
+func (p *SimulatorProvider) mac(timestamp int64, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(p.secret))
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *SimulatorProvider) digest(kind, value string) string {
+	h := hmac.New(sha256.New, []byte(p.secret))
+	h.Write([]byte(kind + ":" + value))
+	return hex.EncodeToString(h.Sum(nil))
+}
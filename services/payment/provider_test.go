@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	provider := NewSimulatorProvider("test-secret")
+	payload := []byte(`{"id":"evt_1","type":"payment.succeeded","reference":"sim_pi_1","amount":12.5}`)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   string
+	}{
+		{"valid signature", payload, provider.Sign(payload, now), ""},
+		{"tampered payload", []byte(strings.Replace(string(payload), "12.5", "1.5", 1)), provider.Sign(payload, now), "invalid signature"},
+		{"other secret", payload, NewSimulatorProvider("other-secret").Sign(payload, now), "invalid signature"},
+		{"expired timestamp", payload, provider.Sign(payload, now.Add(-10*time.Minute)), "outside tolerance"},
+		{"future timestamp", payload, provider.Sign(payload, now.Add(10*time.Minute)), "outside tolerance"},
+		{"missing mac", payload, "t=" + strings.Split(provider.Sign(payload, now), ",")[0][2:], "invalid signature header"},
+		{"garbage header", payload, "nonsense", "invalid signature header"},
+		{"missing fields", []byte(`{"id":"evt_1"}`), provider.Sign([]byte(`{"id":"evt_1"}`), now), "id, type and reference are required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := provider.VerifyWebhook(tt.payload, tt.signature)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyWebhook: %v", err)
+				}
+				if event.IdEvent != "evt_1" || event.Reference != "sim_pi_1" || event.Amount != 12.5 {
+					t.Errorf("event = %+v", event)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyWebhook error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewProviderSecret(t *testing.T) {
+	tests := []struct {
+		provider string
+		secret   string
+		wantErr  string
+	}{
+		{"", "test-secret", ""},
+		{"simulator", "test-secret", ""},
+		{"simulator", "", "PAYMENT_WEBHOOK_SECRET"},
+		{"stripe", "", "PAYMENT_WEBHOOK_SECRET"},
+		{"stripe", "test-secret", "unknown payment provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.secret, func(t *testing.T) {
+			t.Setenv("PAYMENT_PROVIDER", tt.provider)
+			t.Setenv("PAYMENT_WEBHOOK_SECRET", tt.secret)
+			provider, err := NewProvider()
+			if tt.wantErr == "" {
+				if err != nil || provider.(*SimulatorProvider).secret != tt.secret {
+					t.Errorf("NewProvider = %v, %v, want the simulator signing with %q", provider, err, tt.secret)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewProvider error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSimulatorEnabled(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"false", false},
+		{"yes", false},
+		{"true", true},
+		{"1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("PAYMENT_SIMULATOR_ENABLED", tt.value)
+			if got := SimulatorEnabled(); got != tt.want {
+				t.Errorf("SimulatorEnabled() with %q = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package payment
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+type Handler struct {
+	store    types.PaymentStore
+	provider types.PaymentProvider
+}
+
+func NewHandler(store types.PaymentStore, provider types.PaymentProvider) *Handler {
+	return &Handler{store: store, provider: provider}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/payments/intent", h.CreatePaymentIntent).Methods("POST")
+	router.HandleFunc("/payments/webhook", h.HandleWebhook).Methods("POST")
+	router.HandleFunc("/payments/target/{targetType}/{idTarget}", h.GetPaymentsByTarget).Methods("GET")
+	router.HandleFunc("/payments/{idPayment}", h.GetPayment).Methods("GET")
+	// Anyone can settle a payment through the simulator, so its route only exists when it is explicitly enabled
+	if _, ok := h.provider.(*SimulatorProvider); ok && SimulatorEnabled() {
+		router.HandleFunc("/payments/{idPayment}/simulate", h.SimulatePayment).Methods("POST")
+	}
+	router.HandleFunc("/payments/{idPayment}/refund", h.RefundPayment).Methods("POST")
+	router.HandleFunc("/payments/{idPayment}/refunds", h.GetPaymentRefunds).Methods("GET")
+	router.HandleFunc("/client/{idClient}/payments", h.GetClientPayments).Methods("GET")
+}
+
+// Helper function to map payment errors to status codes
+func writePaymentError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(msg, "invalid signature"), strings.Contains(msg, "invalid webhook"):
+		utils.WriteError(w, http.StatusUnauthorized, err)
+	case strings.Contains(msg, "already paid"), strings.Contains(msg, "cannot be refunded"), strings.Contains(msg, "exceeds"):
+		utils.WriteError(w, http.StatusConflict, err)
+	case strings.Contains(msg, "invalid"), strings.Contains(msg, "must be"), strings.Contains(msg, "unknown test payment method"):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// CreatePaymentIntent opens a payment for an order or an activity booking; the amount comes from the target
+func (h *Handler) CreatePaymentIntent(w http.ResponseWriter, r *http.Request) {
+	var req types.PaymentIntentCreation
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch req.TargetType {
+	case "order", "activity", "deposit", "table_session":
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("targetType must be 'order', 'activity', 'deposit' or 'table_session'"))
+		return
+	}
+	if req.IdTarget == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idTarget is required"))
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = "DZD"
+	}
+
+	amount, idClient, err := h.store.GetPaymentTarget(req.TargetType, req.IdTarget)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	amount = math.Round(amount*100) / 100
+	if amount <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("nothing to pay for this %s", req.TargetType))
+		return
+	}
+
+	existing, err := h.store.GetPaymentsByTarget(req.TargetType, req.IdTarget)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, p := range existing {
+		if p.Status == "succeeded" || p.Status == "partially_refunded" {
+			writePaymentError(w, fmt.Errorf("%s %s is already paid", req.TargetType, req.IdTarget))
+			return
+		}
+		// Reuse an open intent as long as the amount did not change
+		if p.Status == "requires_payment" && p.Amount == amount && p.Currency == req.Currency && p.Provider == h.provider.Name() {
+			utils.WriteJson(w, http.StatusOK, p)
+			return
+		}
+	}
+
+	idPayment, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	providerIntent, err := h.provider.CreateIntent(idPayment, amount, req.Currency)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	intent := types.PaymentIntent{
+		IdPayment:         idPayment,
+		TargetType:        req.TargetType,
+		IdTarget:          req.IdTarget,
+		IdClient:          idClient,
+		Amount:            amount,
+		Currency:          req.Currency,
+		Status:            providerIntent.Status,
+		Provider:          h.provider.Name(),
+		ProviderReference: providerIntent.Reference,
+	}
+	if err := h.store.CreatePaymentIntent(intent); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetPayment(idPayment)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	created.ClientSecret = providerIntent.ClientSecret
+	utils.WriteJson(w, http.StatusCreated, created)
+}
+
+func (h *Handler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	idPayment := mux.Vars(r)["idPayment"]
+	if idPayment == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idPayment is required"))
+		return
+	}
+
+	payment, err := h.store.GetPayment(idPayment)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, payment)
+}
+
+func (h *Handler) GetPaymentsByTarget(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	payments, err := h.store.GetPaymentsByTarget(vars["targetType"], vars["idTarget"])
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, payments)
+}
+
+// GetClientPayments returns the payment history of a client
+func (h *Handler) GetClientPayments(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	payments, err := h.store.GetClientPayments(idClient)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, payments)
+}
+
+// HandleWebhook applies a signed provider callback
+func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error reading webhook body: %v", err))
+		return
+	}
+
+	payment, duplicate, err := h.processWebhook(payload, r.Header.Get("X-Payment-Signature"))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]interface{}{
+		"received":  true,
+		"duplicate": duplicate,
+		"status":    payment.Status,
+	})
+}
+
+// SimulatePayment pays an intent with a test payment method through the simulator, going through the webhook path
+func (h *Handler) SimulatePayment(w http.ResponseWriter, r *http.Request) {
+	simulator, ok := h.provider.(*SimulatorProvider)
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("payment simulation is only available with the simulator provider"))
+		return
+	}
+
+	idPayment := mux.Vars(r)["idPayment"]
+	var req struct {
+		PaymentMethod string `json:"paymentMethod"`
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = "pm_card_success"
+	}
+
+	payment, err := h.store.GetPayment(idPayment)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	payload, signature, err := simulator.Simulate(payment.ProviderReference, payment.Amount, req.PaymentMethod)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	payment, _, err = h.processWebhook(payload, signature)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, payment)
+}
+
+// processWebhook verifies an event, ignores replays and moves the payment to its new status. A failure leaves the
+// event unrecorded, so the provider's retry applies it again.
+func (h *Handler) processWebhook(payload []byte, signature string) (*types.PaymentIntent, bool, error) {
+	event, err := h.provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		return nil, false, err
+	}
+
+	payment, err := h.store.GetPaymentByReference(h.provider.Name(), event.Reference)
+	if err != nil {
+		return nil, false, err
+	}
+
+	newStatus := ""
+	switch event.Type {
+	case "payment.succeeded":
+		if math.Abs(event.Amount-payment.Amount) > 0.001 {
+			return nil, false, fmt.Errorf("invalid webhook: amount %.2f does not match payment amount %.2f", event.Amount, payment.Amount)
+		}
+		if payment.Status == "requires_payment" || payment.Status == "failed" {
+			newStatus = "succeeded"
+		}
+	case "payment.failed":
+		if payment.Status == "requires_payment" {
+			newStatus = "failed"
+		}
+	default:
+		log.Printf("Ignoring payment event %s of type %s", event.IdEvent, event.Type)
+	}
+
+	isNew, err := h.store.RecordPaymentEvent(event.IdEvent, payment, event.Type, payload, newStatus)
+	if err != nil {
+		return nil, false, err
+	}
+	if !isNew {
+		return payment, true, nil
+	}
+	if newStatus != "" {
+		payment.Status = newStatus
+	}
+	return payment, false, nil
+}
+
+// RefundPayment refunds all or part of a succeeded payment
+func (h *Handler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	idPayment := mux.Vars(r)["idPayment"]
+	var req types.PaymentRefundCreation
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Amount < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("amount must be positive"))
+		return
+	}
+
+	payment, err := h.store.GetPayment(idPayment)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	if payment.Status != "succeeded" && payment.Status != "partially_refunded" {
+		writePaymentError(w, fmt.Errorf("payment is %s and cannot be refunded", payment.Status))
+		return
+	}
+
+	remaining := math.Round((payment.Amount-payment.AmountRefunded)*100) / 100
+	amount := math.Round(req.Amount*100) / 100
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount > remaining {
+		writePaymentError(w, fmt.Errorf("refund exceeds the remaining amount of %.2f", remaining))
+		return
+	}
+
+	idRefund, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	providerRefund, err := h.provider.Refund(idRefund, payment.ProviderReference, amount)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	refund := types.PaymentRefund{
+		IdRefund:          idRefund,
+		IdPayment:         idPayment,
+		Amount:            amount,
+		Reason:            req.Reason,
+		Status:            providerRefund.Status,
+		ProviderReference: providerRefund.Reference,
+	}
+	if err := h.store.CreateRefund(refund); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	payment, err = h.store.GetPayment(idPayment)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, map[string]interface{}{
+		"refund":  refund,
+		"payment": payment,
+	})
+}
+
+func (h *Handler) GetPaymentRefunds(w http.ResponseWriter, r *http.Request) {
+	idPayment := mux.Vars(r)["idPayment"]
+	refunds, err := h.store.GetPaymentRefunds(idPayment)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, refunds)
+}
@@ -0,0 +1,94 @@
+package payment
+
+import (
+	"log"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+const depositRefundReason = "reservation deposit settlement"
+
+// DepositSettler refunds the deposits of finished reservations, minus the fee
+// the restaurant keeps for a late cancellation or a no-show.
+type DepositSettler struct {
+	store     types.PaymentStore
+	provider  types.PaymentProvider
+	interval  time.Duration
+	batchSize int
+}
+
+func NewDepositSettler(store types.PaymentStore, provider types.PaymentProvider, interval time.Duration) *DepositSettler {
+	return &DepositSettler{store: store, provider: provider, interval: interval, batchSize: 50}
+}
+
+// Start runs the settlement in the background, once right away and then on every tick
+func (s *DepositSettler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.settle()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *DepositSettler) settle() {
+	deposits, err := s.store.GetPendingDepositSettlements(s.batchSize)
+	if err != nil {
+		log.Printf("Error fetching pending deposits: %v", err)
+		return
+	}
+	for _, deposit := range deposits {
+		if err := s.settleDeposit(deposit); err != nil {
+			log.Printf("Error settling deposit of reservation %s: %v", deposit.IdReservation, err)
+		}
+	}
+}
+
+func (s *DepositSettler) settleDeposit(deposit types.ReservationDeposit) error {
+	if deposit.RefundAmount > 0 && deposit.IdPayment != nil {
+		payment, err := s.store.GetPayment(*deposit.IdPayment)
+		if err != nil {
+			return err
+		}
+
+		// A previous run may have refunded before failing to mark the deposit settled
+		refunds, err := s.store.GetPaymentRefunds(payment.IdPayment)
+		if err != nil {
+			return err
+		}
+		alreadyRefunded := false
+		for _, refund := range refunds {
+			if refund.Reason == depositRefundReason {
+				alreadyRefunded = true
+				break
+			}
+		}
+
+		if !alreadyRefunded {
+			idRefund, err := utils.CreateAnId()
+			if err != nil {
+				return err
+			}
+			providerRefund, err := s.provider.Refund(idRefund, payment.ProviderReference, deposit.RefundAmount)
+			if err != nil {
+				return err
+			}
+			refund := types.PaymentRefund{
+				IdRefund:          idRefund,
+				IdPayment:         payment.IdPayment,
+				Amount:            deposit.RefundAmount,
+				Reason:            depositRefundReason,
+				Status:            providerRefund.Status,
+				ProviderReference: providerRefund.Reference,
+			}
+			if err := s.store.CreateRefund(refund); err != nil {
+				return err
+			}
+		}
+	}
+	return s.store.MarkDepositSettled(deposit.IdReservation)
+}
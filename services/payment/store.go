@@ -0,0 +1,390 @@
+package payment
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetPaymentTarget returns the amount due for an order, activity booking, reservation deposit or
+// table session and the client who pays it (empty for walk-in table sessions)
+func (s *Store) GetPaymentTarget(targetType string, idTarget string) (float64, string, error) {
+	var query string
+	switch targetType {
+	case "order":
+		// Orders of a reservation or a takeaway request; those of a table session are paid with the session
+		query = `
+			SELECT ol.totalPrice, COALESCE(r.idClient, tk.idClient)
+			FROM orderList ol
+			LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+			LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+			WHERE ol.idOrder = ? AND (r.idReservation IS NOT NULL OR tk.idOrder IS NOT NULL)
+		`
+	case "activity":
+		query = `
+			SELECT a.price, ca.idClient
+			FROM clientActivity ca
+			JOIN activity a ON a.idActivity = ca.idActivity
+			WHERE ca.idClientActivity = ?
+		`
+	case "table_session":
+		// Walk-in guests have no client account, the whole session is paid at once
+		query = `
+			SELECT IFNULL(SUM(IF(ol.status <> 'cancelled', ol.totalPrice, 0)), 0), ''
+			FROM tableSession ts
+			LEFT JOIN orderList ol ON ol.idTableSession = ts.idTableSession
+			WHERE ts.idTableSession = ? AND ts.status = 'open'
+			GROUP BY ts.idTableSession
+		`
+	case "deposit":
+		query = `
+			SELECT d.amount, r.idClient
+			FROM reservationDeposit d
+			JOIN reservation r ON r.idReservation = d.idReservation
+			WHERE d.idReservation = ? AND d.status IN ('required', 'paid')
+		`
+	default:
+		return 0, "", fmt.Errorf("invalid target type %s", targetType)
+	}
+
+	var amount float64
+	var idClient string
+	err := s.db.QueryRow(query, idTarget).Scan(&amount, &idClient)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, "", fmt.Errorf("%s with ID %s not found", targetType, idTarget)
+		}
+		return 0, "", fmt.Errorf("error retrieving %s amount: %v", targetType, err)
+	}
+	return amount, idClient, nil
+}
+
+const paymentColumns = `
+	idPayment, targetType, idTarget, IFNULL(idClient, ''), amount, amountRefunded, currency,
+	status, provider, providerReference, createdAt, updatedAt
+`
+
+func scanPayment(row interface{ Scan(...interface{}) error }) (*types.PaymentIntent, error) {
+	var p types.PaymentIntent
+	err := row.Scan(
+		&p.IdPayment,
+		&p.TargetType,
+		&p.IdTarget,
+		&p.IdClient,
+		&p.Amount,
+		&p.AmountRefunded,
+		&p.Currency,
+		&p.Status,
+		&p.Provider,
+		&p.ProviderReference,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *Store) queryPayments(query string, args ...interface{}) ([]types.PaymentIntent, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving payments: %v", err)
+	}
+	defer rows.Close()
+
+	payments := []types.PaymentIntent{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment: %v", err)
+		}
+		payments = append(payments, *p)
+	}
+	return payments, rows.Err()
+}
+
+func (s *Store) GetPaymentsByTarget(targetType string, idTarget string) ([]types.PaymentIntent, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payment WHERE targetType = ? AND idTarget = ? ORDER BY createdAt DESC`
+	return s.queryPayments(query, targetType, idTarget)
+}
+
+func (s *Store) GetClientPayments(idClient string) ([]types.PaymentIntent, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payment WHERE idClient = ? ORDER BY createdAt DESC`
+	return s.queryPayments(query, idClient)
+}
+
+func (s *Store) CreatePaymentIntent(intent types.PaymentIntent) error {
+	query := `
+		INSERT INTO payment (
+			idPayment, targetType, idTarget, idClient, amount, currency,
+			status, provider, providerReference, createdAt
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	var idClient sql.NullString
+	if intent.IdClient != "" {
+		idClient = sql.NullString{String: intent.IdClient, Valid: true}
+	}
+	_, err := s.db.Exec(query,
+		intent.IdPayment,
+		intent.TargetType,
+		intent.IdTarget,
+		idClient,
+		intent.Amount,
+		intent.Currency,
+		intent.Status,
+		intent.Provider,
+		intent.ProviderReference,
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating payment: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetPayment(idPayment string) (*types.PaymentIntent, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payment WHERE idPayment = ?`
+	p, err := scanPayment(s.db.QueryRow(query, idPayment))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("payment with ID %s not found", idPayment)
+		}
+		return nil, fmt.Errorf("error retrieving payment: %v", err)
+	}
+	return p, nil
+}
+
+func (s *Store) GetPaymentByReference(provider string, reference string) (*types.PaymentIntent, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payment WHERE provider = ? AND providerReference = ?`
+	p, err := scanPayment(s.db.QueryRow(query, provider, reference))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("payment with reference %s not found", reference)
+		}
+		return nil, fmt.Errorf("error retrieving payment: %v", err)
+	}
+	return p, nil
+}
+
+// RecordPaymentEvent stores a webhook event and moves its payment to newStatus (when set) in one transaction, with
+// what a succeeded payment settles: the deposit of its reservation or its table session. An event is only ever seen
+// as a duplicate once it was applied. It returns false for an event already received.
+func (s *Store) RecordPaymentEvent(idEvent string, payment *types.PaymentIntent, eventType string, payload []byte, newStatus string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `INSERT IGNORE INTO paymentEvent (idEvent, idPayment, type, payload, receivedAt) VALUES (?, ?, ?, ?, ?)`
+	res, err := tx.Exec(query, idEvent, payment.IdPayment, eventType, string(payload), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("error recording payment event: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if newStatus != "" {
+		if _, err = tx.Exec(`UPDATE payment SET status = ? WHERE idPayment = ?`, newStatus, payment.IdPayment); err != nil {
+			err = fmt.Errorf("error updating payment status: %v", err)
+			return false, err
+		}
+	}
+	if newStatus == "succeeded" {
+		switch payment.TargetType {
+		case "deposit":
+			err = markDepositPaid(tx, payment.IdTarget, payment.IdPayment)
+		case "table_session":
+			err = closeTableSession(tx, payment.IdTarget)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return true, nil
+}
+
+// CreateRefund stores a refund and updates the refunded amount and status of its payment
+func (s *Store) CreateRefund(refund types.PaymentRefund) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var amount, refunded float64
+	err = tx.QueryRow(`SELECT amount, amountRefunded FROM payment WHERE idPayment = ? FOR UPDATE`, refund.IdPayment).
+		Scan(&amount, &refunded)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("payment with ID %s not found", refund.IdPayment)
+			return err
+		}
+		return fmt.Errorf("error retrieving payment: %v", err)
+	}
+	if refunded+refund.Amount > amount+0.001 {
+		err = fmt.Errorf("refund exceeds the remaining amount of %.2f", amount-refunded)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO paymentRefund (idRefund, idPayment, amount, reason, status, providerReference, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		refund.IdRefund, refund.IdPayment, refund.Amount, refund.Reason, refund.Status, refund.ProviderReference, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving refund: %v", err)
+	}
+
+	if refund.Status == "succeeded" {
+		status := "partially_refunded"
+		if refunded+refund.Amount >= amount-0.001 {
+			status = "refunded"
+		}
+		_, err = tx.Exec(`UPDATE payment SET amountRefunded = amountRefunded + ?, status = ? WHERE idPayment = ?`,
+			refund.Amount, status, refund.IdPayment)
+		if err != nil {
+			return fmt.Errorf("error updating payment: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetPaymentRefunds(idPayment string) ([]types.PaymentRefund, error) {
+	query := `
+		SELECT idRefund, idPayment, amount, IFNULL(reason, ''), status, providerReference, createdAt
+		FROM paymentRefund
+		WHERE idPayment = ?
+		ORDER BY createdAt ASC
+	`
+	rows, err := s.db.Query(query, idPayment)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving refunds: %v", err)
+	}
+	defer rows.Close()
+
+	refunds := []types.PaymentRefund{}
+	for rows.Next() {
+		var refund types.PaymentRefund
+		if err := rows.Scan(
+			&refund.IdRefund,
+			&refund.IdPayment,
+			&refund.Amount,
+			&refund.Reason,
+			&refund.Status,
+			&refund.ProviderReference,
+			&refund.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning refund: %v", err)
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// Helper function to link a succeeded payment to the deposit of its reservation
+func markDepositPaid(tx *sql.Tx, idReservation string, idPayment string) error {
+	query := `UPDATE reservationDeposit SET status = 'paid', idPayment = ? WHERE idReservation = ? AND status = 'required'`
+	res, err := tx.Exec(query, idPayment, idReservation)
+	if err != nil {
+		return fmt.Errorf("error marking deposit as paid: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no unpaid deposit found for reservation %s", idReservation)
+	}
+	return nil
+}
+
+// GetPendingDepositSettlements returns paid deposits of finished reservations waiting for their refund
+func (s *Store) GetPendingDepositSettlements(limit int) ([]types.ReservationDeposit, error) {
+	query := `
+		SELECT idReservation, idRestaurant, amount, reason, status, idPayment, IFNULL(outcome, ''),
+			feeAmount, refundAmount, createdAt
+		FROM reservationDeposit
+		WHERE status = 'pending_settlement'
+		ORDER BY createdAt ASC
+		LIMIT ?
+	`
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving pending deposits: %v", err)
+	}
+	defer rows.Close()
+
+	deposits := []types.ReservationDeposit{}
+	for rows.Next() {
+		var deposit types.ReservationDeposit
+		var idPayment sql.NullString
+		if err := rows.Scan(
+			&deposit.IdReservation,
+			&deposit.IdRestaurant,
+			&deposit.Amount,
+			&deposit.Reason,
+			&deposit.Status,
+			&idPayment,
+			&deposit.Outcome,
+			&deposit.FeeAmount,
+			&deposit.RefundAmount,
+			&deposit.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning deposit: %v", err)
+		}
+		if idPayment.Valid {
+			deposit.IdPayment = &idPayment.String
+		}
+		deposits = append(deposits, deposit)
+	}
+	return deposits, rows.Err()
+}
+
+func (s *Store) MarkDepositSettled(idReservation string) error {
+	query := `UPDATE reservationDeposit SET status = 'settled', settledAt = ? WHERE idReservation = ? AND status = 'pending_settlement'`
+	if _, err := s.db.Exec(query, time.Now(), idReservation); err != nil {
+		return fmt.Errorf("error settling deposit: %v", err)
+	}
+	return nil
+}
+
+// Helper function to close a table session once its bill is paid
+func closeTableSession(tx *sql.Tx, idTableSession string) error {
+	query := `UPDATE tableSession SET status = 'closed', closedAt = ? WHERE idTableSession = ? AND status = 'open'`
+	if _, err := tx.Exec(query, time.Now(), idTableSession); err != nil {
+		return fmt.Errorf("error closing table session: %v", err)
+	}
+	return nil
+}
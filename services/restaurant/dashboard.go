@@ -0,0 +1,121 @@
+package restaurant
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// Connections are kept at package level since handlers are rebuilt on every request
+var (
+	dashboardHub = newClientHub()
+	kitchenHub   = newClientHub()
+)
+
+// clientHub groups the open websocket connections of each restaurant
+type clientHub struct {
+	mu      sync.Mutex
+	clients map[string]map[*Client]bool
+}
+
+func newClientHub() *clientHub {
+	return &clientHub{clients: make(map[string]map[*Client]bool)}
+}
+
+// RestaurantDashboardWS streams live reservation and order events to a restaurant dashboard
+func (h *Handler) RestaurantDashboardWS(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		http.Error(w, "idRestaurant is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket Upgrade:", err)
+		return
+	}
+
+	client := &Client{conn: conn, restaurantID: idRestaurant, send: make(chan []byte, 32)}
+	dashboardHub.register(client)
+
+	go writePump(client)
+	go func() {
+		readPump(client)
+		dashboardHub.unregister(client)
+	}()
+}
+
+func (hub *clientHub) register(client *Client) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.clients[client.restaurantID] == nil {
+		hub.clients[client.restaurantID] = make(map[*Client]bool)
+	}
+	hub.clients[client.restaurantID][client] = true
+}
+
+func (hub *clientHub) unregister(client *Client) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.clients[client.restaurantID][client]; ok {
+		delete(hub.clients[client.restaurantID], client)
+		close(client.send)
+	}
+	if len(hub.clients[client.restaurantID]) == 0 {
+		delete(hub.clients, client.restaurantID)
+	}
+}
+
+// send delivers a message to one connection, unless it was closed in the meantime
+func (hub *clientHub) send(client *Client, message []byte) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if !hub.clients[client.restaurantID][client] {
+		return
+	}
+	select {
+	case client.send <- message:
+	default:
+		log.Printf("Dropping message for restaurant %s, connection is too slow", client.restaurantID)
+	}
+}
+
+// broadcast pushes an event to every connection open for the restaurant.
+// Slow connections drop the event instead of blocking the request.
+func (hub *clientHub) broadcast(idRestaurant, eventType string, payload interface{}) {
+	message, err := encodeDashboardEvent(idRestaurant, eventType, payload)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", eventType, err)
+		return
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for client := range hub.clients[idRestaurant] {
+		select {
+		case client.send <- message:
+		default:
+			log.Printf("Dropping event %s for restaurant %s", eventType, idRestaurant)
+		}
+	}
+}
+
+func encodeDashboardEvent(idRestaurant, eventType string, payload interface{}) ([]byte, error) {
+	return json.Marshal(types.DashboardEvent{
+		Type:         eventType,
+		IdRestaurant: idRestaurant,
+		Payload:      payload,
+		CreatedAt:    time.Now(),
+	})
+}
+
+// broadcastDashboardEvent pushes an event to every dashboard open for the restaurant
+func broadcastDashboardEvent(idRestaurant, eventType string, payload interface{}) {
+	dashboardHub.broadcast(idRestaurant, eventType, payload)
+}
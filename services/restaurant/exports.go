@@ -0,0 +1,136 @@
+package restaurant
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// The exports take ?format=csv|pdf and the same filters as the lists they come from. Rows are written as they are
+// read, so once the download has started an error can only be logged and the file is cut short.
+
+// ExportRestaurantReservations downloads the reservations of a restaurant (?from, ?to, ?status)
+func (h *Handler) ExportRestaurantReservations(w http.ResponseWriter, r *http.Request) {
+	idRestaurant, format, filter, ok := readExportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	exporter := utils.NewExporter(w, format, "reservations-"+idRestaurant, "Reservations", utils.DescribeListFilter(filter),
+		[]string{"Reservation", "Time", "Client", "Table", "People", "Status", "Created"})
+	err := h.store.ExportRestaurantReservations(idRestaurant, filter, func(reservation types.RestaurantReservationDetail) error {
+		return exporter.WriteRow(
+			reservation.IdReservation,
+			utils.ExportTime(reservation.TimeFrom),
+			reservation.FullName,
+			reservation.TableId,
+			strconv.Itoa(reservation.NumberOfPeople),
+			reservation.Status,
+			utils.ExportTime(reservation.CreatedAt),
+		)
+	})
+	closeExport(exporter, err, "reservations", idRestaurant)
+}
+
+// ExportRestaurantOrders downloads the orders of a restaurant, dine-in and takeaway (?from, ?to, ?status)
+func (h *Handler) ExportRestaurantOrders(w http.ResponseWriter, r *http.Request) {
+	idRestaurant, format, filter, ok := readExportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	exporter := utils.NewExporter(w, format, "orders-"+idRestaurant, "Orders", utils.DescribeListFilter(filter),
+		[]string{"Order", "Placed", "Source", "Client", "Table", "Items", "Total", "Status"})
+	err := h.store.ExportRestaurantOrders(idRestaurant, filter, func(order types.RestaurantOrderRow) error {
+		return exporter.WriteRow(
+			order.IdOrder,
+			utils.ExportTime(order.CreatedAt),
+			order.Source,
+			order.ClientName,
+			order.IdTable,
+			strconv.Itoa(order.Items),
+			fmt.Sprintf("%.2f", order.TotalPrice),
+			order.Status,
+		)
+	})
+	closeExport(exporter, err, "orders", idRestaurant)
+}
+
+// ExportRestaurantReviews downloads the published reviews of a restaurant, as listed by GetAllRestaurantReviews
+func (h *Handler) ExportRestaurantReviews(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["id"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("restaurant id is required"))
+		return
+	}
+	format, err := utils.ExportFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Reviews are few enough per restaurant to be read before the download starts
+	reviews, err := h.store.GetAllRestaurantReviews(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	exporter := utils.NewExporter(w, format, "reviews-"+idRestaurant, "Reviews", "",
+		[]string{"Review", "Date", "Client", "Rating", "Comment", "Reply", "Photos"})
+	for _, review := range reviews {
+		reply := ""
+		if review.Reply != nil {
+			reply = *review.Reply
+		}
+		err = exporter.WriteRow(
+			review.IdRating,
+			review.CreatedAt,
+			review.FirstName+" "+review.LastName,
+			strconv.Itoa(review.RatingValue),
+			review.Comment,
+			reply,
+			strconv.Itoa(len(review.Photos)),
+		)
+		if err != nil {
+			break
+		}
+	}
+	closeExport(exporter, err, "reviews", idRestaurant)
+}
+
+// Helper function to read the restaurant, format and filters of an export, answering the errors itself
+func readExportRequest(w http.ResponseWriter, r *http.Request) (string, string, types.ListFilter, bool) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return "", "", types.ListFilter{}, false
+	}
+	format, err := utils.ExportFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return "", "", types.ListFilter{}, false
+	}
+	filter, err := utils.ParseListFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return "", "", types.ListFilter{}, false
+	}
+	return idRestaurant, format, filter, true
+}
+
+// Helper function to end an export, the download is already under way so errors are only logged
+func closeExport(exporter utils.Exporter, err error, what, idRestaurant string) {
+	if err != nil {
+		log.Printf("Error exporting %s of restaurant %s: %v", what, idRestaurant, err)
+		return
+	}
+	if err := exporter.Close(); err != nil {
+		log.Printf("Error exporting %s of restaurant %s: %v", what, idRestaurant, err)
+	}
+}
@@ -0,0 +1,87 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// GetRestaurantForecast forecasts the covers and orders of a restaurant for the next 14 days, hour by hour, from
+// the same weekdays of the last ?weeks weeks (8 by default)
+func (h *Handler) GetRestaurantForecast(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	weeks, err := utils.ForecastWeeks(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	start := utils.Today()
+	covers, orders, err := h.store.GetRestaurantDemand(idRestaurant, start.AddDate(0, 0, -7*weeks), start)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, types.DemandForecast{
+		IdRestaurant: idRestaurant,
+		From:         start.Format("2006-01-02"),
+		To:           start.AddDate(0, 0, utils.ForecastDays-1).Format("2006-01-02"),
+		HistoryWeeks: weeks,
+		Series: []types.ForecastSeries{
+			utils.ForecastDemand("covers", covers, start, utils.ForecastDays, weeks),
+			utils.ForecastDemand("orders", orders, start, utils.ForecastDays, weeks),
+		},
+	})
+}
+
+// GetRestaurantForecastAccuracy forecasts the last ?days days (14 by default) as it would have been done before
+// them, and compares the forecast with the covers and orders there were
+func (h *Handler) GetRestaurantForecastAccuracy(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	weeks, err := utils.ForecastWeeks(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	days, err := utils.AccuracyDays(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	to := utils.Today()
+	from := to.AddDate(0, 0, -days)
+	historyCovers, historyOrders, err := h.store.GetRestaurantDemand(idRestaurant, from.AddDate(0, 0, -7*weeks), from)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	covers, orders, err := h.store.GetRestaurantDemand(idRestaurant, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, types.ForecastAccuracy{
+		IdRestaurant: idRestaurant,
+		From:         from.Format("2006-01-02"),
+		To:           to.AddDate(0, 0, -1).Format("2006-01-02"),
+		HistoryWeeks: weeks,
+		Metrics: []types.MetricAccuracy{
+			utils.ForecastAccuracy(utils.ForecastDemand("covers", historyCovers, from, days, weeks), covers),
+			utils.ForecastAccuracy(utils.ForecastDemand("orders", historyOrders, from, days, weeks), orders),
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// SetRestaurantImage replaces the image of a restaurant (multipart "image")
+func (h *Handler) SetRestaurantImage(w http.ResponseWriter, r *http.Request) {
+	h.replaceImage(w, r, "idRestaurant", "restaurants", h.store.GetRestaurantImage, h.store.SetRestaurantImage)
+}
+
+func (h *Handler) SetFoodImage(w http.ResponseWriter, r *http.Request) {
+	h.replaceImage(w, r, "idFood", "foods", h.store.GetFoodImage, h.store.SetFoodImage)
+}
+
+func (h *Handler) SetRestaurantWorkerImage(w http.ResponseWriter, r *http.Request) {
+	h.replaceImage(w, r, "idRestaurantWorker", "workers", h.store.GetRestaurantWorkerImage, h.store.SetRestaurantWorkerImage)
+}
+
+// Helper function to answer a failed image owner lookup, with a 404 when the owner does not exist
+func writeImageOwnerError(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	utils.WriteError(w, http.StatusInternalServerError, err)
+}
+
+// Helper function to save the uploaded image of an entity under its own ID, point the entity to it and delete the
+// image it had before. The entity is looked up with get first, so nothing is uploaded for a missing one.
+func (h *Handler) replaceImage(w http.ResponseWriter, r *http.Request, idVar, kind string, get func(id string) (string, error), set func(id, image string) (string, error)) {
+	id := mux.Vars(r)[idVar]
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s is required", idVar))
+		return
+	}
+	if _, err := get(id); err != nil {
+		writeImageOwnerError(w, err)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("image is required"))
+		return
+	}
+	defer file.Close()
+
+	image, err := utils.SaveImage(h.images, utils.ImageID(kind, id), file)
+	if err != nil {
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
+		return
+	}
+
+	previous, err := set(id, image.URL)
+	if err != nil {
+		// The entity was removed during the upload, the image saved under its ID has no owner left
+		if strings.Contains(err.Error(), "not found") {
+			utils.DeleteImages(h.images, image.PublicID)
+		}
+		writeImageOwnerError(w, err)
+		return
+	}
+	utils.DeleteReplacedImage(h.images, previous, image.PublicID)
+	utils.WriteJson(w, http.StatusOK, map[string]string{"image": image.URL})
+}
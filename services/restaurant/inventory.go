@@ -0,0 +1,235 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// Helper function to map inventory errors to status codes
+func writeInventoryError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(err.Error(), "already exists"):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// GetIngredients lists the stock of a restaurant, ?lowStock=true keeps the ingredients running low
+func (h *Handler) GetIngredients(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	ingredients, err := h.store.GetIngredients(idRestaurant, r.URL.Query().Get("lowStock") == "true")
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, ingredients)
+}
+
+func (h *Handler) CreateIngredient(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var ingredient types.Ingredient
+	if err := utils.ParseJson(r, &ingredient); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if ingredient.Name == "" || ingredient.Unit == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name and unit are required"))
+		return
+	}
+	if ingredient.Stock < 0 || ingredient.LowStockThreshold < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("stock and lowStockThreshold cannot be negative"))
+		return
+	}
+
+	idIngredient, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ingredient.IdIngredient = idIngredient
+	ingredient.IdRestaurant = idRestaurant
+	ingredient.CreatedAt = time.Now()
+
+	if err := h.store.CreateIngredient(ingredient); err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, ingredient)
+}
+
+func (h *Handler) UpdateIngredient(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idIngredient"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idIngredient are required"))
+		return
+	}
+
+	var ingredient types.Ingredient
+	if err := utils.ParseJson(r, &ingredient); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if ingredient.Name == "" || ingredient.Unit == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name and unit are required"))
+		return
+	}
+	if ingredient.LowStockThreshold < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("lowStockThreshold cannot be negative"))
+		return
+	}
+	ingredient.IdRestaurant = vars["idRestaurant"]
+	ingredient.IdIngredient = vars["idIngredient"]
+
+	if err := h.store.UpdateIngredient(ingredient); err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Ingredient updated"})
+}
+
+func (h *Handler) DeleteIngredient(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idIngredient"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idIngredient are required"))
+		return
+	}
+
+	if err := h.store.DeleteIngredient(vars["idRestaurant"], vars["idIngredient"]); err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Ingredient deleted"})
+}
+
+// RestockIngredient records a delivery; foods held back for lack of the ingredient come back on the menu
+func (h *Handler) RestockIngredient(w http.ResponseWriter, r *http.Request) {
+	h.recordStockMovement(w, r, "restock")
+}
+
+// AdjustIngredientStock corrects the stock after a count, waste or breakage
+func (h *Handler) AdjustIngredientStock(w http.ResponseWriter, r *http.Request) {
+	h.recordStockMovement(w, r, "adjustment")
+}
+
+func (h *Handler) recordStockMovement(w http.ResponseWriter, r *http.Request, kind string) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idIngredient"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idIngredient are required"))
+		return
+	}
+
+	var entry types.StockEntry
+	if err := utils.ParseJson(r, &entry); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if entry.Quantity == 0 || (kind == "restock" && entry.Quantity < 0) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("quantity must be positive for a restock and non zero for an adjustment"))
+		return
+	}
+
+	if err := h.store.RecordStockMovement(vars["idRestaurant"], vars["idIngredient"], kind, entry); err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	ingredients, err := h.store.GetIngredients(vars["idRestaurant"], false)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, ingredient := range ingredients {
+		if ingredient.IdIngredient == vars["idIngredient"] {
+			utils.WriteJson(w, http.StatusOK, ingredient)
+			return
+		}
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Stock updated"})
+}
+
+// GetStockMovements returns the stock history of a restaurant, ?idIngredient= narrows it to one ingredient
+func (h *Handler) GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	movements, err := h.store.GetStockMovements(idRestaurant, r.URL.Query().Get("idIngredient"))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, movements)
+}
+
+func (h *Handler) GetFoodRecipe(w http.ResponseWriter, r *http.Request) {
+	idFood := mux.Vars(r)["idFood"]
+	if idFood == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood is required"))
+		return
+	}
+
+	items, err := h.store.GetFoodRecipe(idFood)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, items)
+}
+
+// SetFoodRecipe replaces the ingredients used by one portion of a food
+func (h *Handler) SetFoodRecipe(w http.ResponseWriter, r *http.Request) {
+	idFood := mux.Vars(r)["idFood"]
+	if idFood == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood is required"))
+		return
+	}
+
+	var recipe types.RecipeUpdate
+	if err := utils.ParseJson(r, &recipe); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, item := range recipe.Items {
+		if item.IdIngredient == "" || item.Quantity <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("each recipe item needs an idIngredient and a positive quantity"))
+			return
+		}
+		if seen[item.IdIngredient] {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("ingredient %s is listed twice", item.IdIngredient))
+			return
+		}
+		seen[item.IdIngredient] = true
+	}
+
+	if err := h.store.SetFoodRecipe(idFood, recipe.Items); err != nil {
+		writeInventoryError(w, err)
+		return
+	}
+	items, err := h.store.GetFoodRecipe(idFood)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, items)
+}
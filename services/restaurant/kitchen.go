@@ -0,0 +1,167 @@
+package restaurant
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// kitchenRefreshInterval is how often an open kitchen display gets a fresh snapshot with updated timers
+const kitchenRefreshInterval = 30 * time.Second
+
+// KitchenDisplayWS streams the active orders of a restaurant to the kitchen tablet.
+// The tablet receives a snapshot on connect and every kitchenRefreshInterval, then
+// order_created / order_updated / order_status_changed events, and sends back
+// {"action": "bump"|"ready", "idOrder": "..."} to move orders forward.
+func (h *Handler) KitchenDisplayWS(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		http.Error(w, "idRestaurant is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket Upgrade:", err)
+		return
+	}
+
+	client := &Client{conn: conn, restaurantID: idRestaurant, send: make(chan []byte, 32)}
+	kitchenHub.register(client)
+	done := make(chan struct{})
+
+	go writePump(client)
+	go h.refreshKitchenDisplay(client, done)
+	go func() {
+		h.kitchenReadPump(client)
+		close(done)
+		kitchenHub.unregister(client)
+	}()
+}
+
+func (h *Handler) refreshKitchenDisplay(client *Client, done chan struct{}) {
+	ticker := time.NewTicker(kitchenRefreshInterval)
+	defer ticker.Stop()
+	for {
+		h.sendKitchenSnapshot(client)
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Handler) sendKitchenSnapshot(client *Client) {
+	tickets, err := h.store.GetKitchenTickets(client.restaurantID)
+	if err != nil {
+		log.Printf("Error loading kitchen orders for restaurant %s: %v", client.restaurantID, err)
+		return
+	}
+	message, err := encodeDashboardEvent(client.restaurantID, "kitchen_snapshot", tickets)
+	if err != nil {
+		log.Printf("Error encoding kitchen snapshot: %v", err)
+		return
+	}
+	kitchenHub.send(client, message)
+}
+
+func (h *Handler) kitchenReadPump(client *Client) {
+	defer client.conn.Close()
+
+	for {
+		var action types.KitchenAction
+		if err := client.conn.ReadJSON(&action); err != nil {
+			log.Println("kitchen read error:", err)
+			return
+		}
+
+		if _, err := h.applyKitchenAction(client.restaurantID, action); err != nil {
+			message, encodeErr := encodeDashboardEvent(client.restaurantID, "kitchen_error", map[string]string{
+				"idOrder": action.IdOrder,
+				"action":  action.Action,
+				"error":   err.Error(),
+			})
+			if encodeErr == nil {
+				kitchenHub.send(client, message)
+			}
+		}
+	}
+}
+
+// applyKitchenAction moves an order forward and tells every kitchen display and dashboard of the restaurant
+func (h *Handler) applyKitchenAction(idRestaurant string, action types.KitchenAction) (*types.KitchenTicket, error) {
+	if action.IdOrder == "" {
+		return nil, fmt.Errorf("idOrder is required")
+	}
+	ticket, err := h.store.AdvanceKitchenOrder(idRestaurant, action.IdOrder, action.Action)
+	if err != nil {
+		return nil, err
+	}
+	broadcastKitchenTicket("order_status_changed", ticket)
+	return ticket, nil
+}
+
+func broadcastKitchenTicket(eventType string, ticket *types.KitchenTicket) {
+	kitchenHub.broadcast(ticket.IdRestaurant, eventType, ticket)
+	dashboardHub.broadcast(ticket.IdRestaurant, eventType, ticket)
+}
+
+// pushKitchenOrder reloads an order and broadcasts it; the order is already saved so failures are only logged
+func (h *Handler) pushKitchenOrder(idOrder string, eventType string) {
+	ticket, err := h.store.GetKitchenTicket(idOrder)
+	if err != nil {
+		log.Printf("Error loading order %s for the kitchen display: %v", idOrder, err)
+		return
+	}
+	broadcastKitchenTicket(eventType, ticket)
+}
+
+// GetKitchenOrders returns the same snapshot as the kitchen websocket, for displays that cannot keep a socket open
+func (h *Handler) GetKitchenOrders(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	tickets, err := h.store.GetKitchenTickets(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, tickets)
+}
+
+func (h *Handler) KitchenOrderAction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idRestaurant := vars["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	ticket, err := h.applyKitchenAction(idRestaurant, types.KitchenAction{Action: vars["action"], IdOrder: vars["idOrder"]})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "does not belong"):
+			utils.WriteError(w, http.StatusForbidden, err)
+		case strings.Contains(err.Error(), "cannot"):
+			utils.WriteError(w, http.StatusConflict, err)
+		case strings.Contains(err.Error(), "invalid kitchen action"), strings.Contains(err.Error(), "required"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, ticket)
+}
@@ -0,0 +1,100 @@
+package restaurant
+
+import (
+	"log"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// EmailDispatcher sends the queued client reservation and takeaway emails. The queue
+// lives in the database, so reminders scheduled before a restart are still delivered.
+type EmailDispatcher struct {
+	store     types.RestaurantStore
+	interval  time.Duration
+	batchSize int
+}
+
+func NewEmailDispatcher(store types.RestaurantStore, interval time.Duration) *EmailDispatcher {
+	return &EmailDispatcher{store: store, interval: interval, batchSize: 50}
+}
+
+// Start polls the queue in the background
+func (d *EmailDispatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			d.dispatch()
+			<-ticker.C
+		}
+	}()
+}
+
+func (d *EmailDispatcher) dispatch() {
+	jobs, err := d.store.GetDueEmailJobs(time.Now(), d.batchSize)
+	if err != nil {
+		log.Printf("Error loading email jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if reason := skipReason(job); reason != "" {
+			if err := d.store.MarkEmailJobSkipped(job.IdJob, reason); err != nil {
+				log.Printf("Error skipping email job %s: %v", job.IdJob, err)
+			}
+			continue
+		}
+
+		err := utils.SendReservationEmail(job.JobType, job.Email, utils.ReservationEmailData{
+			FirstName:      job.FirstName,
+			RestaurantName: job.RestaurantName,
+			TimeFrom:       job.TimeFrom,
+			NumberOfPeople: job.NumberOfPeople,
+			IdReservation:  job.IdReservation,
+			IdOrder:        job.IdOrder,
+		})
+		if err != nil {
+			var retryAt *time.Time
+			if job.Attempts+1 < maxEmailJobAttempts {
+				next := time.Now().Add(time.Duration(job.Attempts+1) * 5 * time.Minute)
+				retryAt = &next
+			}
+			if markErr := d.store.MarkEmailJobFailed(job.IdJob, err.Error(), retryAt); markErr != nil {
+				log.Printf("Error recording failed email job %s: %v", job.IdJob, markErr)
+			}
+			continue
+		}
+
+		if err := d.store.MarkEmailJobSent(job.IdJob); err != nil {
+			log.Printf("Error marking email job %s as sent: %v", job.IdJob, err)
+		}
+	}
+}
+
+// Helper function to decide whether a due job should not be sent anymore
+func skipReason(job types.EmailJob) string {
+	if job.Email == "" {
+		return "client has no email"
+	}
+	if job.JobType == "reminder" {
+		if !job.ReminderEmails {
+			return "client opted out of reminders"
+		}
+		if job.ReservationStatus != "pending" && job.ReservationStatus != "confirmed" {
+			return "reservation is " + job.ReservationStatus
+		}
+		if job.TimeFrom.Before(time.Now()) {
+			return "reservation already started"
+		}
+		return ""
+	}
+	if !job.ReservationEmails {
+		return "client opted out of reservation emails"
+	}
+	if job.JobType == "takeaway_ready" && job.ReservationStatus != "ready" {
+		return "order is " + job.ReservationStatus
+	}
+	return ""
+}
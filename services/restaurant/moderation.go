@@ -0,0 +1,220 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+const maxReviewReplyLength = 1000
+
+// Helper function to map review moderation errors to status codes
+func writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(err.Error(), "does not belong"):
+		utils.WriteError(w, http.StatusForbidden, err)
+	case strings.Contains(err.Error(), "cannot"), strings.Contains(err.Error(), "already"):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// Helper function to read the profile ID and role from the token in the Authorization header
+func requestIdentity(r *http.Request) (string, string, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		return "", "", fmt.Errorf("authorization token is required")
+	}
+	claims, err := utils.DecodeToken(token)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid token")
+	}
+	id, _ := claims["id"].(string)
+	role, _ := claims["role"].(string)
+	if id == "" || role == "" {
+		return "", "", fmt.Errorf("invalid token")
+	}
+	return id, role, nil
+}
+
+// Helper function to let only general admins through, it writes the error and returns false otherwise
+func requireModerator(w http.ResponseWriter, r *http.Request) bool {
+	_, role, err := requestIdentity(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err)
+		return false
+	}
+	if role != "admin" {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("only admins can moderate reviews"))
+		return false
+	}
+	return true
+}
+
+// Helper function to let only the admin of idRestaurant through, it writes the error and returns false otherwise
+func (h *Handler) requireRestaurantAdmin(w http.ResponseWriter, r *http.Request, idRestaurant string) bool {
+	idProfile, role, err := requestIdentity(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err)
+		return false
+	}
+	if role != "adminRestaurant" {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("only restaurant admins can answer reviews"))
+		return false
+	}
+	admin, err := h.store.GetRestaurantByIdProfile(idProfile)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if admin == nil || admin.IdRestaurant != idRestaurant {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("restaurant %s does not belong to this admin", idRestaurant))
+		return false
+	}
+	return true
+}
+
+// ReplyToReview publishes the restaurant's answer under one of its reviews, for the admin of that restaurant only
+func (h *Handler) ReplyToReview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idRating"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idRating are required"))
+		return
+	}
+	if !h.requireRestaurantAdmin(w, r, vars["idRestaurant"]) {
+		return
+	}
+
+	var req types.ReviewReply
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	req.Reply = strings.TrimSpace(req.Reply)
+	if req.Reply == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("reply is required"))
+		return
+	}
+	if len([]rune(req.Reply)) > maxReviewReplyLength {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("reply cannot exceed %d characters", maxReviewReplyLength))
+		return
+	}
+	req.Reply, _ = utils.FilterProfanity(req.Reply)
+
+	if err := h.store.ReplyToReview(vars["idRestaurant"], vars["idRating"], req.Reply); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Reply posted", "reply": req.Reply})
+}
+
+func (h *Handler) DeleteReviewReply(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idRating"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idRating are required"))
+		return
+	}
+
+	if !h.requireRestaurantAdmin(w, r, vars["idRestaurant"]) {
+		return
+	}
+
+	if err := h.store.ReplyToReview(vars["idRestaurant"], vars["idRating"], ""); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Reply deleted"})
+}
+
+// FlagReview reports an abusive review to the moderators
+func (h *Handler) FlagReview(w http.ResponseWriter, r *http.Request) {
+	idRating := mux.Vars(r)["idRating"]
+	if idRating == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRating is required"))
+		return
+	}
+
+	var flag types.ReviewFlag
+	if err := utils.ParseJson(r, &flag); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if flag.IdClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	idFlag, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.store.FlagReview(idFlag, idRating, flag); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, map[string]string{"message": "Review flagged"})
+}
+
+// GetModerationQueue lists the flagged reviews, or the hidden ones with ?status=hidden. Like every moderator
+// action it needs a general admin's token in the Authorization header.
+func (h *Handler) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if !requireModerator(w, r) {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "flagged"
+	}
+	if status != "flagged" && status != "hidden" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be flagged or hidden"))
+		return
+	}
+
+	reviews, err := h.store.GetModerationQueue(status)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, reviews)
+}
+
+func (h *Handler) HideReview(w http.ResponseWriter, r *http.Request) {
+	h.moderateReview(w, r, "hidden")
+}
+
+func (h *Handler) RestoreReview(w http.ResponseWriter, r *http.Request) {
+	h.moderateReview(w, r, "visible")
+}
+
+func (h *Handler) moderateReview(w http.ResponseWriter, r *http.Request, status string) {
+	if !requireModerator(w, r) {
+		return
+	}
+	idRating := mux.Vars(r)["idRating"]
+	if idRating == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRating is required"))
+		return
+	}
+
+	var decision types.ModerationDecision
+	if r.ContentLength > 0 {
+		if err := utils.ParseJson(r, &decision); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := h.store.ModerateReview(idRating, status, decision.Note); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Review " + status})
+}
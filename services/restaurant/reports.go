@@ -0,0 +1,73 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// Longest range a report covers
+const maxReportDays = 731
+
+// Helper function to read the ?from and ?to days of a report (YYYY-MM-DD, both included). It returns [from, to)
+// with to the day after the last one; without them the report covers the last 30 days.
+func reportPeriod(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	if value := r.URL.Query().Get("to"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must use the YYYY-MM-DD format")
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -30)
+	if value := r.URL.Query().Get("from"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must use the YYYY-MM-DD format")
+		}
+		from = day
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	if to.Sub(from) > maxReportDays*24*time.Hour+time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("a report cannot cover more than %d days", maxReportDays)
+	}
+	return from, to, nil
+}
+
+// GetRevenueReport returns the revenue of a restaurant over ?from..?to grouped by ?groupBy (day, week or month),
+// with the average ticket, the breakdowns per hour, food and category and the comparison to the previous period
+func (h *Handler) GetRevenueReport(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	if groupBy != "day" && groupBy != "week" && groupBy != "month" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("groupBy must be day, week or month"))
+		return
+	}
+
+	report, err := h.store.GetRevenueReport(idRestaurant, from, to, groupBy)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, report)
+}
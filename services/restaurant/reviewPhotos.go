@@ -0,0 +1,146 @@
+package restaurant
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// UploadReviewPhotos attaches photos (multipart "photos", with the author in "idClient") to a restaurant or activity review
+func (h *Handler) UploadReviewPhotos(w http.ResponseWriter, r *http.Request) {
+	idRating := mux.Vars(r)["idRating"]
+	if idRating == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRating is required"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReviewPhotos*utils.MaxImageSize+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	idClient := r.FormValue("idClient")
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+	headers := r.MultipartForm.File["photos"]
+	if len(headers) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("at least one photo is required"))
+		return
+	}
+
+	slots, err := h.store.GetReviewPhotoSlots(idRating, idClient)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	if len(headers) > slots {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("cannot attach %d more photos, the review has room for %d", len(headers), slots))
+		return
+	}
+
+	// Check every file before saving any of them
+	files := make([]reviewPhotoFile, 0, len(headers))
+	for _, header := range headers {
+		file, err := readReviewPhoto(header)
+		if err != nil {
+			utils.WriteError(w, utils.ImageErrorStatus(err), err)
+			return
+		}
+		files = append(files, *file)
+	}
+
+	photos := make([]types.ReviewPhoto, 0, len(files))
+	var saved []string
+	for _, file := range files {
+		idPhoto, err := utils.CreateAnId()
+		if err != nil {
+			utils.DeleteImages(h.images, saved...)
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		image, err := h.images.Save(utils.ImageID("reviews", idRating, idPhoto), file.data, file.contentType)
+		if err != nil {
+			utils.DeleteImages(h.images, saved...)
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		saved = append(saved, image.PublicID)
+		photos = append(photos, types.ReviewPhoto{
+			IdPhoto:      idPhoto,
+			IdRating:     idRating,
+			URL:          image.URL,
+			ThumbnailURL: image.ThumbnailURL,
+			PublicID:     image.PublicID,
+			CreatedAt:    time.Now(),
+		})
+	}
+
+	if err := h.store.AddReviewPhotos(idRating, idClient, photos); err != nil {
+		utils.DeleteImages(h.images, saved...)
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, photos)
+}
+
+type reviewPhotoFile struct {
+	data        []byte
+	contentType string
+}
+
+// Helper function to read and check one uploaded photo
+func readReviewPhoto(header *multipart.FileHeader) (*reviewPhotoFile, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, contentType, err := utils.ReadImage(file)
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s)", err, header.Filename)
+	}
+	return &reviewPhotoFile{data: data, contentType: contentType}, nil
+}
+
+// DeleteReviewPhoto lets the author of a review take one of its photos down
+func (h *Handler) DeleteReviewPhoto(w http.ResponseWriter, r *http.Request) {
+	idClient := r.URL.Query().Get("idClient")
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+	h.removeReviewPhoto(w, r, idClient)
+}
+
+// RemoveReviewPhoto is the moderator action on a flagged photo
+func (h *Handler) RemoveReviewPhoto(w http.ResponseWriter, r *http.Request) {
+	if !requireModerator(w, r) {
+		return
+	}
+	h.removeReviewPhoto(w, r, "")
+}
+
+func (h *Handler) removeReviewPhoto(w http.ResponseWriter, r *http.Request, idClient string) {
+	vars := mux.Vars(r)
+	if vars["idRating"] == "" || vars["idPhoto"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRating and idPhoto are required"))
+		return
+	}
+
+	publicID, err := h.store.RemoveReviewPhoto(vars["idRating"], vars["idPhoto"], idClient)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	// The photo is already off the review, a file left behind in the store is not worth failing the request
+	utils.DeleteImages(h.images, publicID)
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Photo removed"})
+}
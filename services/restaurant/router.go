@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/wael-boudissaa/zencitiBackend/types"
@@ -14,17 +15,19 @@ import (
 )
 
 type Handler struct {
-	store types.RestaurantStore
+	store  types.RestaurantStore
+	images types.ImageStore
 }
 
-func NewHandler(s types.RestaurantStore) *Handler {
-	return &Handler{store: s}
+func NewHandler(s types.RestaurantStore, images types.ImageStore) *Handler {
+	return &Handler{store: s, images: images}
 }
 
 func (h *Handler) RegisterRouter(r *mux.Router) {
 	//!NOTE: RESTAURANT INFORMATIONS
 	r.HandleFunc("/worker/{idRestaurantWorker}/details", h.GetRestaurantWorkerWithRatings).Methods("GET")
 	r.HandleFunc("/restaurant", h.GetRestaurant).Methods("GET")
+	r.HandleFunc("/restaurant/search", h.SearchRestaurants).Methods("GET")
 	r.HandleFunc("/restaurant", h.CreateRestaurant).Methods("POST")
 	r.HandleFunc("/restaurant/count/{restaurantId}", h.RestaurantCountInformation).Methods("GET")
 	r.HandleFunc("/restaurant/{id}", h.GetRestaurantById).Methods("GET")
@@ -48,24 +51,76 @@ func (h *Handler) RegisterRouter(r *mux.Router) {
 	r.HandleFunc("/restaurant/worker/{idRestaurantWorker}", h.UpdateRestaurantWorker).Methods("PUT")
 	r.HandleFunc("/menu/restaurant/{idRestaurant}", h.GetMenusByRestaurant).Methods("GET")
 	r.HandleFunc("/food/category/{idRestaurant}", h.GetFoodCategoriesByRestaurant).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/food-categories/order", h.ReorderFoodCategories).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/food-categories/{idCategory}", h.UpdateFoodCategory).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/food-categories/{idCategory}", h.DeleteFoodCategory).Methods("DELETE")
+	r.HandleFunc("/restaurant/{idRestaurant}/food-categories/{idCategory}/image", h.SetFoodCategoryImage).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/image", h.SetRestaurantImage).Methods("PUT")
+	r.HandleFunc("/food/{idFood}/image", h.SetFoodImage).Methods("PUT")
+	r.HandleFunc("/restaurant/worker/{idRestaurantWorker}/image", h.SetRestaurantWorkerImage).Methods("PUT")
 	r.HandleFunc("/food/active/{idRestaurant}", h.GetFoodsOfActiveMenu).Methods("GET")
 	r.HandleFunc("/restaurant/menu/stats/{restaurantId}", h.GetRestaurantMenuStats).Methods("GET")
 	r.HandleFunc("/restaurant/food/{restaurantId}", h.GetFoodRestaurant).Methods("GET")
 	r.HandleFunc("/restaurant/addfood/{idMenu}", h.AddFoodToMenu).Methods("POST")
 	r.HandleFunc("/menu/{idMenu}/activate/{idRestaurant}", h.SetMenuActive).Methods("PUT")
+	r.HandleFunc("/menu/{idMenu}/schedules", h.GetMenuSchedules).Methods("GET")
+	r.HandleFunc("/menu/{idMenu}/schedules", h.CreateMenuSchedule).Methods("POST")
+	r.HandleFunc("/menu/{idMenu}/schedules/{idSchedule}", h.DeleteMenuSchedule).Methods("DELETE")
+	r.HandleFunc("/restaurant/{idRestaurant}/menu/current", h.GetCurrentMenu).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/menu-override", h.SetMenuOverride).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/menu-override", h.ClearMenuOverride).Methods("DELETE")
 	//!NOTE: REVIEWS
 	r.HandleFunc("/reviews/{idRestaurant}", h.GetRecentReviewsRestaurant).Methods("GET")
 	r.HandleFunc("/friends/reviews", h.GetFriendsReviewsRestaurant).Methods("POST")
 	r.HandleFunc("/restaurant/rating", h.PostReviewRestaurant).Methods("POST")
+	r.HandleFunc("/worker/rating", h.PostReviewWorker).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/reviews/{idRating}/reply", h.ReplyToReview).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/reviews/{idRating}/reply", h.DeleteReviewReply).Methods("DELETE")
+	r.HandleFunc("/reviews/{idRating}/flag", h.FlagReview).Methods("POST")
+	r.HandleFunc("/admin/reviews/moderation", h.GetModerationQueue).Methods("GET")
+	r.HandleFunc("/admin/reviews/{idRating}/hide", h.HideReview).Methods("PUT")
+	r.HandleFunc("/admin/reviews/{idRating}/restore", h.RestoreReview).Methods("PUT")
+	r.HandleFunc("/reviews/{idRating}/photos", h.UploadReviewPhotos).Methods("POST")
+	r.HandleFunc("/reviews/{idRating}/photos/{idPhoto}", h.DeleteReviewPhoto).Methods("DELETE")
+	r.HandleFunc("/admin/reviews/{idRating}/photos/{idPhoto}/remove", h.RemoveReviewPhoto).Methods("PUT")
 	//!NOTE: RESERVATION
 	r.HandleFunc("/reservation/month/{restaurantId}", h.GetReservationMonthStats).Methods("GET")
 	r.HandleFunc("/reservation", h.CreateReservation).Methods("POST")
 	r.HandleFunc("/reservation/stats/{restaurantId}", h.GetReservationStats).Methods("GET")
 	r.HandleFunc("/reservation/today/{restaurantId}", h.GetReservationToday).Methods("GET")
 	r.HandleFunc("/reservation/{idReservation}/status", h.UpdateReservationStatus).Methods("PUT")
+	r.HandleFunc("/reservation/{idReservation}/check-in", h.CheckInReservation).Methods("PUT")
+	r.HandleFunc("/reservation/{idReservation}/server", h.AssignReservationServer).Methods("PUT")
 	r.HandleFunc("/reservation/upcoming/{restaurantId}", h.GetUpcomingReservations).Methods("GET")
 	r.HandleFunc("/restaurant/{idRestaurant}/reservations", h.GetAllRestaurantReservations).Methods("GET")
 	r.HandleFunc("/reservation/{idReservation}/details", h.GetReservationDetails).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/reservation-policy", h.GetReservationPolicy).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/reservation-policy", h.UpdateReservationPolicy).Methods("PUT")
+	r.HandleFunc("/client/{idClient}/noshows", h.GetClientNoShowSummary).Methods("GET")
+	r.HandleFunc("/client/{idClient}/email-preferences", h.GetClientEmailPreferences).Methods("GET")
+	r.HandleFunc("/client/{idClient}/email-preferences", h.UpdateClientEmailPreferences).Methods("PUT")
+	r.HandleFunc("/client/{idClient}/reservation/{idReservation}", h.ModifyReservationByClient).Methods("PUT")
+	r.HandleFunc("/client/{idClient}/reservation/{idReservation}/cancel", h.CancelReservationByClient).Methods("POST")
+	r.HandleFunc("/reservation/{idReservation}/history", h.GetReservationHistory).Methods("GET")
+	r.HandleFunc("/ws/restaurant/{idRestaurant}/dashboard", h.RestaurantDashboardWS)
+	//!NOTE: GROUP RESERVATIONS
+	r.HandleFunc("/reservation/{idReservation}/guests", h.InviteFriendsToReservation).Methods("POST")
+	r.HandleFunc("/reservation/{idReservation}/guests", h.GetReservationGuests).Methods("GET")
+	r.HandleFunc("/reservation/{idReservation}/guests/{idClient}", h.RespondToReservationInvitation).Methods("PUT")
+	r.HandleFunc("/client/{idClient}/invitations", h.GetClientReservationInvitations).Methods("GET")
+	r.HandleFunc("/reservation/{idReservation}/order/items", h.AddGuestItemsToOrder).Methods("POST")
+
+	//!NOTE: BILLS
+	r.HandleFunc("/restaurant/{idRestaurant}/billing-settings", h.GetBillingSettings).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/billing-settings", h.UpdateBillingSettings).Methods("PUT")
+	r.HandleFunc("/order/{idOrder}/lines/assign", h.AssignOrderLine).Methods("PUT")
+	r.HandleFunc("/reservation/{idReservation}/bill", h.GetReservationBill).Methods("GET")
+	r.HandleFunc("/reservation/{idReservation}/bill", h.CreateReservationBill).Methods("POST")
+
+	//!NOTE: DEPOSITS
+	r.HandleFunc("/restaurant/{idRestaurant}/deposit-policy", h.GetDepositPolicy).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/deposit-policy", h.UpdateDepositPolicy).Methods("PUT")
+	r.HandleFunc("/reservation/{idReservation}/deposit", h.GetReservationDeposit).Methods("GET")
 
 	//!NOTE: ORDER
 	r.HandleFunc("/order", h.CreateOrder).Methods("POST")
@@ -75,15 +130,85 @@ func (h *Handler) RegisterRouter(r *mux.Router) {
 	r.HandleFunc("/order/place", h.PostOrderClient).Methods("POST")
 	r.HandleFunc("/food/{menuId}", h.GetFoodByMenu).Methods("GET")
 	r.HandleFunc("/order/{idOrder}/status", h.UpdateOrderStatus).Methods("PUT")
+	r.HandleFunc("/order/{idOrder}/server", h.AssignOrderServer).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/kitchen/orders", h.GetKitchenOrders).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/kitchen/orders/{idOrder}/{action}", h.KitchenOrderAction).Methods("POST")
+	r.HandleFunc("/ws/restaurant/{idRestaurant}/kitchen", h.KitchenDisplayWS)
+
+	//!NOTE: TABLE ORDERING
+	r.HandleFunc("/restaurant/{idRestaurant}/tables/{idTable}/qr", h.GetTableQrCode).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/tables/{idTable}/qr/rotate", h.RotateTableQrCode).Methods("POST")
+	r.HandleFunc("/table-session", h.OpenTableSession).Methods("POST")
+	r.HandleFunc("/table-session/{idTableSession}", h.GetTableSession).Methods("GET")
+	r.HandleFunc("/table-session/{idTableSession}/order", h.PlaceTableSessionOrder).Methods("POST")
+	r.HandleFunc("/table-session/{idTableSession}/close", h.CloseTableSession).Methods("POST")
+
+	//!NOTE: TAKEAWAY
+	r.HandleFunc("/restaurant/{idRestaurant}/takeaway-settings", h.GetTakeawaySettings).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/takeaway-settings", h.UpdateTakeawaySettings).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/takeaway/windows", h.GetTakeawayWindows).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/takeaway/orders", h.GetRestaurantTakeawayOrders).Methods("GET")
+	r.HandleFunc("/takeaway/order", h.CreateTakeawayOrder).Methods("POST")
+	r.HandleFunc("/client/{idClient}/takeaway/orders", h.GetClientTakeawayOrders).Methods("GET")
+
+	//!NOTE: INVENTORY
+	r.HandleFunc("/restaurant/{idRestaurant}/ingredients", h.GetIngredients).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/ingredients", h.CreateIngredient).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/ingredients/{idIngredient}", h.UpdateIngredient).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/ingredients/{idIngredient}", h.DeleteIngredient).Methods("DELETE")
+	r.HandleFunc("/restaurant/{idRestaurant}/ingredients/{idIngredient}/restock", h.RestockIngredient).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/ingredients/{idIngredient}/adjust", h.AdjustIngredientStock).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/stock-movements", h.GetStockMovements).Methods("GET")
+	r.HandleFunc("/food/{idFood}/recipe", h.GetFoodRecipe).Methods("GET")
+	r.HandleFunc("/food/{idFood}/recipe", h.SetFoodRecipe).Methods("PUT")
+
+	//!NOTE: STAFF SCHEDULING
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-settings", h.GetShiftSettings).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-settings", h.UpdateShiftSettings).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-templates", h.GetShiftTemplates).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-templates", h.CreateShiftTemplate).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-templates/{idTemplate}", h.DeleteShiftTemplate).Methods("DELETE")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-templates/{idTemplate}/assign", h.AssignShiftTemplate).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/shifts", h.GetWorkerShifts).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/shifts", h.CreateWorkerShift).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/shifts/conflicts", h.GetShiftConflicts).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/shifts/{idShift}", h.DeleteWorkerShift).Methods("DELETE")
+	r.HandleFunc("/restaurant/{idRestaurant}/shifts/{idShift}/swap", h.CreateShiftSwap).Methods("POST")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-swaps", h.GetShiftSwaps).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/shift-swaps/{idSwap}", h.ResolveShiftSwap).Methods("PUT")
+	r.HandleFunc("/restaurant/{idRestaurant}/on-shift", h.GetOnShiftWorkers).Methods("GET")
+	r.HandleFunc("/worker/{idRestaurantWorker}/clock-in", h.ClockIn).Methods("POST")
+	r.HandleFunc("/worker/{idRestaurantWorker}/clock-out", h.ClockOut).Methods("POST")
+	r.HandleFunc("/worker/{idRestaurantWorker}/timesheet", h.GetWorkerTimesheet).Methods("GET")
 
 	r.HandleFunc("/menu", h.CreateMenu).Methods("POST")
 	r.HandleFunc("/food/{idFood}", h.GetFoodById).Methods("GET")
 	r.HandleFunc("/food/{idFood}", h.UpdateFood).Methods("PUT")
 	r.HandleFunc("/menu/{idMenu}", h.GetMenuWithFoods).Methods("GET")
 	r.HandleFunc("/food/{idFood}/status", h.SetFoodStatusInMenu).Methods("PUT")
+	r.HandleFunc("/food/{idFood}/options", h.GetFoodCustomization).Methods("GET")
+	r.HandleFunc("/food/{idFood}/option-groups", h.CreateFoodOptionGroup).Methods("POST")
+	r.HandleFunc("/food/{idFood}/option-groups/{idGroup}", h.DeleteFoodOptionGroup).Methods("DELETE")
+	r.HandleFunc("/food/{idFood}/option-groups/{idGroup}/options", h.CreateFoodOption).Methods("POST")
+	r.HandleFunc("/food/{idFood}/options/{idOption}", h.UpdateFoodOption).Methods("PUT")
+	r.HandleFunc("/food/{idFood}/options/{idOption}", h.DeleteFoodOption).Methods("DELETE")
+	r.HandleFunc("/food/{idFood}/combo", h.SetComboItems).Methods("PUT")
+	r.HandleFunc("/food/{idFood}/tags", h.SetFoodTags).Methods("PUT")
+	r.HandleFunc("/food-tags", h.GetFoodTags).Methods("GET")
+	r.HandleFunc("/food-tags", h.CreateFoodTag).Methods("POST")
+	r.HandleFunc("/client/{idClient}/dietary-preferences", h.GetClientDietaryPreferences).Methods("GET")
+	r.HandleFunc("/client/{idClient}/dietary-preferences", h.UpdateClientDietaryPreferences).Methods("PUT")
 
 	r.HandleFunc("/client/{idClient}/reservations", h.GetAllClientReservations).Methods("GET")
 
+	//!NOTE: REPORTS
+	r.HandleFunc("/restaurant/{idRestaurant}/reports/revenue", h.GetRevenueReport).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/reservations/export", h.ExportRestaurantReservations).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/orders/export", h.ExportRestaurantOrders).Methods("GET")
+	r.HandleFunc("/restaurant/{id}/reviews/export", h.ExportRestaurantReviews).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/forecast", h.GetRestaurantForecast).Methods("GET")
+	r.HandleFunc("/restaurant/{idRestaurant}/forecast/accuracy", h.GetRestaurantForecastAccuracy).Methods("GET")
+
 	//!NOTE:NOTIFICATIONI NOT THIS PLACE
 	r.HandleFunc("/notification", h.CreateNotification).Methods("POST")
 	r.HandleFunc("/notification", h.GetNotifications).Methods("GET")
@@ -260,10 +385,16 @@ func (h *Handler) GetAllRestaurantReservations(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	filter, err := utils.ParseListFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	// Fixed limit of 6 per page
 	limit := 6
 
-	reservations, err := h.store.GetAllRestaurantReservations(idRestaurant, page, limit)
+	reservations, err := h.store.GetAllRestaurantReservations(idRestaurant, filter, page, limit)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -288,7 +419,7 @@ func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate status
-	validStatuses := []string{"pending", "completed", "cancelled"}
+	validStatuses := []string{"pending", "preparing", "ready", "completed", "cancelled"}
 	isValid := false
 	for _, validStatus := range validStatuses {
 		if req.Status == validStatus {
@@ -297,7 +428,7 @@ func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !isValid {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid status. Valid statuses are: pending, completed, cancelled"))
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid status. Valid statuses are: pending, preparing, ready, completed, cancelled"))
 		return
 	}
 
@@ -315,6 +446,8 @@ func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.pushKitchenOrder(idOrder, "order_status_changed")
+
 	utils.WriteJson(w, http.StatusOK, map[string]string{
 		"message": fmt.Sprintf("Order status updated to %s successfully", req.Status),
 	})
@@ -421,18 +554,19 @@ func (h *Handler) CreateRestaurant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-	imageURL, err := utils.UploadImageToCloudinary(file)
+	image, err := utils.SaveImage(h.images, utils.ImageID("restaurants", idRestaurant), file)
 	if err != nil {
-		utils.WriteError(w, 500, err)
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
 		return
 	}
 
-	err = h.store.CreateRestaurant(idRestaurant, idAdminRestaurant, name, imageURL, longitude, latitude, description, capacity, location)
+	err = h.store.CreateRestaurant(idRestaurant, idAdminRestaurant, name, image.URL, longitude, latitude, description, capacity, location)
 	if err != nil {
+		utils.DeleteImages(h.images, image.PublicID)
 		utils.WriteError(w, 500, err)
 		return
 	}
-	utils.WriteJson(w, 201, map[string]string{"idRestaurant": idRestaurant, "image": imageURL})
+	utils.WriteJson(w, 201, map[string]string{"idRestaurant": idRestaurant, "image": image.URL})
 }
 
 func (h *Handler) GetFoodRestaurant(w http.ResponseWriter, r *http.Request) {
@@ -483,8 +617,12 @@ func (h *Handler) GetFoodsOfActiveMenu(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
 		return
 	}
-	foods, err := h.store.GetFoodsOfActiveMenu(idRestaurant)
+	foods, err := h.store.GetFoodsOfActiveMenu(idRestaurant, dietaryFilterFromQuery(r))
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -561,12 +699,38 @@ func (h *Handler) UpdateReservationStatus(w http.ResponseWriter, r *http.Request
 		return
 	}
 	if err := h.store.UpdateReservationStatus(id, req.Status); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Reservation status updated"})
 }
 
+// CheckInReservation marks the guests of a confirmed reservation as arrived
+func (h *Handler) CheckInReservation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["idReservation"]
+	if err := h.store.CheckInReservation(id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Reservation checked in"})
+}
+
 func (h *Handler) CreateNotification(w http.ResponseWriter, r *http.Request) {
 	var notif types.Notification
 	if err := utils.ParseJson(r, &notif); err != nil {
@@ -636,18 +800,22 @@ func (h *Handler) CreateRestaurantWorker(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var image *types.StoredImage
 	file, _, err := r.FormFile("image")
 	if err == nil {
 		defer file.Close()
-		imageURL, err := utils.UploadImageToCloudinary(file)
+		image, err = utils.SaveImage(h.images, utils.ImageID("workers", id), file)
 		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, err)
+			utils.WriteError(w, utils.ImageErrorStatus(err), err)
 			return
 		}
-		worker.Image = imageURL
+		worker.Image = image.URL
 	}
 
 	if err := h.store.CreateRestaurantWorker(id, idRestaurant, worker); err != nil {
+		if image != nil {
+			utils.DeleteImages(h.images, image.PublicID)
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -703,8 +871,17 @@ func (h *Handler) DeleteFood(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Food deleted"})
 }
 
+// GetFoodCategoriesByRestaurant returns the categories of a restaurant, from the path or ?idRestaurant=
 func (h *Handler) GetFoodCategoriesByRestaurant(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.store.GetFoodCategoriesByRestaurant()
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		idRestaurant = r.URL.Query().Get("idRestaurant")
+	}
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	categories, err := h.store.GetFoodCategoriesByRestaurant(idRestaurant)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -714,18 +891,34 @@ func (h *Handler) GetFoodCategoriesByRestaurant(w http.ResponseWriter, r *http.R
 
 func (h *Handler) CreateFoodCategory(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		NameCategorie string `json:"nameCategorie"`
+		IdRestaurant  string  `json:"idRestaurant"`
+		NameCategorie string  `json:"nameCategorie"`
+		Image         *string `json:"image"`
 	}
 	if err := utils.ParseJson(r, &req); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	if req.IdRestaurant == "" || req.NameCategorie == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and nameCategorie are required"))
+		return
+	}
 	idCategory, err := utils.CreateAnId()
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	if err := h.store.CreateFoodCategory(idCategory, req.NameCategorie); err != nil {
+	category := types.FoodCategory{
+		IdCategory:    idCategory,
+		IdRestaurant:  req.IdRestaurant,
+		NameCategorie: req.NameCategorie,
+		Image:         req.Image,
+	}
+	if err := h.store.CreateFoodCategory(category); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -797,16 +990,21 @@ func (h *Handler) CreateFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-	imageURL, err := utils.UploadImageToCloudinary(file)
+	image, err := utils.SaveImage(h.images, utils.ImageID("foods", idFood), file)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
 		return
 	}
-	if err := h.store.CreateFood(idFood, idCategory, idRestaurant, name, description, imageURL, price, status); err != nil {
+	if err := h.store.CreateFood(idFood, idCategory, idRestaurant, name, description, image.URL, price, status); err != nil {
+		utils.DeleteImages(h.images, image.PublicID)
+		if strings.Contains(err.Error(), "does not belong") {
+			utils.WriteError(w, http.StatusForbidden, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	utils.WriteJson(w, http.StatusCreated, map[string]string{"idFood": idFood, "image": imageURL})
+	utils.WriteJson(w, http.StatusCreated, map[string]string{"idFood": idFood, "image": image.URL})
 }
 
 func (h *Handler) UpdateFood(w http.ResponseWriter, r *http.Request) {
@@ -817,6 +1015,10 @@ func (h *Handler) UpdateFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := h.store.UpdateFood(idFood, food); err != nil {
+		if strings.Contains(err.Error(), "does not belong") {
+			utils.WriteError(w, http.StatusForbidden, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -1009,7 +1211,12 @@ func (h *Handler) GetOrderStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch order stats
-	orderStatsByHour, orderStatsByStatus, err := h.store.GetOrderStatsByHourAndStatus(restaurantId)
+	orderStatsByHour, orderStatsByStatus, err := h.store.GetOrderStatsByHourAndStatus(restaurantId, "dine_in")
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	takeawayStatsByHour, takeawayStatsByStatus, err := h.store.GetOrderStatsByHourAndStatus(restaurantId, "takeaway")
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -1035,9 +1242,11 @@ func (h *Handler) GetOrderStats(w http.ResponseWriter, r *http.Request) {
 
 	// Combine stats and recent orders in the response
 	utils.WriteJson(w, http.StatusOK, map[string]interface{}{
-		"hourlyStats":  orderStatsByHour,
-		"statusStats":  orderStatsByStatus,
-		"recentOrders": recentOrders,
+		"hourlyStats":         orderStatsByHour,
+		"statusStats":         orderStatsByStatus,
+		"recentOrders":        recentOrders,
+		"takeawayHourlyStats": takeawayStatsByHour,
+		"takeawayStatusStats": takeawayStatsByStatus,
 	})
 }
 
@@ -1095,7 +1304,7 @@ func (h *Handler) GetAvailableMenuInformation(w http.ResponseWriter, r *http.Req
 		utils.WriteError(w, http.StatusBadRequest, errors.New("restaurantId is required"))
 		return
 	}
-	menu, err := h.store.GetAvailableMenuInformation(restaurantId)
+	menu, err := h.store.GetAvailableMenuInformation(restaurantId, dietaryFilterFromQuery(r))
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -1127,10 +1336,20 @@ func (h *Handler) PostOrderClient(w http.ResponseWriter, r *http.Request) {
 	log.Println("Order ID:", idOrder)
 	err = h.store.PostOrderList(idOrder, orderCreation.Foods)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "not available"):
+			utils.WriteError(w, http.StatusConflict, err)
+		case strings.Contains(err.Error(), "invalid options"), strings.Contains(err.Error(), "invalid quantity"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
 		return
 	}
 	log.Println("Order list posted successfully")
+	h.pushKitchenOrder(idOrder, "order_created")
 
 	utils.WriteJson(w, http.StatusCreated, "Success modifying price")
 }
@@ -1237,6 +1456,10 @@ func (h *Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
 
 	err = h.store.CreateReservation(idReservation, reservation)
 	if err != nil {
+		if strings.Contains(err.Error(), "blocked") {
+			utils.WriteError(w, http.StatusForbidden, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -1360,14 +1583,59 @@ func (h *Handler) PostReviewRestaurant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	review.IdRating = idReview
+	review.Comment, _ = utils.FilterProfanity(review.Comment)
 	err = h.store.PostRatingRestaurant(review)
 	if err != nil {
+		if strings.Contains(err.Error(), "no completed reservation") {
+			utils.WriteError(w, http.StatusForbidden, err)
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 	utils.WriteJson(w, http.StatusCreated, map[string]string{"message": "Review posted successfully"})
 }
 
+// PostReviewWorker lets a client rate the worker who served one of their completed visits
+func (h *Handler) PostReviewWorker(w http.ResponseWriter, r *http.Request) {
+	var review types.PostRatingWorker
+	if err := utils.ParseJson(r, &review); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if review.IdClient == "" || (review.IdReservation == nil && review.IdOrder == nil) {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("idClient and either idReservation or idOrder are required"))
+		return
+	}
+	if review.RatingValue < 1 || review.RatingValue > 5 {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("rating must be between 1 and 5"))
+		return
+	}
+	idReview, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	review.IdRating = idReview
+	review.Comment, _ = utils.FilterProfanity(review.Comment)
+	if err := h.store.PostRatingWorker(&review); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "does not belong"):
+			utils.WriteError(w, http.StatusForbidden, err)
+		case strings.Contains(err.Error(), "table session"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		case strings.Contains(err.Error(), "cannot"), strings.Contains(err.Error(), "already"):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, review)
+}
+
 func (h *Handler) GetFriendsReviewsRestaurant(w http.ResponseWriter, r *http.Request) {
 	var rating types.FriendsReviewsRestaruant
 	if err := utils.ParseJson(r, &rating); err != nil {
@@ -1435,3 +1703,1589 @@ func (h *Handler) GetRestaurantTodaySummary(w http.ResponseWriter, r *http.Reque
 	}
 	utils.WriteJson(w, http.StatusOK, summary)
 }
+
+// GetReservationPolicy returns the no-show and slot policy of a restaurant
+func (h *Handler) GetReservationPolicy(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	policy, err := h.store.GetReservationPolicy(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, policy)
+}
+
+// UpdateReservationPolicy sets the no-show and slot policy of a restaurant
+func (h *Handler) UpdateReservationPolicy(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var policy types.ReservationPolicy
+	if err := utils.ParseJson(r, &policy); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	policy.IdRestaurant = idRestaurant
+
+	if policy.GracePeriodMinutes < 0 || policy.SlotDurationMinutes <= 0 || policy.NoShowLimit < 0 ||
+		policy.NoShowWindowDays <= 0 || policy.BlockDays < 0 || policy.ReminderHoursBefore < 0 ||
+		policy.ModificationCutoffMinutes < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid policy values"))
+		return
+	}
+
+	if err := h.store.UpsertReservationPolicy(policy); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, policy)
+}
+
+// GetClientNoShowSummary returns the no-show count of a client, optionally scoped to a restaurant
+func (h *Handler) GetClientNoShowSummary(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+	idRestaurant := r.URL.Query().Get("restaurantId")
+
+	summary, err := h.store.GetClientNoShowSummary(idClient, idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, summary)
+}
+
+// GetClientEmailPreferences returns which reservation emails a client receives
+func (h *Handler) GetClientEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	prefs, err := h.store.GetClientEmailPreferences(idClient)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, prefs)
+}
+
+// UpdateClientEmailPreferences lets a client opt in or out of reservation emails and reminders
+func (h *Handler) UpdateClientEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	var prefs types.ClientEmailPreferences
+	if err := utils.ParseJson(r, &prefs); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	prefs.IdClient = idClient
+
+	if err := h.store.UpdateClientEmailPreferences(prefs); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, prefs)
+}
+
+// Helper function to map client reservation change errors to status codes
+func writeReservationChangeError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(msg, "does not belong"), strings.Contains(msg, "cutoff"):
+		utils.WriteError(w, http.StatusForbidden, err)
+	case strings.Contains(msg, "not available"), strings.Contains(msg, "already has"), strings.Contains(msg, "only pending"):
+		utils.WriteError(w, http.StatusConflict, err)
+	case strings.Contains(msg, "must be"), strings.Contains(msg, "nothing to change"):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// ModifyReservationByClient lets a client reschedule a reservation or change its party size
+func (h *Handler) ModifyReservationByClient(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idClient := vars["idClient"]
+	idReservation := vars["idReservation"]
+	if idClient == "" || idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient and idReservation are required"))
+		return
+	}
+
+	var modification types.ReservationModification
+	if err := utils.ParseJson(r, &modification); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if modification.TimeFrom == nil && modification.NumberOfPeople == nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("timeFrom or numberOfPeople is required"))
+		return
+	}
+	if modification.NumberOfPeople != nil && *modification.NumberOfPeople <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("numberOfPeople must be greater than 0"))
+		return
+	}
+
+	entry, err := h.store.ModifyReservationByClient(idReservation, idClient, modification)
+	if err != nil {
+		writeReservationChangeError(w, err)
+		return
+	}
+
+	broadcastDashboardEvent(entry.IdRestaurant, "reservation_modified", entry)
+	utils.WriteJson(w, http.StatusOK, entry)
+}
+
+// CancelReservationByClient lets a client cancel their own reservation
+func (h *Handler) CancelReservationByClient(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idClient := vars["idClient"]
+	idReservation := vars["idReservation"]
+	if idClient == "" || idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient and idReservation are required"))
+		return
+	}
+
+	entry, err := h.store.CancelReservationByClient(idReservation, idClient)
+	if err != nil {
+		writeReservationChangeError(w, err)
+		return
+	}
+
+	broadcastDashboardEvent(entry.IdRestaurant, "reservation_cancelled", entry)
+	utils.WriteJson(w, http.StatusOK, entry)
+}
+
+// GetReservationHistory returns every recorded change of a reservation
+func (h *Handler) GetReservationHistory(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	history, err := h.store.GetReservationHistory(idReservation)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, history)
+}
+
+// InviteFriendsToReservation lets the host invite friends to a reservation
+func (h *Handler) InviteFriendsToReservation(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	var req struct {
+		IdClient string   `json:"idClient"`
+		Friends  []string `json:"friends"`
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.IdClient == "" || len(req.Friends) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient and friends are required"))
+		return
+	}
+
+	guests, err := h.store.InviteFriendsToReservation(idReservation, req.IdClient, req.Friends)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "does not belong"), strings.Contains(err.Error(), "friend list"):
+			utils.WriteError(w, http.StatusForbidden, err)
+		case strings.Contains(err.Error(), "only pending"):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, guests)
+}
+
+func (h *Handler) GetReservationGuests(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	guests, err := h.store.GetReservationGuests(idReservation)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, guests)
+}
+
+// RespondToReservationInvitation lets an invited friend accept or decline, or leave after accepting
+func (h *Handler) RespondToReservationInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idReservation := vars["idReservation"]
+	idClient := vars["idClient"]
+	if idReservation == "" || idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation and idClient are required"))
+		return
+	}
+
+	var req struct {
+		Response string `json:"response"` // "accepted" or "declined"
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Response != "accepted" && req.Response != "declined" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("response must be either 'accepted' or 'declined'"))
+		return
+	}
+
+	entry, err := h.store.RespondToReservationInvitation(idReservation, idClient, req.Response)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "invalid invitation response"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		case strings.Contains(err.Error(), "not available"), strings.Contains(err.Error(), "only pending"):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if entry.NewNumberOfPeople != nil {
+		broadcastDashboardEvent(entry.IdRestaurant, "reservation_party_size_changed", entry)
+	}
+	utils.WriteJson(w, http.StatusOK, entry)
+}
+
+// GetClientReservationInvitations lists the reservations a client was invited to and has not answered
+func (h *Handler) GetClientReservationInvitations(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	invitations, err := h.store.GetClientReservationInvitations(idClient)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, invitations)
+}
+
+// AddGuestItemsToOrder adds a guest's own items to the shared order of a reservation
+func (h *Handler) AddGuestItemsToOrder(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	var req struct {
+		IdClient string           `json:"idClient"`
+		Foods    []types.FoodItem `json:"food"`
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.IdClient == "" || len(req.Foods) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient and food are required"))
+		return
+	}
+	for _, food := range req.Foods {
+		if food.IdFood == "" || food.Quantity <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("each food needs an idFood and a positive quantity"))
+			return
+		}
+	}
+
+	idOrder, err := h.store.AddGuestItemsToOrder(idReservation, req.IdClient, req.Foods)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "does not belong"):
+			utils.WriteError(w, http.StatusForbidden, err)
+		case strings.Contains(err.Error(), "only pending"), strings.Contains(err.Error(), "already completed"), strings.Contains(err.Error(), "not available"):
+			utils.WriteError(w, http.StatusConflict, err)
+		case strings.Contains(err.Error(), "invalid options"), strings.Contains(err.Error(), "invalid quantity"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	h.pushKitchenOrder(idOrder, "order_updated")
+
+	utils.WriteJson(w, http.StatusCreated, map[string]string{"idOrder": idOrder})
+}
+
+func (h *Handler) GetBillingSettings(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	settings, err := h.store.GetBillingSettings(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+// UpdateBillingSettings sets the tax and service charge rates of a restaurant
+func (h *Handler) UpdateBillingSettings(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var settings types.BillingSettings
+	if err := utils.ParseJson(r, &settings); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	settings.IdRestaurant = idRestaurant
+	if settings.TaxRate < 0 || settings.TaxRate > 100 || settings.ServiceChargeRate < 0 || settings.ServiceChargeRate > 100 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("taxRate and serviceChargeRate must be between 0 and 100"))
+		return
+	}
+
+	if err := h.store.UpsertBillingSettings(settings); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+// AssignOrderLine attributes an order line to another participant of the reservation
+func (h *Handler) AssignOrderLine(w http.ResponseWriter, r *http.Request) {
+	idOrder := mux.Vars(r)["idOrder"]
+	if idOrder == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idOrder is required"))
+		return
+	}
+
+	var req struct {
+		IdFood     string `json:"idFood"`
+		FromClient string `json:"fromClient"` // empty for the host's lines
+		ToClient   string `json:"toClient"`
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.IdFood == "" || req.ToClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood and toClient are required"))
+		return
+	}
+
+	if err := h.store.AssignOrderLine(idOrder, req.IdFood, req.FromClient, req.ToClient); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "does not belong"):
+			utils.WriteError(w, http.StatusForbidden, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Order line assigned"})
+}
+
+// Helper function to map bill errors to status codes
+func writeBillError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(err.Error(), "invalid bill mode"), strings.Contains(err.Error(), "custom amounts"),
+		strings.Contains(err.Error(), "does not belong"):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// GetReservationBill renders the current bill of a reservation, split evenly or by item
+func (h *Handler) GetReservationBill(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "even"
+	}
+	if mode == "custom" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("custom splits need amounts, use POST"))
+		return
+	}
+
+	bill, err := h.store.ComputeReservationBill(idReservation, types.BillRequest{Mode: mode})
+	if err != nil {
+		writeBillError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, bill)
+}
+
+// CreateReservationBill generates and stores the bill of a reservation
+func (h *Handler) CreateReservationBill(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	var req types.BillRequest
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = "even"
+	}
+
+	bill, err := h.store.SaveReservationBill(idReservation, req)
+	if err != nil {
+		writeBillError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, bill)
+}
+
+func (h *Handler) GetDepositPolicy(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	policy, err := h.store.GetDepositPolicy(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, policy)
+}
+
+// UpdateDepositPolicy sets when a restaurant asks for a deposit and which fees it keeps
+func (h *Handler) UpdateDepositPolicy(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var policy types.DepositPolicy
+	if err := utils.ParseJson(r, &policy); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	policy.IdRestaurant = idRestaurant
+	if policy.PeakDays == nil {
+		policy.PeakDays = []int{}
+	}
+
+	if policy.MinPartySize < 0 || policy.AmountPerPerson < 0 ||
+		policy.LateCancellationFeePercent < 0 || policy.LateCancellationFeePercent > 100 ||
+		policy.NoShowFeePercent < 0 || policy.NoShowFeePercent > 100 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid policy values"))
+		return
+	}
+	for _, day := range policy.PeakDays {
+		if day < 0 || day > 6 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("peak days must be between 0 (Sunday) and 6 (Saturday)"))
+			return
+		}
+	}
+	for _, slot := range []string{policy.PeakStartTime, policy.PeakEndTime} {
+		if slot == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", slot); err != nil || len(slot) != 5 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("peak times must use the HH:MM format"))
+			return
+		}
+	}
+	if (policy.PeakStartTime == "") != (policy.PeakEndTime == "") || policy.PeakStartTime > policy.PeakEndTime {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("peak slot needs a start time before its end time"))
+		return
+	}
+
+	if err := h.store.UpsertDepositPolicy(policy); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, policy)
+}
+
+func (h *Handler) GetReservationDeposit(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	deposit, err := h.store.GetReservationDeposit(idReservation)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if deposit == nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no deposit for reservation %s", idReservation))
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, deposit)
+}
+
+func writeTableSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(err.Error(), "invalid table code"), strings.Contains(err.Error(), "does not belong"):
+		utils.WriteError(w, http.StatusForbidden, err)
+	case strings.Contains(err.Error(), "already closed"), strings.Contains(err.Error(), "not available"):
+		utils.WriteError(w, http.StatusConflict, err)
+	case strings.Contains(err.Error(), "invalid options"), strings.Contains(err.Error(), "invalid quantity"):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// GetTableQrCode returns the token to print as the QR code of a table
+func (h *Handler) GetTableQrCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idTable"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idTable are required"))
+		return
+	}
+
+	code, err := h.store.GetTableQrCode(vars["idRestaurant"], vars["idTable"])
+	if err != nil {
+		writeTableSessionError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, code)
+}
+
+func (h *Handler) RotateTableQrCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idTable"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idTable are required"))
+		return
+	}
+
+	code, err := h.store.RotateTableQrCode(vars["idRestaurant"], vars["idTable"])
+	if err != nil {
+		writeTableSessionError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, code)
+}
+
+// OpenTableSession is called when a guest scans a table QR code. The guest then browses
+// /menu/actif/{restaurantId} with the returned idRestaurant and orders on the session.
+func (h *Handler) OpenTableSession(w http.ResponseWriter, r *http.Request) {
+	var req types.TableSessionOpening
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Token == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("token is required"))
+		return
+	}
+
+	session, err := h.store.OpenTableSession(req.Token)
+	if err != nil {
+		writeTableSessionError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, session)
+}
+
+func (h *Handler) GetTableSession(w http.ResponseWriter, r *http.Request) {
+	idTableSession := mux.Vars(r)["idTableSession"]
+	if idTableSession == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idTableSession is required"))
+		return
+	}
+
+	session, err := h.store.GetTableSession(idTableSession)
+	if err != nil {
+		writeTableSessionError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, session)
+}
+
+// PlaceTableSessionOrder places a walk-in order on a table session and sends it to the kitchen
+func (h *Handler) PlaceTableSessionOrder(w http.ResponseWriter, r *http.Request) {
+	idTableSession := mux.Vars(r)["idTableSession"]
+	if idTableSession == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idTableSession is required"))
+		return
+	}
+
+	var req types.TableSessionOrderCreation
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Token == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("token is required"))
+		return
+	}
+	if len(req.Foods) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("at least one food is required"))
+		return
+	}
+	for _, food := range req.Foods {
+		if food.IdFood == "" || food.Quantity <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("each food needs an idFood and a positive quantity"))
+			return
+		}
+	}
+
+	idOrder, err := h.store.PlaceTableSessionOrder(idTableSession, req.Token, req.Foods)
+	if err != nil {
+		writeTableSessionError(w, err)
+		return
+	}
+	h.pushKitchenOrder(idOrder, "order_created")
+
+	utils.WriteJson(w, http.StatusCreated, map[string]string{"idOrder": idOrder})
+}
+
+// CloseTableSession closes a session settled at the counter; card payments close it on their own
+func (h *Handler) CloseTableSession(w http.ResponseWriter, r *http.Request) {
+	idTableSession := mux.Vars(r)["idTableSession"]
+	if idTableSession == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idTableSession is required"))
+		return
+	}
+
+	if err := h.store.CloseTableSession(idTableSession); err != nil {
+		writeTableSessionError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Table session closed"})
+}
+
+func (h *Handler) GetTakeawaySettings(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	settings, err := h.store.GetTakeawaySettings(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+// UpdateTakeawaySettings turns takeaway on or off and sets the pickup window capacity
+func (h *Handler) UpdateTakeawaySettings(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var settings types.TakeawaySettings
+	if err := utils.ParseJson(r, &settings); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	settings.IdRestaurant = idRestaurant
+
+	if settings.WindowMinutes <= 0 || settings.MaxOrdersPerWindow <= 0 || settings.MinLeadMinutes < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid takeaway settings"))
+		return
+	}
+	first, err := time.Parse("15:04", settings.FirstPickupTime)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("firstPickupTime must use the HH:MM format"))
+		return
+	}
+	last, err := time.Parse("15:04", settings.LastPickupTime)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("lastPickupTime must use the HH:MM format"))
+		return
+	}
+	if !first.Before(last) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("firstPickupTime must be before lastPickupTime"))
+		return
+	}
+	settings.FirstPickupTime = first.Format("15:04")
+	settings.LastPickupTime = last.Format("15:04")
+
+	if err := h.store.UpsertTakeawaySettings(settings); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+// Helper function to read the optional ?date=YYYY-MM-DD parameter, today by default
+func takeawayDay(r *http.Request) (time.Time, error) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		return time.Now(), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must use the YYYY-MM-DD format")
+	}
+	return day, nil
+}
+
+// GetTakeawayWindows lists the pickup windows of a day and how many orders each one still accepts
+func (h *Handler) GetTakeawayWindows(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	day, err := takeawayDay(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	windows, err := h.store.GetTakeawayWindows(idRestaurant, day)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, windows)
+}
+
+func (h *Handler) GetRestaurantTakeawayOrders(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	day, err := takeawayDay(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	orders, err := h.store.GetRestaurantTakeawayOrders(idRestaurant, day)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, orders)
+}
+
+// CreateTakeawayOrder places a takeaway order directly against a restaurant
+func (h *Handler) CreateTakeawayOrder(w http.ResponseWriter, r *http.Request) {
+	var order types.TakeawayOrderCreation
+	if err := utils.ParseJson(r, &order); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if order.IdClient == "" || order.IdRestaurant == "" || order.PickupTime.IsZero() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient, idRestaurant and pickupTime are required"))
+		return
+	}
+	if len(order.Foods) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("at least one food is required"))
+		return
+	}
+	for _, food := range order.Foods {
+		if food.IdFood == "" || food.Quantity <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("each food needs an idFood and a positive quantity"))
+			return
+		}
+	}
+
+	idOrder, err := h.store.CreateTakeawayOrder(order)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "is full"), strings.Contains(err.Error(), "not available"):
+			utils.WriteError(w, http.StatusConflict, err)
+		case strings.Contains(err.Error(), "pickup time"), strings.Contains(err.Error(), "invalid options"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	h.pushKitchenOrder(idOrder, "order_created")
+
+	utils.WriteJson(w, http.StatusCreated, map[string]string{"idOrder": idOrder})
+}
+
+func (h *Handler) GetClientTakeawayOrders(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	orders, err := h.store.GetClientTakeawayOrders(idClient)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, orders)
+}
+
+func (h *Handler) GetMenuSchedules(w http.ResponseWriter, r *http.Request) {
+	idMenu := mux.Vars(r)["idMenu"]
+	if idMenu == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idMenu is required"))
+		return
+	}
+
+	schedules, err := h.store.GetMenuSchedules(idMenu)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, schedules)
+}
+
+func (h *Handler) CreateMenuSchedule(w http.ResponseWriter, r *http.Request) {
+	idMenu := mux.Vars(r)["idMenu"]
+	if idMenu == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idMenu is required"))
+		return
+	}
+
+	var schedule types.MenuSchedule
+	if err := utils.ParseJson(r, &schedule); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	start, err := time.Parse("15:04", schedule.StartTime)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("startTime must use the HH:MM format"))
+		return
+	}
+	end, err := time.Parse("15:04", schedule.EndTime)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("endTime must use the HH:MM format"))
+		return
+	}
+	if start.Equal(end) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("startTime and endTime must differ"))
+		return
+	}
+	for _, weekday := range schedule.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("weekdays must be between 0 (Sunday) and 6 (Saturday)"))
+			return
+		}
+	}
+	for _, date := range []*string{schedule.ValidFrom, schedule.ValidTo} {
+		if date == nil {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", *date); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("validFrom and validTo must use the YYYY-MM-DD format"))
+			return
+		}
+	}
+	if schedule.ValidFrom != nil && schedule.ValidTo != nil && *schedule.ValidFrom > *schedule.ValidTo {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("validFrom must not be after validTo"))
+		return
+	}
+
+	idSchedule, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	schedule.IdSchedule = idSchedule
+	schedule.IdMenu = idMenu
+	schedule.StartTime = start.Format("15:04")
+	schedule.EndTime = end.Format("15:04")
+	schedule.CreatedAt = time.Now()
+	if schedule.Weekdays == nil {
+		schedule.Weekdays = []int{}
+	}
+
+	if err := h.store.CreateMenuSchedule(schedule); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, schedule)
+}
+
+func (h *Handler) DeleteMenuSchedule(w http.ResponseWriter, r *http.Request) {
+	idMenu := mux.Vars(r)["idMenu"]
+	idSchedule := mux.Vars(r)["idSchedule"]
+	if idMenu == "" || idSchedule == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idMenu and idSchedule are required"))
+		return
+	}
+
+	if err := h.store.DeleteMenuSchedule(idMenu, idSchedule); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Menu schedule deleted"})
+}
+
+func (h *Handler) GetCurrentMenu(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	active, err := h.store.GetCurrentMenu(idRestaurant)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, active)
+}
+
+func (h *Handler) SetMenuOverride(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var override types.MenuOverrideCreation
+	if err := utils.ParseJson(r, &override); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if override.IdMenu == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idMenu is required"))
+		return
+	}
+	if override.Until != nil && !override.Until.After(time.Now()) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("until must be in the future"))
+		return
+	}
+
+	if err := h.store.SetMenuOverride(idRestaurant, override.IdMenu, override.Until); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	active, err := h.store.GetCurrentMenu(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, active)
+}
+
+func (h *Handler) ClearMenuOverride(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	if err := h.store.ClearMenuOverride(idRestaurant); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Menu override cleared"})
+}
+
+// GetFoodCustomization returns the option groups of a food and the foods a combo is made of
+func (h *Handler) GetFoodCustomization(w http.ResponseWriter, r *http.Request) {
+	idFood := mux.Vars(r)["idFood"]
+	if idFood == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood is required"))
+		return
+	}
+
+	customization, err := h.store.GetFoodCustomization(idFood)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, customization)
+}
+
+func (h *Handler) CreateFoodOptionGroup(w http.ResponseWriter, r *http.Request) {
+	idFood := mux.Vars(r)["idFood"]
+	if idFood == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood is required"))
+		return
+	}
+
+	var group types.FoodOptionGroup
+	if err := utils.ParseJson(r, &group); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if group.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name is required"))
+		return
+	}
+	if group.MinSelect < 0 || group.MaxSelect < 1 || group.MinSelect > group.MaxSelect {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("minSelect must be between 0 and maxSelect, and maxSelect at least 1"))
+		return
+	}
+
+	idGroup, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	group.IdGroup = idGroup
+	group.IdFood = idFood
+	group.Options = []types.FoodOption{}
+
+	if err := h.store.CreateFoodOptionGroup(group); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, group)
+}
+
+func (h *Handler) DeleteFoodOptionGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idFood"] == "" || vars["idGroup"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood and idGroup are required"))
+		return
+	}
+
+	if err := h.store.DeleteFoodOptionGroup(vars["idFood"], vars["idGroup"]); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Option group deleted"})
+}
+
+func (h *Handler) CreateFoodOption(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idFood"] == "" || vars["idGroup"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood and idGroup are required"))
+		return
+	}
+
+	// Options are available unless said otherwise
+	option := types.FoodOption{Available: true}
+	if err := utils.ParseJson(r, &option); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if option.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name is required"))
+		return
+	}
+
+	idOption, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	option.IdOption = idOption
+	option.IdGroup = vars["idGroup"]
+
+	if err := h.store.CreateFoodOption(vars["idFood"], option); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, option)
+}
+
+func (h *Handler) UpdateFoodOption(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idFood"] == "" || vars["idOption"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood and idOption are required"))
+		return
+	}
+
+	var option types.FoodOption
+	if err := utils.ParseJson(r, &option); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if option.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name is required"))
+		return
+	}
+	option.IdOption = vars["idOption"]
+
+	if err := h.store.UpdateFoodOption(vars["idFood"], option); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, option)
+}
+
+func (h *Handler) DeleteFoodOption(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idFood"] == "" || vars["idOption"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood and idOption are required"))
+		return
+	}
+
+	if err := h.store.DeleteFoodOption(vars["idFood"], vars["idOption"]); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Option deleted"})
+}
+
+// SetComboItems replaces the foods served with a combo, an empty list makes it a plain food again
+func (h *Handler) SetComboItems(w http.ResponseWriter, r *http.Request) {
+	idFood := mux.Vars(r)["idFood"]
+	if idFood == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood is required"))
+		return
+	}
+
+	var combo types.ComboUpdate
+	if err := utils.ParseJson(r, &combo); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, item := range combo.Items {
+		if item.IdFood == "" || item.Quantity <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("each combo item needs an idFood and a positive quantity"))
+			return
+		}
+		if seen[item.IdFood] {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("food %s is listed twice", item.IdFood))
+			return
+		}
+		seen[item.IdFood] = true
+	}
+
+	if err := h.store.SetComboItems(idFood, combo.Items); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "does not belong"):
+			utils.WriteError(w, http.StatusForbidden, err)
+		case strings.Contains(err.Error(), "invalid combo"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	customization, err := h.store.GetFoodCustomization(idFood)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, customization)
+}
+
+// Helper function to read the menu filters: ?require=vegan,halal&exclude=peanuts&idClient=
+func dietaryFilterFromQuery(r *http.Request) types.DietaryFilter {
+	split := func(value string) []string {
+		var tags []string
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		return tags
+	}
+	query := r.URL.Query()
+	return types.DietaryFilter{
+		Require:  split(query.Get("require")),
+		Exclude:  split(query.Get("exclude")),
+		IdClient: query.Get("idClient"),
+	}
+}
+
+func (h *Handler) GetFoodTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.store.GetFoodTags()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, tags)
+}
+
+func (h *Handler) CreateFoodTag(w http.ResponseWriter, r *http.Request) {
+	var tag types.FoodTag
+	if err := utils.ParseJson(r, &tag); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if tag.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("name is required"))
+		return
+	}
+	if tag.Kind != "allergen" && tag.Kind != "dietary" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("kind must be allergen or dietary"))
+		return
+	}
+
+	idTag, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	tag.IdTag = idTag
+
+	if err := h.store.CreateFoodTag(tag); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, tag)
+}
+
+// SetFoodTags replaces the allergens and dietary labels of a food
+func (h *Handler) SetFoodTags(w http.ResponseWriter, r *http.Request) {
+	idFood := mux.Vars(r)["idFood"]
+	if idFood == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idFood is required"))
+		return
+	}
+
+	var update types.FoodTagsUpdate
+	if err := utils.ParseJson(r, &update); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SetFoodTags(idFood, update.Tags); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, update)
+}
+
+// GetClientDietaryPreferences returns the allergens a client avoids and the labels they need
+func (h *Handler) GetClientDietaryPreferences(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	prefs, err := h.store.GetClientDietaryPreferences(idClient)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, prefs)
+}
+
+// UpdateClientDietaryPreferences sets which foods are flagged, or hidden, on the menus a client reads
+func (h *Handler) UpdateClientDietaryPreferences(w http.ResponseWriter, r *http.Request) {
+	idClient := mux.Vars(r)["idClient"]
+	if idClient == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idClient is required"))
+		return
+	}
+
+	var prefs types.ClientDietaryPreferences
+	if err := utils.ParseJson(r, &prefs); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	prefs.IdClient = idClient
+	if prefs.AvoidAllergens == nil {
+		prefs.AvoidAllergens = []string{}
+	}
+	if prefs.RequireLabels == nil {
+		prefs.RequireLabels = []string{}
+	}
+
+	if err := h.store.UpdateClientDietaryPreferences(prefs); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "invalid dietary preferences"):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, prefs)
+}
+
+func (h *Handler) UpdateFoodCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idCategory"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idCategory are required"))
+		return
+	}
+
+	var req struct {
+		NameCategorie string `json:"nameCategorie"`
+	}
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.NameCategorie == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("nameCategorie is required"))
+		return
+	}
+
+	if err := h.store.UpdateFoodCategory(vars["idRestaurant"], vars["idCategory"], req.NameCategorie); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "already exists"):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Category updated"})
+}
+
+// SetFoodCategoryImage uploads the image shown for a category on the menu
+func (h *Handler) SetFoodCategoryImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idCategory"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idCategory are required"))
+		return
+	}
+	// The image is saved under the category ID, so the category must be the restaurant's before anything is uploaded
+	if _, err := h.store.GetFoodCategoryImage(vars["idRestaurant"], vars["idCategory"]); err != nil {
+		writeImageOwnerError(w, err)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer file.Close()
+	image, err := utils.SaveImage(h.images, utils.ImageID("food-categories", vars["idCategory"]), file)
+	if err != nil {
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
+		return
+	}
+
+	previous, err := h.store.SetFoodCategoryImage(vars["idRestaurant"], vars["idCategory"], image.URL)
+	if err != nil {
+		// The category was removed during the upload, the image saved under its ID has no owner left
+		if strings.Contains(err.Error(), "not found") {
+			utils.DeleteImages(h.images, image.PublicID)
+		}
+		writeImageOwnerError(w, err)
+		return
+	}
+	utils.DeleteReplacedImage(h.images, previous, image.PublicID)
+	utils.WriteJson(w, http.StatusOK, map[string]string{"image": image.URL})
+}
+
+// ReorderFoodCategories sets the order categories appear in on the restaurant's menu
+func (h *Handler) ReorderFoodCategories(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var order types.FoodCategoryOrder
+	if err := utils.ParseJson(r, &order); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(order.IdCategories) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idCategories is required"))
+		return
+	}
+
+	if err := h.store.ReorderFoodCategories(idRestaurant, order.IdCategories); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	categories, err := h.store.GetFoodCategoriesByRestaurant(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, categories)
+}
+
+func (h *Handler) DeleteFoodCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idCategory"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idCategory are required"))
+		return
+	}
+
+	if err := h.store.DeleteFoodCategory(vars["idRestaurant"], vars["idCategory"]); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.WriteError(w, http.StatusNotFound, err)
+		case strings.Contains(err.Error(), "cannot"):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Category deleted"})
+}
+
+// Helper function to map server assignment errors to status codes
+func writeServerAssignmentError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(err.Error(), "does not belong"):
+		utils.WriteError(w, http.StatusForbidden, err)
+	case strings.Contains(err.Error(), "cannot"):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// AssignReservationServer sets the worker serving a reservation
+func (h *Handler) AssignReservationServer(w http.ResponseWriter, r *http.Request) {
+	idReservation := mux.Vars(r)["idReservation"]
+	if idReservation == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idReservation is required"))
+		return
+	}
+
+	var assignment types.ServerAssignment
+	if err := utils.ParseJson(r, &assignment); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.AssignReservationServer(idReservation, assignment.IdRestaurantWorker); err != nil {
+		writeServerAssignmentError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Reservation server updated"})
+}
+
+// AssignOrderServer sets the worker serving an order
+func (h *Handler) AssignOrderServer(w http.ResponseWriter, r *http.Request) {
+	idOrder := mux.Vars(r)["idOrder"]
+	if idOrder == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idOrder is required"))
+		return
+	}
+
+	var assignment types.ServerAssignment
+	if err := utils.ParseJson(r, &assignment); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.AssignOrderServer(idOrder, assignment.IdRestaurantWorker); err != nil {
+		writeServerAssignmentError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Order server updated"})
+}
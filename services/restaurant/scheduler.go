@@ -0,0 +1,42 @@
+package restaurant
+
+import (
+	"log"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// Scheduler periodically closes elapsed reservations: unconfirmed ones expire,
+// missed ones become no-shows and checked-in ones are completed once their slot is over.
+type Scheduler struct {
+	store    types.RestaurantStore
+	interval time.Duration
+}
+
+func NewScheduler(store types.RestaurantStore, interval time.Duration) *Scheduler {
+	return &Scheduler{store: store, interval: interval}
+}
+
+// Start runs the sweep in the background, once right away and then on every tick
+func (s *Scheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *Scheduler) sweep() {
+	result, err := s.store.SweepElapsedReservations(time.Now())
+	if err != nil {
+		log.Printf("Error sweeping elapsed reservations: %v", err)
+		return
+	}
+	if len(result.NoShows) > 0 || result.Expired > 0 || result.Completed > 0 {
+		log.Printf("Reservation sweep: %d no-show(s), %d expired, %d completed", len(result.NoShows), result.Expired, result.Completed)
+	}
+}
@@ -0,0 +1,163 @@
+package restaurant
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Helper function to read the search parameters, rejecting values that cannot be searched with
+func parseRestaurantSearch(r *http.Request) (types.RestaurantSearch, error) {
+	query := r.URL.Query()
+	search := types.RestaurantSearch{
+		Query: strings.TrimSpace(query.Get("q")),
+		Sort:  query.Get("sort"),
+		Page:  1,
+		Limit: defaultSearchLimit,
+	}
+
+	if value := query.Get("openNow"); value != "" {
+		openNow, err := strconv.ParseBool(value)
+		if err != nil {
+			return search, fmt.Errorf("openNow must be true or false")
+		}
+		search.OpenNow = openNow
+	}
+
+	numbers := []struct {
+		name   string
+		target **float64
+	}{
+		{"minRating", &search.MinRating},
+		{"lat", &search.Latitude},
+		{"lng", &search.Longitude},
+		{"radiusKm", &search.RadiusKm},
+		{"minPrice", &search.MinPrice},
+		{"maxPrice", &search.MaxPrice},
+	}
+	for _, number := range numbers {
+		value := query.Get(number.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return search, fmt.Errorf("%s must be a number", number.name)
+		}
+		*number.target = &parsed
+	}
+
+	if search.MinRating != nil && (*search.MinRating < 0 || *search.MinRating > 5) {
+		return search, fmt.Errorf("minRating must be between 0 and 5")
+	}
+	if (search.Latitude == nil) != (search.Longitude == nil) {
+		return search, fmt.Errorf("lat and lng must be given together")
+	}
+	if search.Latitude != nil && (*search.Latitude < -90 || *search.Latitude > 90 || *search.Longitude < -180 || *search.Longitude > 180) {
+		return search, fmt.Errorf("lat must be between -90 and 90 and lng between -180 and 180")
+	}
+	if search.RadiusKm != nil {
+		if search.Latitude == nil {
+			return search, fmt.Errorf("radiusKm needs lat and lng")
+		}
+		if *search.RadiusKm <= 0 {
+			return search, fmt.Errorf("radiusKm must be positive")
+		}
+	}
+	if (search.MinPrice != nil && *search.MinPrice < 0) || (search.MaxPrice != nil && *search.MaxPrice < 0) {
+		return search, fmt.Errorf("prices must not be negative")
+	}
+	if search.MinPrice != nil && search.MaxPrice != nil && *search.MinPrice > *search.MaxPrice {
+		return search, fmt.Errorf("minPrice must not be above maxPrice")
+	}
+
+	for _, tag := range strings.Split(query.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			search.DietaryTags = append(search.DietaryTags, tag)
+		}
+	}
+
+	partySize, at := query.Get("partySize"), query.Get("at")
+	if (partySize == "") != (at == "") {
+		return search, fmt.Errorf("partySize and at must be given together")
+	}
+	if partySize != "" {
+		size, err := strconv.Atoi(partySize)
+		if err != nil || size < 1 {
+			return search, fmt.Errorf("partySize must be a positive number")
+		}
+		when, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			when, err = time.ParseInLocation("2006-01-02T15:04", at, time.Local)
+			if err != nil {
+				return search, fmt.Errorf("at must use the RFC3339 or YYYY-MM-DDTHH:MM format")
+			}
+		}
+		if when.Before(time.Now()) {
+			return search, fmt.Errorf("at must be in the future")
+		}
+		search.PartySize, search.At = size, &when
+	}
+
+	switch search.Sort {
+	case "":
+		search.Sort = "relevance"
+	case "relevance", "rating":
+	case "distance":
+		if search.Latitude == nil {
+			return search, fmt.Errorf("sorting by distance needs lat and lng")
+		}
+	default:
+		return search, fmt.Errorf("sort must be relevance, rating or distance")
+	}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return search, fmt.Errorf("page must be a positive number")
+		}
+		search.Page = page
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return search, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		search.Limit = limit
+	}
+	return search, nil
+}
+
+// SearchRestaurants looks restaurants up by ?q in their name, description, location and food names. They can be
+// narrowed with ?openNow, ?minRating, ?lat and ?lng with ?radiusKm, ?tags (dietary tags, comma separated, allergens
+// are refused), ?minPrice and ?maxPrice, and ?partySize with ?at for the ones able to take the party then. Results
+// are ranked by relevance, rating and distance together unless ?sort is rating or distance, 20 per page by default.
+func (h *Handler) SearchRestaurants(w http.ResponseWriter, r *http.Request) {
+	search, err := parseRestaurantSearch(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.store.SearchRestaurants(search)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid dietary tag") {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, results)
+}
@@ -0,0 +1,477 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+	"github.com/wael-boudissaa/zencitiBackend/utils"
+)
+
+// Longest period a template can be assigned over in one request
+const maxTemplateAssignmentDays = 92
+
+// Helper function to map scheduling errors to status codes
+func writeShiftError(w http.ResponseWriter, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case strings.Contains(err.Error(), "conflicts"),
+		strings.Contains(err.Error(), "cannot"),
+		strings.Contains(err.Error(), "already"),
+		strings.Contains(err.Error(), "not clocked in"):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// Helper function to read the ?from= and ?to= dates (YYYY-MM-DD, inclusive), the coming week by default.
+// The returned end is the start of the day after ?to=.
+func shiftPeriod(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := r.URL.Query().Get("from"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must use the YYYY-MM-DD format")
+		}
+		from = day
+	}
+	to := from.AddDate(0, 0, 7)
+	if value := r.URL.Query().Get("to"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must use the YYYY-MM-DD format")
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	return from, to, nil
+}
+
+func (h *Handler) GetShiftSettings(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	settings, err := h.store.GetShiftSettings(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+func (h *Handler) UpdateShiftSettings(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var settings types.ShiftSettings
+	if err := utils.ParseJson(r, &settings); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if settings.MinRestHours < 0 || settings.MinRestHours > 24 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("minRestHours must be between 0 and 24"))
+		return
+	}
+	if settings.ClockInEarlyMinutes < 0 || settings.ClockInEarlyMinutes > 240 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("clockInEarlyMinutes must be between 0 and 240"))
+		return
+	}
+	settings.IdRestaurant = idRestaurant
+
+	if err := h.store.UpsertShiftSettings(settings); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, settings)
+}
+
+func (h *Handler) GetShiftTemplates(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	templates, err := h.store.GetShiftTemplates(idRestaurant)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, templates)
+}
+
+func (h *Handler) CreateShiftTemplate(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var template types.ShiftTemplate
+	if err := utils.ParseJson(r, &template); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if template.Label == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("label is required"))
+		return
+	}
+	start, err := time.Parse("15:04", template.StartTime)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("startTime must use the HH:MM format"))
+		return
+	}
+	end, err := time.Parse("15:04", template.EndTime)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("endTime must use the HH:MM format"))
+		return
+	}
+	if start.Equal(end) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("startTime and endTime must differ"))
+		return
+	}
+	for _, weekday := range template.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("weekdays must be between 0 (Sunday) and 6 (Saturday)"))
+			return
+		}
+	}
+
+	idTemplate, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	template.IdTemplate = idTemplate
+	template.IdRestaurant = idRestaurant
+	template.CreatedAt = time.Now()
+	if template.Weekdays == nil {
+		template.Weekdays = []int{}
+	}
+
+	if err := h.store.CreateShiftTemplate(template); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, template)
+}
+
+func (h *Handler) DeleteShiftTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idTemplate"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idTemplate are required"))
+		return
+	}
+
+	if err := h.store.DeleteShiftTemplate(vars["idRestaurant"], vars["idTemplate"]); err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Shift template deleted"})
+}
+
+// AssignShiftTemplate gives a worker every shift of a template between two dates
+func (h *Handler) AssignShiftTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idTemplate"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idTemplate are required"))
+		return
+	}
+
+	var assignment types.TemplateAssignment
+	if err := utils.ParseJson(r, &assignment); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if assignment.IdRestaurantWorker == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurantWorker is required"))
+		return
+	}
+	from, err := time.Parse("2006-01-02", assignment.From)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("from must use the YYYY-MM-DD format"))
+		return
+	}
+	to, err := time.Parse("2006-01-02", assignment.To)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("to must use the YYYY-MM-DD format"))
+		return
+	}
+	if to.Before(from) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("from must not be after to"))
+		return
+	}
+	if to.Sub(from) >= maxTemplateAssignmentDays*24*time.Hour {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("a template can be assigned over at most %d days", maxTemplateAssignmentDays))
+		return
+	}
+
+	shifts, err := h.store.AssignShiftTemplate(vars["idRestaurant"], vars["idTemplate"], assignment)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, shifts)
+}
+
+// GetWorkerShifts lists the schedule of a restaurant, ?idRestaurantWorker= narrows it down to one worker
+func (h *Handler) GetWorkerShifts(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	from, to, err := shiftPeriod(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	shifts, err := h.store.GetWorkerShifts(idRestaurant, r.URL.Query().Get("idRestaurantWorker"), from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, shifts)
+}
+
+func (h *Handler) CreateWorkerShift(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	var assignment types.ShiftAssignment
+	if err := utils.ParseJson(r, &assignment); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if assignment.IdRestaurantWorker == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurantWorker is required"))
+		return
+	}
+	if !assignment.EndsAt.After(assignment.StartsAt) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("endsAt must be after startsAt"))
+		return
+	}
+	if assignment.EndsAt.Sub(assignment.StartsAt) > 24*time.Hour {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("a shift cannot last more than 24 hours"))
+		return
+	}
+
+	idShift, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	shift := types.WorkerShift{
+		IdShift:            idShift,
+		IdRestaurant:       idRestaurant,
+		IdRestaurantWorker: assignment.IdRestaurantWorker,
+		StartsAt:           assignment.StartsAt,
+		EndsAt:             assignment.EndsAt,
+		Note:               assignment.Note,
+		CreatedAt:          time.Now(),
+	}
+
+	if err := h.store.CreateWorkerShift(shift); err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, shift)
+}
+
+func (h *Handler) DeleteWorkerShift(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idShift"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idShift are required"))
+		return
+	}
+
+	if err := h.store.DeleteWorkerShift(vars["idRestaurant"], vars["idShift"]); err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Shift deleted"})
+}
+
+// GetShiftConflicts lists the double shifts and too short rests of the schedule over ?from= and ?to=
+func (h *Handler) GetShiftConflicts(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+	from, to, err := shiftPeriod(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	conflicts, err := h.store.GetShiftConflicts(idRestaurant, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, conflicts)
+}
+
+// CreateShiftSwap asks for a shift to be handed over to another worker
+func (h *Handler) CreateShiftSwap(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idShift"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idShift are required"))
+		return
+	}
+
+	var req types.ShiftSwapCreation
+	if err := utils.ParseJson(r, &req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.ToWorker == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("toWorker is required"))
+		return
+	}
+
+	idSwap, err := utils.CreateAnId()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	swap, err := h.store.CreateShiftSwap(vars["idRestaurant"], types.ShiftSwap{
+		IdSwap:    idSwap,
+		IdShift:   vars["idShift"],
+		ToWorker:  req.ToWorker,
+		Note:      req.Note,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, swap)
+}
+
+// GetShiftSwaps lists the swap requests of a restaurant, ?status= keeps the ones with that status
+func (h *Handler) GetShiftSwaps(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	swaps, err := h.store.GetShiftSwaps(idRestaurant, r.URL.Query().Get("status"))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, swaps)
+}
+
+// ResolveShiftSwap accepts, rejects or cancels a pending swap request
+func (h *Handler) ResolveShiftSwap(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if vars["idRestaurant"] == "" || vars["idSwap"] == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant and idSwap are required"))
+		return
+	}
+
+	var decision types.ShiftSwapDecision
+	if err := utils.ParseJson(r, &decision); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if decision.Status != "accepted" && decision.Status != "rejected" && decision.Status != "cancelled" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("status must be accepted, rejected or cancelled"))
+		return
+	}
+
+	if err := h.store.ResolveShiftSwap(vars["idRestaurant"], vars["idSwap"], decision.Status); err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Shift swap " + decision.Status})
+}
+
+func (h *Handler) ClockIn(w http.ResponseWriter, r *http.Request) {
+	idRestaurantWorker := mux.Vars(r)["idRestaurantWorker"]
+	if idRestaurantWorker == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurantWorker is required"))
+		return
+	}
+
+	clock, err := h.store.ClockIn(idRestaurantWorker)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusCreated, clock)
+}
+
+func (h *Handler) ClockOut(w http.ResponseWriter, r *http.Request) {
+	idRestaurantWorker := mux.Vars(r)["idRestaurantWorker"]
+	if idRestaurantWorker == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurantWorker is required"))
+		return
+	}
+
+	clock, err := h.store.ClockOut(idRestaurantWorker)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, clock)
+}
+
+// GetWorkerTimesheet returns the clock records of a worker over ?from= and ?to= with the hours worked
+func (h *Handler) GetWorkerTimesheet(w http.ResponseWriter, r *http.Request) {
+	idRestaurantWorker := mux.Vars(r)["idRestaurantWorker"]
+	if idRestaurantWorker == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurantWorker is required"))
+		return
+	}
+	from, to, err := shiftPeriod(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	timesheet, err := h.store.GetWorkerTimesheet(idRestaurantWorker, from, to)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, timesheet)
+}
+
+// GetOnShiftWorkers lists who is working right now, for the dashboard
+func (h *Handler) GetOnShiftWorkers(w http.ResponseWriter, r *http.Request) {
+	idRestaurant := mux.Vars(r)["idRestaurant"]
+	if idRestaurant == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("idRestaurant is required"))
+		return
+	}
+
+	workers, err := h.store.GetOnShiftWorkers(idRestaurant, time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, workers)
+}
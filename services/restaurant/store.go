@@ -4,6 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	// "log"
@@ -359,11 +363,18 @@ func (s *store) GetReservationDetails(idReservation string) (*types.ReservationI
 	}
 
 	details.Orders = orders
+
+	details.Deposit, err = s.GetReservationDeposit(idReservation)
+	if err != nil {
+		return nil, err
+	}
 	return &details, nil
 }
 
-func (s *store) GetAllRestaurantReservations(idRestaurant string, page, limit int) (*types.PaginatedReservations, error) {
+func (s *store) GetAllRestaurantReservations(idRestaurant string, filter types.ListFilter, page, limit int) (*types.PaginatedReservations, error) {
 	offset := (page - 1) * limit
+	where, args := utils.ListFilterClause(filter, "r.timeFrom", "r.status")
+	args = append([]interface{}{idRestaurant}, args...)
 
 	// Get total count for pagination
 	countQuery := `
@@ -371,33 +382,18 @@ func (s *store) GetAllRestaurantReservations(idRestaurant string, page, limit in
         FROM reservation r
         JOIN client c ON r.idClient = c.idClient
         JOIN profile p ON c.idProfile = p.idProfile
-        WHERE r.idRestaurant = ?
-    `
+        WHERE r.idRestaurant = ?` + where
 	var totalCount int
-	err := s.db.QueryRow(countQuery, idRestaurant).Scan(&totalCount)
+	err := s.db.QueryRow(countQuery, args...).Scan(&totalCount)
 	if err != nil {
 		return nil, fmt.Errorf("error counting reservations: %v", err)
 	}
 
 	// Get reservations with pagination
-	query := `
-        SELECT 
-            r.idReservation,
-            r.timeFrom,
-            CONCAT(p.firstName, ' ', p.lastName) as fullName,
-            r.idTable,
-            r.numberOfPeople,
-            r.status,
-            r.createdAt
-        FROM reservation r
-        JOIN client c ON r.idClient = c.idClient
-        JOIN profile p ON c.idProfile = p.idProfile
-        WHERE r.idRestaurant = ?
+	rows, err := s.db.Query(restaurantReservationsQuery+where+`
         ORDER BY r.timeFrom DESC
         LIMIT ? OFFSET ?
-    `
-
-	rows, err := s.db.Query(query, idRestaurant, limit, offset)
+    `, append(args, limit, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving reservations: %v", err)
 	}
@@ -405,29 +401,11 @@ func (s *store) GetAllRestaurantReservations(idRestaurant string, page, limit in
 
 	var reservations []types.RestaurantReservationDetail
 	for rows.Next() {
-		var reservation types.RestaurantReservationDetail
-		var idTable sql.NullString
-
-		err := rows.Scan(
-			&reservation.IdReservation,
-			&reservation.TimeFrom,
-			&reservation.FullName,
-			&idTable,
-			&reservation.NumberOfPeople,
-			&reservation.Status,
-			&reservation.CreatedAt,
-		)
+		reservation, err := scanRestaurantReservation(rows)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning reservation: %v", err)
-		}
-
-		if idTable.Valid {
-			reservation.TableId = idTable.String
-		} else {
-			reservation.TableId = "Not assigned"
+			return nil, err
 		}
-
-		reservations = append(reservations, reservation)
+		reservations = append(reservations, *reservation)
 	}
 
 	totalPages := (totalCount + limit - 1) / limit
@@ -442,6 +420,45 @@ func (s *store) GetAllRestaurantReservations(idRestaurant string, page, limit in
 	}, nil
 }
 
+const restaurantReservationsQuery = `
+        SELECT 
+            r.idReservation,
+            r.timeFrom,
+            CONCAT(p.firstName, ' ', p.lastName) as fullName,
+            r.idTable,
+            r.numberOfPeople,
+            r.status,
+            r.createdAt
+        FROM reservation r
+        JOIN client c ON r.idClient = c.idClient
+        JOIN profile p ON c.idProfile = p.idProfile
+        WHERE r.idRestaurant = ?`
+
+func scanRestaurantReservation(rows *sql.Rows) (*types.RestaurantReservationDetail, error) {
+	var reservation types.RestaurantReservationDetail
+	var idTable sql.NullString
+
+	err := rows.Scan(
+		&reservation.IdReservation,
+		&reservation.TimeFrom,
+		&reservation.FullName,
+		&idTable,
+		&reservation.NumberOfPeople,
+		&reservation.Status,
+		&reservation.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning reservation: %v", err)
+	}
+
+	if idTable.Valid {
+		reservation.TableId = idTable.String
+	} else {
+		reservation.TableId = "Not assigned"
+	}
+	return &reservation, nil
+}
+
 func (s *store) GetOrderInformation(idOrder string) (*types.OrderInformation, error) {
 	profileQuery := `
         SELECT 
@@ -449,18 +466,32 @@ func (s *store) GetOrderInformation(idOrder string) (*types.OrderInformation, er
             ol.totalPrice,
             ol.status,
             ol.createdAt,
-            profile.firstName,
-            profile.lastName,
-            profile.email,
-            profile.phoneNumber,
-            profile.address,
-            client.username,
-            r.timeFrom,
-            r.numberOfPeople
+            IFNULL(profile.firstName, ''),
+            IFNULL(profile.lastName, ''),
+            IFNULL(profile.email, ''),
+            IFNULL(profile.phoneNumber, ''),
+            IFNULL(profile.address, ''),
+            IFNULL(client.username, ''),
+            COALESCE(r.timeFrom, tk.pickupTime, ts.openedAt, ol.createdAt),
+            IFNULL(r.numberOfPeople, 0),
+            IFNULL(pay.status, 'unpaid'),
+            IF(pay.status IN ('succeeded', 'partially_refunded', 'refunded'), pay.amount, 0),
+            IFNULL(pay.amountRefunded, 0),
+            COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant, ''),
+            ol.idTableSession,
+            tk.pickupTime
         FROM orderList ol
-        JOIN reservation r ON ol.idReservation = r.idReservation
-        JOIN client ON r.idClient = client.idClient
-        JOIN profile ON client.idProfile = profile.idProfile
+        LEFT JOIN reservation r ON ol.idReservation = r.idReservation
+        LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+        LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+        LEFT JOIN client ON client.idClient = COALESCE(r.idClient, tk.idClient)
+        LEFT JOIN profile ON client.idProfile = profile.idProfile
+        LEFT JOIN payment pay ON pay.idPayment = (
+            SELECT p.idPayment FROM payment p
+            WHERE p.targetType = 'order' AND p.idTarget = ol.idOrder
+            ORDER BY p.createdAt DESC
+            LIMIT 1
+        )
         WHERE ol.idOrder = ?
     `
 
@@ -479,6 +510,12 @@ func (s *store) GetOrderInformation(idOrder string) (*types.OrderInformation, er
 		&orderInfo.ClientUsername,
 		&orderInfo.ReservationTime,
 		&orderInfo.NumberOfPeople,
+		&orderInfo.PaymentStatus,
+		&orderInfo.AmountPaid,
+		&orderInfo.AmountRefunded,
+		&orderInfo.IdRestaurant,
+		&orderInfo.IdTableSession,
+		&orderInfo.PickupTime,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -493,9 +530,9 @@ func (s *store) GetOrderInformation(idOrder string) (*types.OrderInformation, er
             food.name,
             food.description,
             food.image,
-            food.price,
+            IFNULL(orderFood.unitPrice, food.price),
             orderFood.quantity,
-            (food.price * orderFood.quantity) as subtotal
+            (IFNULL(orderFood.unitPrice, food.price) * orderFood.quantity) as subtotal
         FROM orderFood 
         JOIN food ON orderFood.idFood = food.idFood
         WHERE orderFood.idOrder = ?
@@ -531,8 +568,14 @@ func (s *store) GetOrderInformation(idOrder string) (*types.OrderInformation, er
 
 func (s *store) UpdateOrderStatus(idOrder string, status string) error {
 	var currentStatus string
-	checkQuery := `SELECT status FROM orderList WHERE idOrder = ?`
-	err := s.db.QueryRow(checkQuery, idOrder).Scan(&currentStatus)
+	var takeaway bool
+	checkQuery := `
+		SELECT ol.status, tk.idOrder IS NOT NULL
+		FROM orderList ol
+		LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+		WHERE ol.idOrder = ?
+	`
+	err := s.db.QueryRow(checkQuery, idOrder).Scan(&currentStatus, &takeaway)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("order with ID %s not found", idOrder)
@@ -544,7 +587,14 @@ func (s *store) UpdateOrderStatus(idOrder string, status string) error {
 		return fmt.Errorf("order is already completed")
 	}
 
+	// Keep the kitchen timestamps in step with the status
 	updateQuery := `UPDATE orderList SET status = ? WHERE idOrder = ?`
+	switch status {
+	case "preparing":
+		updateQuery = `UPDATE orderList SET status = ?, preparingAt = IFNULL(preparingAt, NOW()), readyAt = NULL WHERE idOrder = ?`
+	case "ready":
+		updateQuery = `UPDATE orderList SET status = ?, preparingAt = IFNULL(preparingAt, NOW()), readyAt = NOW() WHERE idOrder = ?`
+	}
 	result, err := s.db.Exec(updateQuery, status, idOrder)
 	if err != nil {
 		return fmt.Errorf("error updating order status: %v", err)
@@ -559,6 +609,20 @@ func (s *store) UpdateOrderStatus(idOrder string, status string) error {
 		return fmt.Errorf("no rows were updated")
 	}
 
+	// The kitchen started the order, its ingredients leave the stock
+	if status == "preparing" || status == "ready" || status == "completed" {
+		if err := s.consumeOrderStock(idOrder); err != nil {
+			log.Printf("Error updating stock for order %s: %v", idOrder, err)
+		}
+	}
+
+	// Takeaway clients are told when they can come and pick up their order
+	if takeaway && status == "ready" && currentStatus != "ready" {
+		if err := s.enqueueOrderEmail(idOrder, "takeaway_ready", time.Now()); err != nil {
+			log.Printf("Error queueing ready email for order %s: %v", idOrder, err)
+		}
+	}
+
 	return nil
 }
 
@@ -573,13 +637,23 @@ func (s *store) GetAllClientReservations(idClient string) ([]types.ClientReserva
             rest.name,
             rest.image,
             rest.location,
-            rest.idRestaurant
+            rest.idRestaurant,
+            r.idClient = ? AS isHost,
+            IFNULL(rg.status, ''),
+            IFNULL((
+                SELECT p.status FROM payment p
+                JOIN orderList ol ON p.targetType = 'order' AND p.idTarget = ol.idOrder
+                WHERE ol.idReservation = r.idReservation
+                ORDER BY p.createdAt DESC
+                LIMIT 1
+            ), 'unpaid')
         FROM reservation r
         JOIN restaurant rest ON r.idRestaurant = rest.idRestaurant
-        WHERE r.idClient = ?
+        LEFT JOIN reservationGuest rg ON rg.idReservation = r.idReservation AND rg.idClient = ?
+        WHERE r.idClient = ? OR rg.status = 'accepted'
         ORDER BY r.timeFrom DESC
     `
-	rows, err := s.db.Query(query, idClient)
+	rows, err := s.db.Query(query, idClient, idClient, idClient)
 	if err != nil {
 		return nil, err
 	}
@@ -598,6 +672,9 @@ func (s *store) GetAllClientReservations(idClient string) ([]types.ClientReserva
 			&reservation.RestaurantImage,
 			&reservation.RestaurantLocation,
 			&reservation.IdRestaurant,
+			&reservation.IsHost,
+			&reservation.GuestStatus,
+			&reservation.PaymentStatus,
 		); err != nil {
 			return nil, err
 		}
@@ -661,15 +738,35 @@ func (s *store) CreateMenu(idMenu, idRestaurant, name string) error {
 }
 
 func (s *store) CreateFood(idFood, idCategory, idRestaurant, name, description, image string, price float64, status string) error {
+	var owned int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM foodCategory WHERE idCategory = ? AND idRestaurant = ?`, idCategory, idRestaurant).Scan(&owned)
+	if err != nil {
+		return fmt.Errorf("error checking food category: %v", err)
+	}
+	if owned == 0 {
+		return fmt.Errorf("category %s does not belong to this restaurant", idCategory)
+	}
 	query := `INSERT INTO food (idFood, idCategory, idRestaurant, name, description, image, price, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(query, idFood, idCategory, idRestaurant, name, description, image, price, status)
+	_, err = s.db.Exec(query, idFood, idCategory, idRestaurant, name, description, image, price, status)
 	return err
 }
 
-func (s *store) CreateFoodCategory(idCategory, nameCategorie string) error {
-	query := `INSERT INTO foodCategory (idCategory, nameCategorie) VALUES (?, ?)`
-	_, err := s.db.Exec(query, idCategory, nameCategorie)
-	return err
+// CreateFoodCategory adds a category at the end of the restaurant's menu
+func (s *store) CreateFoodCategory(category types.FoodCategory) error {
+	query := `
+		INSERT INTO foodCategory (idCategory, idRestaurant, nameCategorie, sortIndex, image)
+		SELECT ?, ?, ?, IFNULL(MAX(sortIndex) + 1, 0), ?
+		FROM foodCategory
+		WHERE idRestaurant = ?
+	`
+	_, err := s.db.Exec(query, category.IdCategory, category.IdRestaurant, category.NameCategorie, category.Image, category.IdRestaurant)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("category %s already exists", category.NameCategorie)
+		}
+		return fmt.Errorf("error creating food category: %v", err)
+	}
+	return nil
 }
 
 func (s *store) DeleteFood(idFood string) error {
@@ -701,6 +798,11 @@ func (s *store) AddFoodToMenu(idMenuFood, idMenu, idFood string) error {
 func (s *store) SetFoodStatusInMenu(idFood, status string) error {
 	query := `UPDATE food SET status = ? WHERE idFood = ?`
 	_, err := s.db.Exec(query, status, idFood)
+	if err != nil {
+		return err
+	}
+	// Set by hand, a restock must not override it
+	_, err = s.db.Exec(`DELETE FROM foodStockHold WHERE idFood = ?`, idFood)
 	return err
 }
 
@@ -815,7 +917,7 @@ func (s *store) GetRestaurantWorkerWithRatings(idRestaurantWorker string) (*type
         FROM rating r
         JOIN client c ON r.idClient = c.idClient
         JOIN profile p ON c.idProfile = p.idProfile
-        WHERE r.idRestaurantWorker = ? AND r.ratingType = 'worker'
+        WHERE r.idRestaurantWorker = ? AND r.ratingType = 'worker' AND r.moderationStatus = 'visible'
         ORDER BY r.createdAt DESC
         LIMIT 10
     `
@@ -853,7 +955,7 @@ func (s *store) GetRestaurantWorkerWithRatings(idRestaurantWorker string) (*type
             IFNULL(SUM(CASE WHEN rating = 2 THEN 1 ELSE 0 END), 0) AS count2Stars,
             IFNULL(SUM(CASE WHEN rating = 1 THEN 1 ELSE 0 END), 0) AS count1Star
         FROM rating
-        WHERE idRestaurantWorker = ? AND ratingType = 'worker'
+        WHERE idRestaurantWorker = ? AND ratingType = 'worker' AND moderationStatus = 'visible'
     `
 
 	var totalRatings, count5Stars, count4Stars, count3Stars, count2Stars, count1Star int
@@ -963,9 +1065,9 @@ func (s *store) GetTablesByRestaurant(restaurantId string) ([]types.Table, error
 func (s *store) UpdateReservationStatus(idReservation, status string) error {
 	// First, get current reservation status and time
 	var currentStatus string
-	var timeFrom time.Time
-	query := `SELECT status, timeFrom FROM reservation WHERE idReservation = ?`
-	err := s.db.QueryRow(query, idReservation).Scan(&currentStatus, &timeFrom)
+	var checkedInAt sql.NullTime
+	query := `SELECT status, checkedInAt FROM reservation WHERE idReservation = ?`
+	err := s.db.QueryRow(query, idReservation).Scan(&currentStatus, &checkedInAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("reservation not found")
@@ -977,40 +1079,112 @@ func (s *store) UpdateReservationStatus(idReservation, status string) error {
 	if !isValidReservationStatusTransition(currentStatus, status) {
 		return fmt.Errorf("invalid status transition from %s to %s", currentStatus, status)
 	}
-	if status == "confirmed" {
-		now := time.Now()
-		timeDiff := timeFrom.Sub(now)
-		absTimeDiff := timeDiff
-		if absTimeDiff < 0 {
-			absTimeDiff = -absTimeDiff
-		}
-
-		twoHours := 2 * time.Hour
-		if absTimeDiff > twoHours {
-			return fmt.Errorf("status can only be changed within 2 hours before or after the reservation time")
-		}
-
+	if status == "no_show" && checkedInAt.Valid {
+		return fmt.Errorf("invalid status transition from %s to %s, the guests checked in", currentStatus, status)
 	}
 
-	// If validation passes, update the status
+	// If validation passes, update the status. Completing a visit by hand means the guests came.
 	updateQuery := `UPDATE reservation SET status = ? WHERE idReservation = ?`
+	if status == "completed" {
+		updateQuery = `UPDATE reservation SET status = ?, checkedInAt = IFNULL(checkedInAt, NOW()) WHERE idReservation = ?`
+	}
 	_, err = s.db.Exec(updateQuery, status, idReservation)
-	return err
+	if err != nil {
+		return err
+	}
+
+	entry := &types.ReservationHistoryEntry{
+		IdReservation:  idReservation,
+		Action:         "status_changed",
+		Actor:          "restaurant",
+		PreviousStatus: currentStatus,
+		NewStatus:      status,
+	}
+	if err := insertReservationHistory(s.db, entry); err != nil {
+		log.Printf("Error recording history for reservation %s: %v", idReservation, err)
+	}
+
+	if status == "cancelled" || status == "no_show" || status == "completed" {
+		if err := s.queueDepositSettlement(s.db, idReservation, status); err != nil {
+			log.Printf("Error settling deposit for reservation %s: %v", idReservation, err)
+		}
+	}
+
+	switch status {
+	case "confirmed":
+		err = s.enqueueReservationEmail(idReservation, "confirmed", time.Now())
+	case "cancelled":
+		if err = s.cancelPendingReminders(idReservation); err == nil {
+			err = s.enqueueReservationEmail(idReservation, "cancelled", time.Now())
+		}
+	default:
+		err = s.cancelPendingReminders(idReservation)
+	}
+	if err != nil {
+		log.Printf("Error updating emails for reservation %s: %v", idReservation, err)
+	}
+	return nil
 }
 
 func isValidReservationStatusTransition(currentStatus, newStatus string) bool {
 	switch currentStatus {
 	case "pending":
-		return newStatus == "confirmed" || newStatus == "cancelled"
+		return newStatus == "confirmed" || newStatus == "cancelled" // left alone, it expires after its slot
 	case "confirmed":
-		return false // confirmed cannot change to any other status
-	case "cancelled":
-		return false // cancelled cannot change to any other status
+		return newStatus == "completed" || newStatus == "cancelled" || newStatus == "no_show"
+	case "cancelled", "no_show", "completed", "expired":
+		return false // terminal states
 	default:
 		return false
 	}
 }
 
+// CheckInReservation records the arrival of the guests of a confirmed reservation, within 2 hours of its time.
+// The sweep completes checked-in visits once their slot is over and turns the others into no-shows.
+func (s *store) CheckInReservation(idReservation string) error {
+	var status string
+	var timeFrom time.Time
+	var checkedInAt sql.NullTime
+	query := `SELECT status, timeFrom, checkedInAt FROM reservation WHERE idReservation = ?`
+	err := s.db.QueryRow(query, idReservation).Scan(&status, &timeFrom, &checkedInAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("reservation not found")
+		}
+		return fmt.Errorf("error fetching reservation: %v", err)
+	}
+	if status != "confirmed" {
+		return fmt.Errorf("invalid status transition, only confirmed reservations can be checked in and this one is %s", status)
+	}
+	if checkedInAt.Valid {
+		return nil
+	}
+	now := time.Now()
+	if diff := timeFrom.Sub(now); diff > 2*time.Hour || diff < -2*time.Hour {
+		return fmt.Errorf("invalid status transition, guests can only be checked in within 2 hours before or after the reservation time")
+	}
+
+	_, err = s.db.Exec(`UPDATE reservation SET checkedInAt = ? WHERE idReservation = ? AND status = 'confirmed'`, now, idReservation)
+	if err != nil {
+		return fmt.Errorf("error checking in reservation: %v", err)
+	}
+
+	entry := &types.ReservationHistoryEntry{
+		IdReservation:  idReservation,
+		Action:         "checked_in",
+		Actor:          "restaurant",
+		PreviousStatus: status,
+		NewStatus:      status,
+	}
+	if err := insertReservationHistory(s.db, entry); err != nil {
+		log.Printf("Error recording history for reservation %s: %v", idReservation, err)
+	}
+	if err := s.cancelPendingReminders(idReservation); err != nil {
+		log.Printf("Error updating emails for reservation %s: %v", idReservation, err)
+	}
+	return nil
+}
+
 func (s *store) GetRestaurantMenuStats(restaurantId string) (*types.RestaurantMenuStats, error) {
 	stats := &types.RestaurantMenuStats{}
 
@@ -1020,13 +1194,14 @@ func (s *store) GetRestaurantMenuStats(restaurantId string) (*types.RestaurantMe
 		return nil, err
 	}
 
-	// Active menu id and name
-	var activeMenuId string
-	err = s.db.QueryRow(`SELECT idMenu, name FROM menu WHERE idRestaurant = ? AND active = 1 LIMIT 1`, restaurantId).Scan(&activeMenuId, &stats.ActiveMenuName)
-	if err != nil {
+	// Menu served right now, scheduled or activated by hand
+	active, err := s.resolveActiveMenu(restaurantId, time.Now())
+	if err != nil || active == nil {
 		// If no active menu, return stats with zeroes
 		return stats, nil
 	}
+	activeMenuId := active.IdMenu
+	stats.ActiveMenuName = active.Name
 
 	// Total items in active menu
 	err = s.db.QueryRow(`SELECT COUNT(*) FROM food join menufood on food.idFood=menufood.idFood WHERE menufood.idMenu = ?`, activeMenuId).Scan(&stats.TotalItems)
@@ -1080,13 +1255,20 @@ func (s *store) GetRestaurantMenuStats(restaurantId string) (*types.RestaurantMe
 	return stats, nil
 }
 
-func (s *store) GetFoodsOfActiveMenu(idRestaurant string) ([]types.Food, error) {
-	var idMenu string
-	err := s.db.QueryRow("SELECT idMenu FROM menu WHERE idRestaurant = ? AND active = 1 LIMIT 1", idRestaurant).Scan(&idMenu)
+func (s *store) GetFoodsOfActiveMenu(idRestaurant string, filter types.DietaryFilter) ([]types.Food, error) {
+	active, err := s.GetCurrentMenu(idRestaurant)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := s.db.Query("SELECT food.idFood, idCategory, menufood.idMenu, name, description, image, price, status FROM food join menufood on food.idFood = menufood.idFood WHERE menufood.idMenu = ?", idMenu)
+	query := `
+		SELECT food.idFood, food.idCategory, menufood.idMenu, food.name, food.description, food.image, food.price, food.status
+		FROM food
+		JOIN menufood ON food.idFood = menufood.idFood
+		LEFT JOIN foodCategory fc ON fc.idCategory = food.idCategory
+		WHERE menufood.idMenu = ?
+		ORDER BY fc.sortIndex, fc.nameCategorie, food.name
+	`
+	rows, err := s.db.Query(query, active.IdMenu)
 	if err != nil {
 		return nil, err
 	}
@@ -1099,7 +1281,37 @@ func (s *store) GetFoodsOfActiveMenu(idRestaurant string) ([]types.Food, error)
 		}
 		foods = append(foods, food)
 	}
-	return foods, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	idFoods := make([]string, 0, len(foods))
+	for _, food := range foods {
+		idFoods = append(idFoods, food.IdFood)
+	}
+	tags, err := loadFoodTags(s.db, idFoods)
+	if err != nil {
+		return nil, err
+	}
+	check, err := s.newDietaryCheck(filter)
+	if err != nil {
+		return nil, err
+	}
+	var kept []types.Food
+	for _, food := range foods {
+		food.Tags = tags[food.IdFood]
+		if food.Tags == nil {
+			food.Tags = []types.FoodTag{}
+		}
+		keep, compatible, conflicts := check.apply(food.Tags)
+		if !keep {
+			continue
+		}
+		food.Compatible = compatible
+		food.Conflicts = conflicts
+		kept = append(kept, food)
+	}
+	return kept, nil
 }
 
 func (s *store) GetMenusByRestaurant(idRestaurant string) ([]types.Menu, error) {
@@ -1121,8 +1333,19 @@ func (s *store) GetMenusByRestaurant(idRestaurant string) ([]types.Menu, error)
 }
 
 func (s *store) UpdateFood(idFood string, food types.Food) error {
+	var owned int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM foodCategory fc
+		JOIN food f ON f.idRestaurant = fc.idRestaurant
+		WHERE fc.idCategory = ? AND f.idFood = ?`, food.IdCategory, idFood).Scan(&owned)
+	if err != nil {
+		return fmt.Errorf("error checking food category: %v", err)
+	}
+	if owned == 0 {
+		return fmt.Errorf("category %s does not belong to this restaurant", food.IdCategory)
+	}
 	query := `UPDATE food SET idCategory=?,  name=?, description=?, image=?, price=?, status=? WHERE idFood=?`
-	_, err := s.db.Exec(query, food.IdCategory, food.Name, food.Description, food.Image, food.Price, food.Status, idFood)
+	_, err = s.db.Exec(query, food.IdCategory, food.Name, food.Description, food.Image, food.Price, food.Status, idFood)
 	return err
 }
 
@@ -1180,31 +1403,41 @@ func (s *store) CreateTable(table types.Table) error {
 	return err
 }
 
-func (s *store) GetFoodCategoriesByRestaurant() ([]types.FoodCategory, error) {
+// GetFoodCategoriesByRestaurant returns the categories of a restaurant in menu order
+func (s *store) GetFoodCategoriesByRestaurant(idRestaurant string) ([]types.FoodCategory, error) {
 	query := `
-        SELECT DISTINCT fc.idCategory, fc.nameCategorie
+        SELECT fc.idCategory, fc.idRestaurant, fc.nameCategorie, fc.sortIndex, fc.image, COUNT(f.idFood)
         FROM foodCategory fc
+        LEFT JOIN food f ON f.idCategory = fc.idCategory
+        WHERE fc.idRestaurant = ?
+        GROUP BY fc.idCategory, fc.idRestaurant, fc.nameCategorie, fc.sortIndex, fc.image
+        ORDER BY fc.sortIndex, fc.nameCategorie
     `
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, idRestaurant)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var categories []types.FoodCategory
+	categories := []types.FoodCategory{}
 	for rows.Next() {
 		var cat types.FoodCategory
-		if err := rows.Scan(&cat.IdCategory, &cat.NameCategorie); err != nil {
+		if err := rows.Scan(&cat.IdCategory, &cat.IdRestaurant, &cat.NameCategorie, &cat.SortIndex, &cat.Image, &cat.FoodCount); err != nil {
 			return nil, err
 		}
 		categories = append(categories, cat)
 	}
-	return categories, nil
+	return categories, rows.Err()
 }
 
 func (s *store) SetFoodUnavailable(idFood string) error {
 	query := `UPDATE food SET status = 'unavailable' WHERE idFood = ?`
 
 	_, err := s.db.Exec(query, idFood)
+	if err != nil {
+		return err
+	}
+	// Set by hand, a restock must not bring it back
+	_, err = s.db.Exec(`DELETE FROM foodStockHold WHERE idFood = ?`, idFood)
 	return err
 }
 
@@ -1215,6 +1448,7 @@ func (s *store) GetTableOccupationToday(idRestaurant string) ([]types.TableOccup
         LEFT JOIN reservation r ON t.idTable = r.idTable
             AND DATE(r.timeFrom) = CURDATE()
             AND r.idRestaurant = ?
+            AND r.status IN ('pending', 'confirmed')
         WHERE t.idRestaurant = ?
         ORDER BY t.idTable, r.timeFrom
     `
@@ -1340,7 +1574,24 @@ func (s *store) CreateRestaurantWorker(id, idRestaurant string, worker types.Res
 func (s *store) SetRestaurantWorkerStatus(idRestaurantWorker string, status string) error {
 	query := `UPDATE restaurantWorkers SET status = ? WHERE idRestaurantWorker = ?`
 	_, err := s.db.Exec(query, status, idRestaurantWorker)
-	return err
+	if err != nil || status != "inactive" {
+		return err
+	}
+
+	// A worker leaving gives up their upcoming shifts and clocks out
+	now := time.Now()
+	if _, err := s.db.Exec(`DELETE FROM workerShift WHERE idRestaurantWorker = ? AND startsAt > ?`, idRestaurantWorker, now); err != nil {
+		return fmt.Errorf("error removing upcoming shifts: %v", err)
+	}
+	_, err = s.db.Exec(`UPDATE shiftSwap SET status = 'cancelled', resolvedAt = ? WHERE toWorker = ? AND status = 'pending'`, now, idRestaurantWorker)
+	if err != nil {
+		return fmt.Errorf("error cancelling shift swaps: %v", err)
+	}
+	_, err = s.db.Exec(`UPDATE workerClock SET clockOut = ? WHERE idRestaurantWorker = ? AND clockOut IS NULL`, now, idRestaurantWorker)
+	if err != nil {
+		return fmt.Errorf("error closing clock records: %v", err)
+	}
+	return nil
 }
 
 func (s *store) GetRestaurantByIdProfile(idProfile string) (*types.UserAdmin, error) {
@@ -1386,20 +1637,30 @@ func (s *store) GetRestaurantByIdProfile(idProfile string) (*types.UserAdmin, er
 	return &rest, nil
 }
 
-func (s *store) GetAvailableMenuInformation(restaurantId string) (*[]types.MenuInformationFood, error) {
+func (s *store) GetAvailableMenuInformation(restaurantId string, filter types.DietaryFilter) (*[]types.MenuInformationFood, error) {
+	var menuInformation []types.MenuInformationFood
+	active, err := s.resolveActiveMenu(restaurantId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if active == nil {
+		return &menuInformation, nil
+	}
+
 	query := `
 SELECT food.*,menu.idMenu,menu.name as menuName
  FROM menu
  join menufood on menufood.idMenu=menu.idMenu
 JOIN food ON food.idFood = menufood.idFood
-where menu.active = 1 and food.status="available" and menu.idRestaurant = ?;
+LEFT JOIN foodCategory fc ON fc.idCategory = food.idCategory
+where menu.idMenu = ? and food.status="available" and menu.idRestaurant = ?
+ORDER BY fc.sortIndex, fc.nameCategorie, food.name;
 `
-	rows, err := s.db.Query(query, restaurantId)
+	rows, err := s.db.Query(query, active.IdMenu, restaurantId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close() // Ensure rows are closed to avoid memory leaks
-	var menuInformation []types.MenuInformationFood
 	for rows.Next() {
 
 		var menu types.MenuInformationFood
@@ -1425,7 +1686,34 @@ where menu.active = 1 and food.status="available" and menu.idRestaurant = ?;
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return &menuInformation, nil
+
+	idFoods := make([]string, 0, len(menuInformation))
+	for _, food := range menuInformation {
+		idFoods = append(idFoods, food.IdFood)
+	}
+	tags, err := loadFoodTags(s.db, idFoods)
+	if err != nil {
+		return nil, err
+	}
+	check, err := s.newDietaryCheck(filter)
+	if err != nil {
+		return nil, err
+	}
+	var kept []types.MenuInformationFood
+	for _, food := range menuInformation {
+		food.Tags = tags[food.IdFood]
+		if food.Tags == nil {
+			food.Tags = []types.FoodTag{}
+		}
+		keep, compatible, conflicts := check.apply(food.Tags)
+		if !keep {
+			continue
+		}
+		food.Compatible = compatible
+		food.Conflicts = conflicts
+		kept = append(kept, food)
+	}
+	return &kept, nil
 }
 
 // !NOTE: GET all restaurant
@@ -1471,23 +1759,45 @@ func (s *store) PostOrderList(orderId string, foods []types.FoodItem) error {
 		log.Println("⚠️ No foods provided for order:", orderId)
 	}
 	log.Printf("Inserting %d foods into order %s", len(foods), orderId)
-	for _, food := range foods {
-		res, err := s.db.Exec(`Insert INTO orderFood (idOrder, idFood, quantity, createdAt) VALUES (?, ?, ?, ?)`, orderId, food.IdFood, food.Quantity, time.Now())
-		totalPrice += food.PriceSingle * float64(food.Quantity)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
 		if err != nil {
-			log.Printf("Error inserting into orderFood: %v", err)
+			tx.Rollback()
+		}
+	}()
 
+	var idRestaurant string
+	err = tx.QueryRow(`
+		SELECT COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant)
+		FROM orderList ol
+		LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+		LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+		LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+		WHERE ol.idOrder = ?`, orderId).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("order with ID %s not found", orderId)
 			return err
 		}
-		rowsAffected, _ := res.RowsAffected()
-		if rowsAffected == 0 {
-			log.Printf("Errior getting rows affected: %v", err)
+		return fmt.Errorf("error fetching order: %v", err)
+	}
+
+	// Prices come from the menu, options included, rather than from the client
+	for _, food := range foods {
+		var lineTotal float64
+		lineTotal, err = addOrderLine(tx, orderId, idRestaurant, food, sql.NullString{})
+		if err != nil {
+			log.Printf("Error inserting into orderFood: %v", err)
 			return err
 		}
-
+		totalPrice += lineTotal
 	}
 	query := `UPDATE orderList SET totalPrice = ? WHERE idOrder = ?`
-	res, err := s.db.Exec(query, totalPrice, orderId)
+	res, err := tx.Exec(query, totalPrice, orderId)
 	if err != nil {
 		log.Printf("Error inserting into orderFood: %v", err)
 		return err
@@ -1496,6 +1806,9 @@ func (s *store) PostOrderList(orderId string, foods []types.FoodItem) error {
 	if rowsAffected == 0 {
 		log.Printf("⚠️ No rows updated in orderList for idOrder: %s", orderId)
 	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
 	return nil
 }
 
@@ -1511,6 +1824,15 @@ func (s *store) CreateReservation(idReservation string, reservation types.Reserv
 		return fmt.Errorf("You already has a reservation on %s", date)
 	}
 
+	// Clients with too many recent no-shows are blocked for a while
+	blockedUntil, _, err := s.clientBlockedUntil(reservation.IdClient, reservation.IdRestaurant, time.Now())
+	if err != nil {
+		return err
+	}
+	if blockedUntil != nil {
+		return fmt.Errorf("client is blocked from booking this restaurant until %s after repeated no-shows", blockedUntil.Format("2006-01-02"))
+	}
+
 	// Insert reservation
 	query := `
 		INSERT INTO reservation (
@@ -1532,6 +1854,15 @@ func (s *store) CreateReservation(idReservation string, reservation types.Reserv
 		return err
 	}
 
+	if err := s.refreshDepositRequirement(s.db, idReservation, reservation.IdRestaurant, reservation.NumberOfPeople, reservation.TimeFrom); err != nil {
+		log.Printf("Error computing deposit for reservation %s: %v", idReservation, err)
+	}
+
+	// The reservation is saved; a mail queue problem must not fail the booking
+	if err := s.queueNewReservationEmails(idReservation, reservation.IdRestaurant, reservation.TimeFrom); err != nil {
+		log.Printf("Error queueing emails for reservation %s: %v", idReservation, err)
+	}
+
 	return nil
 }
 
@@ -1574,6 +1905,25 @@ func (s *store) BulkUpdateRestaurantTables(idRestaurant string, tables []types.T
 		}
 	}()
 
+	// Keep the QR codes of tables that survive the update, printed codes must stay valid
+	qrTokens := make(map[string]string)
+	rows, err := tx.Query(`SELECT idTable, qrToken FROM table_restaurant WHERE idRestaurant = ? AND qrToken IS NOT NULL`, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error fetching table QR codes: %v", err)
+	}
+	for rows.Next() {
+		var idTable, qrToken string
+		if err = rows.Scan(&idTable, &qrToken); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning table QR code: %v", err)
+		}
+		qrTokens[idTable] = qrToken
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating table QR codes: %v", err)
+	}
+
 	// Delete all existing tables for this restaurant
 	deleteQuery := `DELETE FROM table_restaurant WHERE idRestaurant = ?`
 	_, err = tx.Exec(deleteQuery, idRestaurant)
@@ -1583,7 +1933,7 @@ func (s *store) BulkUpdateRestaurantTables(idRestaurant string, tables []types.T
 
 	// Insert new tables if any are provided
 	if len(tables) > 0 {
-		insertQuery := `INSERT INTO table_restaurant (idTable, idRestaurant, shape, posX, posY, is_available) VALUES (?, ?, ?, ?, ?, ?)`
+		insertQuery := `INSERT INTO table_restaurant (idTable, idRestaurant, shape, posX, posY, is_available, qrToken) VALUES (?, ?, ?, ?, ?, ?, ?)`
 
 		for _, table := range tables {
 			// Generate ID if not provided
@@ -1601,7 +1951,11 @@ func (s *store) BulkUpdateRestaurantTables(idRestaurant string, tables []types.T
 			// Set availability to true by default (since frontend doesn't send this field)
 			table.IsAvailable = true
 
-			_, err = tx.Exec(insertQuery, table.IdTable, table.IdRestaurant, table.Shape, table.PosX, table.PosY, table.IsAvailable)
+			var qrToken sql.NullString
+			if token, ok := qrTokens[table.IdTable]; ok {
+				qrToken = sql.NullString{String: token, Valid: true}
+			}
+			_, err = tx.Exec(insertQuery, table.IdTable, table.IdRestaurant, table.Shape, table.PosX, table.PosY, table.IsAvailable, qrToken)
 			if err != nil {
 				return fmt.Errorf("error inserting table %s: %v", table.IdTable, err)
 			}
@@ -1626,6 +1980,7 @@ LEFT JOIN
     reservation r 
     ON tr.idTable = r.idTable 
     AND r.timeFrom = ?
+    AND r.status IN ('pending', 'confirmed')
 WHERE tr.idRestaurant = ?;
 `
 
@@ -1681,7 +2036,7 @@ func (s *store) GetRestaurant() (*[]types.Restaurant, error) {
 			r.location,
 			COALESCE(AVG(rating.rating), 0) as averageRating
 		FROM restaurant r
-		LEFT JOIN rating ON r.idRestaurant = rating.idRestaurant
+		LEFT JOIN rating ON r.idRestaurant = rating.idRestaurant AND rating.moderationStatus = 'visible'
 		GROUP BY r.idRestaurant, r.idAdminRestaurant, r.name, r.image, r.longitude, r.latitude, r.description, r.capacity, r.location
 	`
 	rows, err := s.db.Query(query)
@@ -2298,8 +2653,23 @@ func convertToInterfaceSlice(strs []string) []interface{} {
 }
 
 func (s *store) PostRatingRestaurant(rating types.PostRatingRestaurant) error {
+	// Only clients who ate there, as the host or an accepted guest, can review a restaurant
+	var completed int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM reservation r
+		LEFT JOIN reservationGuest rg ON rg.idReservation = r.idReservation AND rg.idClient = ? AND rg.status = 'accepted'
+		WHERE r.idRestaurant = ? AND r.status = 'completed' AND (r.idClient = ? OR rg.idClient IS NOT NULL)`,
+		rating.IdClient, rating.IdRestaurant, rating.IdClient).Scan(&completed)
+	if err != nil {
+		return fmt.Errorf("error checking completed reservations: %v", err)
+	}
+	if completed == 0 {
+		return fmt.Errorf("client %s has no completed reservation at restaurant %s", rating.IdClient, rating.IdRestaurant)
+	}
+
 	query := `INSERT INTO rating (idRating, idClient, idRestaurant, ratingType, rating, comment, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(query, rating.IdRating, rating.IdClient, rating.IdRestaurant, "restaurant", rating.RatingValue, rating.Comment, time.Now())
+	_, err = s.db.Exec(query, rating.IdRating, rating.IdClient, rating.IdRestaurant, "restaurant", rating.RatingValue, rating.Comment, time.Now())
 	if err != nil {
 		return fmt.Errorf("error inserting rating: %v", err)
 	}
@@ -2324,7 +2694,7 @@ FROM (
     FROM rating 
     JOIN client ON rating.idClient = client.idClient 
     JOIN profile ON client.idProfile = profile.idProfile 
-    WHERE rating.idRestaurant = ? AND rating.idClient IN (`
+    WHERE rating.idRestaurant = ? AND rating.moderationStatus = 'visible' AND rating.idClient IN (`
 	for i := range friendsId {
 		if i > 0 {
 			query += ", "
@@ -2399,10 +2769,11 @@ func (s *store) GetRestaurantWorker(idRestaurant string) (*[]types.RestaurantWor
 
 func (s *store) GetRecentReviews(idRestaurant string) ([]*types.Rating, error) {
 	query := `select
-    rating.comment,rating.rating,rating.createdAt,profile.firstName,profile.lastName
+    rating.idRating,rating.comment,rating.rating,rating.createdAt,profile.firstName,profile.lastName,
+    rating.reply,rating.repliedAt
     from rating join client on rating.idClient = client.idClient join profile
     on profile.idProfile = client.idProfile where idRestaurant=? and
-    ratingType="restaurant" order by createdAt desc limit 5`
+    ratingType="restaurant" and moderationStatus="visible" order by createdAt desc limit 5`
 	rows, err := s.db.Query(query, idRestaurant)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving recent reviews: %v", err)
@@ -2412,11 +2783,14 @@ func (s *store) GetRecentReviews(idRestaurant string) ([]*types.Rating, error) {
 	for rows.Next() {
 		var review types.Rating
 		err = rows.Scan(
+			&review.IdRating,
 			&review.Comment,
 			&review.RatingValue,
 			&review.CreatedAt,
 			&review.FirstName,
 			&review.LastName,
+			&review.Reply,
+			&review.RepliedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning recent review: %v", err)
@@ -2426,6 +2800,10 @@ func (s *store) GetRecentReviews(idRestaurant string) ([]*types.Rating, error) {
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating over recent review rows: %v", err)
 	}
+	rows.Close()
+	if err := attachReviewPhotos(s.db, reviews); err != nil {
+		return nil, err
+	}
 	return reviews, nil
 }
 
@@ -2460,9 +2838,17 @@ func (s *store) GetRecentOrders(idRestaurant string, limit int) ([]types.RecentO
 	return recentOrders, nil
 }
 
-func (s *store) GetOrderStatsByHourAndStatus(idRestaurant string) (map[int]int, map[string]int, error) {
-	queryHour := `SELECT HOUR(orderList.createdAt) AS hour, COUNT(*) AS count FROM orderList join reservation on orderList.idReservation=reservation.idReservation WHERE reservation.idRestaurant = ? GROUP BY HOUR(createdAt)`
-	queryStatus := `SELECT orderList.status, COUNT(*) AS count FROM orderList join reservation on orderList.idReservation=reservation.idReservation WHERE idRestaurant = ? GROUP BY status`
+// GetOrderStatsByHourAndStatus counts the orders of a restaurant by hour and status.
+// orderType "takeaway" counts takeaway orders by pickup hour, anything else the reservation orders.
+func (s *store) GetOrderStatsByHourAndStatus(idRestaurant string, orderType string) (map[int]int, map[string]int, error) {
+	// Dine-in orders come from a reservation or from a walk-in table session
+	dineIn := `FROM orderList left join reservation on orderList.idReservation=reservation.idReservation left join tableSession on orderList.idTableSession=tableSession.idTableSession WHERE COALESCE(reservation.idRestaurant, tableSession.idRestaurant) = ?`
+	queryHour := `SELECT HOUR(orderList.createdAt) AS hour, COUNT(*) AS count ` + dineIn + ` GROUP BY HOUR(orderList.createdAt)`
+	queryStatus := `SELECT orderList.status, COUNT(*) AS count ` + dineIn + ` GROUP BY orderList.status`
+	if orderType == "takeaway" {
+		queryHour = `SELECT HOUR(takeawayOrder.pickupTime) AS hour, COUNT(*) AS count FROM orderList join takeawayOrder on orderList.idOrder=takeawayOrder.idOrder WHERE takeawayOrder.idRestaurant = ? GROUP BY HOUR(takeawayOrder.pickupTime)`
+		queryStatus = `SELECT orderList.status, COUNT(*) AS count FROM orderList join takeawayOrder on orderList.idOrder=takeawayOrder.idOrder WHERE takeawayOrder.idRestaurant = ? GROUP BY orderList.status`
+	}
 
 	rowsHour, err := s.db.Query(queryHour, idRestaurant)
 	if err != nil {
@@ -2504,7 +2890,7 @@ func (s *store) GetClientReservationAndOrderDetails(idClient string) (*types.Cli
 		FROM client 
 		JOIN profile ON client.idProfile = profile.idProfile 
 		WHERE client.idClient = ?`
-	queryOrders := `SELECT orderList.idOrder, orderList.totalPrice, orderList.createdAt, orderList.status, food.name, food.price, orderFood.quantity 
+	queryOrders := `SELECT orderList.idOrder, orderList.totalPrice, orderList.createdAt, orderList.status, food.name, IFNULL(orderFood.unitPrice, food.price), orderFood.quantity 
 		FROM orderList 
         join reservation ON orderList.idReservation = reservation.idReservation
 		JOIN orderFood ON orderList.idOrder = orderFood.idOrder 
@@ -2593,7 +2979,7 @@ func (s *store) GetRestaurantRatingStats(idRestaurant string) (*types.Restaurant
             AVG(rating) AS averageRating,
             COUNT(*) AS totalRatings
         FROM rating
-        WHERE idRestaurant = ?
+        WHERE idRestaurant = ? AND moderationStatus = 'visible'
         GROUP BY YEAR(createdAt), MONTH(createdAt)
         ORDER BY year, month
     `
@@ -2630,7 +3016,7 @@ func (s *store) GetRestaurantRatingStats(idRestaurant string) (*types.Restaurant
             SUM(CASE WHEN rating = 2 THEN 1 ELSE 0 END) AS count2Stars,
             SUM(CASE WHEN rating = 1 THEN 1 ELSE 0 END) AS count1Star
         FROM rating
-        WHERE idRestaurant = ?
+        WHERE idRestaurant = ? AND moderationStatus = 'visible'
     `
 	var overallAverage float64
 	var totalRatings, count5Stars, count4Stars, count3Stars, count2Stars, count1Star int
@@ -2761,7 +3147,7 @@ func (s *store) GetAdminRestaurantStats() (*types.AdminRestaurantStats, error) {
 	err = s.db.QueryRow(`
 		SELECT COALESCE(AVG(rating), 0) 
 		FROM rating 
-		WHERE idRestaurant IS NOT NULL
+		WHERE idRestaurant IS NOT NULL AND moderationStatus = 'visible'
 	`).Scan(&stats.AverageRating)
 	if err != nil {
 		return nil, fmt.Errorf("error getting average rating: %v", err)
@@ -2790,10 +3176,13 @@ func (s *store) GetAllRestaurantReviews(idRestaurant string) ([]*types.Rating, e
 			r.comment,
 			r.createdAt,
 			p.firstName,
-			p.lastName
+			p.lastName,
+			r.reply,
+			r.repliedAt
 		FROM rating r
-		JOIN profile p ON r.idClient = p.idProfile
-		WHERE r.idRestaurant = ?
+		JOIN client c ON c.idClient = r.idClient
+		JOIN profile p ON c.idProfile = p.idProfile
+		WHERE r.idRestaurant = ? AND r.moderationStatus = 'visible'
 		ORDER BY r.createdAt DESC
 	`
 
@@ -2814,6 +3203,8 @@ func (s *store) GetAllRestaurantReviews(idRestaurant string) ([]*types.Rating, e
 			&review.CreatedAt,
 			&review.FirstName,
 			&review.LastName,
+			&review.Reply,
+			&review.RepliedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning review row: %v", err)
@@ -2821,7 +3212,11 @@ func (s *store) GetAllRestaurantReviews(idRestaurant string) ([]*types.Rating, e
 
 		reviews = append(reviews, &review)
 	}
+	rows.Close()
 
+	if err := attachReviewPhotos(s.db, reviews); err != nil {
+		return nil, err
+	}
 	return reviews, nil
 }
 
@@ -2875,5 +3270,6432 @@ func (s *store) GetRestaurantTodaySummary(idRestaurant string) (*types.Restauran
 		summary.CurrentOccupancy = float64(summary.ConfirmedReservations) / float64(capacity) * 100
 	}
 
+	// Takeaway orders picked up today
+	err = s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			IFNULL(SUM(ol.status IN ('pending', 'preparing')), 0),
+			IFNULL(SUM(ol.status = 'ready'), 0),
+			IFNULL(SUM(IF(ol.status <> 'cancelled', ol.totalPrice, 0)), 0)
+		FROM takeawayOrder tk
+		JOIN orderList ol ON ol.idOrder = tk.idOrder
+		WHERE tk.idRestaurant = ?
+		AND DATE(tk.pickupTime) = CURDATE()
+	`, idRestaurant).Scan(
+		&summary.TakeawayOrdersToday,
+		&summary.TakeawayInProgress,
+		&summary.TakeawayReadyOrders,
+		&summary.TakeawayRevenueToday,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting takeaway orders today: %v", err)
+	}
+
+	summary.TakeawayOrders, err = s.GetRestaurantTakeawayOrders(idRestaurant, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	summary.OnShift, err = s.GetOnShiftWorkers(idRestaurant, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	return &summary, nil
 }
+
+const (
+	defaultGracePeriodMinutes  = 30
+	defaultSlotDurationMinutes = 120
+	defaultNoShowLimit         = 3
+	defaultNoShowWindowDays    = 90
+	defaultBlockDays           = 30
+	defaultReminderHoursBefore = 3
+	defaultModificationCutoff  = 120
+	maxEmailJobAttempts        = 5
+)
+
+func defaultReservationPolicy(idRestaurant string) *types.ReservationPolicy {
+	return &types.ReservationPolicy{
+		IdRestaurant:              idRestaurant,
+		GracePeriodMinutes:        defaultGracePeriodMinutes,
+		SlotDurationMinutes:       defaultSlotDurationMinutes,
+		NoShowLimit:               defaultNoShowLimit,
+		NoShowWindowDays:          defaultNoShowWindowDays,
+		BlockDays:                 defaultBlockDays,
+		ReminderHoursBefore:       defaultReminderHoursBefore,
+		ModificationCutoffMinutes: defaultModificationCutoff,
+	}
+}
+
+// GetReservationPolicy returns the restaurant's reservation policy, falling back to the defaults
+func (s *store) GetReservationPolicy(idRestaurant string) (*types.ReservationPolicy, error) {
+	query := `
+		SELECT idRestaurant, gracePeriodMinutes, slotDurationMinutes, noShowLimit, noShowWindowDays, blockDays, reminderHoursBefore, modificationCutoffMinutes
+		FROM reservationPolicy
+		WHERE idRestaurant = ?
+	`
+	var policy types.ReservationPolicy
+	err := s.db.QueryRow(query, idRestaurant).Scan(
+		&policy.IdRestaurant,
+		&policy.GracePeriodMinutes,
+		&policy.SlotDurationMinutes,
+		&policy.NoShowLimit,
+		&policy.NoShowWindowDays,
+		&policy.BlockDays,
+		&policy.ReminderHoursBefore,
+		&policy.ModificationCutoffMinutes,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return defaultReservationPolicy(idRestaurant), nil
+		}
+		return nil, fmt.Errorf("error retrieving reservation policy: %v", err)
+	}
+	return &policy, nil
+}
+
+// UpsertReservationPolicy creates or replaces the reservation policy of a restaurant
+func (s *store) UpsertReservationPolicy(policy types.ReservationPolicy) error {
+	query := `
+		INSERT INTO reservationPolicy (idRestaurant, gracePeriodMinutes, slotDurationMinutes, noShowLimit, noShowWindowDays, blockDays, reminderHoursBefore, modificationCutoffMinutes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			gracePeriodMinutes = VALUES(gracePeriodMinutes),
+			slotDurationMinutes = VALUES(slotDurationMinutes),
+			noShowLimit = VALUES(noShowLimit),
+			noShowWindowDays = VALUES(noShowWindowDays),
+			blockDays = VALUES(blockDays),
+			reminderHoursBefore = VALUES(reminderHoursBefore),
+			modificationCutoffMinutes = VALUES(modificationCutoffMinutes)
+	`
+	_, err := s.db.Exec(query,
+		policy.IdRestaurant,
+		policy.GracePeriodMinutes,
+		policy.SlotDurationMinutes,
+		policy.NoShowLimit,
+		policy.NoShowWindowDays,
+		policy.BlockDays,
+		policy.ReminderHoursBefore,
+		policy.ModificationCutoffMinutes,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving reservation policy: %v", err)
+	}
+	return nil
+}
+
+// Helper function to get the status the sweep moves an elapsed reservation to, or "" while it is not over yet.
+// A pending reservation the restaurant never confirmed expires once its slot is over, a confirmed one whose guests
+// did not check in within the grace period is a no-show, and a checked-in visit is completed after its slot.
+func sweptReservationStatus(status string, checkedIn bool, timeFrom time.Time, graceMinutes, slotMinutes int, now time.Time) string {
+	slotEnd := timeFrom.Add(time.Duration(slotMinutes) * time.Minute)
+	switch {
+	case status == "pending" && now.After(slotEnd):
+		return "expired"
+	case status == "confirmed" && !checkedIn && now.After(timeFrom.Add(time.Duration(graceMinutes)*time.Minute)):
+		return "no_show"
+	case status == "confirmed" && checkedIn && now.After(slotEnd):
+		return "completed"
+	}
+	return ""
+}
+
+// SweepElapsedReservations expires pending reservations whose slot is over, marks confirmed reservations nobody
+// checked in to as no-shows after the grace period and completes checked-in visits once their slot is over,
+// which frees their tables. Only no-shows count towards the booking ban of a client.
+func (s *store) SweepElapsedReservations(now time.Time) (*types.ReservationSweepResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		SELECT r.idReservation, r.status, r.checkedInAt IS NOT NULL, r.timeFrom,
+			IFNULL(rp.gracePeriodMinutes, ?), IFNULL(rp.slotDurationMinutes, ?)
+		FROM reservation r
+		LEFT JOIN reservationPolicy rp ON rp.idRestaurant = r.idRestaurant
+		WHERE r.status IN ('pending', 'confirmed') AND r.timeFrom < ?
+		FOR UPDATE
+	`
+	rows, err := tx.Query(query, defaultGracePeriodMinutes, defaultSlotDurationMinutes, now)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting elapsed reservations: %v", err)
+	}
+	swept := make(map[string][]string)
+	previous := make(map[string]string)
+	for rows.Next() {
+		var idReservation, status string
+		var checkedIn bool
+		var timeFrom time.Time
+		var graceMinutes, slotMinutes int
+		if err = rows.Scan(&idReservation, &status, &checkedIn, &timeFrom, &graceMinutes, &slotMinutes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning elapsed reservation: %v", err)
+		}
+		if newStatus := sweptReservationStatus(status, checkedIn, timeFrom, graceMinutes, slotMinutes, now); newStatus != "" {
+			swept[newStatus] = append(swept[newStatus], idReservation)
+			previous[idReservation] = status
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating elapsed reservations: %v", err)
+	}
+
+	for _, newStatus := range []string{"expired", "no_show", "completed"} {
+		ids := swept[newStatus]
+		if len(ids) == 0 {
+			continue
+		}
+		query := `UPDATE reservation SET status = ? WHERE status IN ('pending', 'confirmed') AND idReservation IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		if _, err = tx.Exec(query, append([]interface{}{newStatus}, convertToInterfaceSlice(ids)...)...); err != nil {
+			return nil, fmt.Errorf("error sweeping %s reservations: %v", newStatus, err)
+		}
+		for _, idReservation := range ids {
+			entry := &types.ReservationHistoryEntry{
+				IdReservation:  idReservation,
+				Action:         "status_changed",
+				Actor:          "scheduler",
+				PreviousStatus: previous[idReservation],
+				NewStatus:      newStatus,
+			}
+			if err = insertReservationHistory(tx, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &types.ReservationSweepResult{
+		NoShows:   []string{},
+		Expired:   int64(len(swept["expired"])),
+		Completed: int64(len(swept["completed"])),
+	}
+	result.NoShows = append(result.NoShows, swept["no_show"]...)
+
+	// The no-show fee is kept from the deposits of no-shows, the others get theirs back in full: the visit took
+	// place, or the restaurant never confirmed the booking
+	for _, newStatus := range []string{"expired", "no_show", "completed"} {
+		for _, idReservation := range swept[newStatus] {
+			if err = s.queueDepositSettlement(tx, idReservation, newStatus); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return result, nil
+}
+
+// clientBlockedUntil returns the end of the booking ban of a client at a restaurant, or nil if none applies
+func (s *store) clientBlockedUntil(idClient, idRestaurant string, now time.Time) (*time.Time, int, error) {
+	policy, err := s.GetReservationPolicy(idRestaurant)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT COUNT(*), MAX(timeFrom)
+		FROM reservation
+		WHERE idClient = ? AND idRestaurant = ? AND status = 'no_show'
+		AND timeFrom >= ?
+	`
+	var count int
+	var lastNoShow sql.NullTime
+	windowStart := now.AddDate(0, 0, -policy.NoShowWindowDays)
+	err = s.db.QueryRow(query, idClient, idRestaurant, windowStart).Scan(&count, &lastNoShow)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting client no-shows: %v", err)
+	}
+
+	if policy.NoShowLimit <= 0 || count < policy.NoShowLimit || !lastNoShow.Valid {
+		return nil, count, nil
+	}
+	blockedUntil := lastNoShow.Time.AddDate(0, 0, policy.BlockDays)
+	if !blockedUntil.After(now) {
+		return nil, count, nil
+	}
+	return &blockedUntil, count, nil
+}
+
+// GetClientNoShowSummary returns the no-show history of a client, and the ban status at a restaurant when one is given
+func (s *store) GetClientNoShowSummary(idClient string, idRestaurant string) (*types.ClientNoShowSummary, error) {
+	summary := types.ClientNoShowSummary{IdClient: idClient, IdRestaurant: idRestaurant}
+
+	var lastNoShow sql.NullTime
+	query := `SELECT COUNT(*), MAX(timeFrom) FROM reservation WHERE idClient = ? AND status = 'no_show'`
+	err := s.db.QueryRow(query, idClient).Scan(&summary.TotalNoShows, &lastNoShow)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving client no-shows: %v", err)
+	}
+	if lastNoShow.Valid {
+		summary.LastNoShowAt = &lastNoShow.Time
+	}
+
+	if idRestaurant != "" {
+		blockedUntil, count, err := s.clientBlockedUntil(idClient, idRestaurant, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		summary.RestaurantNoShows = count
+		summary.BlockedUntil = blockedUntil
+	}
+
+	return &summary, nil
+}
+
+// enqueueReservationEmail queues a client email for a reservation
+func (s *store) enqueueReservationEmail(idReservation, jobType string, sendAt time.Time) error {
+	idJob, err := utils.CreateAnId()
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO emailJob (idJob, idReservation, jobType, sendAt, status) VALUES (?, ?, ?, ?, 'pending')`
+	if _, err := s.db.Exec(query, idJob, idReservation, jobType, sendAt); err != nil {
+		return fmt.Errorf("error queueing %s email: %v", jobType, err)
+	}
+	return nil
+}
+
+// cancelPendingReminders drops reminders that should no longer be sent for a reservation
+func (s *store) cancelPendingReminders(idReservation string) error {
+	query := `UPDATE emailJob SET status = 'cancelled' WHERE idReservation = ? AND jobType = 'reminder' AND status = 'pending'`
+	if _, err := s.db.Exec(query, idReservation); err != nil {
+		return fmt.Errorf("error cancelling reminders: %v", err)
+	}
+	return nil
+}
+
+// queueNewReservationEmails queues the booking received email and the reminder before timeFrom
+func (s *store) queueNewReservationEmails(idReservation, idRestaurant string, timeFrom time.Time) error {
+	if err := s.enqueueReservationEmail(idReservation, "received", time.Now()); err != nil {
+		return err
+	}
+	return s.queueReservationReminder(idReservation, idRestaurant, timeFrom)
+}
+
+// queueReservationReminder queues the reminder sent ReminderHoursBefore the reservation
+func (s *store) queueReservationReminder(idReservation, idRestaurant string, timeFrom time.Time) error {
+	now := time.Now()
+	policy, err := s.GetReservationPolicy(idRestaurant)
+	if err != nil {
+		return err
+	}
+	if policy.ReminderHoursBefore <= 0 {
+		return nil
+	}
+	remindAt := timeFrom.Add(-time.Duration(policy.ReminderHoursBefore) * time.Hour)
+	if !remindAt.After(now) {
+		return nil
+	}
+	return s.enqueueReservationEmail(idReservation, "reminder", remindAt)
+}
+
+// GetDueEmailJobs returns pending email jobs whose send time has passed
+func (s *store) GetDueEmailJobs(now time.Time, limit int) ([]types.EmailJob, error) {
+	query := `
+		SELECT ej.idJob, IFNULL(ej.idReservation, ''), IFNULL(ej.idOrder, ''), ej.jobType, ej.sendAt, ej.attempts,
+			c.idClient, p.email, p.firstName, rest.name,
+			COALESCE(r.timeFrom, tk.pickupTime), IFNULL(r.numberOfPeople, 0), COALESCE(r.status, ol.status),
+			IFNULL(cep.reservationEmails, 1), IFNULL(cep.reminderEmails, 1)
+		FROM emailJob ej
+		LEFT JOIN reservation r ON r.idReservation = ej.idReservation
+		LEFT JOIN takeawayOrder tk ON tk.idOrder = ej.idOrder
+		LEFT JOIN orderList ol ON ol.idOrder = ej.idOrder
+		JOIN client c ON c.idClient = COALESCE(r.idClient, tk.idClient)
+		JOIN profile p ON p.idProfile = c.idProfile
+		JOIN restaurant rest ON rest.idRestaurant = COALESCE(r.idRestaurant, tk.idRestaurant)
+		LEFT JOIN clientEmailPreference cep ON cep.idClient = c.idClient
+		WHERE ej.status = 'pending' AND ej.sendAt <= ?
+		ORDER BY ej.sendAt ASC
+		LIMIT ?
+	`
+	rows, err := s.db.Query(query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving due email jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var jobs []types.EmailJob
+	for rows.Next() {
+		var job types.EmailJob
+		if err := rows.Scan(
+			&job.IdJob,
+			&job.IdReservation,
+			&job.IdOrder,
+			&job.JobType,
+			&job.SendAt,
+			&job.Attempts,
+			&job.IdClient,
+			&job.Email,
+			&job.FirstName,
+			&job.RestaurantName,
+			&job.TimeFrom,
+			&job.NumberOfPeople,
+			&job.ReservationStatus,
+			&job.ReservationEmails,
+			&job.ReminderEmails,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning email job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *store) MarkEmailJobSent(idJob string) error {
+	query := `UPDATE emailJob SET status = 'sent', attempts = attempts + 1, sentAt = ?, lastError = NULL WHERE idJob = ?`
+	if _, err := s.db.Exec(query, time.Now(), idJob); err != nil {
+		return fmt.Errorf("error marking email job as sent: %v", err)
+	}
+	return nil
+}
+
+func (s *store) MarkEmailJobSkipped(idJob string, reason string) error {
+	query := `UPDATE emailJob SET status = 'skipped', lastError = ? WHERE idJob = ?`
+	if _, err := s.db.Exec(query, reason, idJob); err != nil {
+		return fmt.Errorf("error marking email job as skipped: %v", err)
+	}
+	return nil
+}
+
+// MarkEmailJobFailed records a failed attempt; the job is retried at retryAt, or given up when retryAt is nil
+func (s *store) MarkEmailJobFailed(idJob string, errMsg string, retryAt *time.Time) error {
+	var err error
+	if retryAt != nil {
+		query := `UPDATE emailJob SET attempts = attempts + 1, lastError = ?, sendAt = ? WHERE idJob = ?`
+		_, err = s.db.Exec(query, errMsg, *retryAt, idJob)
+	} else {
+		query := `UPDATE emailJob SET status = 'failed', attempts = attempts + 1, lastError = ? WHERE idJob = ?`
+		_, err = s.db.Exec(query, errMsg, idJob)
+	}
+	if err != nil {
+		return fmt.Errorf("error marking email job as failed: %v", err)
+	}
+	return nil
+}
+
+func (s *store) GetClientEmailPreferences(idClient string) (*types.ClientEmailPreferences, error) {
+	prefs := types.ClientEmailPreferences{IdClient: idClient, ReservationEmails: true, ReminderEmails: true}
+	query := `SELECT reservationEmails, reminderEmails FROM clientEmailPreference WHERE idClient = ?`
+	err := s.db.QueryRow(query, idClient).Scan(&prefs.ReservationEmails, &prefs.ReminderEmails)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error retrieving email preferences: %v", err)
+	}
+	return &prefs, nil
+}
+
+func (s *store) UpdateClientEmailPreferences(prefs types.ClientEmailPreferences) error {
+	query := `
+		INSERT INTO clientEmailPreference (idClient, reservationEmails, reminderEmails)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			reservationEmails = VALUES(reservationEmails),
+			reminderEmails = VALUES(reminderEmails)
+	`
+	if _, err := s.db.Exec(query, prefs.IdClient, prefs.ReservationEmails, prefs.ReminderEmails); err != nil {
+		return fmt.Errorf("error saving email preferences: %v", err)
+	}
+	return nil
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertReservationHistory records a change of a reservation, inside a transaction when one is given
+func insertReservationHistory(db execer, entry *types.ReservationHistoryEntry) error {
+	idHistory, err := utils.CreateAnId()
+	if err != nil {
+		return err
+	}
+	entry.IdHistory = idHistory
+	entry.CreatedAt = time.Now()
+
+	var previousStatus, newStatus sql.NullString
+	if entry.PreviousStatus != "" {
+		previousStatus = sql.NullString{String: entry.PreviousStatus, Valid: true}
+	}
+	if entry.NewStatus != "" {
+		newStatus = sql.NullString{String: entry.NewStatus, Valid: true}
+	}
+
+	query := `
+		INSERT INTO reservationHistory (
+			idHistory, idReservation, action, actor,
+			previousTimeFrom, newTimeFrom, previousNumberOfPeople, newNumberOfPeople,
+			previousStatus, newStatus, createdAt
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = db.Exec(query,
+		entry.IdHistory,
+		entry.IdReservation,
+		entry.Action,
+		entry.Actor,
+		entry.PreviousTimeFrom,
+		entry.NewTimeFrom,
+		entry.PreviousNumberOfPeople,
+		entry.NewNumberOfPeople,
+		previousStatus,
+		newStatus,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording reservation history: %v", err)
+	}
+	return nil
+}
+
+type lockedReservation struct {
+	idClient       string
+	idRestaurant   string
+	idTable        sql.NullString
+	status         string
+	timeFrom       time.Time
+	numberOfPeople int
+	checkedIn      bool
+}
+
+// Helper function to load a reservation for update and check that the client may still change it
+// Past the cutoff only a cancellation covered by a paid deposit is accepted, and it is reported as late.
+func (s *store) lockClientReservation(tx *sql.Tx, idReservation, idClient string, cancelling bool) (*lockedReservation, *types.ReservationPolicy, bool, error) {
+	var res lockedReservation
+	query := `
+		SELECT idClient, idRestaurant, idTable, status, timeFrom, numberOfPeople, checkedInAt IS NOT NULL
+		FROM reservation
+		WHERE idReservation = ?
+		FOR UPDATE
+	`
+	err := tx.QueryRow(query, idReservation).Scan(
+		&res.idClient,
+		&res.idRestaurant,
+		&res.idTable,
+		&res.status,
+		&res.timeFrom,
+		&res.numberOfPeople,
+		&res.checkedIn,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, false, fmt.Errorf("reservation with ID %s not found", idReservation)
+		}
+		return nil, nil, false, fmt.Errorf("error fetching reservation: %v", err)
+	}
+	if res.idClient != idClient {
+		return nil, nil, false, fmt.Errorf("reservation does not belong to this client")
+	}
+	if res.status != "pending" && res.status != "confirmed" {
+		return nil, nil, false, fmt.Errorf("only pending or confirmed reservations can be changed, this one is %s", res.status)
+	}
+	if res.checkedIn {
+		return nil, nil, false, fmt.Errorf("only pending or confirmed reservations can be changed, the guests of this one already checked in")
+	}
+
+	policy, err := s.GetReservationPolicy(res.idRestaurant)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	cutoff := res.timeFrom.Add(-time.Duration(policy.ModificationCutoffMinutes) * time.Minute)
+	if !time.Now().After(cutoff) {
+		return &res, policy, false, nil
+	}
+
+	if cancelling {
+		var depositStatus string
+		err = tx.QueryRow(`SELECT status FROM reservationDeposit WHERE idReservation = ?`, idReservation).Scan(&depositStatus)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, nil, false, fmt.Errorf("error fetching deposit: %v", err)
+		}
+		if depositStatus == "paid" {
+			return &res, policy, true, nil
+		}
+	}
+	return nil, nil, false, fmt.Errorf("modification cutoff passed, changes are closed %d minutes before the reservation", policy.ModificationCutoffMinutes)
+}
+
+// Helper function to check that a table and the restaurant capacity can take a reservation at a given time
+func checkReservationAvailability(db queryExecer, idReservation, idRestaurant string, idTable sql.NullString, timeFrom time.Time, numberOfPeople, slotMinutes int) error {
+	if idTable.Valid && idTable.String != "" {
+		var overlapping int
+		query := `
+			SELECT COUNT(*)
+			FROM reservation
+			WHERE idTable = ? AND idReservation <> ?
+			AND status IN ('pending', 'confirmed')
+			AND ABS(TIMESTAMPDIFF(MINUTE, timeFrom, ?)) < ?
+		`
+		err := db.QueryRow(query, idTable.String, idReservation, timeFrom, slotMinutes).Scan(&overlapping)
+		if err != nil {
+			return fmt.Errorf("error checking table availability: %v", err)
+		}
+		if overlapping > 0 {
+			return fmt.Errorf("table is not available at the requested time")
+		}
+	}
+
+	var capacity, booked int
+	err := db.QueryRow(`SELECT IFNULL(capacity, 0) FROM restaurant WHERE idRestaurant = ?`, idRestaurant).Scan(&capacity)
+	if err != nil {
+		return fmt.Errorf("error fetching restaurant capacity: %v", err)
+	}
+	if capacity <= 0 {
+		return nil
+	}
+	query := `
+		SELECT IFNULL(SUM(numberOfPeople), 0)
+		FROM reservation
+		WHERE idRestaurant = ? AND idReservation <> ?
+		AND status IN ('pending', 'confirmed')
+		AND ABS(TIMESTAMPDIFF(MINUTE, timeFrom, ?)) < ?
+	`
+	err = db.QueryRow(query, idRestaurant, idReservation, timeFrom, slotMinutes).Scan(&booked)
+	if err != nil {
+		return fmt.Errorf("error checking restaurant capacity: %v", err)
+	}
+	if booked+numberOfPeople > capacity {
+		return fmt.Errorf("restaurant is not available for %d people at the requested time", numberOfPeople)
+	}
+	return nil
+}
+
+// ModifyReservationByClient reschedules a reservation and/or changes its party size. A rescheduled reservation
+// goes back to pending until the restaurant confirms the new time.
+func (s *store) ModifyReservationByClient(idReservation string, idClient string, modification types.ReservationModification) (*types.ReservationHistoryEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, policy, _, err := s.lockClientReservation(tx, idReservation, idClient, false)
+	if err != nil {
+		return nil, err
+	}
+
+	newTimeFrom := res.timeFrom
+	if modification.TimeFrom != nil {
+		newTimeFrom = *modification.TimeFrom
+	}
+	newNumberOfPeople := res.numberOfPeople
+	if modification.NumberOfPeople != nil {
+		newNumberOfPeople = *modification.NumberOfPeople
+	}
+	rescheduled := !newTimeFrom.Equal(res.timeFrom)
+	resized := newNumberOfPeople != res.numberOfPeople
+	if !rescheduled && !resized {
+		err = fmt.Errorf("nothing to change on this reservation")
+		return nil, err
+	}
+
+	if rescheduled {
+		if !newTimeFrom.After(time.Now()) {
+			err = fmt.Errorf("new reservation time must be in the future")
+			return nil, err
+		}
+		date := newTimeFrom.Format("2006-01-02")
+		var count int
+		checkQuery := `
+			SELECT COUNT(*) FROM reservation
+			WHERE idClient = ? AND DATE(timeFrom) = ? AND idReservation <> ?
+			AND status IN ('pending', 'confirmed')
+		`
+		if err = tx.QueryRow(checkQuery, idClient, date, idReservation).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			err = fmt.Errorf("You already has a reservation on %s", date)
+			return nil, err
+		}
+	}
+
+	err = checkReservationAvailability(tx, idReservation, res.idRestaurant, res.idTable, newTimeFrom, newNumberOfPeople, policy.SlotDurationMinutes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The restaurant only accepted the old time, a rescheduled reservation waits for it to confirm again
+	newStatus := res.status
+	if rescheduled {
+		newStatus = "pending"
+	}
+	_, err = tx.Exec(`UPDATE reservation SET timeFrom = ?, numberOfPeople = ?, status = ? WHERE idReservation = ?`,
+		newTimeFrom, newNumberOfPeople, newStatus, idReservation)
+	if err != nil {
+		return nil, fmt.Errorf("error updating reservation: %v", err)
+	}
+
+	entry := &types.ReservationHistoryEntry{
+		IdReservation: idReservation,
+		IdRestaurant:  res.idRestaurant,
+		Actor:         "client",
+	}
+	switch {
+	case rescheduled && resized:
+		entry.Action = "modified"
+	case rescheduled:
+		entry.Action = "rescheduled"
+	default:
+		entry.Action = "party_size_changed"
+	}
+	if rescheduled {
+		entry.PreviousTimeFrom = &res.timeFrom
+		entry.NewTimeFrom = &newTimeFrom
+		entry.PreviousStatus = res.status
+		entry.NewStatus = newStatus
+	}
+	if resized {
+		entry.PreviousNumberOfPeople = &res.numberOfPeople
+		entry.NewNumberOfPeople = &newNumberOfPeople
+	}
+	if err = insertReservationHistory(tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err = s.refreshDepositRequirement(tx, idReservation, res.idRestaurant, newNumberOfPeople, newTimeFrom); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	if rescheduled {
+		if mailErr := s.cancelPendingReminders(idReservation); mailErr == nil {
+			mailErr = s.queueReservationReminder(idReservation, res.idRestaurant, newTimeFrom)
+			if mailErr != nil {
+				log.Printf("Error queueing reminder for reservation %s: %v", idReservation, mailErr)
+			}
+		} else {
+			log.Printf("Error cancelling reminders for reservation %s: %v", idReservation, mailErr)
+		}
+	}
+	return entry, nil
+}
+
+// CancelReservationByClient cancels a pending or confirmed reservation; past the cutoff the deposit fee is kept
+func (s *store) CancelReservationByClient(idReservation string, idClient string) (*types.ReservationHistoryEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, _, late, err := s.lockClientReservation(tx, idReservation, idClient, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(`UPDATE reservation SET status = 'cancelled' WHERE idReservation = ?`, idReservation); err != nil {
+		return nil, fmt.Errorf("error cancelling reservation: %v", err)
+	}
+
+	outcome := "cancelled"
+	if late {
+		outcome = "late_cancellation"
+	}
+	if err = s.queueDepositSettlement(tx, idReservation, outcome); err != nil {
+		return nil, err
+	}
+
+	entry := &types.ReservationHistoryEntry{
+		IdReservation:  idReservation,
+		IdRestaurant:   res.idRestaurant,
+		Action:         "cancelled",
+		Actor:          "client",
+		PreviousStatus: res.status,
+		NewStatus:      "cancelled",
+	}
+	if err = insertReservationHistory(tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	if mailErr := s.cancelPendingReminders(idReservation); mailErr != nil {
+		log.Printf("Error cancelling reminders for reservation %s: %v", idReservation, mailErr)
+	} else if mailErr := s.enqueueReservationEmail(idReservation, "cancelled", time.Now()); mailErr != nil {
+		log.Printf("Error queueing cancellation email for reservation %s: %v", idReservation, mailErr)
+	}
+	return entry, nil
+}
+
+// GetReservationHistory lists the changes of a reservation, oldest first
+func (s *store) GetReservationHistory(idReservation string) ([]types.ReservationHistoryEntry, error) {
+	query := `
+		SELECT h.idHistory, h.idReservation, r.idRestaurant, h.action, h.actor,
+			h.previousTimeFrom, h.newTimeFrom, h.previousNumberOfPeople, h.newNumberOfPeople,
+			IFNULL(h.previousStatus, ''), IFNULL(h.newStatus, ''), h.createdAt
+		FROM reservationHistory h
+		JOIN reservation r ON r.idReservation = h.idReservation
+		WHERE h.idReservation = ?
+		ORDER BY h.createdAt ASC
+	`
+	rows, err := s.db.Query(query, idReservation)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving reservation history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []types.ReservationHistoryEntry{}
+	for rows.Next() {
+		var entry types.ReservationHistoryEntry
+		var previousTimeFrom, newTimeFrom sql.NullTime
+		var previousPeople, newPeople sql.NullInt64
+		if err := rows.Scan(
+			&entry.IdHistory,
+			&entry.IdReservation,
+			&entry.IdRestaurant,
+			&entry.Action,
+			&entry.Actor,
+			&previousTimeFrom,
+			&newTimeFrom,
+			&previousPeople,
+			&newPeople,
+			&entry.PreviousStatus,
+			&entry.NewStatus,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning reservation history: %v", err)
+		}
+		if previousTimeFrom.Valid {
+			entry.PreviousTimeFrom = &previousTimeFrom.Time
+		}
+		if newTimeFrom.Valid {
+			entry.NewTimeFrom = &newTimeFrom.Time
+		}
+		if previousPeople.Valid {
+			n := int(previousPeople.Int64)
+			entry.PreviousNumberOfPeople = &n
+		}
+		if newPeople.Valid {
+			n := int(newPeople.Int64)
+			entry.NewNumberOfPeople = &n
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// InviteFriendsToReservation invites friends of the host to join a reservation
+func (s *store) InviteFriendsToReservation(idReservation string, idHost string, friendIds []string) ([]types.ReservationGuest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var owner, status string
+	err = tx.QueryRow(`SELECT idClient, status FROM reservation WHERE idReservation = ?`, idReservation).Scan(&owner, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("reservation with ID %s not found", idReservation)
+			return nil, err
+		}
+		return nil, fmt.Errorf("error fetching reservation: %v", err)
+	}
+	if owner != idHost {
+		err = fmt.Errorf("reservation does not belong to this client")
+		return nil, err
+	}
+	if status != "pending" && status != "confirmed" {
+		err = fmt.Errorf("only pending or confirmed reservations accept guests, this one is %s", status)
+		return nil, err
+	}
+
+	friendQuery := `
+		SELECT COUNT(*) FROM friendship
+		WHERE status = 'accepted'
+		AND ((idClient1 = ? AND idClient2 = ?) OR (idClient1 = ? AND idClient2 = ?))
+	`
+	inviteQuery := `
+		INSERT INTO reservationGuest (idReservationGuest, idReservation, idClient, status, invitedAt)
+		VALUES (?, ?, ?, 'invited', ?)
+		ON DUPLICATE KEY UPDATE
+			status = IF(status = 'declined', 'invited', status),
+			invitedAt = IF(status = 'invited', VALUES(invitedAt), invitedAt)
+	`
+	for _, idFriend := range friendIds {
+		if idFriend == idHost {
+			continue
+		}
+		var isFriend int
+		if err = tx.QueryRow(friendQuery, idHost, idFriend, idFriend, idHost).Scan(&isFriend); err != nil {
+			return nil, fmt.Errorf("error checking friendship: %v", err)
+		}
+		if isFriend == 0 {
+			err = fmt.Errorf("client %s is not in your friend list", idFriend)
+			return nil, err
+		}
+
+		var idGuest string
+		idGuest, err = utils.CreateAnId()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tx.Exec(inviteQuery, idGuest, idReservation, idFriend, time.Now()); err != nil {
+			return nil, fmt.Errorf("error inviting guest: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return s.GetReservationGuests(idReservation)
+}
+
+// RespondToReservationInvitation accepts or declines an invitation and updates the party size
+func (s *store) RespondToReservationInvitation(idReservation string, idClient string, response string) (*types.ReservationHistoryEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var guestStatus string
+	guestQuery := `SELECT status FROM reservationGuest WHERE idReservation = ? AND idClient = ? FOR UPDATE`
+	err = tx.QueryRow(guestQuery, idReservation, idClient).Scan(&guestStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("invitation not found")
+			return nil, err
+		}
+		return nil, fmt.Errorf("error fetching invitation: %v", err)
+	}
+
+	var idRestaurant, status string
+	var idTable sql.NullString
+	var timeFrom time.Time
+	var numberOfPeople int
+	reservationQuery := `
+		SELECT idRestaurant, idTable, status, timeFrom, numberOfPeople
+		FROM reservation
+		WHERE idReservation = ?
+		FOR UPDATE
+	`
+	err = tx.QueryRow(reservationQuery, idReservation).Scan(&idRestaurant, &idTable, &status, &timeFrom, &numberOfPeople)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reservation: %v", err)
+	}
+	if status != "pending" && status != "confirmed" {
+		err = fmt.Errorf("only pending or confirmed reservations accept guests, this one is %s", status)
+		return nil, err
+	}
+
+	delta := 0
+	switch {
+	case guestStatus == "invited" && response == "accepted":
+		delta = 1
+	case guestStatus == "invited" && response == "declined":
+	case guestStatus == "accepted" && response == "declined":
+		delta = -1
+	default:
+		err = fmt.Errorf("invalid invitation response from %s to %s", guestStatus, response)
+		return nil, err
+	}
+
+	newNumberOfPeople := numberOfPeople + delta
+	if delta > 0 {
+		var policy *types.ReservationPolicy
+		policy, err = s.GetReservationPolicy(idRestaurant)
+		if err != nil {
+			return nil, err
+		}
+		err = checkReservationAvailability(tx, idReservation, idRestaurant, idTable, timeFrom, newNumberOfPeople, policy.SlotDurationMinutes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE reservationGuest SET status = ?, respondedAt = ? WHERE idReservation = ? AND idClient = ?`,
+		response, time.Now(), idReservation, idClient)
+	if err != nil {
+		return nil, fmt.Errorf("error updating invitation: %v", err)
+	}
+
+	entry := &types.ReservationHistoryEntry{
+		IdReservation: idReservation,
+		IdRestaurant:  idRestaurant,
+		Action:        "guest_" + response,
+		Actor:         "guest",
+	}
+	if delta != 0 {
+		_, err = tx.Exec(`UPDATE reservation SET numberOfPeople = ? WHERE idReservation = ?`, newNumberOfPeople, idReservation)
+		if err != nil {
+			return nil, fmt.Errorf("error updating party size: %v", err)
+		}
+		entry.PreviousNumberOfPeople = &numberOfPeople
+		entry.NewNumberOfPeople = &newNumberOfPeople
+		if err = s.refreshDepositRequirement(tx, idReservation, idRestaurant, newNumberOfPeople, timeFrom); err != nil {
+			return nil, err
+		}
+	}
+	if err = insertReservationHistory(tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return entry, nil
+}
+
+func (s *store) GetReservationGuests(idReservation string) ([]types.ReservationGuest, error) {
+	query := `
+		SELECT rg.idReservationGuest, rg.idReservation, rg.idClient, c.username,
+			p.firstName, p.lastName, rg.status, rg.invitedAt, rg.respondedAt
+		FROM reservationGuest rg
+		JOIN client c ON c.idClient = rg.idClient
+		JOIN profile p ON p.idProfile = c.idProfile
+		WHERE rg.idReservation = ?
+		ORDER BY rg.invitedAt ASC
+	`
+	rows, err := s.db.Query(query, idReservation)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving reservation guests: %v", err)
+	}
+	defer rows.Close()
+
+	guests := []types.ReservationGuest{}
+	for rows.Next() {
+		var guest types.ReservationGuest
+		var respondedAt sql.NullTime
+		if err := rows.Scan(
+			&guest.IdReservationGuest,
+			&guest.IdReservation,
+			&guest.IdClient,
+			&guest.Username,
+			&guest.FirstName,
+			&guest.LastName,
+			&guest.Status,
+			&guest.InvitedAt,
+			&respondedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning reservation guest: %v", err)
+		}
+		if respondedAt.Valid {
+			guest.RespondedAt = &respondedAt.Time
+		}
+		guests = append(guests, guest)
+	}
+	return guests, rows.Err()
+}
+
+// GetClientReservationInvitations lists the invitations a client has not answered yet
+func (s *store) GetClientReservationInvitations(idClient string) ([]types.ClientReservationInfo, error) {
+	query := `
+        SELECT 
+            r.idReservation,
+            r.timeFrom,
+            r.numberOfPeople,
+            r.status,
+            r.createdAt,
+            rest.name,
+            rest.image,
+            rest.location,
+            rest.idRestaurant,
+            rg.status
+        FROM reservationGuest rg
+        JOIN reservation r ON r.idReservation = rg.idReservation
+        JOIN restaurant rest ON r.idRestaurant = rest.idRestaurant
+        WHERE rg.idClient = ? AND rg.status = 'invited'
+        AND r.status IN ('pending', 'confirmed')
+        ORDER BY r.timeFrom ASC
+    `
+	rows, err := s.db.Query(query, idClient)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving invitations: %v", err)
+	}
+	defer rows.Close()
+
+	invitations := []types.ClientReservationInfo{}
+	for rows.Next() {
+		var reservation types.ClientReservationInfo
+		if err := rows.Scan(
+			&reservation.IdReservation,
+			&reservation.TimeFrom,
+			&reservation.NumberOfPeople,
+			&reservation.Status,
+			&reservation.CreatedAt,
+			&reservation.RestaurantName,
+			&reservation.RestaurantImage,
+			&reservation.RestaurantLocation,
+			&reservation.IdRestaurant,
+			&reservation.GuestStatus,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning invitation: %v", err)
+		}
+		invitations = append(invitations, reservation)
+	}
+	return invitations, rows.Err()
+}
+
+// AddGuestItemsToOrder adds items ordered by the host or an accepted guest to the reservation's shared order
+func (s *store) AddGuestItemsToOrder(idReservation string, idClient string, foods []types.FoodItem) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var host, idRestaurant, status string
+	err = tx.QueryRow(`SELECT idClient, idRestaurant, status FROM reservation WHERE idReservation = ?`, idReservation).
+		Scan(&host, &idRestaurant, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("reservation with ID %s not found", idReservation)
+			return "", err
+		}
+		return "", fmt.Errorf("error fetching reservation: %v", err)
+	}
+	if status != "pending" && status != "confirmed" {
+		err = fmt.Errorf("only pending or confirmed reservations accept orders, this one is %s", status)
+		return "", err
+	}
+
+	// NULL marks lines added by the host
+	var orderedBy sql.NullString
+	if idClient != host {
+		var guestStatus string
+		err = tx.QueryRow(`SELECT status FROM reservationGuest WHERE idReservation = ? AND idClient = ?`, idReservation, idClient).Scan(&guestStatus)
+		if err != nil && err != sql.ErrNoRows {
+			return "", fmt.Errorf("error fetching guest: %v", err)
+		}
+		if err == sql.ErrNoRows || guestStatus != "accepted" {
+			err = fmt.Errorf("client does not belong to this reservation")
+			return "", err
+		}
+		orderedBy = sql.NullString{String: idClient, Valid: true}
+	}
+
+	var idOrder, orderStatus string
+	orderQuery := `
+		SELECT idOrder, status FROM orderList
+		WHERE idReservation = ? AND status <> 'cancelled'
+		ORDER BY createdAt DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+	err = tx.QueryRow(orderQuery, idReservation).Scan(&idOrder, &orderStatus)
+	if err == sql.ErrNoRows {
+		idOrder, err = utils.CreateAnId()
+		if err != nil {
+			return "", err
+		}
+		_, err = tx.Exec(`INSERT INTO orderList (idOrder, idReservation, totalPrice, status, createdAt) VALUES (?, ?, ?, ?, ?)`,
+			idOrder, idReservation, 0, "pending", time.Now())
+		if err != nil {
+			return "", fmt.Errorf("error creating order: %v", err)
+		}
+		orderStatus = "pending"
+	} else if err != nil {
+		return "", fmt.Errorf("error fetching order: %v", err)
+	}
+	if orderStatus == "completed" {
+		err = fmt.Errorf("order %s is already completed", idOrder)
+		return "", err
+	}
+
+	var added float64
+	for _, food := range foods {
+		var lineTotal float64
+		lineTotal, err = addOrderLine(tx, idOrder, idRestaurant, food, orderedBy)
+		if err != nil {
+			return "", err
+		}
+		added += lineTotal
+	}
+
+	if _, err = tx.Exec(`UPDATE orderList SET totalPrice = totalPrice + ? WHERE idOrder = ?`, added, idOrder); err != nil {
+		return "", fmt.Errorf("error updating order total: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+	return idOrder, nil
+}
+
+func (s *store) GetBillingSettings(idRestaurant string) (*types.BillingSettings, error) {
+	settings := types.BillingSettings{IdRestaurant: idRestaurant}
+	query := `SELECT taxRate, serviceChargeRate FROM restaurantBillingSettings WHERE idRestaurant = ?`
+	err := s.db.QueryRow(query, idRestaurant).Scan(&settings.TaxRate, &settings.ServiceChargeRate)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error retrieving billing settings: %v", err)
+	}
+	return &settings, nil
+}
+
+func (s *store) UpsertBillingSettings(settings types.BillingSettings) error {
+	query := `
+		INSERT INTO restaurantBillingSettings (idRestaurant, taxRate, serviceChargeRate)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			taxRate = VALUES(taxRate),
+			serviceChargeRate = VALUES(serviceChargeRate)
+	`
+	if _, err := s.db.Exec(query, settings.IdRestaurant, settings.TaxRate, settings.ServiceChargeRate); err != nil {
+		return fmt.Errorf("error saving billing settings: %v", err)
+	}
+	return nil
+}
+
+// AssignOrderLine moves an order line to another participant of the reservation
+func (s *store) AssignOrderLine(idOrder string, idFood string, fromClient string, toClient string) error {
+	var idReservation, host string
+	query := `
+		SELECT r.idReservation, r.idClient
+		FROM orderList ol
+		JOIN reservation r ON r.idReservation = ol.idReservation
+		WHERE ol.idOrder = ?
+	`
+	err := s.db.QueryRow(query, idOrder).Scan(&idReservation, &host)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order with ID %s not found", idOrder)
+		}
+		return fmt.Errorf("error fetching order: %v", err)
+	}
+
+	if toClient != host {
+		var count int
+		guestQuery := `SELECT COUNT(*) FROM reservationGuest WHERE idReservation = ? AND idClient = ? AND status = 'accepted'`
+		if err := s.db.QueryRow(guestQuery, idReservation, toClient).Scan(&count); err != nil {
+			return fmt.Errorf("error fetching guest: %v", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("client does not belong to this reservation")
+		}
+	}
+
+	// Host lines are stored with a NULL idClient
+	var from, to sql.NullString
+	if fromClient != "" && fromClient != host {
+		from = sql.NullString{String: fromClient, Valid: true}
+	}
+	if toClient != host {
+		to = sql.NullString{String: toClient, Valid: true}
+	}
+
+	res, err := s.db.Exec(`UPDATE orderFood SET idClient = ? WHERE idOrder = ? AND idFood = ? AND idClient <=> ?`,
+		to, idOrder, idFood, from)
+	if err != nil {
+		return fmt.Errorf("error assigning order line: %v", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("order line not found")
+	}
+	return nil
+}
+
+// ComputeReservationBill builds the bill of a reservation from all its orders, split according to the request
+func (s *store) ComputeReservationBill(idReservation string, req types.BillRequest) (*types.Bill, error) {
+	bill := types.Bill{IdReservation: idReservation, Mode: req.Mode, Lines: []types.BillLine{}}
+
+	var host string
+	err := s.db.QueryRow(`SELECT idClient, idRestaurant FROM reservation WHERE idReservation = ?`, idReservation).
+		Scan(&host, &bill.IdRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("reservation with ID %s not found", idReservation)
+		}
+		return nil, fmt.Errorf("error fetching reservation: %v", err)
+	}
+
+	settings, err := s.GetBillingSettings(bill.IdRestaurant)
+	if err != nil {
+		return nil, err
+	}
+	bill.TaxRate = settings.TaxRate
+	bill.ServiceChargeRate = settings.ServiceChargeRate
+
+	// Participants: the host first, then accepted guests
+	participantsQuery := `
+		SELECT c.idClient, c.username, 1 AS isHost, 0 AS sortOrder
+		FROM reservation r
+		JOIN client c ON c.idClient = r.idClient
+		WHERE r.idReservation = ?
+		UNION ALL
+		SELECT c.idClient, c.username, 0, 1
+		FROM reservationGuest rg
+		JOIN client c ON c.idClient = rg.idClient
+		WHERE rg.idReservation = ? AND rg.status = 'accepted'
+		ORDER BY sortOrder
+	`
+	rows, err := s.db.Query(participantsQuery, idReservation, idReservation)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving bill participants: %v", err)
+	}
+	shareIndex := make(map[string]int)
+	for rows.Next() {
+		var share types.BillShare
+		var sortOrder int
+		if err := rows.Scan(&share.IdClient, &share.Username, &share.IsHost, &sortOrder); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning bill participant: %v", err)
+		}
+		shareIndex[share.IdClient] = len(bill.Shares)
+		bill.Shares = append(bill.Shares, share)
+	}
+	rows.Close()
+	if len(bill.Shares) == 0 {
+		return nil, fmt.Errorf("reservation with ID %s has no participants", idReservation)
+	}
+
+	linesQuery := `
+		SELECT f.idFood, f.name, IFNULL(ofd.unitPrice, f.price), ofd.quantity, IFNULL(ofd.idClient, r.idClient)
+		FROM orderFood ofd
+		JOIN orderList ol ON ol.idOrder = ofd.idOrder
+		JOIN reservation r ON r.idReservation = ol.idReservation
+		JOIN food f ON f.idFood = ofd.idFood
+		WHERE ol.idReservation = ? AND ol.status <> 'cancelled'
+		ORDER BY ofd.createdAt
+	`
+	rows, err = s.db.Query(linesQuery, idReservation)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving bill lines: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line types.BillLine
+		if err := rows.Scan(&line.IdFood, &line.Name, &line.UnitPrice, &line.Quantity, &line.IdClient); err != nil {
+			return nil, fmt.Errorf("error scanning bill line: %v", err)
+		}
+		line.Subtotal = roundMoney(line.UnitPrice * float64(line.Quantity))
+		bill.Subtotal += line.Subtotal
+
+		// Lines of guests who left the reservation fall back to the host
+		i, ok := shareIndex[line.IdClient]
+		if !ok {
+			i = 0
+		}
+		bill.Shares[i].Items = append(bill.Shares[i].Items, line)
+		bill.Shares[i].Subtotal = roundMoney(bill.Shares[i].Subtotal + line.Subtotal)
+		bill.Lines = append(bill.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bill lines: %v", err)
+	}
+
+	bill.Subtotal = roundMoney(bill.Subtotal)
+	bill.ServiceCharge = roundMoney(bill.Subtotal * settings.ServiceChargeRate / 100)
+	bill.Tax = roundMoney((bill.Subtotal + bill.ServiceCharge) * settings.TaxRate / 100)
+	bill.Total = roundMoney(bill.Subtotal + bill.ServiceCharge + bill.Tax)
+
+	if err := splitBill(&bill, req); err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+// splitBill fills the share amounts; rounding leftovers always go to the host
+func splitBill(bill *types.Bill, req types.BillRequest) error {
+	totalCents := int64(math.Round(bill.Total * 100))
+	var assigned int64
+
+	switch req.Mode {
+	case "even":
+		each := totalCents / int64(len(bill.Shares))
+		for i := range bill.Shares {
+			bill.Shares[i].Items = nil
+			bill.Shares[i].Amount = float64(each) / 100
+			assigned += each
+		}
+	case "by_item":
+		for i := range bill.Shares {
+			var cents int64
+			if bill.Subtotal > 0 {
+				cents = int64(math.Round(bill.Shares[i].Subtotal / bill.Subtotal * bill.Total * 100))
+			}
+			bill.Shares[i].Amount = float64(cents) / 100
+			assigned += cents
+		}
+	case "custom":
+		for i := range bill.Shares {
+			bill.Shares[i].Items = nil
+		}
+		for idClient := range req.CustomAmounts {
+			found := false
+			for _, share := range bill.Shares {
+				if share.IdClient == idClient {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("client %s does not belong to this reservation", idClient)
+			}
+		}
+		for i := range bill.Shares {
+			amount := req.CustomAmounts[bill.Shares[i].IdClient]
+			if amount < 0 {
+				return fmt.Errorf("custom amounts must be positive")
+			}
+			cents := int64(math.Round(amount * 100))
+			bill.Shares[i].Amount = float64(cents) / 100
+			assigned += cents
+		}
+		if assigned != totalCents {
+			return fmt.Errorf("custom amounts must add up to %.2f, got %.2f", bill.Total, float64(assigned)/100)
+		}
+	default:
+		return fmt.Errorf("invalid bill mode %s", req.Mode)
+	}
+
+	bill.Shares[0].Amount = roundMoney(bill.Shares[0].Amount + float64(totalCents-assigned)/100)
+	return nil
+}
+
+// SaveReservationBill computes a bill and stores it with its shares
+func (s *store) SaveReservationBill(idReservation string, req types.BillRequest) (*types.Bill, error) {
+	bill, err := s.ComputeReservationBill(idReservation, req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	bill.IdBill, err = utils.CreateAnId()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	bill.CreatedAt = &now
+
+	_, err = tx.Exec(`
+		INSERT INTO bill (idBill, idReservation, mode, subtotal, serviceCharge, tax, total, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		bill.IdBill, bill.IdReservation, bill.Mode, bill.Subtotal, bill.ServiceCharge, bill.Tax, bill.Total, now)
+	if err != nil {
+		return nil, fmt.Errorf("error saving bill: %v", err)
+	}
+
+	for _, share := range bill.Shares {
+		var idShare string
+		idShare, err = utils.CreateAnId()
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`INSERT INTO billShare (idBillShare, idBill, idClient, amount) VALUES (?, ?, ?, ?)`,
+			idShare, bill.IdBill, share.IdClient, share.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("error saving bill share: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return bill, nil
+}
+
+// Helper function to round an amount to cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// GetDepositPolicy returns the deposit policy of a restaurant, deposits are disabled when none is set
+func (s *store) GetDepositPolicy(idRestaurant string) (*types.DepositPolicy, error) {
+	query := `
+		SELECT idRestaurant, enabled, minPartySize, peakDays, peakStartTime, peakEndTime,
+			amountPerPerson, lateCancellationFeePercent, noShowFeePercent
+		FROM depositPolicy
+		WHERE idRestaurant = ?
+	`
+	var policy types.DepositPolicy
+	var peakDays string
+	err := s.db.QueryRow(query, idRestaurant).Scan(
+		&policy.IdRestaurant,
+		&policy.Enabled,
+		&policy.MinPartySize,
+		&peakDays,
+		&policy.PeakStartTime,
+		&policy.PeakEndTime,
+		&policy.AmountPerPerson,
+		&policy.LateCancellationFeePercent,
+		&policy.NoShowFeePercent,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &types.DepositPolicy{
+				IdRestaurant:               idRestaurant,
+				PeakDays:                   []int{},
+				LateCancellationFeePercent: 100,
+				NoShowFeePercent:           100,
+			}, nil
+		}
+		return nil, fmt.Errorf("error retrieving deposit policy: %v", err)
+	}
+
+	policy.PeakDays = []int{}
+	for _, day := range strings.Split(peakDays, ",") {
+		if day == "" {
+			continue
+		}
+		weekday, err := strconv.Atoi(day)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peak day %q in deposit policy", day)
+		}
+		policy.PeakDays = append(policy.PeakDays, weekday)
+	}
+	return &policy, nil
+}
+
+// UpsertDepositPolicy creates or replaces the deposit policy of a restaurant
+func (s *store) UpsertDepositPolicy(policy types.DepositPolicy) error {
+	days := make([]string, 0, len(policy.PeakDays))
+	for _, day := range policy.PeakDays {
+		days = append(days, strconv.Itoa(day))
+	}
+	query := `
+		INSERT INTO depositPolicy (idRestaurant, enabled, minPartySize, peakDays, peakStartTime, peakEndTime,
+			amountPerPerson, lateCancellationFeePercent, noShowFeePercent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			enabled = VALUES(enabled),
+			minPartySize = VALUES(minPartySize),
+			peakDays = VALUES(peakDays),
+			peakStartTime = VALUES(peakStartTime),
+			peakEndTime = VALUES(peakEndTime),
+			amountPerPerson = VALUES(amountPerPerson),
+			lateCancellationFeePercent = VALUES(lateCancellationFeePercent),
+			noShowFeePercent = VALUES(noShowFeePercent)
+	`
+	_, err := s.db.Exec(query,
+		policy.IdRestaurant,
+		policy.Enabled,
+		policy.MinPartySize,
+		strings.Join(days, ","),
+		policy.PeakStartTime,
+		policy.PeakEndTime,
+		policy.AmountPerPerson,
+		policy.LateCancellationFeePercent,
+		policy.NoShowFeePercent,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving deposit policy: %v", err)
+	}
+	return nil
+}
+
+// GetReservationDeposit returns the deposit of a reservation, or nil when none is required
+func (s *store) GetReservationDeposit(idReservation string) (*types.ReservationDeposit, error) {
+	query := `
+		SELECT idReservation, idRestaurant, amount, reason, status, idPayment, IFNULL(outcome, ''),
+			feeAmount, refundAmount, createdAt, settledAt
+		FROM reservationDeposit
+		WHERE idReservation = ?
+	`
+	var deposit types.ReservationDeposit
+	var idPayment sql.NullString
+	var settledAt sql.NullTime
+	err := s.db.QueryRow(query, idReservation).Scan(
+		&deposit.IdReservation,
+		&deposit.IdRestaurant,
+		&deposit.Amount,
+		&deposit.Reason,
+		&deposit.Status,
+		&idPayment,
+		&deposit.Outcome,
+		&deposit.FeeAmount,
+		&deposit.RefundAmount,
+		&deposit.CreatedAt,
+		&settledAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error retrieving reservation deposit: %v", err)
+	}
+	if idPayment.Valid {
+		deposit.IdPayment = &idPayment.String
+	}
+	if settledAt.Valid {
+		deposit.SettledAt = &settledAt.Time
+	}
+	return &deposit, nil
+}
+
+// Helper function to tell whether a booking needs a deposit under the policy, and how much
+func depositRequirement(policy *types.DepositPolicy, numberOfPeople int, timeFrom time.Time) (string, float64) {
+	if !policy.Enabled || policy.AmountPerPerson <= 0 {
+		return "", 0
+	}
+	amount := roundMoney(policy.AmountPerPerson * float64(numberOfPeople))
+	if policy.MinPartySize > 0 && numberOfPeople >= policy.MinPartySize {
+		return "party_size", amount
+	}
+	if policy.PeakStartTime != "" && policy.PeakEndTime != "" {
+		slot := timeFrom.Format("15:04")
+		for _, day := range policy.PeakDays {
+			if time.Weekday(day) == timeFrom.Weekday() && slot >= policy.PeakStartTime && slot < policy.PeakEndTime {
+				return "peak_slot", amount
+			}
+		}
+	}
+	return "", 0
+}
+
+type queryExecer interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// refreshDepositRequirement adds, resizes or drops the unpaid deposit of a reservation after it was booked or changed.
+// A deposit that has already been paid is left as it is.
+func (s *store) refreshDepositRequirement(db queryExecer, idReservation, idRestaurant string, numberOfPeople int, timeFrom time.Time) error {
+	policy, err := s.GetDepositPolicy(idRestaurant)
+	if err != nil {
+		return err
+	}
+	reason, amount := depositRequirement(policy, numberOfPeople, timeFrom)
+
+	var status string
+	err = db.QueryRow(`SELECT status FROM reservationDeposit WHERE idReservation = ?`, idReservation).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error fetching deposit: %v", err)
+	}
+
+	switch {
+	case err == sql.ErrNoRows && reason != "":
+		_, err = db.Exec(`
+			INSERT INTO reservationDeposit (idReservation, idRestaurant, amount, reason, status, createdAt)
+			VALUES (?, ?, ?, ?, 'required', ?)`,
+			idReservation, idRestaurant, amount, reason, time.Now())
+	case err == sql.ErrNoRows:
+		return nil
+	case status != "required":
+		return nil
+	case reason != "":
+		_, err = db.Exec(`UPDATE reservationDeposit SET amount = ?, reason = ? WHERE idReservation = ?`, amount, reason, idReservation)
+	default:
+		_, err = db.Exec(`DELETE FROM reservationDeposit WHERE idReservation = ?`, idReservation)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving deposit: %v", err)
+	}
+	return nil
+}
+
+// queueDepositSettlement closes the deposit of a reservation that ended. Unpaid deposits are dropped; paid ones
+// are queued for the payment settler with the fee kept for a late cancellation or a no-show and the rest refunded.
+// Any other outcome (cancelled in time or by the restaurant, expired, completed) is refunded in full.
+func (s *store) queueDepositSettlement(db queryExecer, idReservation string, outcome string) error {
+	var idRestaurant, status string
+	var amount float64
+	err := db.QueryRow(`SELECT idRestaurant, status, amount FROM reservationDeposit WHERE idReservation = ?`, idReservation).
+		Scan(&idRestaurant, &status, &amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("error fetching deposit: %v", err)
+	}
+
+	switch status {
+	case "required":
+		_, err = db.Exec(`UPDATE reservationDeposit SET status = 'cancelled', outcome = ? WHERE idReservation = ?`, outcome, idReservation)
+	case "paid":
+		policy, policyErr := s.GetDepositPolicy(idRestaurant)
+		if policyErr != nil {
+			return policyErr
+		}
+		fee, refund := depositSettlement(*policy, amount, outcome)
+		_, err = db.Exec(`
+			UPDATE reservationDeposit
+			SET status = 'pending_settlement', outcome = ?, feeAmount = ?, refundAmount = ?
+			WHERE idReservation = ?`,
+			outcome, fee, refund, idReservation)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error settling deposit: %v", err)
+	}
+	return nil
+}
+
+// Helper function to split a paid deposit into the fee kept by the restaurant and the refund for an outcome
+func depositSettlement(policy types.DepositPolicy, amount float64, outcome string) (float64, float64) {
+	feePercent := 0.0
+	switch outcome {
+	case "late_cancellation":
+		feePercent = policy.LateCancellationFeePercent
+	case "no_show":
+		feePercent = policy.NoShowFeePercent
+	}
+	fee := roundMoney(amount * feePercent / 100)
+	return fee, roundMoney(amount - fee)
+}
+
+// Orders come from a reservation, a QR code table session or a takeaway request
+const kitchenTicketQuery = `
+	SELECT ol.idOrder, ol.idReservation, ol.idTableSession, COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant),
+		COALESCE(r.idTable, ts.idTable), IFNULL(r.numberOfPeople, 0),
+		IF(tk.idOrder IS NULL, 'dine_in', 'takeaway'), tk.pickupTime,
+		ol.status, ol.createdAt, ol.preparingAt, ol.readyAt
+	FROM orderList ol
+	LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+	LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+	LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+`
+
+// GetKitchenTickets returns the orders the kitchen still has to deal with, oldest first
+func (s *store) GetKitchenTickets(idRestaurant string) ([]types.KitchenTicket, error) {
+	query := kitchenTicketQuery + `
+		WHERE COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant) = ? AND ol.status IN ('pending', 'preparing', 'ready')
+		ORDER BY ol.createdAt ASC
+	`
+	return s.queryKitchenTickets(query, idRestaurant)
+}
+
+func (s *store) GetKitchenTicket(idOrder string) (*types.KitchenTicket, error) {
+	tickets, err := s.queryKitchenTickets(kitchenTicketQuery+` WHERE ol.idOrder = ?`, idOrder)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 {
+		return nil, fmt.Errorf("order with ID %s not found", idOrder)
+	}
+	return &tickets[0], nil
+}
+
+func (s *store) queryKitchenTickets(query string, args ...interface{}) ([]types.KitchenTicket, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving kitchen orders: %v", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	tickets := []types.KitchenTicket{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var ticket types.KitchenTicket
+		var idReservation, idTableSession, idTable sql.NullString
+		var pickupTime, preparingAt, readyAt sql.NullTime
+		err := rows.Scan(
+			&ticket.IdOrder,
+			&idReservation,
+			&idTableSession,
+			&ticket.IdRestaurant,
+			&idTable,
+			&ticket.NumberOfPeople,
+			&ticket.OrderType,
+			&pickupTime,
+			&ticket.Status,
+			&ticket.CreatedAt,
+			&preparingAt,
+			&readyAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning kitchen order: %v", err)
+		}
+		ticket.IdReservation = idReservation.String
+		ticket.IdTableSession = idTableSession.String
+		if idTable.Valid {
+			ticket.IdTable = &idTable.String
+		}
+		if pickupTime.Valid {
+			ticket.PickupTime = &pickupTime.Time
+		}
+		if preparingAt.Valid {
+			ticket.PreparingAt = &preparingAt.Time
+			end := now
+			if readyAt.Valid {
+				end = readyAt.Time
+			}
+			ticket.PreparationSeconds = int64(end.Sub(preparingAt.Time).Seconds())
+		}
+		if readyAt.Valid {
+			ticket.ReadyAt = &readyAt.Time
+		}
+		ticket.ElapsedSeconds = int64(now.Sub(ticket.CreatedAt).Seconds())
+		ticket.Categories = []types.KitchenCategoryGroup{}
+		index[ticket.IdOrder] = len(tickets)
+		tickets = append(tickets, ticket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating kitchen orders: %v", err)
+	}
+	if len(tickets) == 0 {
+		return tickets, nil
+	}
+
+	ids := make([]string, 0, len(tickets))
+	placeholders := ""
+	for i, ticket := range tickets {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		ids = append(ids, ticket.IdOrder)
+	}
+	// Lines with options stay apart, lines placed before options existed are merged per food
+	linesQuery := `
+		SELECT ofd.idOrder, IFNULL(ofd.idOrderFood, ''), f.idFood, f.name, IFNULL(fc.idCategory, ''), IFNULL(fc.nameCategorie, 'Other'), SUM(ofd.quantity)
+		FROM orderFood ofd
+		JOIN food f ON f.idFood = ofd.idFood
+		LEFT JOIN foodCategory fc ON fc.idCategory = f.idCategory
+		WHERE ofd.idOrder IN (` + placeholders + `)
+		GROUP BY ofd.idOrder, ofd.idOrderFood, f.idFood, f.name, fc.idCategory, fc.nameCategorie, fc.sortIndex
+		ORDER BY fc.sortIndex, fc.nameCategorie, f.name, MIN(ofd.createdAt)
+	`
+	lineRows, err := s.db.Query(linesQuery, convertToInterfaceSlice(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving kitchen order lines: %v", err)
+	}
+	type kitchenRow struct {
+		idOrder    string
+		idCategory string
+		category   string
+		line       types.KitchenLine
+	}
+	var lines []kitchenRow
+	var idOrderFoods, idFoods []string
+	for lineRows.Next() {
+		var row kitchenRow
+		if err := lineRows.Scan(&row.idOrder, &row.line.IdOrderFood, &row.line.IdFood, &row.line.Name, &row.idCategory, &row.category, &row.line.Quantity); err != nil {
+			lineRows.Close()
+			return nil, fmt.Errorf("error scanning kitchen order line: %v", err)
+		}
+		if row.line.IdOrderFood != "" {
+			idOrderFoods = append(idOrderFoods, row.line.IdOrderFood)
+		}
+		idFoods = append(idFoods, row.line.IdFood)
+		lines = append(lines, row)
+	}
+	lineRows.Close()
+	if err := lineRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating kitchen order lines: %v", err)
+	}
+
+	options, err := loadOrderLineOptions(s.db, idOrderFoods)
+	if err != nil {
+		return nil, err
+	}
+	components, err := loadComboItems(s.db, idFoods)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range lines {
+		line := row.line
+		line.Options = options[line.IdOrderFood]
+		if line.Options == nil {
+			line.Options = []types.OrderLineOption{}
+		}
+		line.Components = components[line.IdFood]
+		if line.Components == nil {
+			line.Components = []types.ComboItem{}
+		}
+
+		ticket := &tickets[index[row.idOrder]]
+		last := len(ticket.Categories) - 1
+		if last < 0 || ticket.Categories[last].IdCategory != row.idCategory {
+			ticket.Categories = append(ticket.Categories, types.KitchenCategoryGroup{
+				IdCategory: row.idCategory,
+				Category:   row.category,
+				Items:      []types.KitchenLine{},
+			})
+			last++
+		}
+		ticket.Categories[last].Items = append(ticket.Categories[last].Items, line)
+	}
+	return tickets, nil
+}
+
+// AdvanceKitchenOrder applies a kitchen display action to an order of the restaurant.
+// "bump" moves the order one step (pending -> preparing -> ready -> completed), "ready" jumps to ready.
+func (s *store) AdvanceKitchenOrder(idRestaurant string, idOrder string, action string) (*types.KitchenTicket, error) {
+	ticket, err := s.GetKitchenTicket(idOrder)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.IdRestaurant != idRestaurant {
+		return nil, fmt.Errorf("order does not belong to this restaurant")
+	}
+
+	next := ""
+	switch {
+	case action == "bump" && ticket.Status == "pending":
+		next = "preparing"
+	case action == "bump" && ticket.Status == "preparing":
+		next = "ready"
+	case action == "bump" && ticket.Status == "ready":
+		next = "completed"
+	case action == "ready" && (ticket.Status == "pending" || ticket.Status == "preparing"):
+		next = "ready"
+	case action != "bump" && action != "ready":
+		return nil, fmt.Errorf("invalid kitchen action %s", action)
+	default:
+		return nil, fmt.Errorf("cannot %s an order that is %s", action, ticket.Status)
+	}
+
+	if err := s.UpdateOrderStatus(idOrder, next); err != nil {
+		return nil, err
+	}
+	return s.GetKitchenTicket(idOrder)
+}
+
+// GetTableQrCode returns the QR code token of a table, issuing one the first time it is asked for
+func (s *store) GetTableQrCode(idRestaurant string, idTable string) (*types.TableQrCode, error) {
+	var qrToken sql.NullString
+	query := `SELECT qrToken FROM table_restaurant WHERE idTable = ? AND idRestaurant = ?`
+	err := s.db.QueryRow(query, idTable, idRestaurant).Scan(&qrToken)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("table with ID %s not found in this restaurant", idTable)
+		}
+		return nil, fmt.Errorf("error retrieving table: %v", err)
+	}
+	if qrToken.Valid {
+		return &types.TableQrCode{IdTable: idTable, IdRestaurant: idRestaurant, Token: qrToken.String}, nil
+	}
+	return s.RotateTableQrCode(idRestaurant, idTable)
+}
+
+// RotateTableQrCode issues a new token for a table, the previously printed code stops working
+func (s *store) RotateTableQrCode(idRestaurant string, idTable string) (*types.TableQrCode, error) {
+	token, err := utils.CreateTableToken(idTable)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(`UPDATE table_restaurant SET qrToken = ? WHERE idTable = ? AND idRestaurant = ?`, token, idTable, idRestaurant)
+	if err != nil {
+		return nil, fmt.Errorf("error saving table QR code: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("table with ID %s not found in this restaurant", idTable)
+	}
+	return &types.TableQrCode{IdTable: idTable, IdRestaurant: idRestaurant, Token: token}, nil
+}
+
+// Helper function to resolve a scanned QR code token to its table and restaurant
+func tableFromToken(db queryExecer, token string, lock bool) (string, string, error) {
+	idTable, err := utils.ParseTableToken(token)
+	if err != nil {
+		return "", "", err
+	}
+	query := `SELECT idRestaurant FROM table_restaurant WHERE idTable = ? AND qrToken = ?`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	var idRestaurant string
+	err = db.QueryRow(query, idTable, token).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", fmt.Errorf("invalid table code")
+		}
+		return "", "", fmt.Errorf("error retrieving table: %v", err)
+	}
+	return idTable, idRestaurant, nil
+}
+
+// OpenTableSession opens a session for the scanned table, or joins the one already open there
+func (s *store) OpenTableSession(token string) (*types.TableSession, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Locking the table row keeps two guests scanning at once from opening two sessions
+	idTable, idRestaurant, err := tableFromToken(tx, token, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var idTableSession string
+	err = tx.QueryRow(`SELECT idTableSession FROM tableSession WHERE idTable = ? AND status = 'open'`, idTable).Scan(&idTableSession)
+	if err == sql.ErrNoRows {
+		idTableSession, err = utils.CreateAnId()
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`INSERT INTO tableSession (idTableSession, idRestaurant, idTable, status, openedAt) VALUES (?, ?, ?, 'open', ?)`,
+			idTableSession, idRestaurant, idTable, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("error opening table session: %v", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching table session: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return s.GetTableSession(idTableSession)
+}
+
+func (s *store) GetTableSession(idTableSession string) (*types.TableSession, error) {
+	query := `
+		SELECT idTableSession, idRestaurant, idTable, status, openedAt, closedAt
+		FROM tableSession
+		WHERE idTableSession = ?
+	`
+	var session types.TableSession
+	var closedAt sql.NullTime
+	err := s.db.QueryRow(query, idTableSession).Scan(
+		&session.IdTableSession,
+		&session.IdRestaurant,
+		&session.IdTable,
+		&session.Status,
+		&session.OpenedAt,
+		&closedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("table session with ID %s not found", idTableSession)
+		}
+		return nil, fmt.Errorf("error retrieving table session: %v", err)
+	}
+	if closedAt.Valid {
+		session.ClosedAt = &closedAt.Time
+	}
+
+	ordersQuery := `
+		SELECT ol.idOrder, ol.totalPrice, ol.createdAt, ol.status, IFNULL(SUM(ofd.quantity), 0)
+		FROM orderList ol
+		LEFT JOIN orderFood ofd ON ofd.idOrder = ol.idOrder
+		WHERE ol.idTableSession = ?
+		GROUP BY ol.idOrder, ol.totalPrice, ol.createdAt, ol.status
+		ORDER BY ol.createdAt ASC
+	`
+	rows, err := s.db.Query(ordersQuery, idTableSession)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving table session orders: %v", err)
+	}
+	defer rows.Close()
+
+	session.Orders = []types.ClientOrderSummary{}
+	for rows.Next() {
+		var order types.ClientOrderSummary
+		if err := rows.Scan(&order.IdOrder, &order.TotalPrice, &order.CreatedAt, &order.Status, &order.ItemCount); err != nil {
+			return nil, fmt.Errorf("error scanning table session order: %v", err)
+		}
+		if order.Status != "cancelled" {
+			session.Total += order.TotalPrice
+		}
+		session.Orders = append(session.Orders, order)
+	}
+	session.Total = roundMoney(session.Total)
+	return &session, rows.Err()
+}
+
+// PlaceTableSessionOrder creates an order for an open table session. The guest sends the
+// table token again so that only someone seated at the table can order on its session.
+func (s *store) PlaceTableSessionOrder(idTableSession string, token string, foods []types.FoodItem) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	idTable, idRestaurant, err := tableFromToken(tx, token, false)
+	if err != nil {
+		return "", err
+	}
+
+	var sessionTable, status string
+	err = tx.QueryRow(`SELECT idTable, status FROM tableSession WHERE idTableSession = ? FOR UPDATE`, idTableSession).Scan(&sessionTable, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("table session with ID %s not found", idTableSession)
+			return "", err
+		}
+		return "", fmt.Errorf("error fetching table session: %v", err)
+	}
+	if sessionTable != idTable {
+		err = fmt.Errorf("table code does not belong to this session")
+		return "", err
+	}
+	if status != "open" {
+		err = fmt.Errorf("table session is already closed")
+		return "", err
+	}
+
+	idOrder, err := utils.CreateAnId()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`INSERT INTO orderList (idOrder, idTableSession, totalPrice, status, createdAt) VALUES (?, ?, ?, ?, ?)`,
+		idOrder, idTableSession, 0, "pending", time.Now())
+	if err != nil {
+		return "", fmt.Errorf("error creating order: %v", err)
+	}
+
+	var total float64
+	for _, food := range foods {
+		var lineTotal float64
+		lineTotal, err = addOrderLine(tx, idOrder, idRestaurant, food, sql.NullString{})
+		if err != nil {
+			return "", err
+		}
+		total += lineTotal
+	}
+
+	if _, err = tx.Exec(`UPDATE orderList SET totalPrice = ? WHERE idOrder = ?`, total, idOrder); err != nil {
+		return "", fmt.Errorf("error updating order total: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+	return idOrder, nil
+}
+
+// CloseTableSession closes a session once its bill is settled at the counter
+func (s *store) CloseTableSession(idTableSession string) error {
+	result, err := s.db.Exec(`UPDATE tableSession SET status = 'closed', closedAt = ? WHERE idTableSession = ? AND status = 'open'`,
+		time.Now(), idTableSession)
+	if err != nil {
+		return fmt.Errorf("error closing table session: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		if _, err := s.GetTableSession(idTableSession); err != nil {
+			return err
+		}
+		return fmt.Errorf("table session is already closed")
+	}
+	return nil
+}
+
+// enqueueOrderEmail queues an email about a takeaway order for the email dispatcher
+func (s *store) enqueueOrderEmail(idOrder, jobType string, sendAt time.Time) error {
+	idJob, err := utils.CreateAnId()
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO emailJob (idJob, idOrder, jobType, sendAt, status) VALUES (?, ?, ?, ?, 'pending')`
+	if _, err := s.db.Exec(query, idJob, idOrder, jobType, sendAt); err != nil {
+		return fmt.Errorf("error queueing %s email: %v", jobType, err)
+	}
+	return nil
+}
+
+// GetTakeawaySettings returns the takeaway settings of a restaurant, takeaway is disabled when none are set
+func (s *store) GetTakeawaySettings(idRestaurant string) (*types.TakeawaySettings, error) {
+	query := `
+		SELECT idRestaurant, enabled, windowMinutes, maxOrdersPerWindow, minLeadMinutes, firstPickupTime, lastPickupTime
+		FROM takeawaySettings
+		WHERE idRestaurant = ?
+	`
+	var settings types.TakeawaySettings
+	err := s.db.QueryRow(query, idRestaurant).Scan(
+		&settings.IdRestaurant,
+		&settings.Enabled,
+		&settings.WindowMinutes,
+		&settings.MaxOrdersPerWindow,
+		&settings.MinLeadMinutes,
+		&settings.FirstPickupTime,
+		&settings.LastPickupTime,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &types.TakeawaySettings{
+				IdRestaurant:       idRestaurant,
+				WindowMinutes:      15,
+				MaxOrdersPerWindow: 5,
+				MinLeadMinutes:     20,
+				FirstPickupTime:    "11:00",
+				LastPickupTime:     "22:00",
+			}, nil
+		}
+		return nil, fmt.Errorf("error retrieving takeaway settings: %v", err)
+	}
+	return &settings, nil
+}
+
+func (s *store) UpsertTakeawaySettings(settings types.TakeawaySettings) error {
+	query := `
+		INSERT INTO takeawaySettings (idRestaurant, enabled, windowMinutes, maxOrdersPerWindow, minLeadMinutes, firstPickupTime, lastPickupTime)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			enabled = VALUES(enabled),
+			windowMinutes = VALUES(windowMinutes),
+			maxOrdersPerWindow = VALUES(maxOrdersPerWindow),
+			minLeadMinutes = VALUES(minLeadMinutes),
+			firstPickupTime = VALUES(firstPickupTime),
+			lastPickupTime = VALUES(lastPickupTime)
+	`
+	_, err := s.db.Exec(query,
+		settings.IdRestaurant,
+		settings.Enabled,
+		settings.WindowMinutes,
+		settings.MaxOrdersPerWindow,
+		settings.MinLeadMinutes,
+		settings.FirstPickupTime,
+		settings.LastPickupTime,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving takeaway settings: %v", err)
+	}
+	return nil
+}
+
+// Helper function to turn an "HH:MM" clock time into the given day
+func clockTimeOn(day time.Time, clock string) (time.Time, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location()), nil
+}
+
+// Helper function to find the pickup window a time falls in; windows start at firstPickupTime
+func pickupWindowStart(settings *types.TakeawaySettings, pickupTime time.Time) (time.Time, error) {
+	first, err := clockTimeOn(pickupTime, settings.FirstPickupTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	window := time.Duration(settings.WindowMinutes) * time.Minute
+	return first.Add(pickupTime.Sub(first) / window * window), nil
+}
+
+// GetTakeawayWindows lists the pickup windows of a day with how many orders each one still accepts
+func (s *store) GetTakeawayWindows(idRestaurant string, day time.Time) ([]types.TakeawayWindow, error) {
+	settings, err := s.GetTakeawaySettings(idRestaurant)
+	if err != nil {
+		return nil, err
+	}
+	first, err := clockTimeOn(day, settings.FirstPickupTime)
+	if err != nil {
+		return nil, err
+	}
+	last, err := clockTimeOn(day, settings.LastPickupTime)
+	if err != nil {
+		return nil, err
+	}
+	window := time.Duration(settings.WindowMinutes) * time.Minute
+
+	query := `
+		SELECT tk.pickupTime
+		FROM takeawayOrder tk
+		JOIN orderList ol ON ol.idOrder = tk.idOrder
+		WHERE tk.idRestaurant = ? AND ol.status <> 'cancelled'
+		AND tk.pickupTime >= ? AND tk.pickupTime < ?
+	`
+	rows, err := s.db.Query(query, idRestaurant, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving takeaway orders: %v", err)
+	}
+	defer rows.Close()
+
+	booked := make(map[int64]int)
+	for rows.Next() {
+		var pickupTime time.Time
+		if err := rows.Scan(&pickupTime); err != nil {
+			return nil, fmt.Errorf("error scanning takeaway order: %v", err)
+		}
+		booked[int64(pickupTime.Sub(first)/window)]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating takeaway orders: %v", err)
+	}
+
+	earliest := time.Now().Add(time.Duration(settings.MinLeadMinutes) * time.Minute)
+	windows := []types.TakeawayWindow{}
+	for i, start := int64(0), first; start.Before(last); i, start = i+1, start.Add(window) {
+		end := start.Add(window)
+		if end.After(last) {
+			end = last
+		}
+		windows = append(windows, types.TakeawayWindow{
+			Start:     start,
+			End:       end,
+			Capacity:  settings.MaxOrdersPerWindow,
+			Booked:    booked[i],
+			Available: settings.Enabled && end.After(earliest) && booked[i] < settings.MaxOrdersPerWindow,
+		})
+	}
+	return windows, nil
+}
+
+// CreateTakeawayOrder places a takeaway order for a pickup time, within the capacity of its pickup window
+func (s *store) CreateTakeawayOrder(order types.TakeawayOrderCreation) (string, error) {
+	settings, err := s.GetTakeawaySettings(order.IdRestaurant)
+	if err != nil {
+		return "", err
+	}
+	if !settings.Enabled {
+		return "", fmt.Errorf("takeaway is not available at this restaurant")
+	}
+	earliest := time.Now().Add(time.Duration(settings.MinLeadMinutes) * time.Minute)
+	if order.PickupTime.Before(earliest) {
+		return "", fmt.Errorf("pickup time must be at least %d minutes from now", settings.MinLeadMinutes)
+	}
+	first, err := clockTimeOn(order.PickupTime, settings.FirstPickupTime)
+	if err != nil {
+		return "", err
+	}
+	last, err := clockTimeOn(order.PickupTime, settings.LastPickupTime)
+	if err != nil {
+		return "", err
+	}
+	if order.PickupTime.Before(first) || !order.PickupTime.Before(last) {
+		return "", fmt.Errorf("pickup time must be between %s and %s", settings.FirstPickupTime, settings.LastPickupTime)
+	}
+	windowStart, err := pickupWindowStart(settings, order.PickupTime)
+	if err != nil {
+		return "", err
+	}
+	windowEnd := windowStart.Add(time.Duration(settings.WindowMinutes) * time.Minute)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Locking the restaurant row serialises the capacity check of concurrent orders
+	var idRestaurant string
+	err = tx.QueryRow(`SELECT idRestaurant FROM restaurant WHERE idRestaurant = ? FOR UPDATE`, order.IdRestaurant).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("restaurant with ID %s not found", order.IdRestaurant)
+			return "", err
+		}
+		return "", fmt.Errorf("error fetching restaurant: %v", err)
+	}
+
+	var booked int
+	countQuery := `
+		SELECT COUNT(*)
+		FROM takeawayOrder tk
+		JOIN orderList ol ON ol.idOrder = tk.idOrder
+		WHERE tk.idRestaurant = ? AND ol.status <> 'cancelled'
+		AND tk.pickupTime >= ? AND tk.pickupTime < ?
+	`
+	if err = tx.QueryRow(countQuery, order.IdRestaurant, windowStart, windowEnd).Scan(&booked); err != nil {
+		return "", fmt.Errorf("error counting takeaway orders: %v", err)
+	}
+	if booked >= settings.MaxOrdersPerWindow {
+		err = fmt.Errorf("pickup window %s-%s is full", windowStart.Format("15:04"), windowEnd.Format("15:04"))
+		return "", err
+	}
+
+	idOrder, err := utils.CreateAnId()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`INSERT INTO orderList (idOrder, totalPrice, status, createdAt) VALUES (?, ?, ?, ?)`,
+		idOrder, 0, "pending", time.Now())
+	if err != nil {
+		return "", fmt.Errorf("error creating order: %v", err)
+	}
+	_, err = tx.Exec(`INSERT INTO takeawayOrder (idOrder, idRestaurant, idClient, pickupTime) VALUES (?, ?, ?, ?)`,
+		idOrder, order.IdRestaurant, order.IdClient, order.PickupTime)
+	if err != nil {
+		return "", fmt.Errorf("error creating takeaway order: %v", err)
+	}
+
+	var total float64
+	for _, food := range order.Foods {
+		var lineTotal float64
+		lineTotal, err = addOrderLine(tx, idOrder, order.IdRestaurant, food, sql.NullString{})
+		if err != nil {
+			return "", err
+		}
+		total += lineTotal
+	}
+
+	if _, err = tx.Exec(`UPDATE orderList SET totalPrice = ? WHERE idOrder = ?`, total, idOrder); err != nil {
+		return "", fmt.Errorf("error updating order total: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+	return idOrder, nil
+}
+
+const takeawayOrderQuery = `
+	SELECT ol.idOrder, tk.idRestaurant, rest.name, tk.idClient, p.firstName, p.lastName,
+		tk.pickupTime, ol.status, ol.totalPrice, IFNULL(SUM(ofd.quantity), 0), ol.createdAt
+	FROM takeawayOrder tk
+	JOIN orderList ol ON ol.idOrder = tk.idOrder
+	JOIN restaurant rest ON rest.idRestaurant = tk.idRestaurant
+	JOIN client c ON c.idClient = tk.idClient
+	JOIN profile p ON p.idProfile = c.idProfile
+	LEFT JOIN orderFood ofd ON ofd.idOrder = ol.idOrder
+`
+
+func (s *store) queryTakeawayOrders(query string, args ...interface{}) ([]types.TakeawayOrder, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving takeaway orders: %v", err)
+	}
+	defer rows.Close()
+
+	orders := []types.TakeawayOrder{}
+	for rows.Next() {
+		var order types.TakeawayOrder
+		if err := rows.Scan(
+			&order.IdOrder,
+			&order.IdRestaurant,
+			&order.RestaurantName,
+			&order.IdClient,
+			&order.FirstName,
+			&order.LastName,
+			&order.PickupTime,
+			&order.Status,
+			&order.TotalPrice,
+			&order.ItemCount,
+			&order.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning takeaway order: %v", err)
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// GetRestaurantTakeawayOrders lists the takeaway orders to pick up on a day, by pickup time
+func (s *store) GetRestaurantTakeawayOrders(idRestaurant string, day time.Time) ([]types.TakeawayOrder, error) {
+	query := takeawayOrderQuery + `
+		WHERE tk.idRestaurant = ? AND DATE(tk.pickupTime) = ?
+		GROUP BY ol.idOrder, tk.idRestaurant, rest.name, tk.idClient, p.firstName, p.lastName, tk.pickupTime, ol.status, ol.totalPrice, ol.createdAt
+		ORDER BY tk.pickupTime ASC
+	`
+	return s.queryTakeawayOrders(query, idRestaurant, day.Format("2006-01-02"))
+}
+
+func (s *store) GetClientTakeawayOrders(idClient string) ([]types.TakeawayOrder, error) {
+	query := takeawayOrderQuery + `
+		WHERE tk.idClient = ?
+		GROUP BY ol.idOrder, tk.idRestaurant, rest.name, tk.idClient, p.firstName, p.lastName, tk.pickupTime, ol.status, ol.totalPrice, ol.createdAt
+		ORDER BY tk.pickupTime DESC
+	`
+	return s.queryTakeawayOrders(query, idClient)
+}
+
+// Helper function to read a CSV of time.Weekday values
+func parseWeekdays(csv string) ([]int, error) {
+	weekdays := []int{}
+	for _, day := range strings.Split(csv, ",") {
+		if day == "" {
+			continue
+		}
+		weekday, err := strconv.Atoi(day)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weekday %q", day)
+		}
+		weekdays = append(weekdays, weekday)
+	}
+	return weekdays, nil
+}
+
+const menuScheduleQuery = `
+	SELECT ms.idSchedule, ms.idMenu, ms.label, ms.startTime, ms.endTime, ms.weekdays,
+		DATE_FORMAT(ms.validFrom, '%Y-%m-%d'), DATE_FORMAT(ms.validTo, '%Y-%m-%d'), ms.priority, ms.createdAt
+	FROM menuSchedule ms
+	JOIN menu m ON m.idMenu = ms.idMenu
+`
+
+func (s *store) queryMenuSchedules(query string, args ...interface{}) ([]types.MenuSchedule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching menu schedules: %v", err)
+	}
+	defer rows.Close()
+
+	schedules := []types.MenuSchedule{}
+	for rows.Next() {
+		var schedule types.MenuSchedule
+		var weekdays string
+		err := rows.Scan(
+			&schedule.IdSchedule,
+			&schedule.IdMenu,
+			&schedule.Label,
+			&schedule.StartTime,
+			&schedule.EndTime,
+			&weekdays,
+			&schedule.ValidFrom,
+			&schedule.ValidTo,
+			&schedule.Priority,
+			&schedule.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning menu schedule: %v", err)
+		}
+		if schedule.Weekdays, err = parseWeekdays(weekdays); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// GetMenuSchedules lists the schedules of a menu
+func (s *store) GetMenuSchedules(idMenu string) ([]types.MenuSchedule, error) {
+	return s.queryMenuSchedules(menuScheduleQuery+` WHERE ms.idMenu = ? ORDER BY ms.priority DESC, ms.startTime`, idMenu)
+}
+
+// CreateMenuSchedule adds a serving window to a menu
+func (s *store) CreateMenuSchedule(schedule types.MenuSchedule) error {
+	var menuExists int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM menu WHERE idMenu = ?`, schedule.IdMenu).Scan(&menuExists)
+	if err != nil {
+		return fmt.Errorf("error checking menu existence: %v", err)
+	}
+	if menuExists == 0 {
+		return fmt.Errorf("menu with ID %s not found", schedule.IdMenu)
+	}
+
+	weekdays := make([]string, 0, len(schedule.Weekdays))
+	for _, day := range schedule.Weekdays {
+		weekdays = append(weekdays, strconv.Itoa(day))
+	}
+	query := `
+		INSERT INTO menuSchedule (idSchedule, idMenu, label, startTime, endTime, weekdays, validFrom, validTo, priority, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = s.db.Exec(query,
+		schedule.IdSchedule,
+		schedule.IdMenu,
+		schedule.Label,
+		schedule.StartTime,
+		schedule.EndTime,
+		strings.Join(weekdays, ","),
+		schedule.ValidFrom,
+		schedule.ValidTo,
+		schedule.Priority,
+		schedule.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating menu schedule: %v", err)
+	}
+	return nil
+}
+
+// DeleteMenuSchedule removes a serving window from a menu
+func (s *store) DeleteMenuSchedule(idMenu, idSchedule string) error {
+	result, err := s.db.Exec(`DELETE FROM menuSchedule WHERE idSchedule = ? AND idMenu = ?`, idSchedule, idMenu)
+	if err != nil {
+		return fmt.Errorf("error deleting menu schedule: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("menu schedule with ID %s not found", idSchedule)
+	}
+	return nil
+}
+
+// SetMenuOverride forces a menu ahead of the schedules, until the given time or until it is cleared
+func (s *store) SetMenuOverride(idRestaurant, idMenu string, until *time.Time) error {
+	var menuExists int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM menu WHERE idMenu = ? AND idRestaurant = ?`, idMenu, idRestaurant).Scan(&menuExists)
+	if err != nil {
+		return fmt.Errorf("error checking menu existence: %v", err)
+	}
+	if menuExists == 0 {
+		return fmt.Errorf("menu with ID %s not found for restaurant %s", idMenu, idRestaurant)
+	}
+
+	query := `
+		INSERT INTO menuOverride (idRestaurant, idMenu, until, createdAt)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			idMenu = VALUES(idMenu),
+			until = VALUES(until),
+			createdAt = VALUES(createdAt)
+	`
+	_, err = s.db.Exec(query, idRestaurant, idMenu, until, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving menu override: %v", err)
+	}
+	return nil
+}
+
+// ClearMenuOverride hands the active menu back to the schedules
+func (s *store) ClearMenuOverride(idRestaurant string) error {
+	_, err := s.db.Exec(`DELETE FROM menuOverride WHERE idRestaurant = ?`, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error clearing menu override: %v", err)
+	}
+	return nil
+}
+
+// Helper function to check whether a schedule is serving at the given time. A window ending before it
+// starts crosses midnight, its early hours then belong to the previous day for the weekday and date checks.
+func scheduleCovers(schedule types.MenuSchedule, now time.Time) bool {
+	start, err := clockTimeOn(now, schedule.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := clockTimeOn(now, schedule.EndTime)
+	if err != nil {
+		return false
+	}
+
+	day := now
+	if end.After(start) {
+		if now.Before(start) || !now.Before(end) {
+			return false
+		}
+	} else {
+		switch {
+		case !now.Before(start):
+		case now.Before(end):
+			day = now.AddDate(0, 0, -1)
+		default:
+			return false
+		}
+	}
+
+	if len(schedule.Weekdays) > 0 {
+		found := false
+		for _, weekday := range schedule.Weekdays {
+			if weekday == int(day.Weekday()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	date := day.Format("2006-01-02")
+	if schedule.ValidFrom != nil && date < *schedule.ValidFrom {
+		return false
+	}
+	if schedule.ValidTo != nil && date > *schedule.ValidTo {
+		return false
+	}
+	return true
+}
+
+// Helper function to find the menu served at a given time: a running override first, then the
+// highest priority schedule covering that time, then the menu activated by hand. Nil when none applies.
+func (s *store) resolveActiveMenu(idRestaurant string, now time.Time) (*types.ActiveMenu, error) {
+	var active types.ActiveMenu
+	err := s.db.QueryRow(`
+		SELECT mo.idMenu, m.name, mo.until
+		FROM menuOverride mo
+		JOIN menu m ON m.idMenu = mo.idMenu
+		WHERE mo.idRestaurant = ? AND (mo.until IS NULL OR mo.until > ?)`,
+		idRestaurant, now,
+	).Scan(&active.IdMenu, &active.Name, &active.Until)
+	if err == nil {
+		active.Source = "override"
+		return &active, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error fetching menu override: %v", err)
+	}
+
+	schedules, err := s.queryMenuSchedules(menuScheduleQuery+` WHERE m.idRestaurant = ? ORDER BY ms.priority DESC, ms.createdAt`, idRestaurant)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		if !scheduleCovers(schedule, now) {
+			continue
+		}
+		active = types.ActiveMenu{
+			IdMenu:     schedule.IdMenu,
+			Source:     "schedule",
+			IdSchedule: schedule.IdSchedule,
+			Label:      schedule.Label,
+		}
+		err = s.db.QueryRow(`SELECT name FROM menu WHERE idMenu = ?`, schedule.IdMenu).Scan(&active.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching menu: %v", err)
+		}
+		return &active, nil
+	}
+
+	active = types.ActiveMenu{Source: "default"}
+	err = s.db.QueryRow(`SELECT idMenu, name FROM menu WHERE idRestaurant = ? AND active = 1 LIMIT 1`, idRestaurant).Scan(&active.IdMenu, &active.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching active menu: %v", err)
+	}
+	return &active, nil
+}
+
+// GetCurrentMenu tells which menu a restaurant is serving right now
+func (s *store) GetCurrentMenu(idRestaurant string) (*types.ActiveMenu, error) {
+	active, err := s.resolveActiveMenu(idRestaurant, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if active == nil {
+		return nil, fmt.Errorf("no active menu found for restaurant %s", idRestaurant)
+	}
+	return active, nil
+}
+
+type queryRunner interface {
+	queryExecer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Helper function to load the option groups of a food with their options, in display order
+func loadFoodOptionGroups(db queryRunner, idFood string) ([]types.FoodOptionGroup, error) {
+	rows, err := db.Query(`
+		SELECT idGroup, idFood, name, minSelect, maxSelect, sortIndex
+		FROM foodOptionGroup
+		WHERE idFood = ?
+		ORDER BY sortIndex, name`, idFood)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching option groups: %v", err)
+	}
+	groups := []types.FoodOptionGroup{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var group types.FoodOptionGroup
+		if err := rows.Scan(&group.IdGroup, &group.IdFood, &group.Name, &group.MinSelect, &group.MaxSelect, &group.SortIndex); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning option group: %v", err)
+		}
+		group.Options = []types.FoodOption{}
+		index[group.IdGroup] = len(groups)
+		groups = append(groups, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating option groups: %v", err)
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	rows, err = db.Query(`
+		SELECT fo.idOption, fo.idGroup, fo.name, fo.priceDelta, fo.available
+		FROM foodOption fo
+		JOIN foodOptionGroup g ON g.idGroup = fo.idGroup
+		WHERE g.idFood = ?
+		ORDER BY fo.priceDelta, fo.name`, idFood)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching options: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var option types.FoodOption
+		if err := rows.Scan(&option.IdOption, &option.IdGroup, &option.Name, &option.PriceDelta, &option.Available); err != nil {
+			return nil, fmt.Errorf("error scanning option: %v", err)
+		}
+		group := &groups[index[option.IdGroup]]
+		group.Options = append(group.Options, option)
+	}
+	return groups, rows.Err()
+}
+
+// Helper function to load the components of the given combos, keyed by combo
+func loadComboItems(db queryRunner, idCombos []string) (map[string][]types.ComboItem, error) {
+	items := make(map[string][]types.ComboItem)
+	if len(idCombos) == 0 {
+		return items, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(idCombos)), ", ")
+	rows, err := db.Query(`
+		SELECT ci.idCombo, ci.idFood, f.name, ci.quantity
+		FROM comboItem ci
+		JOIN food f ON f.idFood = ci.idFood
+		WHERE ci.idCombo IN (`+placeholders+`)
+		ORDER BY f.name`, convertToInterfaceSlice(idCombos)...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching combo items: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idCombo string
+		var item types.ComboItem
+		if err := rows.Scan(&idCombo, &item.IdFood, &item.Name, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("error scanning combo item: %v", err)
+		}
+		items[idCombo] = append(items[idCombo], item)
+	}
+	return items, rows.Err()
+}
+
+// GetFoodCustomization returns the option groups of a food and, for a combo, the foods it is made of
+func (s *store) GetFoodCustomization(idFood string) (*types.FoodCustomization, error) {
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM food WHERE idFood = ?`, idFood).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error checking food existence: %v", err)
+	}
+	if exists == 0 {
+		return nil, fmt.Errorf("food with ID %s not found", idFood)
+	}
+
+	groups, err := loadFoodOptionGroups(s.db, idFood)
+	if err != nil {
+		return nil, err
+	}
+	combos, err := loadComboItems(s.db, []string{idFood})
+	if err != nil {
+		return nil, err
+	}
+	customization := &types.FoodCustomization{
+		IdFood:       idFood,
+		OptionGroups: groups,
+		ComboItems:   combos[idFood],
+	}
+	if customization.ComboItems == nil {
+		customization.ComboItems = []types.ComboItem{}
+	}
+	return customization, nil
+}
+
+// CreateFoodOptionGroup adds an option group to a food
+func (s *store) CreateFoodOptionGroup(group types.FoodOptionGroup) error {
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM food WHERE idFood = ?`, group.IdFood).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking food existence: %v", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("food with ID %s not found", group.IdFood)
+	}
+
+	_, err := s.db.Exec(`INSERT INTO foodOptionGroup (idGroup, idFood, name, minSelect, maxSelect, sortIndex) VALUES (?, ?, ?, ?, ?, ?)`,
+		group.IdGroup, group.IdFood, group.Name, group.MinSelect, group.MaxSelect, group.SortIndex)
+	if err != nil {
+		return fmt.Errorf("error creating option group: %v", err)
+	}
+	return nil
+}
+
+// DeleteFoodOptionGroup removes an option group and its options from a food
+func (s *store) DeleteFoodOptionGroup(idFood, idGroup string) error {
+	result, err := s.db.Exec(`DELETE FROM foodOptionGroup WHERE idGroup = ? AND idFood = ?`, idGroup, idFood)
+	if err != nil {
+		return fmt.Errorf("error deleting option group: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("option group with ID %s not found", idGroup)
+	}
+	return nil
+}
+
+// CreateFoodOption adds an option to one of the option groups of a food
+func (s *store) CreateFoodOption(idFood string, option types.FoodOption) error {
+	var exists int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM foodOptionGroup WHERE idGroup = ? AND idFood = ?`, option.IdGroup, idFood).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking option group existence: %v", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("option group with ID %s not found", option.IdGroup)
+	}
+
+	_, err = s.db.Exec(`INSERT INTO foodOption (idOption, idGroup, name, priceDelta, available) VALUES (?, ?, ?, ?, ?)`,
+		option.IdOption, option.IdGroup, option.Name, option.PriceDelta, option.Available)
+	if err != nil {
+		return fmt.Errorf("error creating option: %v", err)
+	}
+	return nil
+}
+
+// UpdateFoodOption changes the name, price delta or availability of an option of a food
+func (s *store) UpdateFoodOption(idFood string, option types.FoodOption) error {
+	query := `
+		UPDATE foodOption fo
+		JOIN foodOptionGroup g ON g.idGroup = fo.idGroup
+		SET fo.name = ?, fo.priceDelta = ?, fo.available = ?
+		WHERE fo.idOption = ? AND g.idFood = ?
+	`
+	result, err := s.db.Exec(query, option.Name, option.PriceDelta, option.Available, option.IdOption, idFood)
+	if err != nil {
+		return fmt.Errorf("error updating option: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		var exists int
+		err = s.db.QueryRow(`SELECT COUNT(*) FROM foodOption fo JOIN foodOptionGroup g ON g.idGroup = fo.idGroup WHERE fo.idOption = ? AND g.idFood = ?`,
+			option.IdOption, idFood).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error checking option existence: %v", err)
+		}
+		if exists == 0 {
+			return fmt.Errorf("option with ID %s not found", option.IdOption)
+		}
+	}
+	return nil
+}
+
+// DeleteFoodOption removes an option from a food
+func (s *store) DeleteFoodOption(idFood, idOption string) error {
+	query := `
+		DELETE fo FROM foodOption fo
+		JOIN foodOptionGroup g ON g.idGroup = fo.idGroup
+		WHERE fo.idOption = ? AND g.idFood = ?
+	`
+	result, err := s.db.Exec(query, idOption, idFood)
+	if err != nil {
+		return fmt.Errorf("error deleting option: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("option with ID %s not found", idOption)
+	}
+	return nil
+}
+
+// SetComboItems replaces the foods a combo is made of; an empty list turns the combo back into a plain food.
+// Components must come from the same restaurant and cannot be combos themselves.
+func (s *store) SetComboItems(idCombo string, items []types.ComboItem) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var idRestaurant string
+	err = tx.QueryRow(`SELECT idRestaurant FROM food WHERE idFood = ? FOR UPDATE`, idCombo).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("food with ID %s not found", idCombo)
+			return err
+		}
+		return fmt.Errorf("error fetching combo: %v", err)
+	}
+	var usedInCombo int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM comboItem WHERE idFood = ?`, idCombo).Scan(&usedInCombo)
+	if err != nil {
+		return fmt.Errorf("error checking combo usage: %v", err)
+	}
+	if usedInCombo > 0 && len(items) > 0 {
+		err = fmt.Errorf("invalid combo: food %s is already part of another combo", idCombo)
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM comboItem WHERE idCombo = ?`, idCombo); err != nil {
+		return fmt.Errorf("error clearing combo items: %v", err)
+	}
+	for _, item := range items {
+		if item.IdFood == idCombo {
+			err = fmt.Errorf("invalid combo: a combo cannot contain itself")
+			return err
+		}
+		var componentRestaurant string
+		var nested int
+		err = tx.QueryRow(`
+			SELECT f.idRestaurant, (SELECT COUNT(*) FROM comboItem ci WHERE ci.idCombo = f.idFood)
+			FROM food f WHERE f.idFood = ?`, item.IdFood).Scan(&componentRestaurant, &nested)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("food with ID %s not found", item.IdFood)
+				return err
+			}
+			return fmt.Errorf("error fetching combo item: %v", err)
+		}
+		if componentRestaurant != idRestaurant {
+			err = fmt.Errorf("food %s does not belong to this restaurant", item.IdFood)
+			return err
+		}
+		if nested > 0 {
+			err = fmt.Errorf("invalid combo: food %s is a combo itself", item.IdFood)
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO comboItem (idCombo, idFood, quantity) VALUES (?, ?, ?)`, idCombo, item.IdFood, item.Quantity)
+		if err != nil {
+			return fmt.Errorf("error adding combo item: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// addOrderLine validates the options picked on a food, prices it and inserts it as an order line with
+// a copy of its options. orderedBy is the guest who added the line, NULL for the host or a walk-in.
+// It returns the line total.
+func addOrderLine(tx *sql.Tx, idOrder string, idRestaurant string, food types.FoodItem, orderedBy sql.NullString) (float64, error) {
+	if food.Quantity <= 0 {
+		return 0, fmt.Errorf("invalid quantity for food %s", food.IdFood)
+	}
+
+	var price float64
+	err := tx.QueryRow(`SELECT price FROM food WHERE idFood = ? AND idRestaurant = ?`, food.IdFood, idRestaurant).Scan(&price)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("food with ID %s not found in this restaurant", food.IdFood)
+		}
+		return 0, fmt.Errorf("error fetching food price: %v", err)
+	}
+
+	// Every food of a combo has to be available for the combo to be served
+	var missing string
+	err = tx.QueryRow(`
+		SELECT f.name FROM comboItem ci
+		JOIN food f ON f.idFood = ci.idFood
+		WHERE ci.idCombo = ? AND f.status <> 'available'
+		LIMIT 1`, food.IdFood).Scan(&missing)
+	if err == nil {
+		return 0, fmt.Errorf("combo %s is not available, %s is out", food.IdFood, missing)
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error checking combo items: %v", err)
+	}
+
+	groups, err := loadFoodOptionGroups(tx, food.IdFood)
+	if err != nil {
+		return 0, err
+	}
+	type pickedOption struct {
+		group  string
+		option types.FoodOption
+	}
+	offered := make(map[string]pickedOption)
+	for _, group := range groups {
+		for _, option := range group.Options {
+			offered[option.IdOption] = pickedOption{group: group.Name, option: option}
+		}
+	}
+
+	picked := make([]pickedOption, 0, len(food.Options))
+	perGroup := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, idOption := range food.Options {
+		choice, ok := offered[idOption]
+		if !ok {
+			return 0, fmt.Errorf("invalid options for food %s: option %s is not offered", food.IdFood, idOption)
+		}
+		if seen[idOption] {
+			return 0, fmt.Errorf("invalid options for food %s: option %s picked twice", food.IdFood, choice.option.Name)
+		}
+		if !choice.option.Available {
+			return 0, fmt.Errorf("option %s is not available", choice.option.Name)
+		}
+		seen[idOption] = true
+		perGroup[choice.option.IdGroup]++
+		picked = append(picked, choice)
+		price += choice.option.PriceDelta
+	}
+	for _, group := range groups {
+		count := perGroup[group.IdGroup]
+		if count < group.MinSelect {
+			return 0, fmt.Errorf("invalid options for food %s: pick at least %d in %s", food.IdFood, group.MinSelect, group.Name)
+		}
+		if count > group.MaxSelect {
+			return 0, fmt.Errorf("invalid options for food %s: pick at most %d in %s", food.IdFood, group.MaxSelect, group.Name)
+		}
+	}
+
+	idOrderFood, err := utils.CreateAnId()
+	if err != nil {
+		return 0, err
+	}
+	price = roundMoney(price)
+	_, err = tx.Exec(`INSERT INTO orderFood (idOrderFood, idOrder, idFood, quantity, unitPrice, createdAt, idClient) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		idOrderFood, idOrder, food.IdFood, food.Quantity, price, time.Now(), orderedBy)
+	if err != nil {
+		return 0, fmt.Errorf("error adding food to order: %v", err)
+	}
+	for _, choice := range picked {
+		_, err = tx.Exec(`INSERT INTO orderFoodOption (idOrderFood, idOption, groupName, name, priceDelta) VALUES (?, ?, ?, ?, ?)`,
+			idOrderFood, choice.option.IdOption, choice.group, choice.option.Name, choice.option.PriceDelta)
+		if err != nil {
+			return 0, fmt.Errorf("error saving order line option: %v", err)
+		}
+	}
+	return price * float64(food.Quantity), nil
+}
+
+// Helper function to load the options chosen on the given order lines, keyed by line
+func loadOrderLineOptions(db queryRunner, idOrderFoods []string) (map[string][]types.OrderLineOption, error) {
+	options := make(map[string][]types.OrderLineOption)
+	if len(idOrderFoods) == 0 {
+		return options, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(idOrderFoods)), ", ")
+	rows, err := db.Query(`
+		SELECT idOrderFood, idOption, groupName, name, priceDelta
+		FROM orderFoodOption
+		WHERE idOrderFood IN (`+placeholders+`)
+		ORDER BY groupName, name`, convertToInterfaceSlice(idOrderFoods)...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order line options: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idOrderFood string
+		var option types.OrderLineOption
+		if err := rows.Scan(&idOrderFood, &option.IdOption, &option.GroupName, &option.Name, &option.PriceDelta); err != nil {
+			return nil, fmt.Errorf("error scanning order line option: %v", err)
+		}
+		options[idOrderFood] = append(options[idOrderFood], option)
+	}
+	return options, rows.Err()
+}
+
+// GetFoodTags returns the catalogue of allergens and dietary labels
+func (s *store) GetFoodTags() ([]types.FoodTag, error) {
+	rows, err := s.db.Query(`SELECT idTag, name, kind FROM foodTag ORDER BY kind, name`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching food tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []types.FoodTag{}
+	for rows.Next() {
+		var tag types.FoodTag
+		if err := rows.Scan(&tag.IdTag, &tag.Name, &tag.Kind); err != nil {
+			return nil, fmt.Errorf("error scanning food tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *store) CreateFoodTag(tag types.FoodTag) error {
+	_, err := s.db.Exec(`INSERT INTO foodTag (idTag, name, kind) VALUES (?, ?, ?)`, tag.IdTag, tag.Name, tag.Kind)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("food tag %s already exists", tag.Name)
+		}
+		return fmt.Errorf("error creating food tag: %v", err)
+	}
+	return nil
+}
+
+// SetFoodTags replaces the allergens and dietary labels of a food
+func (s *store) SetFoodTags(idFood string, tags []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var exists int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM food WHERE idFood = ?`, idFood).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking food existence: %v", err)
+	}
+	if exists == 0 {
+		err = fmt.Errorf("food with ID %s not found", idFood)
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM foodTagLink WHERE idFood = ?`, idFood); err != nil {
+		return fmt.Errorf("error clearing food tags: %v", err)
+	}
+	for _, idTag := range tags {
+		if err = tx.QueryRow(`SELECT COUNT(*) FROM foodTag WHERE idTag = ?`, idTag).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking food tag existence: %v", err)
+		}
+		if exists == 0 {
+			err = fmt.Errorf("food tag with ID %s not found", idTag)
+			return err
+		}
+		if _, err = tx.Exec(`INSERT IGNORE INTO foodTagLink (idFood, idTag) VALUES (?, ?)`, idFood, idTag); err != nil {
+			return fmt.Errorf("error tagging food: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// Helper function to load the tags of the given foods, keyed by food. A combo is tagged from what it is made of,
+// see mergeComboTags.
+func loadFoodTags(db queryRunner, idFoods []string) (map[string][]types.FoodTag, error) {
+	tags, err := queryFoodTags(db, idFoods)
+	if err != nil || len(idFoods) == 0 {
+		return tags, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(idFoods)), ", ")
+	rows, err := db.Query(`SELECT idCombo, idFood FROM comboItem WHERE idCombo IN (`+placeholders+`)`, convertToInterfaceSlice(idFoods)...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching combo items: %v", err)
+	}
+	defer rows.Close()
+	components := make(map[string][]string)
+	var idComponents []string
+	for rows.Next() {
+		var idCombo, idFood string
+		if err := rows.Scan(&idCombo, &idFood); err != nil {
+			return nil, fmt.Errorf("error scanning combo item: %v", err)
+		}
+		components[idCombo] = append(components[idCombo], idFood)
+		idComponents = append(idComponents, idFood)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching combo items: %v", err)
+	}
+	if len(components) == 0 {
+		return tags, nil
+	}
+
+	componentTags, err := queryFoodTags(db, idComponents)
+	if err != nil {
+		return nil, err
+	}
+	for idCombo, idItems := range components {
+		itemTags := make([][]types.FoodTag, len(idItems))
+		for i, idItem := range idItems {
+			itemTags[i] = componentTags[idItem]
+		}
+		tags[idCombo] = mergeComboTags(tags[idCombo], itemTags)
+	}
+	return tags, nil
+}
+
+// Helper function to tag a combo: it contains every allergen of the combo and of its components, and only
+// carries the dietary labels all of its components carry, whatever the combo itself was labelled with
+func mergeComboTags(own []types.FoodTag, components [][]types.FoodTag) []types.FoodTag {
+	merged := []types.FoodTag{}
+	seen := make(map[string]bool)
+	for _, tags := range append([][]types.FoodTag{own}, components...) {
+		for _, tag := range tags {
+			if tag.Kind == "allergen" && !seen[tag.IdTag] {
+				seen[tag.IdTag] = true
+				merged = append(merged, tag)
+			}
+		}
+	}
+
+	if len(components) > 0 {
+		for _, tag := range components[0] {
+			if tag.Kind != "dietary" || seen[tag.IdTag] {
+				continue
+			}
+			onEvery := true
+			for _, tags := range components[1:] {
+				found := false
+				for _, other := range tags {
+					if other.IdTag == tag.IdTag {
+						found = true
+						break
+					}
+				}
+				if !found {
+					onEvery = false
+					break
+				}
+			}
+			if onEvery {
+				seen[tag.IdTag] = true
+				merged = append(merged, tag)
+			}
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Kind != merged[j].Kind {
+			return merged[i].Kind < merged[j].Kind
+		}
+		return merged[i].Name < merged[j].Name
+	})
+	return merged
+}
+
+// Helper function to load the tags linked to the given foods themselves, keyed by food
+func queryFoodTags(db queryRunner, idFoods []string) (map[string][]types.FoodTag, error) {
+	tags := make(map[string][]types.FoodTag)
+	if len(idFoods) == 0 {
+		return tags, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(idFoods)), ", ")
+	rows, err := db.Query(`
+		SELECT ftl.idFood, ft.idTag, ft.name, ft.kind
+		FROM foodTagLink ftl
+		JOIN foodTag ft ON ft.idTag = ftl.idTag
+		WHERE ftl.idFood IN (`+placeholders+`)
+		ORDER BY ft.kind, ft.name`, convertToInterfaceSlice(idFoods)...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching food tags: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idFood string
+		var tag types.FoodTag
+		if err := rows.Scan(&idFood, &tag.IdTag, &tag.Name, &tag.Kind); err != nil {
+			return nil, fmt.Errorf("error scanning food tag: %v", err)
+		}
+		tags[idFood] = append(tags[idFood], tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *store) GetClientDietaryPreferences(idClient string) (*types.ClientDietaryPreferences, error) {
+	prefs := types.ClientDietaryPreferences{IdClient: idClient, AvoidAllergens: []string{}, RequireLabels: []string{}}
+	err := s.db.QueryRow(`SELECT hideIncompatible FROM clientDietarySettings WHERE idClient = ?`, idClient).Scan(&prefs.HideIncompatible)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error retrieving dietary preferences: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ft.idTag, ft.kind
+		FROM clientDietaryTag cdt
+		JOIN foodTag ft ON ft.idTag = cdt.idTag
+		WHERE cdt.idClient = ?
+		ORDER BY ft.name`, idClient)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving dietary preferences: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idTag, kind string
+		if err := rows.Scan(&idTag, &kind); err != nil {
+			return nil, fmt.Errorf("error scanning dietary preference: %v", err)
+		}
+		if kind == "allergen" {
+			prefs.AvoidAllergens = append(prefs.AvoidAllergens, idTag)
+		} else {
+			prefs.RequireLabels = append(prefs.RequireLabels, idTag)
+		}
+	}
+	return &prefs, rows.Err()
+}
+
+// UpdateClientDietaryPreferences replaces the allergens a client avoids and the labels they need
+func (s *store) UpdateClientDietaryPreferences(prefs types.ClientDietaryPreferences) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(`
+		INSERT INTO clientDietarySettings (idClient, hideIncompatible)
+		VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE hideIncompatible = VALUES(hideIncompatible)`,
+		prefs.IdClient, prefs.HideIncompatible)
+	if err != nil {
+		return fmt.Errorf("error saving dietary preferences: %v", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM clientDietaryTag WHERE idClient = ?`, prefs.IdClient); err != nil {
+		return fmt.Errorf("error clearing dietary preferences: %v", err)
+	}
+
+	wanted := map[string][]string{"allergen": prefs.AvoidAllergens, "dietary": prefs.RequireLabels}
+	for kind, tags := range wanted {
+		for _, idTag := range tags {
+			var tagKind string
+			err = tx.QueryRow(`SELECT kind FROM foodTag WHERE idTag = ?`, idTag).Scan(&tagKind)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					err = fmt.Errorf("food tag with ID %s not found", idTag)
+					return err
+				}
+				return fmt.Errorf("error fetching food tag: %v", err)
+			}
+			if tagKind != kind {
+				err = fmt.Errorf("invalid dietary preferences: %s is not a %s tag", idTag, kind)
+				return err
+			}
+			if _, err = tx.Exec(`INSERT IGNORE INTO clientDietaryTag (idClient, idTag) VALUES (?, ?)`, prefs.IdClient, idTag); err != nil {
+				return fmt.Errorf("error saving dietary preference: %v", err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// dietaryCheck decides whether a food passes a menu filter and, for a client, whether it suits them
+type dietaryCheck struct {
+	filter types.DietaryFilter
+	prefs  *types.ClientDietaryPreferences
+	names  map[string]string
+}
+
+func (s *store) newDietaryCheck(filter types.DietaryFilter) (*dietaryCheck, error) {
+	check := &dietaryCheck{filter: filter, names: make(map[string]string)}
+	if filter.IdClient == "" {
+		return check, nil
+	}
+
+	prefs, err := s.GetClientDietaryPreferences(filter.IdClient)
+	if err != nil {
+		return nil, err
+	}
+	check.prefs = prefs
+	tags, err := s.GetFoodTags()
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		check.names[tag.IdTag] = tag.Name
+	}
+	return check, nil
+}
+
+// apply returns whether the food stays on the menu and, for a client, whether it suits them and why not
+func (c *dietaryCheck) apply(tags []types.FoodTag) (bool, *bool, []string) {
+	has := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		has[tag.IdTag] = true
+	}
+	for _, idTag := range c.filter.Require {
+		if !has[idTag] {
+			return false, nil, nil
+		}
+	}
+	for _, idTag := range c.filter.Exclude {
+		if has[idTag] {
+			return false, nil, nil
+		}
+	}
+	if c.prefs == nil {
+		return true, nil, nil
+	}
+
+	conflicts := []string{}
+	for _, idTag := range c.prefs.AvoidAllergens {
+		if has[idTag] {
+			conflicts = append(conflicts, "contains "+c.names[idTag])
+		}
+	}
+	for _, idTag := range c.prefs.RequireLabels {
+		if !has[idTag] {
+			conflicts = append(conflicts, "not "+strings.ToLower(c.names[idTag]))
+		}
+	}
+	compatible := len(conflicts) == 0
+	if !compatible && c.prefs.HideIncompatible {
+		return false, nil, nil
+	}
+	return true, &compatible, conflicts
+}
+
+const ingredientColumns = `idIngredient, idRestaurant, name, unit, stock, lowStockThreshold, createdAt`
+
+func scanIngredient(row interface{ Scan(...interface{}) error }) (types.Ingredient, error) {
+	var ingredient types.Ingredient
+	err := row.Scan(
+		&ingredient.IdIngredient,
+		&ingredient.IdRestaurant,
+		&ingredient.Name,
+		&ingredient.Unit,
+		&ingredient.Stock,
+		&ingredient.LowStockThreshold,
+		&ingredient.CreatedAt,
+	)
+	ingredient.LowStock = ingredient.LowStockThreshold > 0 && ingredient.Stock <= ingredient.LowStockThreshold
+	return ingredient, err
+}
+
+// GetIngredients lists the stock of a restaurant, lowStockOnly keeps the ingredients at or under their threshold
+func (s *store) GetIngredients(idRestaurant string, lowStockOnly bool) ([]types.Ingredient, error) {
+	query := `SELECT ` + ingredientColumns + ` FROM ingredient WHERE idRestaurant = ?`
+	if lowStockOnly {
+		query += ` AND lowStockThreshold > 0 AND stock <= lowStockThreshold`
+	}
+	rows, err := s.db.Query(query+` ORDER BY name`, idRestaurant)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ingredients: %v", err)
+	}
+	defer rows.Close()
+
+	ingredients := []types.Ingredient{}
+	for rows.Next() {
+		ingredient, err := scanIngredient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning ingredient: %v", err)
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+	return ingredients, rows.Err()
+}
+
+func (s *store) CreateIngredient(ingredient types.Ingredient) error {
+	// Stock starts empty, the initial quantity goes in as a first restock
+	query := `INSERT INTO ingredient (` + ingredientColumns + `) VALUES (?, ?, ?, ?, 0, ?, ?)`
+	_, err := s.db.Exec(query,
+		ingredient.IdIngredient,
+		ingredient.IdRestaurant,
+		ingredient.Name,
+		ingredient.Unit,
+		ingredient.LowStockThreshold,
+		ingredient.CreatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("ingredient %s already exists", ingredient.Name)
+		}
+		return fmt.Errorf("error creating ingredient: %v", err)
+	}
+	if ingredient.Stock > 0 {
+		return s.RecordStockMovement(ingredient.IdRestaurant, ingredient.IdIngredient, "restock", types.StockEntry{Quantity: ingredient.Stock, Note: "initial stock"})
+	}
+	return nil
+}
+
+// UpdateIngredient renames an ingredient or changes its unit or low stock threshold; stock only moves through movements
+func (s *store) UpdateIngredient(ingredient types.Ingredient) error {
+	query := `
+		UPDATE ingredient
+		SET name = ?, unit = ?, lowStockThreshold = ?,
+			lowStockAlertedAt = IF(stock > ?, NULL, lowStockAlertedAt)
+		WHERE idIngredient = ? AND idRestaurant = ?
+	`
+	result, err := s.db.Exec(query,
+		ingredient.Name,
+		ingredient.Unit,
+		ingredient.LowStockThreshold,
+		ingredient.LowStockThreshold,
+		ingredient.IdIngredient,
+		ingredient.IdRestaurant,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("ingredient %s already exists", ingredient.Name)
+		}
+		return fmt.Errorf("error updating ingredient: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		if _, err := s.getIngredient(s.db, ingredient.IdRestaurant, ingredient.IdIngredient, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteIngredient removes an ingredient and drops it from every recipe
+func (s *store) DeleteIngredient(idRestaurant, idIngredient string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`DELETE FROM ingredient WHERE idIngredient = ? AND idRestaurant = ?`, idIngredient, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error deleting ingredient: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		err = fmt.Errorf("ingredient with ID %s not found", idIngredient)
+		return err
+	}
+	// Foods held back by this ingredient only may come back
+	if err = refreshStockAvailability(tx, idRestaurant); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+func (s *store) getIngredient(db queryExecer, idRestaurant, idIngredient string, lock bool) (*types.Ingredient, error) {
+	query := `SELECT ` + ingredientColumns + ` FROM ingredient WHERE idIngredient = ? AND idRestaurant = ?`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	ingredient, err := scanIngredient(db.QueryRow(query, idIngredient, idRestaurant))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("ingredient with ID %s not found", idIngredient)
+		}
+		return nil, fmt.Errorf("error fetching ingredient: %v", err)
+	}
+	return &ingredient, nil
+}
+
+// RecordStockMovement adds a restock or a manual adjustment to an ingredient's stock, which never goes below zero
+func (s *store) RecordStockMovement(idRestaurant, idIngredient, kind string, entry types.StockEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	ingredient, err := s.getIngredient(tx, idRestaurant, idIngredient, true)
+	if err != nil {
+		return err
+	}
+	quantity := entry.Quantity
+	if ingredient.Stock+quantity < 0 {
+		quantity = -ingredient.Stock
+	}
+	if _, err = tx.Exec(`UPDATE ingredient SET stock = stock + ? WHERE idIngredient = ?`, quantity, idIngredient); err != nil {
+		return fmt.Errorf("error updating stock: %v", err)
+	}
+
+	idMovement, err := utils.CreateAnId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO stockMovement (idMovement, idIngredient, kind, quantity, note, createdAt) VALUES (?, ?, ?, ?, ?, ?)`,
+		idMovement, idIngredient, kind, quantity, entry.Note, time.Now())
+	if err != nil {
+		return fmt.Errorf("error recording stock movement: %v", err)
+	}
+	if err = refreshStockAvailability(tx, idRestaurant); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// GetStockMovements returns the latest stock movements of a restaurant, of one ingredient when given
+func (s *store) GetStockMovements(idRestaurant, idIngredient string) ([]types.StockMovement, error) {
+	query := `
+		SELECT sm.idMovement, sm.idIngredient, i.name, sm.kind, sm.quantity, sm.idOrder, sm.note, sm.createdAt
+		FROM stockMovement sm
+		JOIN ingredient i ON i.idIngredient = sm.idIngredient
+		WHERE i.idRestaurant = ?
+	`
+	args := []interface{}{idRestaurant}
+	if idIngredient != "" {
+		query += ` AND sm.idIngredient = ?`
+		args = append(args, idIngredient)
+	}
+	rows, err := s.db.Query(query+` ORDER BY sm.createdAt DESC LIMIT 200`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching stock movements: %v", err)
+	}
+	defer rows.Close()
+
+	movements := []types.StockMovement{}
+	for rows.Next() {
+		var movement types.StockMovement
+		var idOrder sql.NullString
+		err := rows.Scan(
+			&movement.IdMovement,
+			&movement.IdIngredient,
+			&movement.Ingredient,
+			&movement.Kind,
+			&movement.Quantity,
+			&idOrder,
+			&movement.Note,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning stock movement: %v", err)
+		}
+		if idOrder.Valid {
+			movement.IdOrder = &idOrder.String
+		}
+		movements = append(movements, movement)
+	}
+	return movements, rows.Err()
+}
+
+func (s *store) GetFoodRecipe(idFood string) ([]types.RecipeItem, error) {
+	rows, err := s.db.Query(`
+		SELECT ri.idIngredient, i.name, i.unit, ri.quantity
+		FROM recipeItem ri
+		JOIN ingredient i ON i.idIngredient = ri.idIngredient
+		WHERE ri.idFood = ?
+		ORDER BY i.name`, idFood)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching recipe: %v", err)
+	}
+	defer rows.Close()
+
+	items := []types.RecipeItem{}
+	for rows.Next() {
+		var item types.RecipeItem
+		if err := rows.Scan(&item.IdIngredient, &item.Name, &item.Unit, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("error scanning recipe item: %v", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// SetFoodRecipe replaces the ingredients one portion of a food uses
+func (s *store) SetFoodRecipe(idFood string, items []types.RecipeItem) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var idRestaurant string
+	err = tx.QueryRow(`SELECT idRestaurant FROM food WHERE idFood = ?`, idFood).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("food with ID %s not found", idFood)
+			return err
+		}
+		return fmt.Errorf("error fetching food: %v", err)
+	}
+
+	if _, err = tx.Exec(`DELETE FROM recipeItem WHERE idFood = ?`, idFood); err != nil {
+		return fmt.Errorf("error clearing recipe: %v", err)
+	}
+	for _, item := range items {
+		if _, err = s.getIngredient(tx, idRestaurant, item.IdIngredient, false); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO recipeItem (idFood, idIngredient, quantity) VALUES (?, ?, ?)`, idFood, item.IdIngredient, item.Quantity)
+		if err != nil {
+			return fmt.Errorf("error saving recipe item: %v", err)
+		}
+	}
+	if err = refreshStockAvailability(tx, idRestaurant); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// consumeOrderStock takes the ingredients of an order out of stock, combos through the recipes of their foods.
+// It runs once per order, later calls are no-ops.
+func (s *store) consumeOrderStock(idOrder string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`UPDATE orderList SET stockDeductedAt = NOW() WHERE idOrder = ? AND stockDeductedAt IS NULL`, idOrder)
+	if err != nil {
+		return fmt.Errorf("error marking order stock: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return tx.Commit()
+	}
+
+	var idRestaurant sql.NullString
+	err = tx.QueryRow(`
+		SELECT COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant)
+		FROM orderList ol
+		LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+		LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+		LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+		WHERE ol.idOrder = ?`, idOrder).Scan(&idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error fetching order restaurant: %v", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT ri.idIngredient, SUM(portions.quantity * ri.quantity)
+		FROM (
+			SELECT ofd.idFood, ofd.quantity FROM orderFood ofd WHERE ofd.idOrder = ?
+			UNION ALL
+			SELECT ci.idFood, ofd.quantity * ci.quantity
+			FROM orderFood ofd
+			JOIN comboItem ci ON ci.idCombo = ofd.idFood
+			WHERE ofd.idOrder = ?
+		) portions
+		JOIN recipeItem ri ON ri.idFood = portions.idFood
+		GROUP BY ri.idIngredient`, idOrder, idOrder)
+	if err != nil {
+		return fmt.Errorf("error computing order ingredients: %v", err)
+	}
+	needs := make(map[string]float64)
+	for rows.Next() {
+		var idIngredient string
+		var quantity float64
+		if err = rows.Scan(&idIngredient, &quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning order ingredient: %v", err)
+		}
+		needs[idIngredient] = quantity
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating order ingredients: %v", err)
+	}
+
+	for idIngredient, quantity := range needs {
+		if _, err = tx.Exec(`UPDATE ingredient SET stock = GREATEST(stock - ?, 0) WHERE idIngredient = ?`, quantity, idIngredient); err != nil {
+			return fmt.Errorf("error updating stock: %v", err)
+		}
+		var idMovement string
+		if idMovement, err = utils.CreateAnId(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO stockMovement (idMovement, idIngredient, kind, quantity, idOrder, createdAt) VALUES (?, ?, 'consumption', ?, ?, ?)`,
+			idMovement, idIngredient, -quantity, idOrder, time.Now())
+		if err != nil {
+			return fmt.Errorf("error recording stock movement: %v", err)
+		}
+	}
+	if len(needs) > 0 && idRestaurant.Valid {
+		if err = refreshStockAvailability(tx, idRestaurant.String); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// Helper function to collect the first column of a query, so the transaction is free for the next statement
+func queryIds(db queryRunner, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// refreshStockAvailability puts foods whose ingredients ran out on hold, brings back the held foods that can be
+// cooked again, and notifies the restaurant admin of ingredients reaching their low stock threshold.
+func refreshStockAvailability(tx *sql.Tx, idRestaurant string) error {
+	shortFoods, err := queryIds(tx, `
+		SELECT DISTINCT ri.idFood
+		FROM recipeItem ri
+		JOIN ingredient i ON i.idIngredient = ri.idIngredient
+		JOIN food f ON f.idFood = ri.idFood
+		WHERE i.idRestaurant = ? AND f.status = 'available' AND i.stock < ri.quantity`, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error checking foods out of stock: %v", err)
+	}
+	for _, idFood := range shortFoods {
+		if _, err := tx.Exec(`UPDATE food SET status = 'unavailable' WHERE idFood = ?`, idFood); err != nil {
+			return fmt.Errorf("error marking food unavailable: %v", err)
+		}
+		if _, err := tx.Exec(`INSERT IGNORE INTO foodStockHold (idFood, createdAt) VALUES (?, ?)`, idFood, time.Now()); err != nil {
+			return fmt.Errorf("error holding food: %v", err)
+		}
+	}
+
+	backFoods, err := queryIds(tx, `
+		SELECT h.idFood
+		FROM foodStockHold h
+		JOIN food f ON f.idFood = h.idFood
+		WHERE f.idRestaurant = ? AND NOT EXISTS (
+			SELECT 1 FROM recipeItem ri
+			JOIN ingredient i ON i.idIngredient = ri.idIngredient
+			WHERE ri.idFood = h.idFood AND i.stock < ri.quantity
+		)`, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error checking foods back in stock: %v", err)
+	}
+	for _, idFood := range backFoods {
+		if _, err := tx.Exec(`UPDATE food SET status = 'available' WHERE idFood = ? AND status = 'unavailable'`, idFood); err != nil {
+			return fmt.Errorf("error marking food available: %v", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM foodStockHold WHERE idFood = ?`, idFood); err != nil {
+			return fmt.Errorf("error releasing food: %v", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE ingredient SET lowStockAlertedAt = NULL WHERE idRestaurant = ? AND lowStockAlertedAt IS NOT NULL AND stock > lowStockThreshold`, idRestaurant); err != nil {
+		return fmt.Errorf("error clearing low stock alerts: %v", err)
+	}
+	lowIngredients, err := queryIds(tx, `
+		SELECT idIngredient FROM ingredient
+		WHERE idRestaurant = ? AND lowStockThreshold > 0 AND stock <= lowStockThreshold AND lowStockAlertedAt IS NULL`, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error checking low stock: %v", err)
+	}
+	for _, idIngredient := range lowIngredients {
+		var idAdmin, restaurantName, name, unit string
+		var stock, threshold float64
+		err := tx.QueryRow(`
+			SELECT rest.idAdminRestaurant, rest.name, i.name, i.unit, i.stock, i.lowStockThreshold
+			FROM ingredient i
+			JOIN restaurant rest ON rest.idRestaurant = i.idRestaurant
+			WHERE i.idIngredient = ?`, idIngredient).Scan(&idAdmin, &restaurantName, &name, &unit, &stock, &threshold)
+		if err != nil {
+			return fmt.Errorf("error fetching low stock ingredient: %v", err)
+		}
+		idNotification, err := utils.CreateAnId()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO notifications (idNotification, idAdmin, titre, type, description) VALUES (?, ?, ?, ?, ?)`,
+			idNotification, idAdmin, "Low stock: "+name, "low_stock",
+			fmt.Sprintf("%s at %s is down to %g %s (alert threshold %g %s).", name, restaurantName, stock, unit, threshold, unit))
+		if err != nil {
+			return fmt.Errorf("error creating low stock notification: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE ingredient SET lowStockAlertedAt = ? WHERE idIngredient = ?`, time.Now(), idIngredient); err != nil {
+			return fmt.Errorf("error marking low stock alert: %v", err)
+		}
+	}
+	return nil
+}
+
+// UpdateFoodCategory renames a category of a restaurant
+func (s *store) UpdateFoodCategory(idRestaurant, idCategory, nameCategorie string) error {
+	result, err := s.db.Exec(`UPDATE foodCategory SET nameCategorie = ? WHERE idCategory = ? AND idRestaurant = ?`, nameCategorie, idCategory, idRestaurant)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("category %s already exists", nameCategorie)
+		}
+		return fmt.Errorf("error updating food category: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return s.checkFoodCategory(idRestaurant, idCategory)
+	}
+	return nil
+}
+
+// GetFoodCategoryImage gets the image of a category, which must belong to the restaurant
+func (s *store) GetFoodCategoryImage(idRestaurant, idCategory string) (string, error) {
+	if err := s.checkFoodCategory(idRestaurant, idCategory); err != nil {
+		return "", err
+	}
+	return currentImage(s.db, "category", "foodCategory", "idCategory", idCategory)
+}
+
+// SetFoodCategoryImage replaces the image of a category of a restaurant, returning the image it had so that the
+// caller can delete it
+func (s *store) SetFoodCategoryImage(idRestaurant, idCategory, image string) (string, error) {
+	if err := s.checkFoodCategory(idRestaurant, idCategory); err != nil {
+		return "", err
+	}
+	return swapImage(s.db, "category", "foodCategory", "idCategory", idCategory, image)
+}
+
+// Helper function to tell an unchanged category apart from a missing one
+func (s *store) checkFoodCategory(idRestaurant, idCategory string) error {
+	var exists int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM foodCategory WHERE idCategory = ? AND idRestaurant = ?`, idCategory, idRestaurant).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking food category: %v", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("category with ID %s not found", idCategory)
+	}
+	return nil
+}
+
+// ReorderFoodCategories puts the listed categories first, in that order, followed by the others as they were
+func (s *store) ReorderFoodCategories(idRestaurant string, idCategories []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := queryIds(tx, `SELECT idCategory FROM foodCategory WHERE idRestaurant = ? ORDER BY sortIndex, nameCategorie FOR UPDATE`, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error fetching food categories: %v", err)
+	}
+	owned := make(map[string]bool, len(current))
+	for _, idCategory := range current {
+		owned[idCategory] = true
+	}
+
+	ordered := make([]string, 0, len(current))
+	listed := make(map[string]bool, len(idCategories))
+	for _, idCategory := range idCategories {
+		if !owned[idCategory] {
+			err = fmt.Errorf("category with ID %s not found", idCategory)
+			return err
+		}
+		if !listed[idCategory] {
+			listed[idCategory] = true
+			ordered = append(ordered, idCategory)
+		}
+	}
+	for _, idCategory := range current {
+		if !listed[idCategory] {
+			ordered = append(ordered, idCategory)
+		}
+	}
+
+	for index, idCategory := range ordered {
+		if _, err = tx.Exec(`UPDATE foodCategory SET sortIndex = ? WHERE idCategory = ?`, index, idCategory); err != nil {
+			return fmt.Errorf("error ordering food categories: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// DeleteFoodCategory removes an empty category of a restaurant
+func (s *store) DeleteFoodCategory(idRestaurant, idCategory string) error {
+	if err := s.checkFoodCategory(idRestaurant, idCategory); err != nil {
+		return err
+	}
+	var foods int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM food WHERE idCategory = ?`, idCategory).Scan(&foods); err != nil {
+		return fmt.Errorf("error counting category foods: %v", err)
+	}
+	if foods > 0 {
+		return fmt.Errorf("cannot delete a category still used by %d foods", foods)
+	}
+	if _, err := s.db.Exec(`DELETE FROM foodCategory WHERE idCategory = ? AND idRestaurant = ?`, idCategory, idRestaurant); err != nil {
+		return fmt.Errorf("error deleting food category: %v", err)
+	}
+	return nil
+}
+
+// GetShiftSettings returns the scheduling rules of a restaurant, with the defaults when none were saved
+func (s *store) GetShiftSettings(idRestaurant string) (*types.ShiftSettings, error) {
+	return getShiftSettings(s.db, idRestaurant)
+}
+
+func getShiftSettings(db queryExecer, idRestaurant string) (*types.ShiftSettings, error) {
+	query := `SELECT idRestaurant, minRestHours, clockInEarlyMinutes FROM shiftSettings WHERE idRestaurant = ?`
+	var settings types.ShiftSettings
+	err := db.QueryRow(query, idRestaurant).Scan(&settings.IdRestaurant, &settings.MinRestHours, &settings.ClockInEarlyMinutes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &types.ShiftSettings{
+				IdRestaurant:        idRestaurant,
+				MinRestHours:        defaultMinRestHours,
+				ClockInEarlyMinutes: defaultClockInEarlyMinutes,
+			}, nil
+		}
+		return nil, fmt.Errorf("error retrieving shift settings: %v", err)
+	}
+	return &settings, nil
+}
+
+func (s *store) UpsertShiftSettings(settings types.ShiftSettings) error {
+	query := `
+		INSERT INTO shiftSettings (idRestaurant, minRestHours, clockInEarlyMinutes)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			minRestHours = VALUES(minRestHours),
+			clockInEarlyMinutes = VALUES(clockInEarlyMinutes)
+	`
+	_, err := s.db.Exec(query, settings.IdRestaurant, settings.MinRestHours, settings.ClockInEarlyMinutes)
+	if err != nil {
+		return fmt.Errorf("error saving shift settings: %v", err)
+	}
+	return nil
+}
+
+const (
+	defaultMinRestHours        = 10
+	defaultClockInEarlyMinutes = 30
+)
+
+const shiftTemplateColumns = `idTemplate, idRestaurant, label, startTime, endTime, weekdays, createdAt`
+
+func scanShiftTemplate(row interface{ Scan(...interface{}) error }) (types.ShiftTemplate, error) {
+	var template types.ShiftTemplate
+	var weekdays string
+	err := row.Scan(
+		&template.IdTemplate,
+		&template.IdRestaurant,
+		&template.Label,
+		&template.StartTime,
+		&template.EndTime,
+		&weekdays,
+		&template.CreatedAt,
+	)
+	if err != nil {
+		return template, err
+	}
+	template.Weekdays, err = parseWeekdays(weekdays)
+	return template, err
+}
+
+// GetShiftTemplates lists the weekly shift templates of a restaurant
+func (s *store) GetShiftTemplates(idRestaurant string) ([]types.ShiftTemplate, error) {
+	rows, err := s.db.Query(`SELECT `+shiftTemplateColumns+` FROM shiftTemplate WHERE idRestaurant = ? ORDER BY startTime, label`, idRestaurant)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching shift templates: %v", err)
+	}
+	defer rows.Close()
+
+	templates := []types.ShiftTemplate{}
+	for rows.Next() {
+		template, err := scanShiftTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shift template: %v", err)
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (s *store) CreateShiftTemplate(template types.ShiftTemplate) error {
+	weekdays := make([]string, 0, len(template.Weekdays))
+	for _, day := range template.Weekdays {
+		weekdays = append(weekdays, strconv.Itoa(day))
+	}
+	query := `
+		INSERT INTO shiftTemplate (idTemplate, idRestaurant, label, startTime, endTime, weekdays, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query,
+		template.IdTemplate,
+		template.IdRestaurant,
+		template.Label,
+		template.StartTime,
+		template.EndTime,
+		strings.Join(weekdays, ","),
+		template.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating shift template: %v", err)
+	}
+	return nil
+}
+
+// DeleteShiftTemplate removes a template, the shifts already built from it are kept
+func (s *store) DeleteShiftTemplate(idRestaurant, idTemplate string) error {
+	result, err := s.db.Exec(`DELETE FROM shiftTemplate WHERE idTemplate = ? AND idRestaurant = ?`, idTemplate, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error deleting shift template: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("shift template with ID %s not found", idTemplate)
+	}
+	return nil
+}
+
+// Helper function to check that a worker belongs to the restaurant and is still employed. The worker row is
+// locked so that two assignments for the same worker cannot pass the conflict checks at the same time.
+func lockActiveWorker(db queryExecer, idRestaurant, idRestaurantWorker string) error {
+	var status string
+	query := `SELECT status FROM restaurantWorkers WHERE idRestaurantWorker = ? AND idRestaurant = ? FOR UPDATE`
+	err := db.QueryRow(query, idRestaurantWorker, idRestaurant).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("restaurant worker with ID %s not found", idRestaurantWorker)
+		}
+		return fmt.Errorf("error fetching restaurant worker: %v", err)
+	}
+	if status != "active" {
+		return fmt.Errorf("worker %s is %s and cannot be scheduled", idRestaurantWorker, status)
+	}
+	return nil
+}
+
+// Helper function to find the shifts of a worker that overlap a new one, or leave less than minRest between them
+func findShiftConflicts(db queryRunner, idRestaurantWorker string, startsAt, endsAt time.Time, minRest time.Duration, excludeShift string) ([]types.ShiftConflict, error) {
+	rows, err := db.Query(`
+		SELECT idShift, startsAt, endsAt
+		FROM workerShift
+		WHERE idRestaurantWorker = ?
+		AND idShift <> ?
+		AND startsAt < ?
+		AND endsAt > ?
+		ORDER BY startsAt`,
+		idRestaurantWorker, excludeShift, endsAt.Add(minRest), startsAt.Add(-minRest))
+	if err != nil {
+		return nil, fmt.Errorf("error checking shift conflicts: %v", err)
+	}
+	defer rows.Close()
+
+	conflicts := []types.ShiftConflict{}
+	for rows.Next() {
+		conflict := types.ShiftConflict{
+			IdRestaurantWorker: idRestaurantWorker,
+			StartsAt:           startsAt,
+			EndsAt:             endsAt,
+		}
+		if err := rows.Scan(&conflict.IdOtherShift, &conflict.OtherStartsAt, &conflict.OtherEndsAt); err != nil {
+			return nil, fmt.Errorf("error scanning shift: %v", err)
+		}
+		conflict.Kind = shiftConflictKind(startsAt, endsAt, conflict.OtherStartsAt, conflict.OtherEndsAt)
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts, rows.Err()
+}
+
+func shiftConflictKind(startsAt, endsAt, otherStartsAt, otherEndsAt time.Time) string {
+	if startsAt.Before(otherEndsAt) && otherStartsAt.Before(endsAt) {
+		return "double_shift"
+	}
+	return "min_rest"
+}
+
+// Helper function to turn the first conflict of a shift into the error returned to the caller
+func shiftConflictError(conflicts []types.ShiftConflict, minRestHours int) error {
+	conflict := conflicts[0]
+	if conflict.Kind == "double_shift" {
+		return fmt.Errorf("shift conflicts with shift %s (%s - %s) of the same worker",
+			conflict.IdOtherShift, conflict.OtherStartsAt.Format("2006-01-02 15:04"), conflict.OtherEndsAt.Format("2006-01-02 15:04"))
+	}
+	return fmt.Errorf("shift conflicts with shift %s (%s - %s): workers need %d hours of rest between shifts",
+		conflict.IdOtherShift, conflict.OtherStartsAt.Format("2006-01-02 15:04"), conflict.OtherEndsAt.Format("2006-01-02 15:04"), minRestHours)
+}
+
+// Helper function to insert a shift once the worker and the scheduling rules were checked
+func insertWorkerShift(tx *sql.Tx, settings *types.ShiftSettings, shift types.WorkerShift) error {
+	minRest := time.Duration(settings.MinRestHours) * time.Hour
+	conflicts, err := findShiftConflicts(tx, shift.IdRestaurantWorker, shift.StartsAt, shift.EndsAt, minRest, "")
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return shiftConflictError(conflicts, settings.MinRestHours)
+	}
+
+	query := `
+		INSERT INTO workerShift (idShift, idRestaurant, idRestaurantWorker, idTemplate, startsAt, endsAt, note, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(query,
+		shift.IdShift,
+		shift.IdRestaurant,
+		shift.IdRestaurantWorker,
+		shift.IdTemplate,
+		shift.StartsAt,
+		shift.EndsAt,
+		shift.Note,
+		shift.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating shift: %v", err)
+	}
+	return nil
+}
+
+// CreateWorkerShift assigns a single shift to a worker, refusing double shifts and too short rests
+func (s *store) CreateWorkerShift(shift types.WorkerShift) error {
+	settings, err := s.GetShiftSettings(shift.IdRestaurant)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = lockActiveWorker(tx, shift.IdRestaurant, shift.IdRestaurantWorker); err != nil {
+		return err
+	}
+	if err = insertWorkerShift(tx, settings, shift); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// AssignShiftTemplate gives a worker every shift of a template between two dates. Nothing is assigned when
+// one of the shifts conflicts with the worker's schedule.
+func (s *store) AssignShiftTemplate(idRestaurant, idTemplate string, assignment types.TemplateAssignment) ([]types.WorkerShift, error) {
+	from, err := time.ParseInLocation("2006-01-02", assignment.From, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from date %q", assignment.From)
+	}
+	to, err := time.ParseInLocation("2006-01-02", assignment.To, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to date %q", assignment.To)
+	}
+	settings, err := s.GetShiftSettings(idRestaurant)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := scanShiftTemplate(s.db.QueryRow(`SELECT `+shiftTemplateColumns+` FROM shiftTemplate WHERE idTemplate = ? AND idRestaurant = ?`, idTemplate, idRestaurant))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("shift template with ID %s not found", idTemplate)
+		}
+		return nil, fmt.Errorf("error fetching shift template: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = lockActiveWorker(tx, idRestaurant, assignment.IdRestaurantWorker); err != nil {
+		return nil, err
+	}
+
+	shifts := []types.WorkerShift{}
+	now := time.Now()
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !weekdayIncluded(template.Weekdays, day.Weekday()) {
+			continue
+		}
+		shift := types.WorkerShift{
+			IdRestaurant:       idRestaurant,
+			IdRestaurantWorker: assignment.IdRestaurantWorker,
+			IdTemplate:         &template.IdTemplate,
+			Note:               template.Label,
+			CreatedAt:          now,
+		}
+		if shift.StartsAt, err = clockTimeOn(day, template.StartTime); err != nil {
+			return nil, err
+		}
+		if shift.EndsAt, err = clockTimeOn(day, template.EndTime); err != nil {
+			return nil, err
+		}
+		if !shift.EndsAt.After(shift.StartsAt) {
+			shift.EndsAt = shift.EndsAt.AddDate(0, 0, 1)
+		}
+		if shift.IdShift, err = utils.CreateAnId(); err != nil {
+			return nil, err
+		}
+		if err = insertWorkerShift(tx, settings, shift); err != nil {
+			err = fmt.Errorf("%s: %v", day.Format("2006-01-02"), err)
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return shifts, nil
+}
+
+func weekdayIncluded(weekdays []int, weekday time.Weekday) bool {
+	if len(weekdays) == 0 {
+		return true
+	}
+	for _, day := range weekdays {
+		if day == int(weekday) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWorkerShifts lists the shifts of a restaurant overlapping a period, optionally for a single worker
+func (s *store) GetWorkerShifts(idRestaurant, idRestaurantWorker string, from, to time.Time) ([]types.WorkerShift, error) {
+	query := `
+		SELECT ws.idShift, ws.idRestaurant, ws.idRestaurantWorker, rw.firstName, rw.lastName,
+			ws.idTemplate, ws.startsAt, ws.endsAt, ws.note, ws.createdAt
+		FROM workerShift ws
+		JOIN restaurantWorkers rw ON rw.idRestaurantWorker = ws.idRestaurantWorker
+		WHERE ws.idRestaurant = ?
+		AND ws.startsAt < ?
+		AND ws.endsAt > ?
+	`
+	args := []interface{}{idRestaurant, to, from}
+	if idRestaurantWorker != "" {
+		query += ` AND ws.idRestaurantWorker = ?`
+		args = append(args, idRestaurantWorker)
+	}
+	query += ` ORDER BY ws.startsAt, rw.lastName`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching shifts: %v", err)
+	}
+	defer rows.Close()
+
+	shifts := []types.WorkerShift{}
+	for rows.Next() {
+		var shift types.WorkerShift
+		err := rows.Scan(
+			&shift.IdShift,
+			&shift.IdRestaurant,
+			&shift.IdRestaurantWorker,
+			&shift.FirstName,
+			&shift.LastName,
+			&shift.IdTemplate,
+			&shift.StartsAt,
+			&shift.EndsAt,
+			&shift.Note,
+			&shift.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shift: %v", err)
+		}
+		shifts = append(shifts, shift)
+	}
+	return shifts, rows.Err()
+}
+
+func (s *store) DeleteWorkerShift(idRestaurant, idShift string) error {
+	result, err := s.db.Exec(`DELETE FROM workerShift WHERE idShift = ? AND idRestaurant = ?`, idShift, idRestaurant)
+	if err != nil {
+		return fmt.Errorf("error deleting shift: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("shift with ID %s not found", idShift)
+	}
+	return nil
+}
+
+// GetShiftConflicts lists the double shifts and too short rests of a restaurant's schedule over a period,
+// e.g. left behind after the minimum rest was raised
+func (s *store) GetShiftConflicts(idRestaurant string, from, to time.Time) ([]types.ShiftConflict, error) {
+	settings, err := s.GetShiftSettings(idRestaurant)
+	if err != nil {
+		return nil, err
+	}
+	minRest := time.Duration(settings.MinRestHours) * time.Hour
+
+	shifts, err := s.GetWorkerShifts(idRestaurant, "", from.Add(-minRest), to.Add(minRest))
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []types.ShiftConflict{}
+	for i, shift := range shifts {
+		for _, other := range shifts[i+1:] {
+			if other.IdRestaurantWorker != shift.IdRestaurantWorker {
+				continue
+			}
+			// Shifts are sorted by start, so other never starts before shift
+			if !other.StartsAt.Before(shift.EndsAt.Add(minRest)) {
+				continue
+			}
+			if !shift.StartsAt.Before(to) || !other.EndsAt.After(from) {
+				continue
+			}
+			conflicts = append(conflicts, types.ShiftConflict{
+				IdRestaurantWorker: shift.IdRestaurantWorker,
+				Kind:               shiftConflictKind(shift.StartsAt, shift.EndsAt, other.StartsAt, other.EndsAt),
+				IdShift:            shift.IdShift,
+				IdOtherShift:       other.IdShift,
+				StartsAt:           shift.StartsAt,
+				EndsAt:             shift.EndsAt,
+				OtherStartsAt:      other.StartsAt,
+				OtherEndsAt:        other.EndsAt,
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+const shiftSwapQuery = `
+	SELECT sw.idSwap, sw.idShift, sw.fromWorker, sw.toWorker, sw.status, sw.note,
+		ws.startsAt, ws.endsAt, sw.createdAt, sw.resolvedAt
+	FROM shiftSwap sw
+	JOIN workerShift ws ON ws.idShift = sw.idShift
+`
+
+func scanShiftSwap(row interface{ Scan(...interface{}) error }) (types.ShiftSwap, error) {
+	var swap types.ShiftSwap
+	err := row.Scan(
+		&swap.IdSwap,
+		&swap.IdShift,
+		&swap.FromWorker,
+		&swap.ToWorker,
+		&swap.Status,
+		&swap.Note,
+		&swap.StartsAt,
+		&swap.EndsAt,
+		&swap.CreatedAt,
+		&swap.ResolvedAt,
+	)
+	return swap, err
+}
+
+// CreateShiftSwap asks for a shift to be handed over to another worker of the restaurant
+func (s *store) CreateShiftSwap(idRestaurant string, swap types.ShiftSwap) (*types.ShiftSwap, error) {
+	var startsAt, endsAt time.Time
+	query := `SELECT idRestaurantWorker, startsAt, endsAt FROM workerShift WHERE idShift = ? AND idRestaurant = ?`
+	err := s.db.QueryRow(query, swap.IdShift, idRestaurant).Scan(&swap.FromWorker, &startsAt, &endsAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("shift with ID %s not found", swap.IdShift)
+		}
+		return nil, fmt.Errorf("error fetching shift: %v", err)
+	}
+	if !startsAt.After(time.Now()) {
+		return nil, fmt.Errorf("cannot swap a shift that already started")
+	}
+	if swap.ToWorker == swap.FromWorker {
+		return nil, fmt.Errorf("cannot swap a shift with the worker already assigned to it")
+	}
+	if err := lockActiveWorker(s.db, idRestaurant, swap.ToWorker); err != nil {
+		return nil, err
+	}
+
+	var pending int
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM shiftSwap WHERE idShift = ? AND status = 'pending'`, swap.IdShift).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("error checking pending swaps: %v", err)
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("a pending swap already exists for shift %s", swap.IdShift)
+	}
+
+	swap.Status = "pending"
+	swap.StartsAt = startsAt
+	swap.EndsAt = endsAt
+	_, err = s.db.Exec(`
+		INSERT INTO shiftSwap (idSwap, idShift, fromWorker, toWorker, status, note, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		swap.IdSwap, swap.IdShift, swap.FromWorker, swap.ToWorker, swap.Status, swap.Note, swap.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating shift swap: %v", err)
+	}
+	return &swap, nil
+}
+
+// GetShiftSwaps lists the swap requests of a restaurant, optionally with a given status
+func (s *store) GetShiftSwaps(idRestaurant, status string) ([]types.ShiftSwap, error) {
+	query := shiftSwapQuery + ` WHERE ws.idRestaurant = ?`
+	args := []interface{}{idRestaurant}
+	if status != "" {
+		query += ` AND sw.status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY ws.startsAt`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching shift swaps: %v", err)
+	}
+	defer rows.Close()
+
+	swaps := []types.ShiftSwap{}
+	for rows.Next() {
+		swap, err := scanShiftSwap(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning shift swap: %v", err)
+		}
+		swaps = append(swaps, swap)
+	}
+	return swaps, rows.Err()
+}
+
+// ResolveShiftSwap accepts, rejects or cancels a pending swap. Accepting hands the shift over, as long as it
+// fits the schedule of the worker taking it.
+func (s *store) ResolveShiftSwap(idRestaurant, idSwap, status string) error {
+	settings, err := s.GetShiftSettings(idRestaurant)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	swap, err := scanShiftSwap(tx.QueryRow(shiftSwapQuery+` WHERE sw.idSwap = ? AND ws.idRestaurant = ? FOR UPDATE`, idSwap, idRestaurant))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("shift swap with ID %s not found", idSwap)
+			return err
+		}
+		err = fmt.Errorf("error fetching shift swap: %v", err)
+		return err
+	}
+	if swap.Status != "pending" {
+		err = fmt.Errorf("cannot change a swap that was already %s", swap.Status)
+		return err
+	}
+
+	if status == "accepted" {
+		if err = lockActiveWorker(tx, idRestaurant, swap.ToWorker); err != nil {
+			return err
+		}
+		minRest := time.Duration(settings.MinRestHours) * time.Hour
+		var conflicts []types.ShiftConflict
+		conflicts, err = findShiftConflicts(tx, swap.ToWorker, swap.StartsAt, swap.EndsAt, minRest, swap.IdShift)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			err = shiftConflictError(conflicts, settings.MinRestHours)
+			return err
+		}
+		if _, err = tx.Exec(`UPDATE workerShift SET idRestaurantWorker = ? WHERE idShift = ?`, swap.ToWorker, swap.IdShift); err != nil {
+			err = fmt.Errorf("error reassigning shift: %v", err)
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(`UPDATE shiftSwap SET status = ?, resolvedAt = ? WHERE idSwap = ?`, status, time.Now(), idSwap); err != nil {
+		err = fmt.Errorf("error updating shift swap: %v", err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+const workerClockColumns = `idClock, idRestaurantWorker, idRestaurant, idShift, clockIn, clockOut`
+
+func scanWorkerClock(row interface{ Scan(...interface{}) error }, now time.Time) (types.WorkerClock, error) {
+	var clock types.WorkerClock
+	err := row.Scan(&clock.IdClock, &clock.IdRestaurantWorker, &clock.IdRestaurant, &clock.IdShift, &clock.ClockIn, &clock.ClockOut)
+	if err != nil {
+		return clock, err
+	}
+	end := now
+	if clock.ClockOut != nil {
+		end = *clock.ClockOut
+	}
+	clock.Hours = hoursBetween(clock.ClockIn, end)
+	return clock, nil
+}
+
+func hoursBetween(from, to time.Time) float64 {
+	if !to.After(from) {
+		return 0
+	}
+	return math.Round(to.Sub(from).Hours()*100) / 100
+}
+
+// ClockIn opens a clock record for a worker, attached to the shift they are starting when there is one
+func (s *store) ClockIn(idRestaurantWorker string) (*types.WorkerClock, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var idRestaurant, status string
+	err = tx.QueryRow(`SELECT idRestaurant, status FROM restaurantWorkers WHERE idRestaurantWorker = ? FOR UPDATE`, idRestaurantWorker).Scan(&idRestaurant, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("restaurant worker with ID %s not found", idRestaurantWorker)
+			return nil, err
+		}
+		err = fmt.Errorf("error fetching restaurant worker: %v", err)
+		return nil, err
+	}
+	if status != "active" {
+		err = fmt.Errorf("worker %s is %s and cannot clock in", idRestaurantWorker, status)
+		return nil, err
+	}
+
+	var open int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM workerClock WHERE idRestaurantWorker = ? AND clockOut IS NULL`, idRestaurantWorker).Scan(&open)
+	if err != nil {
+		err = fmt.Errorf("error checking open clock records: %v", err)
+		return nil, err
+	}
+	if open > 0 {
+		err = fmt.Errorf("worker %s is already clocked in", idRestaurantWorker)
+		return nil, err
+	}
+
+	settings, err := getShiftSettings(tx, idRestaurant)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	clock := types.WorkerClock{
+		IdRestaurantWorker: idRestaurantWorker,
+		IdRestaurant:       idRestaurant,
+		ClockIn:            now,
+	}
+
+	var idShift string
+	err = tx.QueryRow(`
+		SELECT idShift
+		FROM workerShift
+		WHERE idRestaurantWorker = ?
+		AND startsAt <= ?
+		AND endsAt > ?
+		ORDER BY startsAt
+		LIMIT 1`,
+		idRestaurantWorker, now.Add(time.Duration(settings.ClockInEarlyMinutes)*time.Minute), now).Scan(&idShift)
+	switch {
+	case err == sql.ErrNoRows:
+		err = nil
+	case err != nil:
+		err = fmt.Errorf("error fetching current shift: %v", err)
+		return nil, err
+	default:
+		clock.IdShift = &idShift
+	}
+
+	if clock.IdClock, err = utils.CreateAnId(); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`INSERT INTO workerClock (idClock, idRestaurantWorker, idRestaurant, idShift, clockIn) VALUES (?, ?, ?, ?, ?)`,
+		clock.IdClock, clock.IdRestaurantWorker, clock.IdRestaurant, clock.IdShift, clock.ClockIn)
+	if err != nil {
+		err = fmt.Errorf("error clocking in: %v", err)
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return &clock, nil
+}
+
+// ClockOut closes the open clock record of a worker
+func (s *store) ClockOut(idRestaurantWorker string) (*types.WorkerClock, error) {
+	now := time.Now()
+	clock, err := scanWorkerClock(s.db.QueryRow(`
+		SELECT `+workerClockColumns+`
+		FROM workerClock
+		WHERE idRestaurantWorker = ?
+		AND clockOut IS NULL
+		ORDER BY clockIn DESC
+		LIMIT 1`, idRestaurantWorker), now)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("worker %s is not clocked in", idRestaurantWorker)
+		}
+		return nil, fmt.Errorf("error fetching clock record: %v", err)
+	}
+
+	result, err := s.db.Exec(`UPDATE workerClock SET clockOut = ? WHERE idClock = ? AND clockOut IS NULL`, now, clock.IdClock)
+	if err != nil {
+		return nil, fmt.Errorf("error clocking out: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("worker %s is not clocked in", idRestaurantWorker)
+	}
+	clock.ClockOut = &now
+	return &clock, nil
+}
+
+// GetWorkerTimesheet lists the clock records of a worker over a period, with the hours worked and scheduled
+func (s *store) GetWorkerTimesheet(idRestaurantWorker string, from, to time.Time) (*types.Timesheet, error) {
+	var idRestaurant string
+	err := s.db.QueryRow(`SELECT idRestaurant FROM restaurantWorkers WHERE idRestaurantWorker = ?`, idRestaurantWorker).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("restaurant worker with ID %s not found", idRestaurantWorker)
+		}
+		return nil, fmt.Errorf("error fetching restaurant worker: %v", err)
+	}
+
+	timesheet := types.Timesheet{
+		IdRestaurantWorker: idRestaurantWorker,
+		From:               from.Format("2006-01-02"),
+		To:                 to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Records:            []types.WorkerClock{},
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+workerClockColumns+`
+		FROM workerClock
+		WHERE idRestaurantWorker = ?
+		AND clockIn >= ?
+		AND clockIn < ?
+		ORDER BY clockIn`, idRestaurantWorker, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching clock records: %v", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		clock, err := scanWorkerClock(rows, now)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning clock record: %v", err)
+		}
+		timesheet.WorkedHours += clock.Hours
+		timesheet.Records = append(timesheet.Records, clock)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	timesheet.WorkedHours = math.Round(timesheet.WorkedHours*100) / 100
+
+	shifts, err := s.GetWorkerShifts(idRestaurant, idRestaurantWorker, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, shift := range shifts {
+		if !shift.StartsAt.Before(from) {
+			timesheet.ScheduledHours += hoursBetween(shift.StartsAt, shift.EndsAt)
+		}
+	}
+	timesheet.ScheduledHours = math.Round(timesheet.ScheduledHours*100) / 100
+	return &timesheet, nil
+}
+
+// GetOnShiftWorkers lists the workers scheduled right now and those clocked in, with or without a shift
+func (s *store) GetOnShiftWorkers(idRestaurant string, now time.Time) ([]types.OnShiftWorker, error) {
+	rows, err := s.db.Query(`
+		SELECT rw.idRestaurantWorker, rw.firstName, rw.lastName, rw.image, ws.idShift, ws.startsAt, ws.endsAt, NULL
+		FROM workerShift ws
+		JOIN restaurantWorkers rw ON rw.idRestaurantWorker = ws.idRestaurantWorker
+		WHERE ws.idRestaurant = ?
+		AND rw.status = 'active'
+		AND ws.startsAt <= ?
+		AND ws.endsAt > ?
+		UNION ALL
+		SELECT rw.idRestaurantWorker, rw.firstName, rw.lastName, rw.image, NULL, NULL, NULL, wc.clockIn
+		FROM workerClock wc
+		JOIN restaurantWorkers rw ON rw.idRestaurantWorker = wc.idRestaurantWorker
+		WHERE wc.idRestaurant = ?
+		AND wc.clockOut IS NULL`,
+		idRestaurant, now, now, idRestaurant)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching workers on shift: %v", err)
+	}
+	defer rows.Close()
+
+	workers := []types.OnShiftWorker{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var worker types.OnShiftWorker
+		err := rows.Scan(
+			&worker.IdRestaurantWorker,
+			&worker.FirstName,
+			&worker.LastName,
+			&worker.Image,
+			&worker.IdShift,
+			&worker.ShiftStartsAt,
+			&worker.ShiftEndsAt,
+			&worker.ClockedInAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning worker on shift: %v", err)
+		}
+
+		i, seen := index[worker.IdRestaurantWorker]
+		if !seen {
+			index[worker.IdRestaurantWorker] = len(workers)
+			workers = append(workers, worker)
+			continue
+		}
+		if worker.IdShift != nil && workers[i].IdShift == nil {
+			workers[i].IdShift = worker.IdShift
+			workers[i].ShiftStartsAt = worker.ShiftStartsAt
+			workers[i].ShiftEndsAt = worker.ShiftEndsAt
+		}
+		if worker.ClockedInAt != nil {
+			workers[i].ClockedInAt = worker.ClockedInAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range workers {
+		switch {
+		case workers[i].IdShift == nil:
+			workers[i].Status = "unscheduled"
+		case workers[i].ClockedInAt == nil:
+			workers[i].Status = "not_clocked_in"
+		default:
+			workers[i].Status = "working"
+		}
+	}
+	return workers, nil
+}
+
+// Helper function to check that a worker can serve at a restaurant
+func checkServer(db queryExecer, idRestaurant, idRestaurantWorker string) error {
+	var workerRestaurant, status string
+	query := `SELECT idRestaurant, status FROM restaurantWorkers WHERE idRestaurantWorker = ?`
+	err := db.QueryRow(query, idRestaurantWorker).Scan(&workerRestaurant, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("restaurant worker with ID %s not found", idRestaurantWorker)
+		}
+		return fmt.Errorf("error fetching restaurant worker: %v", err)
+	}
+	if workerRestaurant != idRestaurant {
+		return fmt.Errorf("worker %s does not belong to this restaurant", idRestaurantWorker)
+	}
+	if status != "active" {
+		return fmt.Errorf("worker %s is %s and cannot serve", idRestaurantWorker, status)
+	}
+	return nil
+}
+
+// AssignReservationServer records the worker serving a reservation, nil clears it
+func (s *store) AssignReservationServer(idReservation string, idRestaurantWorker *string) error {
+	var idRestaurant string
+	err := s.db.QueryRow(`SELECT idRestaurant FROM reservation WHERE idReservation = ?`, idReservation).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("reservation with ID %s not found", idReservation)
+		}
+		return fmt.Errorf("error fetching reservation: %v", err)
+	}
+	if idRestaurantWorker != nil {
+		if err := checkServer(s.db, idRestaurant, *idRestaurantWorker); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(`UPDATE reservation SET idServer = ? WHERE idReservation = ?`, idRestaurantWorker, idReservation)
+	if err != nil {
+		return fmt.Errorf("error assigning reservation server: %v", err)
+	}
+	return nil
+}
+
+// AssignOrderServer records the worker serving an order, nil clears it
+func (s *store) AssignOrderServer(idOrder string, idRestaurantWorker *string) error {
+	var idRestaurant sql.NullString
+	err := s.db.QueryRow(`
+		SELECT COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant)
+		FROM orderList ol
+		LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+		LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+		LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+		WHERE ol.idOrder = ?`, idOrder).Scan(&idRestaurant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order with ID %s not found", idOrder)
+		}
+		return fmt.Errorf("error fetching order restaurant: %v", err)
+	}
+	if idRestaurantWorker != nil {
+		if err := checkServer(s.db, idRestaurant.String, *idRestaurantWorker); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(`UPDATE orderList SET idServer = ? WHERE idOrder = ?`, idRestaurantWorker, idOrder)
+	if err != nil {
+		return fmt.Errorf("error assigning order server: %v", err)
+	}
+	return nil
+}
+
+// PostRatingWorker rates the server of a completed visit. Orders placed under a reservation belong to that
+// reservation's visit, so a client rates a visit once whichever way it is given. Takeaway orders are rated by
+// the client who placed them, and table session orders not at all. The worker's aggregate rating is
+// recomputed with the new rating.
+func (s *store) PostRatingWorker(rating *types.PostRatingWorker) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var idServer sql.NullString
+	if rating.IdOrder != nil {
+		var status string
+		var idReservation, idTableSession, orderServer, takeawayClient sql.NullString
+		err = tx.QueryRow(`
+			SELECT ol.status, ol.idReservation, ol.idTableSession, ol.idServer, tk.idClient
+			FROM orderList ol
+			LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+			WHERE ol.idOrder = ?`, *rating.IdOrder).Scan(&status, &idReservation, &idTableSession, &orderServer, &takeawayClient)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("order with ID %s not found", *rating.IdOrder)
+				return err
+			}
+			err = fmt.Errorf("error fetching order: %v", err)
+			return err
+		}
+		// Walk-in guests order against a table session without a client account, so nobody can claim the visit
+		if idTableSession.Valid {
+			err = fmt.Errorf("orders of a table session cannot be rated, they are not tied to a client")
+			return err
+		}
+		if status != "completed" {
+			err = fmt.Errorf("cannot rate the server of an order that is not completed")
+			return err
+		}
+		idServer = orderServer
+
+		if idReservation.Valid {
+			rating.IdReservation = &idReservation.String
+			rating.IdOrder = nil
+		} else if takeawayClient.String != rating.IdClient {
+			err = fmt.Errorf("order %s does not belong to client %s", *rating.IdOrder, rating.IdClient)
+			return err
+		}
+	}
+
+	if rating.IdReservation != nil {
+		var status, host string
+		var reservationServer sql.NullString
+		var accepted int
+		err = tx.QueryRow(`
+			SELECT r.status, r.idClient, r.idServer,
+				(SELECT COUNT(*) FROM reservationGuest rg WHERE rg.idReservation = r.idReservation AND rg.idClient = ? AND rg.status = 'accepted')
+			FROM reservation r
+			WHERE r.idReservation = ?`, rating.IdClient, *rating.IdReservation).Scan(&status, &host, &reservationServer, &accepted)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("reservation with ID %s not found", *rating.IdReservation)
+				return err
+			}
+			err = fmt.Errorf("error fetching reservation: %v", err)
+			return err
+		}
+		if host != rating.IdClient && accepted == 0 {
+			err = fmt.Errorf("reservation %s does not belong to client %s", *rating.IdReservation, rating.IdClient)
+			return err
+		}
+		if status != "completed" {
+			err = fmt.Errorf("cannot rate the server of a reservation that is not completed")
+			return err
+		}
+
+		if !idServer.Valid {
+			idServer = reservationServer
+		}
+		// Without a server on the reservation, the one who brought its last order served the table
+		if !idServer.Valid {
+			err = tx.QueryRow(`
+				SELECT idServer FROM orderList
+				WHERE idReservation = ? AND idServer IS NOT NULL
+				ORDER BY createdAt DESC
+				LIMIT 1`, *rating.IdReservation).Scan(&idServer)
+			if err == sql.ErrNoRows {
+				err = nil
+			}
+			if err != nil {
+				err = fmt.Errorf("error fetching order server: %v", err)
+				return err
+			}
+		}
+	}
+
+	if !idServer.Valid {
+		err = fmt.Errorf("cannot rate this visit, no server was assigned to it")
+		return err
+	}
+	rating.IdRestaurantWorker = idServer.String
+
+	var rated int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM rating
+		WHERE ratingType = 'worker' AND idClient = ? AND (idReservation = ? OR idOrder = ?)`,
+		rating.IdClient, rating.IdReservation, rating.IdOrder).Scan(&rated)
+	if err != nil {
+		err = fmt.Errorf("error checking existing rating: %v", err)
+		return err
+	}
+	if rated > 0 {
+		err = fmt.Errorf("client %s already rated the server of this visit", rating.IdClient)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO rating (idRating, idClient, idRestaurantWorker, idReservation, idOrder, ratingType, rating, comment, createdAt)
+		VALUES (?, ?, ?, ?, ?, 'worker', ?, ?, ?)`,
+		rating.IdRating, rating.IdClient, rating.IdRestaurantWorker, rating.IdReservation, rating.IdOrder,
+		rating.RatingValue, rating.Comment, time.Now())
+	if err != nil {
+		err = fmt.Errorf("error inserting rating: %v", err)
+		return err
+	}
+
+	if rating.WorkerRating, err = recomputeWorkerRating(tx, rating.IdRestaurantWorker); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// Helper function to refresh the aggregate rating of a worker from their ratings
+func recomputeWorkerRating(db queryExecer, idRestaurantWorker string) (float64, error) {
+	_, err := db.Exec(`
+		UPDATE restaurantWorkers
+		SET rating = IFNULL((
+			SELECT AVG(rating) FROM rating
+			WHERE idRestaurantWorker = ? AND ratingType = 'worker' AND moderationStatus = 'visible'
+		), 0)
+		WHERE idRestaurantWorker = ?`, idRestaurantWorker, idRestaurantWorker)
+	if err != nil {
+		return 0, fmt.Errorf("error updating worker rating: %v", err)
+	}
+
+	var average float64
+	err = db.QueryRow(`SELECT IFNULL(rating, 0) FROM restaurantWorkers WHERE idRestaurantWorker = ?`, idRestaurantWorker).Scan(&average)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching worker rating: %v", err)
+	}
+	return average, nil
+}
+
+// ReplyToReview sets the public answer of a restaurant to one of its reviews, an empty reply removes it
+func (s *store) ReplyToReview(idRestaurant, idRating, reply string) error {
+	var reviewRestaurant sql.NullString
+	var ratingType string
+	err := s.db.QueryRow(`SELECT idRestaurant, ratingType FROM rating WHERE idRating = ?`, idRating).Scan(&reviewRestaurant, &ratingType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("review with ID %s not found", idRating)
+		}
+		return fmt.Errorf("error fetching review: %v", err)
+	}
+	if ratingType != "restaurant" || reviewRestaurant.String != idRestaurant {
+		return fmt.Errorf("review %s does not belong to restaurant %s", idRating, idRestaurant)
+	}
+
+	query := `UPDATE rating SET reply = ?, repliedAt = ? WHERE idRating = ?`
+	if reply == "" {
+		_, err = s.db.Exec(query, nil, nil, idRating)
+	} else {
+		_, err = s.db.Exec(query, reply, time.Now(), idRating)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving review reply: %v", err)
+	}
+	return nil
+}
+
+// FlagReview lets a client report an abusive review to the moderators, once per review
+func (s *store) FlagReview(idFlag, idRating string, flag types.ReviewFlag) error {
+	var author, status string
+	err := s.db.QueryRow(`SELECT idClient, moderationStatus FROM rating WHERE idRating = ?`, idRating).Scan(&author, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("review with ID %s not found", idRating)
+		}
+		return fmt.Errorf("error fetching review: %v", err)
+	}
+	if author == flag.IdClient {
+		return fmt.Errorf("clients cannot flag their own review")
+	}
+	if status == "hidden" {
+		return fmt.Errorf("review %s is already hidden", idRating)
+	}
+	if flag.IdPhoto != nil {
+		var photoStatus string
+		err = s.db.QueryRow(`SELECT status FROM reviewPhoto WHERE idPhoto = ? AND idRating = ?`, *flag.IdPhoto, idRating).Scan(&photoStatus)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("photo with ID %s not found on review %s", *flag.IdPhoto, idRating)
+			}
+			return fmt.Errorf("error fetching review photo: %v", err)
+		}
+		if photoStatus != "visible" {
+			return fmt.Errorf("photo %s was already removed", *flag.IdPhoto)
+		}
+	}
+
+	var flagged int
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM ratingFlag WHERE idRating = ? AND idClient = ? AND idPhoto <=> ?`,
+		idRating, flag.IdClient, flag.IdPhoto).Scan(&flagged)
+	if err != nil {
+		return fmt.Errorf("error checking existing flags: %v", err)
+	}
+	if flagged > 0 {
+		return fmt.Errorf("client %s already flagged this review", flag.IdClient)
+	}
+
+	_, err = s.db.Exec(`INSERT INTO ratingFlag (idFlag, idRating, idClient, idPhoto, reason, createdAt) VALUES (?, ?, ?, ?, ?, ?)`,
+		idFlag, idRating, flag.IdClient, flag.IdPhoto, flag.Reason, time.Now())
+	if err != nil {
+		return fmt.Errorf("error flagging review: %v", err)
+	}
+	return nil
+}
+
+// GetModerationQueue lists the reviews awaiting a moderator: "flagged" ones with open flags, most flagged
+// first, or the "hidden" ones so they can be restored
+func (s *store) GetModerationQueue(status string) ([]types.ModeratedReview, error) {
+	query := `
+		SELECT r.idRating, r.ratingType,
+			COALESCE(r.idRestaurant, r.idActivity, r.idRestaurantWorker, ''),
+			COALESCE(rest.name, act.nameActivity, CONCAT(rw.firstName, ' ', rw.lastName), ''),
+			r.idClient, p.firstName, p.lastName, r.rating, IFNULL(r.comment, ''), r.reply, r.createdAt,
+			r.moderationStatus, r.moderationNote, r.moderatedAt,
+			(SELECT COUNT(*) FROM ratingFlag rf WHERE rf.idRating = r.idRating AND rf.idPhoto IS NULL AND rf.resolvedAt IS NULL) AS openFlags,
+			(SELECT IFNULL(GROUP_CONCAT(NULLIF(rf.reason, '') ORDER BY rf.createdAt SEPARATOR '\n'), '')
+				FROM ratingFlag rf WHERE rf.idRating = r.idRating AND rf.resolvedAt IS NULL),
+			(SELECT COUNT(*) FROM ratingFlag rf WHERE rf.idRating = r.idRating AND rf.resolvedAt IS NULL) AS allFlags
+		FROM rating r
+		JOIN client c ON c.idClient = r.idClient
+		JOIN profile p ON p.idProfile = c.idProfile
+		LEFT JOIN restaurant rest ON r.ratingType = 'restaurant' AND rest.idRestaurant = r.idRestaurant
+		LEFT JOIN activity act ON r.ratingType = 'activity' AND act.idActivity = r.idActivity
+		LEFT JOIN restaurantWorkers rw ON r.ratingType = 'worker' AND rw.idRestaurantWorker = r.idRestaurantWorker
+	`
+	if status == "hidden" {
+		query += `
+		WHERE r.moderationStatus = 'hidden'
+		ORDER BY r.moderatedAt DESC`
+	} else {
+		query += `
+		WHERE r.moderationStatus = 'visible'
+		AND EXISTS (SELECT 1 FROM ratingFlag rf WHERE rf.idRating = r.idRating AND rf.resolvedAt IS NULL)
+		ORDER BY allFlags DESC, r.createdAt`
+	}
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching moderation queue: %v", err)
+	}
+	defer rows.Close()
+
+	reviews := []types.ModeratedReview{}
+	var ids []string
+	for rows.Next() {
+		var review types.ModeratedReview
+		var reasons string
+		var allFlags int
+		err := rows.Scan(
+			&review.IdRating,
+			&review.RatingType,
+			&review.IdTarget,
+			&review.TargetName,
+			&review.IdClient,
+			&review.FirstName,
+			&review.LastName,
+			&review.RatingValue,
+			&review.Comment,
+			&review.Reply,
+			&review.CreatedAt,
+			&review.ModerationStatus,
+			&review.ModerationNote,
+			&review.ModeratedAt,
+			&review.OpenFlags,
+			&reasons,
+			&allFlags,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning moderated review: %v", err)
+		}
+		review.FlagReasons = []string{}
+		if reasons != "" {
+			review.FlagReasons = strings.Split(reasons, "\n")
+		}
+		reviews = append(reviews, review)
+		ids = append(ids, review.IdRating)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	photos, err := loadReviewPhotos(s.db, ids, true)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reviews {
+		reviews[i].Photos = photos[reviews[i].IdRating]
+	}
+	return reviews, nil
+}
+
+// ModerateReview hides or restores a review and closes the flags raised against it. Worker ratings are
+// recomputed since hidden reviews no longer count.
+func (s *store) ModerateReview(idRating, status, note string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var ratingType string
+	var idRestaurantWorker sql.NullString
+	err = tx.QueryRow(`SELECT ratingType, idRestaurantWorker FROM rating WHERE idRating = ? FOR UPDATE`, idRating).Scan(&ratingType, &idRestaurantWorker)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("review with ID %s not found", idRating)
+			return err
+		}
+		err = fmt.Errorf("error fetching review: %v", err)
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE rating SET moderationStatus = ?, moderationNote = ?, moderatedAt = ? WHERE idRating = ?`, status, note, now, idRating)
+	if err != nil {
+		err = fmt.Errorf("error moderating review: %v", err)
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE ratingFlag SET resolvedAt = ? WHERE idRating = ? AND resolvedAt IS NULL`, now, idRating); err != nil {
+		err = fmt.Errorf("error resolving review flags: %v", err)
+		return err
+	}
+	if ratingType == "worker" && idRestaurantWorker.Valid {
+		if _, err = recomputeWorkerRating(tx, idRestaurantWorker.String); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// Clients can attach this many photos to one review
+const maxReviewPhotos = 4
+
+// Helper function to check that a client may attach photos to a review, returns how many more it can take
+func reviewPhotoSlots(db queryExecer, idRating, idClient string) (int, error) {
+	var author, ratingType, status string
+	err := db.QueryRow(`SELECT idClient, ratingType, moderationStatus FROM rating WHERE idRating = ?`, idRating).
+		Scan(&author, &ratingType, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("review with ID %s not found", idRating)
+		}
+		return 0, fmt.Errorf("error fetching review: %v", err)
+	}
+	if author != idClient {
+		return 0, fmt.Errorf("review %s does not belong to client %s", idRating, idClient)
+	}
+	if ratingType == "worker" {
+		return 0, fmt.Errorf("cannot attach photos to a worker review")
+	}
+	if status != "visible" {
+		return 0, fmt.Errorf("cannot attach photos to a hidden review")
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM reviewPhoto WHERE idRating = ? AND status = 'visible'`, idRating).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting review photos: %v", err)
+	}
+	return maxReviewPhotos - count, nil
+}
+
+func (s *store) GetReviewPhotoSlots(idRating, idClient string) (int, error) {
+	return reviewPhotoSlots(s.db, idRating, idClient)
+}
+
+// AddReviewPhotos records photos already uploaded to the photo store
+func (s *store) AddReviewPhotos(idRating, idClient string, photos []types.ReviewPhoto) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the review so that two uploads cannot both take the last slot
+	if _, err = tx.Exec(`SELECT idRating FROM rating WHERE idRating = ? FOR UPDATE`, idRating); err != nil {
+		return fmt.Errorf("error locking review: %v", err)
+	}
+	slots, err := reviewPhotoSlots(tx, idRating, idClient)
+	if err != nil {
+		return err
+	}
+	if len(photos) > slots {
+		err = fmt.Errorf("cannot attach more than %d photos to a review", maxReviewPhotos)
+		return err
+	}
+
+	var next int
+	err = tx.QueryRow(`SELECT IFNULL(MAX(sortIndex) + 1, 0) FROM reviewPhoto WHERE idRating = ?`, idRating).Scan(&next)
+	if err != nil {
+		return fmt.Errorf("error fetching photo order: %v", err)
+	}
+	now := time.Now()
+	for i, photo := range photos {
+		_, err = tx.Exec(`INSERT INTO reviewPhoto (idPhoto, idRating, url, thumbnailUrl, publicId, sortIndex, createdAt)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			photo.IdPhoto, idRating, photo.URL, photo.ThumbnailURL, photo.PublicID, next+i, now)
+		if err != nil {
+			return fmt.Errorf("error inserting review photo: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// RemoveReviewPhoto takes a photo off a review, on behalf of its author or of a moderator when idClient is empty.
+// It returns the public ID of the file so that the caller can delete it from the photo store.
+func (s *store) RemoveReviewPhoto(idRating, idPhoto, idClient string) (string, error) {
+	var author, publicID, status string
+	err := s.db.QueryRow(`
+		SELECT r.idClient, p.publicId, p.status
+		FROM reviewPhoto p
+		JOIN rating r ON r.idRating = p.idRating
+		WHERE p.idPhoto = ? AND p.idRating = ?`, idPhoto, idRating).Scan(&author, &publicID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("photo with ID %s not found on review %s", idPhoto, idRating)
+		}
+		return "", fmt.Errorf("error fetching review photo: %v", err)
+	}
+	if idClient != "" && author != idClient {
+		return "", fmt.Errorf("review %s does not belong to client %s", idRating, idClient)
+	}
+	if status == "removed" {
+		return "", fmt.Errorf("photo %s was already removed", idPhoto)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	if _, err = tx.Exec(`UPDATE reviewPhoto SET status = 'removed', removedAt = ? WHERE idPhoto = ?`, now, idPhoto); err != nil {
+		return "", fmt.Errorf("error removing review photo: %v", err)
+	}
+	if _, err = tx.Exec(`UPDATE ratingFlag SET resolvedAt = ? WHERE idPhoto = ? AND resolvedAt IS NULL`, now, idPhoto); err != nil {
+		return "", fmt.Errorf("error resolving photo flags: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+	return publicID, nil
+}
+
+// Helper function to load the visible photos of several reviews, keyed by review. withFlags also counts
+// the open flags of each photo for the moderation queue.
+func loadReviewPhotos(db queryRunner, idRatings []string, withFlags bool) (map[string][]types.ReviewPhoto, error) {
+	photos := make(map[string][]types.ReviewPhoto)
+	if len(idRatings) == 0 {
+		return photos, nil
+	}
+
+	flags := "0"
+	if withFlags {
+		flags = "(SELECT COUNT(*) FROM ratingFlag rf WHERE rf.idPhoto = p.idPhoto AND rf.resolvedAt IS NULL)"
+	}
+	query := `SELECT p.idPhoto, p.idRating, p.url, p.thumbnailUrl, p.publicId, p.sortIndex, p.createdAt, ` + flags + `
+		FROM reviewPhoto p
+		WHERE p.status = 'visible' AND p.idRating IN (?` + strings.Repeat(",?", len(idRatings)-1) + `)
+		ORDER BY p.idRating, p.sortIndex`
+	rows, err := db.Query(query, convertToInterfaceSlice(idRatings)...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving review photos: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var photo types.ReviewPhoto
+		err := rows.Scan(&photo.IdPhoto, &photo.IdRating, &photo.URL, &photo.ThumbnailURL, &photo.PublicID,
+			&photo.SortIndex, &photo.CreatedAt, &photo.OpenFlags)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning review photo: %v", err)
+		}
+		photos[photo.IdRating] = append(photos[photo.IdRating], photo)
+	}
+	return photos, rows.Err()
+}
+
+// Helper function to fill the photos of public reviews
+func attachReviewPhotos(db queryRunner, reviews []*types.Rating) error {
+	ids := make([]string, 0, len(reviews))
+	for _, review := range reviews {
+		ids = append(ids, review.IdRating)
+	}
+	photos, err := loadReviewPhotos(db, ids, false)
+	if err != nil {
+		return err
+	}
+	for _, review := range reviews {
+		review.Photos = photos[review.IdRating]
+		if review.Photos == nil {
+			review.Photos = []types.ReviewPhoto{}
+		}
+	}
+	return nil
+}
+
+// Helper function to get the image of an entity, failing when it does not exist. table and idColumn are never
+// user input.
+func currentImage(db queryExecer, label, table, idColumn, id string) (string, error) {
+	var image sql.NullString
+	err := db.QueryRow(`SELECT image FROM `+table+` WHERE `+idColumn+` = ?`, id).Scan(&image)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%s with ID %s not found", label, id)
+		}
+		return "", fmt.Errorf("error fetching %s image: %v", label, err)
+	}
+	return image.String, nil
+}
+
+// Helper function to point an entity to a new image, returns the image it had. table and idColumn are never user input.
+func swapImage(db queryExecer, label, table, idColumn, id, image string) (string, error) {
+	previous, err := currentImage(db, label, table, idColumn, id)
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.Exec(`UPDATE `+table+` SET image = ? WHERE `+idColumn+` = ?`, image, id); err != nil {
+		return "", fmt.Errorf("error updating %s image: %v", label, err)
+	}
+	return previous, nil
+}
+
+func (s *store) GetRestaurantImage(idRestaurant string) (string, error) {
+	return currentImage(s.db, "restaurant", "restaurant", "idRestaurant", idRestaurant)
+}
+
+func (s *store) SetRestaurantImage(idRestaurant, image string) (string, error) {
+	return swapImage(s.db, "restaurant", "restaurant", "idRestaurant", idRestaurant, image)
+}
+
+func (s *store) GetFoodImage(idFood string) (string, error) {
+	return currentImage(s.db, "food", "food", "idFood", idFood)
+}
+
+func (s *store) SetFoodImage(idFood, image string) (string, error) {
+	return swapImage(s.db, "food", "food", "idFood", idFood, image)
+}
+
+func (s *store) GetRestaurantWorkerImage(idRestaurantWorker string) (string, error) {
+	return currentImage(s.db, "worker", "restaurantWorkers", "idRestaurantWorker", idRestaurantWorker)
+}
+
+func (s *store) SetRestaurantWorkerImage(idRestaurantWorker, image string) (string, error) {
+	return swapImage(s.db, "worker", "restaurantWorkers", "idRestaurantWorker", idRestaurantWorker, image)
+}
+
+// Orders of a restaurant placed in [from, to), whatever they came from, cancelled ones left out
+const revenueOrdersFrom = `
+	FROM orderList ol
+	LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+	LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+	LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+	WHERE COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant) = ?
+	AND ol.status <> 'cancelled' AND ol.createdAt >= ? AND ol.createdAt < ?
+`
+
+// GetRevenueReport builds the revenue report of a restaurant over [from, to), to being the day after the last one
+func (s *store) GetRevenueReport(idRestaurant string, from, to time.Time, groupBy string) (*types.RevenueReport, error) {
+	report := types.RevenueReport{
+		IdRestaurant: idRestaurant,
+		From:         from.Format("2006-01-02"),
+		To:           to.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy:      groupBy,
+	}
+
+	var err error
+	report.Summary, err = s.revenueSummary(idRestaurant, from, to)
+	if err != nil {
+		return nil, err
+	}
+	days := int(to.Sub(from).Hours()/24 + 0.5)
+	report.Previous, err = s.revenueSummary(idRestaurant, from.AddDate(0, 0, -days), from)
+	if err != nil {
+		return nil, err
+	}
+	report.Change = types.RevenueChange{
+		Revenue:       percentChange(report.Previous.Revenue, report.Summary.Revenue),
+		Orders:        percentChange(float64(report.Previous.Orders), float64(report.Summary.Orders)),
+		AverageTicket: percentChange(report.Previous.AverageTicket, report.Summary.AverageTicket),
+	}
+
+	report.Periods, report.ByHour, err = s.revenueBreakdown(idRestaurant, from, to, groupBy)
+	if err != nil {
+		return nil, err
+	}
+	report.ByFood, report.ByCategory, err = s.revenueByFood(idRestaurant, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (s *store) revenueSummary(idRestaurant string, from, to time.Time) (types.RevenueSummary, error) {
+	summary := types.RevenueSummary{
+		From: from.Format("2006-01-02"),
+		To:   to.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), IFNULL(SUM(ol.totalPrice), 0),
+			IFNULL(SUM(IF(tk.idOrder IS NULL, ol.totalPrice, 0)), 0),
+			IFNULL(SUM(IF(tk.idOrder IS NULL, 0, ol.totalPrice)), 0)
+	`+revenueOrdersFrom, idRestaurant, from, to).Scan(
+		&summary.Orders,
+		&summary.Revenue,
+		&summary.DineInRevenue,
+		&summary.TakeawayRevenue,
+	)
+	if err != nil {
+		return summary, fmt.Errorf("error computing revenue: %v", err)
+	}
+
+	err = s.db.QueryRow(`
+		SELECT IFNULL(SUM(ofd.quantity), 0) FROM orderFood ofd
+		WHERE ofd.idOrder IN (SELECT ol.idOrder `+revenueOrdersFrom+`)`, idRestaurant, from, to).Scan(&summary.ItemsSold)
+	if err != nil {
+		return summary, fmt.Errorf("error counting items sold: %v", err)
+	}
+
+	summary.Revenue = roundMoney(summary.Revenue)
+	summary.DineInRevenue = roundMoney(summary.DineInRevenue)
+	summary.TakeawayRevenue = roundMoney(summary.TakeawayRevenue)
+	summary.AverageTicket = averageTicket(summary.Revenue, summary.Orders)
+	return summary, nil
+}
+
+// Helper function to build the revenue per period and per hour of the day from the totals of each day and hour.
+// Every period of the range is listed, empty ones included.
+func (s *store) revenueBreakdown(idRestaurant string, from, to time.Time, groupBy string) ([]types.RevenuePeriod, []types.RevenueHour, error) {
+	periods := []types.RevenuePeriod{}
+	index := make(map[string]int)
+	for start := from; start.Before(to); {
+		end := revenuePeriodEnd(start, groupBy)
+		if end.After(to) {
+			end = to
+		}
+		index[start.Format("2006-01-02")] = len(periods)
+		periods = append(periods, types.RevenuePeriod{
+			Start: start.Format("2006-01-02"),
+			End:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		})
+		start = end
+	}
+	hours := make([]types.RevenueHour, 24)
+	for hour := range hours {
+		hours[hour].Hour = hour
+	}
+
+	rows, err := s.db.Query(`
+		SELECT DATE_FORMAT(ol.createdAt, '%Y-%m-%d'), HOUR(ol.createdAt), COUNT(*), IFNULL(SUM(ol.totalPrice), 0)
+	`+revenueOrdersFrom+`
+		GROUP BY DATE_FORMAT(ol.createdAt, '%Y-%m-%d'), HOUR(ol.createdAt)`, idRestaurant, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving revenue by period: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var hour, orders int
+		var revenue float64
+		if err := rows.Scan(&day, &hour, &orders, &revenue); err != nil {
+			return nil, nil, fmt.Errorf("error scanning revenue by period: %v", err)
+		}
+		date, err := time.ParseInLocation("2006-01-02", day, from.Location())
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing revenue day: %v", err)
+		}
+		start := revenuePeriodStart(date, groupBy)
+		if start.Before(from) {
+			start = from
+		}
+		if i, ok := index[start.Format("2006-01-02")]; ok {
+			periods[i].Orders += orders
+			periods[i].Revenue += revenue
+		}
+		hours[hour].Orders += orders
+		hours[hour].Revenue += revenue
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for i := range periods {
+		periods[i].Revenue = roundMoney(periods[i].Revenue)
+		periods[i].AverageTicket = averageTicket(periods[i].Revenue, periods[i].Orders)
+	}
+	for i := range hours {
+		hours[i].Revenue = roundMoney(hours[i].Revenue)
+		hours[i].AverageTicket = averageTicket(hours[i].Revenue, hours[i].Orders)
+	}
+	return periods, hours, nil
+}
+
+// Helper function to get the revenue per food, at the price paid for each line, and sum it per category
+func (s *store) revenueByFood(idRestaurant string, from, to time.Time) ([]types.FoodRevenue, []types.CategoryRevenue, error) {
+	rows, err := s.db.Query(`
+		SELECT f.idFood, f.name, IFNULL(fc.idCategory, ''), IFNULL(fc.nameCategorie, ''),
+			SUM(ofd.quantity), SUM(IFNULL(ofd.unitPrice, f.price) * ofd.quantity) AS revenue
+		FROM orderFood ofd
+		JOIN food f ON f.idFood = ofd.idFood
+		LEFT JOIN foodCategory fc ON fc.idCategory = f.idCategory
+		WHERE ofd.idOrder IN (SELECT ol.idOrder `+revenueOrdersFrom+`)
+		GROUP BY f.idFood, f.name, fc.idCategory, fc.nameCategorie
+		ORDER BY revenue DESC`, idRestaurant, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving revenue by food: %v", err)
+	}
+	defer rows.Close()
+
+	foods := []types.FoodRevenue{}
+	categories := []types.CategoryRevenue{}
+	categoryIndex := make(map[string]int)
+	total := 0.0
+	for rows.Next() {
+		var food types.FoodRevenue
+		if err := rows.Scan(&food.IdFood, &food.Name, &food.IdCategory, &food.NameCategorie, &food.Quantity, &food.Revenue); err != nil {
+			return nil, nil, fmt.Errorf("error scanning revenue by food: %v", err)
+		}
+		foods = append(foods, food)
+		total += food.Revenue
+
+		i, ok := categoryIndex[food.IdCategory]
+		if !ok {
+			i = len(categories)
+			categoryIndex[food.IdCategory] = i
+			categories = append(categories, types.CategoryRevenue{IdCategory: food.IdCategory, NameCategorie: food.NameCategorie})
+		}
+		categories[i].Quantity += food.Quantity
+		categories[i].Revenue += food.Revenue
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for i := range foods {
+		foods[i].Share = revenueShare(foods[i].Revenue, total)
+		foods[i].Revenue = roundMoney(foods[i].Revenue)
+	}
+	for i := range categories {
+		categories[i].Share = revenueShare(categories[i].Revenue, total)
+		categories[i].Revenue = roundMoney(categories[i].Revenue)
+	}
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Revenue > categories[j].Revenue })
+	return foods, categories, nil
+}
+
+// Helper function to get the first day of the period a day falls in
+func revenuePeriodStart(day time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	default:
+		return day
+	}
+}
+
+// Helper function to get the day after the period starting on a day, or after its part when the day is not its first
+func revenuePeriodEnd(start time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case "week":
+		return revenuePeriodStart(start, "week").AddDate(0, 0, 7)
+	case "month":
+		return revenuePeriodStart(start, "month").AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func averageTicket(revenue float64, orders int) float64 {
+	if orders == 0 {
+		return 0
+	}
+	return roundMoney(revenue / float64(orders))
+}
+
+func revenueShare(revenue, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(revenue/total*1000) / 10
+}
+
+// Helper function to get the change between two values in percent, nil when there is nothing to compare to
+func percentChange(previous, current float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round((current-previous)/previous*1000) / 10
+	return &change
+}
+
+// ExportRestaurantReservations hands the reservations of a restaurant kept by the filter to fn one at a time, in the
+// order of the reservations list, without loading them all
+func (s *store) ExportRestaurantReservations(idRestaurant string, filter types.ListFilter, fn func(types.RestaurantReservationDetail) error) error {
+	where, args := utils.ListFilterClause(filter, "r.timeFrom", "r.status")
+	rows, err := s.db.Query(restaurantReservationsQuery+where+` ORDER BY r.timeFrom DESC`, append([]interface{}{idRestaurant}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error retrieving reservations: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		reservation, err := scanRestaurantReservation(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*reservation); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportRestaurantOrders hands the orders of a restaurant kept by the filter to fn one at a time, newest first. The
+// filter is on the time the order was placed.
+func (s *store) ExportRestaurantOrders(idRestaurant string, filter types.ListFilter, fn func(types.RestaurantOrderRow) error) error {
+	where, args := utils.ListFilterClause(filter, "ol.createdAt", "ol.status")
+	rows, err := s.db.Query(`
+		SELECT ol.idOrder, ol.createdAt,
+			CASE WHEN tk.idOrder IS NOT NULL THEN 'takeaway' WHEN ts.idTableSession IS NOT NULL THEN 'table' ELSE 'reservation' END,
+			IFNULL(CONCAT(p.firstName, ' ', p.lastName), ''),
+			IFNULL(COALESCE(r.idTable, ts.idTable), ''),
+			(SELECT IFNULL(SUM(ofd.quantity), 0) FROM orderFood ofd WHERE ofd.idOrder = ol.idOrder),
+			IFNULL(ol.totalPrice, 0), ol.status
+		FROM orderList ol
+		LEFT JOIN reservation r ON r.idReservation = ol.idReservation
+		LEFT JOIN tableSession ts ON ts.idTableSession = ol.idTableSession
+		LEFT JOIN takeawayOrder tk ON tk.idOrder = ol.idOrder
+		LEFT JOIN client c ON c.idClient = COALESCE(r.idClient, tk.idClient)
+		LEFT JOIN profile p ON p.idProfile = c.idProfile
+		WHERE COALESCE(r.idRestaurant, ts.idRestaurant, tk.idRestaurant) = ?`+where+`
+		ORDER BY ol.createdAt DESC`, append([]interface{}{idRestaurant}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error retrieving orders: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order types.RestaurantOrderRow
+		err := rows.Scan(
+			&order.IdOrder,
+			&order.CreatedAt,
+			&order.Source,
+			&order.ClientName,
+			&order.IdTable,
+			&order.Items,
+			&order.TotalPrice,
+			&order.Status,
+		)
+		if err != nil {
+			return fmt.Errorf("error scanning order: %v", err)
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetRestaurantDemand returns the covers of the reservations and the orders of a restaurant in [from, to), hour by
+// hour. Cancelled reservations and no-shows bring no covers, cancelled orders are left out.
+func (s *store) GetRestaurantDemand(idRestaurant string, from, to time.Time) ([]types.DemandPoint, []types.DemandPoint, error) {
+	covers, err := queryDemand(s.db, `
+		SELECT DATE_FORMAT(r.timeFrom, '%Y-%m-%d'), HOUR(r.timeFrom), SUM(r.numberOfPeople)
+		FROM reservation r
+		WHERE r.idRestaurant = ? AND r.status NOT IN ('cancelled', 'no_show', 'expired')
+		AND r.timeFrom >= ? AND r.timeFrom < ?
+		GROUP BY 1, 2`, idRestaurant, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orders, err := queryDemand(s.db, `
+		SELECT DATE_FORMAT(ol.createdAt, '%Y-%m-%d'), HOUR(ol.createdAt), COUNT(*)
+	`+revenueOrdersFrom+`
+		GROUP BY 1, 2`, idRestaurant, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	return covers, orders, nil
+}
+
+// Helper function to read day, hour and value rows
+func queryDemand(db queryRunner, query string, args ...interface{}) ([]types.DemandPoint, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving demand: %v", err)
+	}
+	defer rows.Close()
+
+	points := []types.DemandPoint{}
+	for rows.Next() {
+		var point types.DemandPoint
+		if err := rows.Scan(&point.Day, &point.Hour, &point.Value); err != nil {
+			return nil, fmt.Errorf("error scanning demand: %v", err)
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// Weights of the search ranking, shared out again over the parts a search has: relevance needs a query and
+// distance a point
+const (
+	searchRelevanceWeight = 0.5
+	searchRatingWeight    = 0.3
+	searchDistanceWeight  = 0.2
+	// Ratings are pulled towards searchPriorRating as if they had this many more reviews, so a single 5 does
+	// not outrank hundreds of 4.8
+	searchPriorReviews = 5
+	searchPriorRating  = 3.0
+	// The distance part of the score halves at this distance
+	searchHalfDistanceKm = 2.0
+	searchMatchedFoods   = 5
+	earthRadiusKm        = 6371.0
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchRestaurants finds the restaurants matching a search, ranked by relevance, rating and distance. The text,
+// rating, dietary and price filters run in the database, distance, opening hours and availability are then
+// checked on the restaurants left, with the hours and bookings of all of them loaded in a few queries.
+func (s *store) SearchRestaurants(search types.RestaurantSearch) (*types.RestaurantSearchResponse, error) {
+	terms := strings.Fields(search.Query)
+
+	// Every word has to be found somewhere, a match in the name counts most
+	relevance := "0"
+	var relevanceParts []string
+	var relevanceArgs, whereArgs []interface{}
+	where := []string{"1 = 1"}
+	foodMatch := `EXISTS (SELECT 1 FROM food f WHERE f.idRestaurant = r.idRestaurant AND f.name LIKE ?)`
+	for _, term := range terms {
+		like := "%" + likeEscaper.Replace(term) + "%"
+		relevanceParts = append(relevanceParts, `IF(r.name LIKE ?, 3, 0) + IF(`+foodMatch+`, 2, 0) + IF(r.location LIKE ?, 1, 0) + IF(r.description LIKE ?, 1, 0)`)
+		relevanceArgs = append(relevanceArgs, like, like, like, like)
+		where = append(where, `(r.name LIKE ? OR `+foodMatch+` OR r.location LIKE ? OR r.description LIKE ?)`)
+		whereArgs = append(whereArgs, like, like, like, like)
+	}
+	if len(relevanceParts) > 0 {
+		relevance = strings.Join(relevanceParts, " + ")
+	}
+
+	if err := s.checkDietaryTags(search.DietaryTags); err != nil {
+		return nil, err
+	}
+	for _, tag := range search.DietaryTags {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM food f
+			JOIN foodTagLink ftl ON ftl.idFood = f.idFood
+			WHERE f.idRestaurant = r.idRestaurant AND ftl.idTag = ?)`)
+		whereArgs = append(whereArgs, tag)
+	}
+	if search.MinRating != nil {
+		where = append(where, "IFNULL(rt.average, 0) >= ?")
+		whereArgs = append(whereArgs, *search.MinRating)
+	}
+	if search.MinPrice != nil {
+		where = append(where, "fp.averagePrice >= ?")
+		whereArgs = append(whereArgs, *search.MinPrice)
+	}
+	if search.MaxPrice != nil {
+		where = append(where, "fp.averagePrice <= ?")
+		whereArgs = append(whereArgs, *search.MaxPrice)
+	}
+
+	query := `
+		SELECT r.idRestaurant, r.idAdminRestaurant, r.name, r.image, r.longitude, r.latitude, r.description,
+			r.capacity, r.location, IFNULL(rt.average, 0), IFNULL(rt.total, 0), fp.averagePrice, ` + relevance + `
+		FROM restaurant r
+		LEFT JOIN (
+			SELECT idRestaurant, AVG(rating) AS average, COUNT(*) AS total
+			FROM rating
+			WHERE moderationStatus = 'visible'
+			GROUP BY idRestaurant
+		) rt ON rt.idRestaurant = r.idRestaurant
+		LEFT JOIN (
+			SELECT idRestaurant, AVG(price) AS averagePrice
+			FROM food
+			GROUP BY idRestaurant
+		) fp ON fp.idRestaurant = r.idRestaurant
+		WHERE ` + strings.Join(where, " AND ")
+	rows, err := s.db.Query(query, append(relevanceArgs, whereArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error searching restaurants: %v", err)
+	}
+	defer rows.Close()
+
+	var candidates []types.RestaurantSearchResult
+	for rows.Next() {
+		var result types.RestaurantSearchResult
+		var averageRating float64
+		var averagePrice sql.NullFloat64
+		err := rows.Scan(
+			&result.IdRestaurant,
+			&result.IdAdminRestaurant,
+			&result.NameRestaurant,
+			&result.Image,
+			&result.Langitude,
+			&result.Latitude,
+			&result.Description,
+			&result.Capacity,
+			&result.Location,
+			&averageRating,
+			&result.TotalRatings,
+			&averagePrice,
+			&result.Relevance,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning restaurant: %v", err)
+		}
+		result.AverageRating = &averageRating
+		isActive := result.IdAdminRestaurant != nil
+		result.IsActive = &isActive
+		if averagePrice.Valid {
+			price := math.Round(averagePrice.Float64*100) / 100
+			result.AveragePrice = &price
+		}
+		candidates = append(candidates, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error searching restaurants: %v", err)
+	}
+
+	// Opening hours and room are loaded for every candidate at once rather than restaurant by restaurant
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = *candidate.IdRestaurant
+	}
+	hours, err := s.loadOpenHours(ids)
+	if err != nil {
+		return nil, err
+	}
+	var available map[string]bool
+	if search.PartySize > 0 && search.At != nil {
+		if available, err = s.restaurantsAvailable(ids, hours, search.PartySize, *search.At); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	hasPoint := search.Latitude != nil && search.Longitude != nil
+	results := []types.RestaurantSearchResult{}
+	var maxRelevance float64
+	for _, result := range candidates {
+		if hasPoint {
+			if result.Latitude != nil && result.Langitude != nil {
+				distance := math.Round(haversineKm(*search.Latitude, *search.Longitude, *result.Latitude, *result.Langitude)*100) / 100
+				result.DistanceKm = &distance
+			}
+			// A restaurant without a position cannot be said to be in range
+			if search.RadiusKm != nil && (result.DistanceKm == nil || *result.DistanceKm > *search.RadiusKm) {
+				continue
+			}
+		}
+
+		result.OpenNow = hours.openAt(*result.IdRestaurant, now)
+		if search.OpenNow && !result.OpenNow {
+			continue
+		}
+		if available != nil && !available[*result.IdRestaurant] {
+			continue
+		}
+
+		maxRelevance = math.Max(maxRelevance, result.Relevance)
+		results = append(results, result)
+	}
+
+	for i := range results {
+		results[i].Score = searchScore(results[i], maxRelevance, hasPoint)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch search.Sort {
+		case "rating":
+			if *a.AverageRating != *b.AverageRating {
+				return *a.AverageRating > *b.AverageRating
+			}
+			if a.TotalRatings != b.TotalRatings {
+				return a.TotalRatings > b.TotalRatings
+			}
+		case "distance":
+			if (a.DistanceKm == nil) != (b.DistanceKm == nil) {
+				return a.DistanceKm != nil
+			}
+			if a.DistanceKm != nil && *a.DistanceKm != *b.DistanceKm {
+				return *a.DistanceKm < *b.DistanceKm
+			}
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return *a.IdRestaurant < *b.IdRestaurant
+	})
+
+	totalCount := len(results)
+	offset := min((search.Page-1)*search.Limit, totalCount)
+	results = results[offset:min(offset+search.Limit, totalCount)]
+	if len(terms) > 0 {
+		for i := range results {
+			if results[i].MatchedFoods, err = s.searchMatchedFoods(*results[i].IdRestaurant, terms); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &types.RestaurantSearchResponse{
+		Results:     results,
+		CurrentPage: search.Page,
+		TotalPages:  (totalCount + search.Limit - 1) / search.Limit,
+		TotalCount:  totalCount,
+	}, nil
+}
+
+// Helper function to score a search result between 0 and 1. The relevance is relative to the best match, the
+// rating is pulled towards the prior and the distance part halves every searchHalfDistanceKm.
+func searchScore(result types.RestaurantSearchResult, maxRelevance float64, hasPoint bool) float64 {
+	var score, weights float64
+	if maxRelevance > 0 {
+		score += searchRelevanceWeight * result.Relevance / maxRelevance
+		weights += searchRelevanceWeight
+	}
+
+	reviews := float64(result.TotalRatings)
+	rating := (*result.AverageRating*reviews + searchPriorRating*searchPriorReviews) / (reviews + searchPriorReviews)
+	score += searchRatingWeight * rating / 5
+	weights += searchRatingWeight
+
+	if hasPoint {
+		if result.DistanceKm != nil {
+			score += searchDistanceWeight * searchHalfDistanceKm / (searchHalfDistanceKm + *result.DistanceKm)
+		}
+		weights += searchDistanceWeight
+	}
+	return math.Round(score/weights*1000) / 1000
+}
+
+// Helper function to get the great-circle distance between two points in kilometres
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Helper function to check that the tags a search filters on exist and are dietary labels. Allergens cannot be
+// asked for, a restaurant having a food with peanuts is no reason to list it.
+func (s *store) checkDietaryTags(idTags []string) error {
+	if len(idTags) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(idTags)), ", ")
+	args := make([]interface{}, len(idTags))
+	for i, idTag := range idTags {
+		args[i] = idTag
+	}
+	rows, err := s.db.Query(`SELECT idTag FROM foodTag WHERE kind = 'dietary' AND idTag IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("error fetching dietary tags: %v", err)
+	}
+	defer rows.Close()
+
+	dietary := make(map[string]bool)
+	for rows.Next() {
+		var idTag string
+		if err := rows.Scan(&idTag); err != nil {
+			return fmt.Errorf("error scanning dietary tag: %v", err)
+		}
+		dietary[idTag] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error fetching dietary tags: %v", err)
+	}
+	for _, idTag := range idTags {
+		if !dietary[idTag] {
+			return fmt.Errorf("invalid dietary tag %s, only dietary labels can be searched for", idTag)
+		}
+	}
+	return nil
+}
+
+// Opening hours of several restaurants: the end of their running override (nil for none), their menu schedules
+// and whether they have a menu activated by hand
+type openHours struct {
+	overrides   map[string]*time.Time
+	schedules   map[string][]types.MenuSchedule
+	activeMenus map[string]bool
+}
+
+// Helper function to load the opening hours of restaurants in a query per table, whatever their number
+func (s *store) loadOpenHours(idRestaurants []string) (*openHours, error) {
+	hours := &openHours{
+		overrides:   make(map[string]*time.Time),
+		schedules:   make(map[string][]types.MenuSchedule),
+		activeMenus: make(map[string]bool),
+	}
+	if len(idRestaurants) == 0 {
+		return hours, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(idRestaurants)), ", ")
+	args := make([]interface{}, len(idRestaurants))
+	for i, idRestaurant := range idRestaurants {
+		args[i] = idRestaurant
+	}
+
+	rows, err := s.db.Query(`SELECT idRestaurant, until FROM menuOverride WHERE idRestaurant IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching menu overrides: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idRestaurant string
+		var until sql.NullTime
+		if err := rows.Scan(&idRestaurant, &until); err != nil {
+			return nil, fmt.Errorf("error scanning menu override: %v", err)
+		}
+		hours.overrides[idRestaurant] = nil
+		if until.Valid {
+			hours.overrides[idRestaurant] = &until.Time
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching menu overrides: %v", err)
+	}
+
+	menus, err := s.db.Query(`SELECT idMenu, idRestaurant, active FROM menu WHERE idRestaurant IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching menus: %v", err)
+	}
+	defer menus.Close()
+	menuRestaurant := make(map[string]string)
+	for menus.Next() {
+		var idMenu, idRestaurant string
+		var active bool
+		if err := menus.Scan(&idMenu, &idRestaurant, &active); err != nil {
+			return nil, fmt.Errorf("error scanning menu: %v", err)
+		}
+		menuRestaurant[idMenu] = idRestaurant
+		if active {
+			hours.activeMenus[idRestaurant] = true
+		}
+	}
+	if err := menus.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching menus: %v", err)
+	}
+
+	schedules, err := s.queryMenuSchedules(menuScheduleQuery+` WHERE m.idRestaurant IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		idRestaurant := menuRestaurant[schedule.IdMenu]
+		hours.schedules[idRestaurant] = append(hours.schedules[idRestaurant], schedule)
+	}
+	return hours, nil
+}
+
+// Helper function to tell whether a restaurant is serving at a given time: a running override, or one of its
+// schedules covering that time. A restaurant without any schedule is open while it has a menu activated by hand.
+func (h *openHours) openAt(idRestaurant string, at time.Time) bool {
+	if until, ok := h.overrides[idRestaurant]; ok && (until == nil || until.After(at)) {
+		return true
+	}
+	schedules := h.schedules[idRestaurant]
+	if len(schedules) > 0 {
+		for _, schedule := range schedules {
+			if scheduleCovers(schedule, at) {
+				return true
+			}
+		}
+		return false
+	}
+	return h.activeMenus[idRestaurant]
+}
+
+// Helper function to get which restaurants can take a party at a given time: they have to be serving then and
+// have room left for the party within a slot of the time. Their bookings are summed in a single query.
+func (s *store) restaurantsAvailable(idRestaurants []string, hours *openHours, partySize int, at time.Time) (map[string]bool, error) {
+	available := make(map[string]bool)
+	var args []interface{}
+	for _, idRestaurant := range idRestaurants {
+		if hours.openAt(idRestaurant, at) {
+			args = append(args, idRestaurant)
+		}
+	}
+	if len(args) == 0 {
+		return available, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+
+	// The same capacity check as checkReservationAvailability, with each restaurant's slot length
+	rows, err := s.db.Query(`
+		SELECT r.idRestaurant
+		FROM restaurant r
+		LEFT JOIN reservationPolicy rp ON rp.idRestaurant = r.idRestaurant
+		WHERE r.idRestaurant IN (`+placeholders+`)
+		AND (IFNULL(r.capacity, 0) <= 0 OR IFNULL((
+			SELECT SUM(res.numberOfPeople)
+			FROM reservation res
+			WHERE res.idRestaurant = r.idRestaurant
+			AND res.status IN ('pending', 'confirmed')
+			AND ABS(TIMESTAMPDIFF(MINUTE, res.timeFrom, ?)) < IFNULL(rp.slotDurationMinutes, ?)
+		), 0) + ? <= r.capacity)`,
+		append(args, at, defaultSlotDurationMinutes, partySize)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error checking restaurant availability: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idRestaurant string
+		if err := rows.Scan(&idRestaurant); err != nil {
+			return nil, fmt.Errorf("error scanning restaurant availability: %v", err)
+		}
+		available[idRestaurant] = true
+	}
+	return available, rows.Err()
+}
+
+// Helper function to list the foods of a restaurant whose name has one of the searched words
+func (s *store) searchMatchedFoods(idRestaurant string, terms []string) ([]string, error) {
+	conditions := make([]string, len(terms))
+	args := []interface{}{idRestaurant}
+	for i, term := range terms {
+		conditions[i] = "name LIKE ?"
+		args = append(args, "%"+likeEscaper.Replace(term)+"%")
+	}
+	rows, err := s.db.Query(`
+		SELECT name FROM food
+		WHERE idRestaurant = ? AND (`+strings.Join(conditions, " OR ")+`)
+		ORDER BY name
+		LIMIT ?`,
+		append(args, searchMatchedFoods)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching matched foods: %v", err)
+	}
+	defer rows.Close()
+
+	var foods []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scanning matched food: %v", err)
+		}
+		foods = append(foods, name)
+	}
+	return foods, rows.Err()
+}
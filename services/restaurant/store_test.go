@@ -0,0 +1,215 @@
+package restaurant
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+func TestSweptReservationStatus(t *testing.T) {
+	timeFrom := time.Date(2024, 5, 10, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		status    string
+		checkedIn bool
+		now       time.Time
+		want      string
+	}{
+		{"pending before its slot ends", "pending", false, timeFrom.Add(90 * time.Minute), ""},
+		{"pending after its slot expires", "pending", false, timeFrom.Add(121 * time.Minute), "expired"},
+		{"confirmed within the grace period", "confirmed", false, timeFrom.Add(20 * time.Minute), ""},
+		{"confirmed without check-in after the grace period", "confirmed", false, timeFrom.Add(31 * time.Minute), "no_show"},
+		{"checked in during the slot", "confirmed", true, timeFrom.Add(60 * time.Minute), ""},
+		{"checked in after the slot", "confirmed", true, timeFrom.Add(121 * time.Minute), "completed"},
+		{"cancelled is left alone", "cancelled", false, timeFrom.Add(24 * time.Hour), ""},
+		{"no-show is left alone", "no_show", false, timeFrom.Add(24 * time.Hour), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sweptReservationStatus(tt.status, tt.checkedIn, timeFrom, 30, 120, tt.now)
+			if got != tt.want {
+				t.Errorf("sweptReservationStatus(%s, %v) = %q, want %q", tt.status, tt.checkedIn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDepositSettlement(t *testing.T) {
+	policy := types.DepositPolicy{LateCancellationFeePercent: 50, NoShowFeePercent: 100}
+	tests := []struct {
+		outcome    string
+		wantFee    float64
+		wantRefund float64
+	}{
+		{"no_show", 40, 0},
+		{"late_cancellation", 20, 20},
+		{"cancelled", 0, 40},
+		{"expired", 0, 40},
+		{"completed", 0, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.outcome, func(t *testing.T) {
+			fee, refund := depositSettlement(policy, 40, tt.outcome)
+			if fee != tt.wantFee || refund != tt.wantRefund {
+				t.Errorf("depositSettlement(40, %s) = %v, %v, want %v, %v", tt.outcome, fee, refund, tt.wantFee, tt.wantRefund)
+			}
+		})
+	}
+}
+
+func TestMergeComboTags(t *testing.T) {
+	peanuts := types.FoodTag{IdTag: "peanuts", Name: "Peanuts", Kind: "allergen"}
+	milk := types.FoodTag{IdTag: "milk", Name: "Milk", Kind: "allergen"}
+	vegan := types.FoodTag{IdTag: "vegan", Name: "Vegan", Kind: "dietary"}
+	halal := types.FoodTag{IdTag: "halal", Name: "Halal", Kind: "dietary"}
+
+	tests := []struct {
+		name       string
+		own        []types.FoodTag
+		components [][]types.FoodTag
+		want       []string
+	}{
+		{"component allergens reach the combo", nil, [][]types.FoodTag{{peanuts}, {milk}}, []string{"milk", "peanuts"}},
+		{"own allergens are kept", []types.FoodTag{milk}, [][]types.FoodTag{{}}, []string{"milk"}},
+		{"label on every component", nil, [][]types.FoodTag{{vegan, halal}, {vegan}}, []string{"vegan"}},
+		{"own label without the components is dropped", []types.FoodTag{vegan}, [][]types.FoodTag{{vegan}, {peanuts}}, []string{"peanuts"}},
+		{"untagged component drops every label", nil, [][]types.FoodTag{{halal}, nil}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, tag := range mergeComboTags(tt.own, tt.components) {
+				got = append(got, tag.IdTag)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("mergeComboTags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevenuePeriod(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name      string
+		day       time.Time
+		groupBy   string
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"day", day(time.May, 15), "day", day(time.May, 15), day(time.May, 16)},
+		{"week from a wednesday", day(time.May, 15), "week", day(time.May, 13), day(time.May, 20)},
+		{"week from a sunday", day(time.May, 19), "week", day(time.May, 13), day(time.May, 20)},
+		{"week from a monday", day(time.May, 13), "week", day(time.May, 13), day(time.May, 20)},
+		{"week across months", day(time.June, 2), "week", day(time.May, 27), day(time.June, 3)},
+		{"month", day(time.May, 15), "month", day(time.May, 1), day(time.June, 1)},
+		{"leap february", day(time.February, 29), "month", day(time.February, 1), day(time.March, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := revenuePeriodStart(tt.day, tt.groupBy); !got.Equal(tt.wantStart) {
+				t.Errorf("revenuePeriodStart = %s, want %s", got.Format("2006-01-02"), tt.wantStart.Format("2006-01-02"))
+			}
+			if got := revenuePeriodEnd(tt.day, tt.groupBy); !got.Equal(tt.wantEnd) {
+				t.Errorf("revenuePeriodEnd = %s, want %s", got.Format("2006-01-02"), tt.wantEnd.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestSearchScore(t *testing.T) {
+	result := func(relevance, rating float64, reviews int, distanceKm *float64) types.RestaurantSearchResult {
+		r := types.RestaurantSearchResult{TotalRatings: reviews, Relevance: relevance, DistanceKm: distanceKm}
+		r.AverageRating = &rating
+		return r
+	}
+	km := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name         string
+		result       types.RestaurantSearchResult
+		maxRelevance float64
+		hasPoint     bool
+		want         float64
+	}{
+		{"unrated restaurant gets the prior", result(0, 0, 0, nil), 0, false, 0.6},
+		{"best match", result(6, 5, 5, nil), 6, false, 0.925},
+		{"a single 5", result(0, 5, 1, nil), 0, false, 0.667},
+		{"a hundred 4.8", result(0, 4.8, 100, nil), 0, false, 0.943},
+		{"distance halves at 2 km", result(0, 0, 0, km(2)), 0, true, 0.56},
+		{"no position with a search point", result(0, 0, 0, nil), 0, true, 0.36},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchScore(tt.result, tt.maxRelevance, tt.hasPoint); got != tt.want {
+				t.Errorf("searchScore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 36.75, 3.06, 36.75, 3.06, 0},
+		{"one degree along the equator", 0, 0, 0, 1, 111.19},
+		{"Paris to London", 48.8566, 2.3522, 51.5074, -0.1278, 343.56},
+		{"antipodes", 0, 0, 0, 180, 20015.09},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := haversineKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("haversineKm = %.2f, want %.2f", got, tt.want)
+			}
+			if back := haversineKm(tt.lat2, tt.lng2, tt.lat1, tt.lng1); math.Abs(back-got) > 1e-9 {
+				t.Errorf("haversineKm is not symmetric: %v and %v", got, back)
+			}
+		})
+	}
+}
+
+func TestOpenHoursOpenAt(t *testing.T) {
+	// 2024-05-13 is a Monday
+	at := time.Date(2024, 5, 13, 13, 0, 0, 0, time.UTC)
+	later := at.Add(time.Hour)
+	earlier := at.Add(-time.Hour)
+	lunch := types.MenuSchedule{StartTime: "12:00", EndTime: "15:00"}
+	weekendLunch := types.MenuSchedule{StartTime: "12:00", EndTime: "15:00", Weekdays: []int{0, 6}}
+	dinner := types.MenuSchedule{StartTime: "19:00", EndTime: "01:00"}
+	hours := &openHours{
+		overrides: map[string]*time.Time{"override": nil, "running": &later, "ended": &earlier},
+		schedules: map[string][]types.MenuSchedule{
+			"lunch":   {dinner, lunch},
+			"weekend": {weekendLunch},
+			"dinner":  {dinner},
+			"ended":   {dinner},
+		},
+		activeMenus: map[string]bool{"manual": true, "dinner": true},
+	}
+	tests := []struct {
+		idRestaurant string
+		want         bool
+	}{
+		{"override", true},
+		{"running", true},
+		{"ended", false},
+		{"lunch", true},
+		{"weekend", false},
+		{"dinner", false},
+		{"manual", true},
+		{"closed", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.idRestaurant, func(t *testing.T) {
+			if got := hours.openAt(tt.idRestaurant, at); got != tt.want {
+				t.Errorf("openAt(%s) = %v, want %v", tt.idRestaurant, got, tt.want)
+			}
+		})
+	}
+}
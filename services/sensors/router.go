@@ -2,6 +2,7 @@ package sensors
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"regexp"
 	"time"
@@ -31,6 +32,7 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/sensors/batch-usage", h.SaveBatchUsage).Methods("POST")
 	router.HandleFunc("/sensors/user/{idClient}/usage", h.GetSensorUsage).Methods("GET")
 	router.HandleFunc("/sensors/{sensorId}/usage/range", h.GetSensorUsageByDateRange).Methods("GET")
+	router.HandleFunc("/sensors/{sensorId}/usage/export", h.ExportSensorUsage).Methods("GET")
 }
 
 // RegisterSensor handles sensor registration to a user
@@ -296,30 +298,8 @@ func (h *Handler) GetSensorUsage(w http.ResponseWriter, r *http.Request) {
 
 // GetSensorUsageByDateRange returns usage data for a sensor within a date range
 func (h *Handler) GetSensorUsageByDateRange(w http.ResponseWriter, r *http.Request) {
-	sensorId := mux.Vars(r)["sensorId"]
-	if sensorId == "" {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sensorId is required"))
-		return
-	}
-
-	startDate := r.URL.Query().Get("startDate")
-	endDate := r.URL.Query().Get("endDate")
-
-	if startDate == "" || endDate == "" {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("startDate and endDate are required"))
-		return
-	}
-
-	// Validate date formats
-	_, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid startDate format, use YYYY-MM-DD"))
-		return
-	}
-
-	_, err = time.Parse("2006-01-02", endDate)
-	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid endDate format, use YYYY-MM-DD"))
+	sensorId, startDate, endDate, ok := readUsageRange(w, r)
+	if !ok {
 		return
 	}
 
@@ -347,6 +327,78 @@ func (h *Handler) GetSensorUsageByDateRange(w http.ResponseWriter, r *http.Reque
 	utils.WriteJson(w, http.StatusOK, response)
 }
 
+// ExportSensorUsage downloads the daily usage of a sensor between ?startDate and ?endDate as CSV or PDF (?format)
+func (h *Handler) ExportSensorUsage(w http.ResponseWriter, r *http.Request) {
+	sensorId, startDate, endDate, ok := readUsageRange(w, r)
+	if !ok {
+		return
+	}
+	format, err := utils.ExportFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// One record per day, read before the download starts
+	records, err := h.store.GetSensorUsageByDate(sensorId, startDate, endDate)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	exporter := utils.NewExporter(w, format, "usage-"+sensorId, "Water usage of sensor "+sensorId,
+		"from "+startDate+" to "+endDate, []string{"Date", "Volume (L)"})
+	var total float64
+	for _, record := range records {
+		total += record.VolumeLiters
+		if err = exporter.WriteRow(record.Date, fmt.Sprintf("%.2f", record.VolumeLiters)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = exporter.WriteRow("Total", fmt.Sprintf("%.2f", total))
+	}
+	if err == nil {
+		err = exporter.Close()
+	}
+	// The download is already under way, the file is only cut short
+	if err != nil {
+		log.Printf("Error exporting usage of sensor %s: %v", sensorId, err)
+	}
+}
+
+// Helper function to read the sensor and the days of a usage range, answering the errors itself
+func readUsageRange(w http.ResponseWriter, r *http.Request) (string, string, string, bool) {
+	sensorId := mux.Vars(r)["sensorId"]
+	if sensorId == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("sensorId is required"))
+		return "", "", "", false
+	}
+
+	startDate := r.URL.Query().Get("startDate")
+	endDate := r.URL.Query().Get("endDate")
+
+	if startDate == "" || endDate == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("startDate and endDate are required"))
+		return "", "", "", false
+	}
+
+	// Validate date formats
+	_, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid startDate format, use YYYY-MM-DD"))
+		return "", "", "", false
+	}
+
+	_, err = time.Parse("2006-01-02", endDate)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid endDate format, use YYYY-MM-DD"))
+		return "", "", "", false
+	}
+
+	return sensorId, startDate, endDate, true
+}
+
 // Helper function to validate sensor ID format
 func isValidSensorId(sensorId string) bool {
 	// Check if sensor ID matches expected format (ZC-WS-YYYY-NNNN)
@@ -23,11 +23,12 @@ import (
 )
 
 type Handler struct {
-	store types.UserStore
+	store  types.UserStore
+	images types.ImageStore
 }
 
-func NewHandler(store types.UserStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.UserStore, images types.ImageStore) *Handler {
+	return &Handler{store: store, images: images}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
@@ -230,16 +231,23 @@ func (h *Handler) CreateRestaurantWithAdmin(w http.ResponseWriter, r *http.Reque
 	}
 	defer file.Close()
 
-	imageURL, err := utils.UploadImageToCloudinary(file)
+	// The image is saved under the ID the restaurant is created with
+	restaurantData.IdRestaurant, err = utils.CreateAnId()
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error uploading image: %v", err))
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	image, err := utils.SaveImage(h.images, utils.ImageID("restaurants", restaurantData.IdRestaurant), file)
+	if err != nil {
+		utils.WriteError(w, utils.ImageErrorStatus(err), err)
 		return
 	}
-	restaurantData.Image = imageURL
+	restaurantData.Image = image.URL
 
 	// Create restaurant with admin
 	idRestaurant, token, err := h.store.CreateRestaurantWithAdmin(restaurantData, profileData)
 	if err != nil {
+		utils.DeleteImages(h.images, image.PublicID)
 		if strings.Contains(err.Error(), "already exists") {
 			utils.WriteError(w, http.StatusConflict, err)
 			return
@@ -256,7 +264,7 @@ func (h *Handler) CreateRestaurantWithAdmin(w http.ResponseWriter, r *http.Reque
 		"message":      "Restaurant and admin created successfully",
 		"idRestaurant": idRestaurant,
 		"token":        token,
-		"image":        imageURL,
+		"image":        image.URL,
 	})
 }
 
@@ -837,16 +845,23 @@ func (h *Handler) CreateActivityWithAdmin(w http.ResponseWriter, r *http.Request
     }
     defer file.Close()
 
-    imageURL, err := utils.UploadImageToCloudinary(file)
+    // The image is saved under the ID the activity is created with
+    activityData.IdActivity, err = utils.CreateAnId()
     if err != nil {
         utils.WriteError(w, http.StatusInternalServerError, err)
         return
     }
-    activityData.Image = imageURL
+    image, err := utils.SaveImage(h.images, utils.ImageID("activities", activityData.IdActivity), file)
+    if err != nil {
+        utils.WriteError(w, utils.ImageErrorStatus(err), err)
+        return
+    }
+    activityData.Image = image.URL
 
     // Create activity with admin
     idActivity, token, err := h.store.CreateActivityWithAdmin(activityData, profileData)
     if err != nil {
+        utils.DeleteImages(h.images, image.PublicID)
         if strings.Contains(err.Error(), "already exists") {
             utils.WriteError(w, http.StatusConflict, err)
             return
@@ -863,7 +878,7 @@ func (h *Handler) CreateActivityWithAdmin(w http.ResponseWriter, r *http.Request
         "message":      "Activity and admin created successfully",
         "idActivity": idActivity,
         "token":        token,
-        "image":        imageURL,
+        "image":        image.URL,
     })
 }
 
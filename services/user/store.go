@@ -112,9 +112,12 @@ func (s *Store) CreateRestaurantWithAdmin(restaurantData types.RestaurantCreatio
 		return "", "", fmt.Errorf("error generating admin restaurant ID: %v", err)
 	}
 
-	idRestaurant, err := utils.CreateAnId()
-	if err != nil {
-		return "", "", fmt.Errorf("error generating restaurant ID: %v", err)
+	idRestaurant := restaurantData.IdRestaurant
+	if idRestaurant == "" {
+		idRestaurant, err = utils.CreateAnId()
+		if err != nil {
+			return "", "", fmt.Errorf("error generating restaurant ID: %v", err)
+		}
 	}
 
 	// Hash password
@@ -979,9 +982,12 @@ func (s *Store) CreateActivityWithAdmin(activityData types.ActivityCreationWithA
         return "", "", fmt.Errorf("error generating admin activity ID: %v", err)
     }
 
-    idActivity, err := utils.CreateAnId()
-    if err != nil {
-        return "", "", fmt.Errorf("error generating activity ID: %v", err)
+    idActivity := activityData.IdActivity
+    if idActivity == "" {
+        idActivity, err = utils.CreateAnId()
+        if err != nil {
+            return "", "", fmt.Errorf("error generating activity ID: %v", err)
+        }
     }
 
     // Generate client ID for dual role
@@ -106,7 +106,12 @@ type ActiviteStore interface {
 	// New methods for bookings and analytics
 	GetActivityBookings(idActivity string) ([]ActivityBookingDetail, error)
 	GetActivityDetailedAnalytics(idActivity string) (*ActivityDetailedAnalytics, error)
-	GetAdminActivityBookings(idAdminActivity string) ([]ActivityBookingDetail, error)
+	GetActivityDemand(idActivity string, from, to time.Time) ([]DemandPoint, error)
+	GetAdminActivityBookings(idAdminActivity string, filter ListFilter) ([]ActivityBookingDetail, error)
+	ExportAdminActivityBookings(idAdminActivity string, filter ListFilter, fn func(ActivityBookingDetail) error) error
+	UpdateActivityPrice(idActivity string, price float64) error
+	GetActivityImage(idActivity string) (string, error)
+	SetActivityImage(idActivity, image string) (string, error)
 }
 
 type RestaurantStore interface {
@@ -127,7 +132,7 @@ type RestaurantStore interface {
 	CreateReservation(idReservation string, reservation ReservationCreation) error
 	GetOrderInformation(idOrder string) (*OrderInformation, error)
 	UpdateOrderStatus(idOrder string, status string) error
-	GetAllRestaurantReservations(idRestaurant string, page, limit int) (*PaginatedReservations, error)
+	GetAllRestaurantReservations(idRestaurant string, filter ListFilter, page, limit int) (*PaginatedReservations, error)
 	GetReservationDetails(idReservation string) (*ReservationIdDetails, error)
 	GetRecentReviews(idRestaurant string) ([]*Rating, error)
 	CreateOrder(idOrder string, order OrderCreation) error
@@ -146,7 +151,7 @@ type RestaurantStore interface {
 	SetRestaurantWorkerStatus(idRestaurantWorker string, status string) error
 	UpdateFood(idFood string, food Food) error
 	GetMenusByRestaurant(idRestaurant string) ([]Menu, error)
-	GetFoodsOfActiveMenu(idRestaurant string) ([]Food, error)
+	GetFoodsOfActiveMenu(idRestaurant string, filter DietaryFilter) ([]Food, error)
 	GetFoodById(idFood string) (*Food, error)
 	GetRestaurantMenuStats(restaurantId string) (*RestaurantMenuStats, error)
 	GetUpcomingReservations(restaurantId string) ([]UpcomingReservationInfo, error)
@@ -156,6 +161,7 @@ type RestaurantStore interface {
 	DeleteTable(idTable string) error
 	GetTablesByRestaurant(restaurantId string) ([]Table, error)
 	UpdateReservationStatus(idReservation, status string) error
+	CheckInReservation(idReservation string) error
 	CreateNotification(notification Notification) error
 	GetNotifications() ([]Notification, error)
 	CreateFood(idFood, idCategory, idRestaurant, name, description, image string, price float64, status string) error
@@ -163,17 +169,17 @@ type RestaurantStore interface {
 	// errorCreateMenu(idMenu, idRestaurant, name string) error
 	SetFoodUnavailable(idFood string) error
 	GetRestaurantWorker(idRestaurant string) (*[]RestaurantWorker, error)
-	CreateFoodCategory(idCategory, nameCategorie string) error
+	CreateFoodCategory(category FoodCategory) error
 	CountOrderReceivedToday(idRestaurant string) (int, error)
 	CountReservationReceivedToday(idRestaurant string) (int, error)
-	GetFoodCategoriesByRestaurant() ([]FoodCategory, error)
+	GetFoodCategoriesByRestaurant(idRestaurant string) ([]FoodCategory, error)
 	DeleteFood(idFood string) error
-	GetAvailableMenuInformation(restaurantId string) (*[]MenuInformationFood, error)
+	GetAvailableMenuInformation(restaurantId string, filter DietaryFilter) (*[]MenuInformationFood, error)
 	ReserveTable(idReservation string, reservation ReservationCreation) error
 	GetFriendsOfClient(idClient string) (*[]string, error)
 	GetRatingOfFriendsRestaurant(friendsId []string, idRestaurant string) (*[]RatingRestaurant, error)
 	PostRatingRestaurant(rating PostRatingRestaurant) error
-	GetOrderStatsByHourAndStatus(idRestaurant string) (map[int]int, map[string]int, error)
+	GetOrderStatsByHourAndStatus(idRestaurant string, orderType string) (map[int]int, map[string]int, error)
 	GetClientReservationAndOrderDetails(idClient string) (*ClientDetails, error)
 	// GetRestaurantWorker() (*[]RestaurantWorker, error)
 	// GetRestaurantWorkerById(id string) (*RestaurantWorker, error)
@@ -198,6 +204,192 @@ type RestaurantStore interface {
 	GetAdminRestaurantStats() (*AdminRestaurantStats, error)
 	GetAllRestaurantReviews(idRestaurant string) ([]*Rating, error)
 	GetRestaurantTodaySummary(idRestaurant string) (*RestaurantTodaySummary, error)
+
+	// Reservation lifecycle and no-show policy
+	GetReservationPolicy(idRestaurant string) (*ReservationPolicy, error)
+	UpsertReservationPolicy(policy ReservationPolicy) error
+	SweepElapsedReservations(now time.Time) (*ReservationSweepResult, error)
+	GetClientNoShowSummary(idClient string, idRestaurant string) (*ClientNoShowSummary, error)
+
+	// Client reservation emails
+	GetDueEmailJobs(now time.Time, limit int) ([]EmailJob, error)
+	MarkEmailJobSent(idJob string) error
+	MarkEmailJobSkipped(idJob string, reason string) error
+	MarkEmailJobFailed(idJob string, errMsg string, retryAt *time.Time) error
+	GetClientEmailPreferences(idClient string) (*ClientEmailPreferences, error)
+	UpdateClientEmailPreferences(prefs ClientEmailPreferences) error
+
+	// Client self-service changes
+	ModifyReservationByClient(idReservation string, idClient string, modification ReservationModification) (*ReservationHistoryEntry, error)
+	CancelReservationByClient(idReservation string, idClient string) (*ReservationHistoryEntry, error)
+	GetReservationHistory(idReservation string) ([]ReservationHistoryEntry, error)
+
+	// Group reservations
+	InviteFriendsToReservation(idReservation string, idHost string, friendIds []string) ([]ReservationGuest, error)
+	RespondToReservationInvitation(idReservation string, idClient string, response string) (*ReservationHistoryEntry, error)
+	GetReservationGuests(idReservation string) ([]ReservationGuest, error)
+	GetClientReservationInvitations(idClient string) ([]ClientReservationInfo, error)
+	AddGuestItemsToOrder(idReservation string, idClient string, foods []FoodItem) (string, error)
+
+	// Bills
+	GetBillingSettings(idRestaurant string) (*BillingSettings, error)
+	UpsertBillingSettings(settings BillingSettings) error
+	AssignOrderLine(idOrder string, idFood string, fromClient string, toClient string) error
+	ComputeReservationBill(idReservation string, req BillRequest) (*Bill, error)
+	SaveReservationBill(idReservation string, req BillRequest) (*Bill, error)
+
+	// Deposits
+	GetDepositPolicy(idRestaurant string) (*DepositPolicy, error)
+	UpsertDepositPolicy(policy DepositPolicy) error
+	GetReservationDeposit(idReservation string) (*ReservationDeposit, error)
+
+	// Kitchen display
+	GetKitchenTickets(idRestaurant string) ([]KitchenTicket, error)
+	GetKitchenTicket(idOrder string) (*KitchenTicket, error)
+	AdvanceKitchenOrder(idRestaurant string, idOrder string, action string) (*KitchenTicket, error)
+
+	// QR code table ordering
+	GetTableQrCode(idRestaurant string, idTable string) (*TableQrCode, error)
+	RotateTableQrCode(idRestaurant string, idTable string) (*TableQrCode, error)
+	OpenTableSession(token string) (*TableSession, error)
+	GetTableSession(idTableSession string) (*TableSession, error)
+	PlaceTableSessionOrder(idTableSession string, token string, foods []FoodItem) (string, error)
+	CloseTableSession(idTableSession string) error
+
+	// Takeaway
+	GetTakeawaySettings(idRestaurant string) (*TakeawaySettings, error)
+	UpsertTakeawaySettings(settings TakeawaySettings) error
+	GetTakeawayWindows(idRestaurant string, day time.Time) ([]TakeawayWindow, error)
+	CreateTakeawayOrder(order TakeawayOrderCreation) (string, error)
+	GetRestaurantTakeawayOrders(idRestaurant string, day time.Time) ([]TakeawayOrder, error)
+	GetClientTakeawayOrders(idClient string) ([]TakeawayOrder, error)
+
+	// Menu schedules
+	GetMenuSchedules(idMenu string) ([]MenuSchedule, error)
+	CreateMenuSchedule(schedule MenuSchedule) error
+	DeleteMenuSchedule(idMenu, idSchedule string) error
+	SetMenuOverride(idRestaurant, idMenu string, until *time.Time) error
+	ClearMenuOverride(idRestaurant string) error
+	GetCurrentMenu(idRestaurant string) (*ActiveMenu, error)
+
+	// Food options and combos
+	GetFoodCustomization(idFood string) (*FoodCustomization, error)
+	CreateFoodOptionGroup(group FoodOptionGroup) error
+	DeleteFoodOptionGroup(idFood, idGroup string) error
+	CreateFoodOption(idFood string, option FoodOption) error
+	UpdateFoodOption(idFood string, option FoodOption) error
+	DeleteFoodOption(idFood, idOption string) error
+	SetComboItems(idCombo string, items []ComboItem) error
+
+	// Allergens and dietary labels
+	GetFoodTags() ([]FoodTag, error)
+	CreateFoodTag(tag FoodTag) error
+	SetFoodTags(idFood string, tags []string) error
+	GetClientDietaryPreferences(idClient string) (*ClientDietaryPreferences, error)
+	UpdateClientDietaryPreferences(prefs ClientDietaryPreferences) error
+
+	// Inventory
+	GetIngredients(idRestaurant string, lowStockOnly bool) ([]Ingredient, error)
+	CreateIngredient(ingredient Ingredient) error
+	UpdateIngredient(ingredient Ingredient) error
+	DeleteIngredient(idRestaurant, idIngredient string) error
+	RecordStockMovement(idRestaurant, idIngredient, kind string, entry StockEntry) error
+	GetStockMovements(idRestaurant, idIngredient string) ([]StockMovement, error)
+	GetFoodRecipe(idFood string) ([]RecipeItem, error)
+	SetFoodRecipe(idFood string, items []RecipeItem) error
+
+	// Food categories
+	UpdateFoodCategory(idRestaurant, idCategory, nameCategorie string) error
+	GetFoodCategoryImage(idRestaurant, idCategory string) (string, error)
+	SetFoodCategoryImage(idRestaurant, idCategory, image string) (string, error)
+	ReorderFoodCategories(idRestaurant string, idCategories []string) error
+	DeleteFoodCategory(idRestaurant, idCategory string) error
+
+	// Staff scheduling
+	GetShiftSettings(idRestaurant string) (*ShiftSettings, error)
+	UpsertShiftSettings(settings ShiftSettings) error
+	GetShiftTemplates(idRestaurant string) ([]ShiftTemplate, error)
+	CreateShiftTemplate(template ShiftTemplate) error
+	DeleteShiftTemplate(idRestaurant, idTemplate string) error
+	AssignShiftTemplate(idRestaurant, idTemplate string, assignment TemplateAssignment) ([]WorkerShift, error)
+	GetWorkerShifts(idRestaurant, idRestaurantWorker string, from, to time.Time) ([]WorkerShift, error)
+	CreateWorkerShift(shift WorkerShift) error
+	DeleteWorkerShift(idRestaurant, idShift string) error
+	GetShiftConflicts(idRestaurant string, from, to time.Time) ([]ShiftConflict, error)
+	CreateShiftSwap(idRestaurant string, swap ShiftSwap) (*ShiftSwap, error)
+	GetShiftSwaps(idRestaurant, status string) ([]ShiftSwap, error)
+	ResolveShiftSwap(idRestaurant, idSwap, status string) error
+	ClockIn(idRestaurantWorker string) (*WorkerClock, error)
+	ClockOut(idRestaurantWorker string) (*WorkerClock, error)
+	GetWorkerTimesheet(idRestaurantWorker string, from, to time.Time) (*Timesheet, error)
+	GetOnShiftWorkers(idRestaurant string, now time.Time) ([]OnShiftWorker, error)
+
+	// Worker ratings
+	AssignReservationServer(idReservation string, idRestaurantWorker *string) error
+	AssignOrderServer(idOrder string, idRestaurantWorker *string) error
+	PostRatingWorker(rating *PostRatingWorker) error
+
+	// Review replies and moderation
+	ReplyToReview(idRestaurant, idRating, reply string) error
+	FlagReview(idFlag, idRating string, flag ReviewFlag) error
+	GetModerationQueue(status string) ([]ModeratedReview, error)
+	ModerateReview(idRating, status, note string) error
+
+	// Images, the previous image is returned so that it can be deleted from the image store. The getters fail
+	// when the entity does not exist, before anything is uploaded for it.
+	GetRestaurantImage(idRestaurant string) (string, error)
+	SetRestaurantImage(idRestaurant, image string) (string, error)
+	GetFoodImage(idFood string) (string, error)
+	SetFoodImage(idFood, image string) (string, error)
+	GetRestaurantWorkerImage(idRestaurantWorker string) (string, error)
+	SetRestaurantWorkerImage(idRestaurantWorker, image string) (string, error)
+
+	// Reports
+	GetRevenueReport(idRestaurant string, from, to time.Time, groupBy string) (*RevenueReport, error)
+	ExportRestaurantReservations(idRestaurant string, filter ListFilter, fn func(RestaurantReservationDetail) error) error
+	ExportRestaurantOrders(idRestaurant string, filter ListFilter, fn func(RestaurantOrderRow) error) error
+
+	// Forecasting
+	GetRestaurantDemand(idRestaurant string, from, to time.Time) (covers, orders []DemandPoint, err error)
+
+	// Search
+	SearchRestaurants(search RestaurantSearch) (*RestaurantSearchResponse, error)
+
+	// Review photos
+	GetReviewPhotoSlots(idRating, idClient string) (int, error)
+	AddReviewPhotos(idRating, idClient string, photos []ReviewPhoto) error
+	RemoveReviewPhoto(idRating, idPhoto, idClient string) (string, error)
+}
+
+type PaymentStore interface {
+	GetPaymentTarget(targetType string, idTarget string) (amount float64, idClient string, err error)
+	GetPaymentsByTarget(targetType string, idTarget string) ([]PaymentIntent, error)
+	CreatePaymentIntent(intent PaymentIntent) error
+	GetPayment(idPayment string) (*PaymentIntent, error)
+	GetPaymentByReference(provider string, reference string) (*PaymentIntent, error)
+	RecordPaymentEvent(idEvent string, payment *PaymentIntent, eventType string, payload []byte, newStatus string) (bool, error)
+	CreateRefund(refund PaymentRefund) error
+	GetPaymentRefunds(idPayment string) ([]PaymentRefund, error)
+	GetClientPayments(idClient string) ([]PaymentIntent, error)
+	GetPendingDepositSettlements(limit int) ([]ReservationDeposit, error)
+	MarkDepositSettled(idReservation string) error
+}
+
+// PaymentProvider is implemented by every payment backend (the local simulator, a card processor...)
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(idPayment string, amount float64, currency string) (*ProviderIntent, error)
+	Refund(idRefund string, reference string, amount float64) (*ProviderRefund, error)
+	VerifyWebhook(payload []byte, signature string) (*PaymentWebhookEvent, error)
+}
+
+// ImageStore is implemented by every backend keeping uploaded images (Cloudinary, the local disk...). The database only
+// keeps their URLs; public IDs are chosen by the caller, one per entity, so saving again replaces the previous image.
+type ImageStore interface {
+	Name() string
+	Save(publicID string, data []byte, contentType string) (*StoredImage, error)
+	Delete(publicID string) error
+	PublicID(url string) (string, bool) // false when the URL is not served by this store
 }
 
 type SensorStore interface {
@@ -216,3 +408,13 @@ type SensorStore interface {
 	UpdateSensorStatus(sensorId, status string) error
 	GetSensorInfo(sensorId string) (*SensorInfo, error)
 }
+
+type DigestStore interface {
+	GetDigestRecipients() ([]DigestRecipient, error)
+	GetDigestRecipient(idProfile string) (*DigestRecipient, error)
+	UpdateDigestSettings(settings DigestSettings) error
+
+	// Deliveries, keyed by the day the digest is sent on
+	DigestDelivered(idProfile, frequency string, day time.Time) (bool, error) // sent or out of attempts
+	RecordDigestDelivery(idProfile, frequency string, day time.Time, sendErr error) error
+}
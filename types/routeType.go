@@ -66,6 +66,9 @@ type FoodItem struct {
 	IdFood      string  `json:"idFood"`
 	PriceSingle float64 `json:"priceSingle"`
     Quantity    int     `json:"quantity"`
+
+	// idOption of each option picked on the food
+	Options []string `json:"options"`
 }
 type FoodItemInformation struct {
 Name        string  `json:"name"`
@@ -137,3 +140,124 @@ type ActivityCreation struct {
     TimeActivity      time.Time `json:"timeActivity"`
 
 }
+
+type ReservationModification struct {
+	TimeFrom       *time.Time `json:"timeFrom"`
+	NumberOfPeople *int       `json:"numberOfPeople"`
+}
+
+type BillRequest struct {
+	Mode          string             `json:"mode"` // "even", "by_item" or "custom"
+	CustomAmounts map[string]float64 `json:"customAmounts"`
+}
+
+type PaymentIntentCreation struct {
+	TargetType string `json:"targetType"` // "order", "activity", "deposit" or "table_session"
+	IdTarget   string `json:"idTarget"`
+	Currency   string `json:"currency"`
+}
+
+type PaymentRefundCreation struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// KitchenAction is sent by the kitchen display to move an order forward
+type KitchenAction struct {
+	Action  string `json:"action"` // "bump" or "ready"
+	IdOrder string `json:"idOrder"`
+}
+
+type TableSessionOpening struct {
+	Token string `json:"token"`
+}
+
+type TableSessionOrderCreation struct {
+	Token string     `json:"token"`
+	Foods []FoodItem `json:"foods"`
+}
+
+type TakeawayOrderCreation struct {
+	IdClient     string     `json:"idClient"`
+	IdRestaurant string     `json:"idRestaurant"`
+	PickupTime   time.Time  `json:"pickupTime"`
+	Foods        []FoodItem `json:"foods"`
+}
+
+type MenuOverrideCreation struct {
+	IdMenu string     `json:"idMenu"`
+	Until  *time.Time `json:"until"` // nil keeps the override until it is removed
+}
+
+type ComboUpdate struct {
+	Items []ComboItem `json:"items"`
+}
+
+// DietaryFilter narrows a menu down; with an idClient the client's preferences apply too
+type DietaryFilter struct {
+	Require  []string // dietary labels every food must carry
+	Exclude  []string // allergens no food may contain
+	IdClient string
+}
+
+type FoodTagsUpdate struct {
+	Tags []string `json:"tags"`
+}
+
+type RecipeUpdate struct {
+	Items []RecipeItem `json:"items"`
+}
+
+// StockEntry is a restock (positive quantity) or a manual correction of an ingredient's stock
+type StockEntry struct {
+	Quantity float64 `json:"quantity"`
+	Note     string  `json:"note"`
+}
+
+// FoodCategoryOrder lists categories in the order the menu shows them, unlisted ones follow
+type FoodCategoryOrder struct {
+	IdCategories []string `json:"idCategories"`
+}
+
+type ShiftAssignment struct {
+	IdRestaurantWorker string    `json:"idRestaurantWorker"`
+	StartsAt           time.Time `json:"startsAt"`
+	EndsAt             time.Time `json:"endsAt"`
+	Note               string    `json:"note"`
+}
+
+// TemplateAssignment gives a worker every shift of a template between two dates (YYYY-MM-DD, inclusive)
+type TemplateAssignment struct {
+	IdRestaurantWorker string `json:"idRestaurantWorker"`
+	From               string `json:"from"`
+	To                 string `json:"to"`
+}
+
+type ShiftSwapCreation struct {
+	ToWorker string `json:"toWorker"`
+	Note     string `json:"note"`
+}
+
+type ShiftSwapDecision struct {
+	Status string `json:"status"` // "accepted", "rejected" or "cancelled"
+}
+
+// ServerAssignment sets the worker serving a reservation or an order, null clears it
+type ServerAssignment struct {
+	IdRestaurantWorker *string `json:"idRestaurantWorker"`
+}
+
+type ReviewReply struct {
+	Reply string `json:"reply"`
+}
+
+type ReviewFlag struct {
+	IdClient string  `json:"idClient"`
+	Reason   string  `json:"reason"`
+	IdPhoto  *string `json:"idPhoto,omitempty"` // flags one photo of the review rather than its text
+}
+
+// ModerationDecision hides or restores a review; the note is kept for the other moderators
+type ModerationDecision struct {
+	Note string `json:"note"`
+}
@@ -21,6 +21,8 @@ type ActivityCreationWithAdmin struct {
     Latitude        float64 `json:"latitude"`
     IdTypeActivity  string  `json:"idTypeActivity"`
     Capacity        int     `json:"capacity"`
+
+    IdActivity string `json:"-"` // set by the handler when the image was saved under the new ID
 }
 
 type ActivityStats struct {
@@ -98,6 +100,9 @@ type ActivityReviewDetail struct {
 	Rating       int    `json:"rating"`
 	Comment      string `json:"comment"`
 	CreatedAt    string `json:"createdAt"`
+
+	IdRating string        `json:"idRating,omitempty"`
+	Photos   []ReviewPhoto `json:"photos,omitempty"`
 }
 
 type MonthlyUserStats struct {
@@ -228,6 +233,8 @@ type RestaurantCreation struct {
 	Description string  `json:"description"`
 	Capacity    int     `json:"capacity"`
 	Location    string  `json:"location"`
+
+	IdRestaurant string `json:"-"` // set by the handler when the image was saved under the new ID
 }
 type AdminLocation struct {
 	Latitude    *float64 `json:"latitude"`
@@ -249,6 +256,12 @@ type OrderInformation struct {
 	ReservationTime time.Time       `json:"reservationTime"`
 	NumberOfPeople  int             `json:"numberOfPeople"`
 	FoodItems       []OrderFoodItem `json:"foodItems"`
+	PaymentStatus   string          `json:"paymentStatus"`
+	AmountPaid      float64         `json:"amountPaid"`
+	AmountRefunded  float64         `json:"amountRefunded"`
+	IdRestaurant    string          `json:"idRestaurant"`
+	IdTableSession  *string         `json:"idTableSession,omitempty"` // walk-in orders have no client
+	PickupTime      *time.Time      `json:"pickupTime,omitempty"`     // takeaway orders only
 }
 
 type OrderFoodItem struct {
@@ -286,6 +299,7 @@ type ReservationIdDetails struct {
 	FavoriteFood    string               `json:"favoriteFood"`
 	TotalOrders     int                  `json:"totalOrders"`
 	Orders          []ClientOrderSummary `json:"orders"`
+	Deposit         *ReservationDeposit  `json:"deposit,omitempty"`
 }
 
 type ClientOrderSummary struct {
@@ -322,6 +336,9 @@ type ClientReservationInfo struct {
 	RestaurantImage    string    `json:"restaurantImage"`
 	RestaurantLocation string    `json:"restaurantLocation"`
 	IdRestaurant       string    `json:"idRestaurant"`
+	IsHost             bool      `json:"isHost"`
+	GuestStatus        string    `json:"guestStatus,omitempty"`
+	PaymentStatus      string    `json:"paymentStatus"`
 }
 type ClientActivityInfo struct {
 	IdClientActivity    string    `json:"idClientActivity"`
@@ -330,6 +347,7 @@ type ClientActivityInfo struct {
 	ActivityName        string    `json:"activityName"`
 	ActivityImage       string    `json:"activityImage"`
 	ActivityDescription string    `json:"activityDescription"`
+	PaymentStatus       string    `json:"paymentStatus"`
 }
 type ClientInfo struct {
 	IdClient        string `json:"idClient"`
@@ -396,6 +414,7 @@ type ActivitetType struct {
 type ActivityCategoryCreation struct {
 	NameTypeActivity string `json:"nameTypeActivity"`
 	ImageActivity    string `json:"imageActivity"`
+	IdTypeActivity   string `json:"-"` // set by the handler when the image was saved under the new ID
 }
 
 // Notification represents a notification in the system
@@ -458,6 +477,10 @@ type Rating struct {
 	CreatedAt    string `json:"createdAt"`
 	FirstName    string `json:"firstName"`
 	LastName     string `json:"lastName"`
+
+	Reply     *string       `json:"reply,omitempty"` // public answer of the restaurant
+	RepliedAt *time.Time    `json:"repliedAt,omitempty"`
+	Photos    []ReviewPhoto `json:"photos"`
 }
 
 type UpcomingReservationInfo struct {
@@ -507,8 +530,12 @@ type RestaurantTableStatus struct {
 	Status         *string    `json:"status"`
 }
 type FoodCategory struct {
-	IdCategory    string `json:"idCategory"`
-	NameCategorie string `json:"nameCategorie"`
+	IdCategory    string  `json:"idCategory"`
+	IdRestaurant  string  `json:"idRestaurant"`
+	NameCategorie string  `json:"nameCategorie"`
+	SortIndex     int     `json:"sortIndex"`
+	Image         *string `json:"image"`
+	FoodCount     int     `json:"foodCount"`
 }
 type RestaurantWorker struct {
 	IdRestaurantWorker string  `json:"idRestaurantWorker"`
@@ -620,6 +647,10 @@ type MenuInformationFood struct {
 	Price        float64 `json:"price" db:"price"`
 	Status       string  `json:"status" db:"status"`
 	MenuName     string  `json:"menuName" db:"menuName"`
+
+	Tags       []FoodTag `json:"tags"`
+	Compatible *bool     `json:"compatible,omitempty"` // set when the menu is read for a client
+	Conflicts  []string  `json:"conflicts,omitempty"`
 }
 type Food struct {
 	IdFood      string   `json:"idFood"`
@@ -630,6 +661,10 @@ type Food struct {
 	Image       *string  `json:"image"`
 	Price       *float64 `json:"price"`
 	Status      *string  `json:"status"`
+
+	Tags       []FoodTag `json:"tags,omitempty"`
+	Compatible *bool     `json:"compatible,omitempty"` // set when the menu is read for a client
+	Conflicts  []string  `json:"conflicts,omitempty"`
 }
 type RestaurantMenuStats struct {
 	TotalMenus       int           `json:"totalMenus"`
@@ -716,6 +751,18 @@ type PostRatingActivity struct {
 	Comment     string `json:"comment"`
 }
 
+// PostRatingWorker rates the worker who served a completed visit, given by idReservation or idOrder
+type PostRatingWorker struct {
+	IdRating           string  `json:"idRating"`
+	IdClient           string  `json:"idClient"`
+	IdReservation      *string `json:"idReservation,omitempty"`
+	IdOrder            *string `json:"idOrder,omitempty"`
+	IdRestaurantWorker string  `json:"idRestaurantWorker"` // the server of the visit, filled in when rated
+	RatingValue        int     `json:"rating"`
+	Comment            string  `json:"comment"`
+	WorkerRating       float64 `json:"workerRating"` // the worker's average after this rating
+}
+
 type RatingRestaurant struct {
 	FirstName   string    `json:"firstName"`
 	LastName    string    `json:"lastName"`
@@ -938,6 +985,7 @@ type ActivityBookingDetail struct {
 	BookingTime      time.Time `json:"bookingTime"`
 	Status           string    `json:"status"`
 	CreatedAt        time.Time `json:"createdAt"`
+	ActivityName     string    `json:"activityName,omitempty"`
 }
 
 // Enhanced analytics with chart-ready data
@@ -990,4 +1038,797 @@ type RestaurantTodaySummary struct {
 	CurrentOccupancy       float64 `json:"currentOccupancy"`
 	ConfirmedReservations  int     `json:"confirmedReservations"`
 	PendingReservations    int     `json:"pendingReservations"`
+
+	// Takeaway orders are counted apart from the reservations
+	TakeawayOrdersToday  int             `json:"takeawayOrdersToday"`
+	TakeawayInProgress   int             `json:"takeawayInProgress"` // pending or preparing
+	TakeawayReadyOrders  int             `json:"takeawayReadyOrders"`
+	TakeawayRevenueToday float64         `json:"takeawayRevenueToday"`
+	TakeawayOrders       []TakeawayOrder `json:"takeawayOrders"`
+
+	OnShift []OnShiftWorker `json:"onShift"`
+}
+
+// ReservationPolicy holds the per-restaurant rules applied by the reservation scheduler
+type ReservationPolicy struct {
+	IdRestaurant              string `json:"idRestaurant"`
+	GracePeriodMinutes        int    `json:"gracePeriodMinutes"`
+	SlotDurationMinutes       int    `json:"slotDurationMinutes"`
+	NoShowLimit               int    `json:"noShowLimit"`
+	NoShowWindowDays          int    `json:"noShowWindowDays"`
+	BlockDays                 int    `json:"blockDays"`
+	ReminderHoursBefore       int    `json:"reminderHoursBefore"`
+	ModificationCutoffMinutes int    `json:"modificationCutoffMinutes"`
+}
+
+// ClientNoShowSummary reports how often a client missed a reservation
+type ClientNoShowSummary struct {
+	IdClient          string     `json:"idClient"`
+	TotalNoShows      int        `json:"totalNoShows"`
+	LastNoShowAt      *time.Time `json:"lastNoShowAt"`
+	IdRestaurant      string     `json:"idRestaurant,omitempty"`
+	RestaurantNoShows int        `json:"restaurantNoShows"`
+	BlockedUntil      *time.Time `json:"blockedUntil,omitempty"`
+}
+
+// ReservationSweepResult is returned by a scheduler pass over elapsed reservations
+type ReservationSweepResult struct {
+	NoShows   []string `json:"noShows"`
+	Expired   int64    `json:"expired"`
+	Completed int64    `json:"completed"`
+}
+
+// EmailJob is a queued client email together with the reservation data needed to render it
+type EmailJob struct {
+	IdJob             string    `json:"idJob"`
+	IdReservation     string    `json:"idReservation"`
+	IdOrder           string    `json:"idOrder"` // set instead of IdReservation for takeaway emails
+	JobType           string    `json:"jobType"`
+	SendAt            time.Time `json:"sendAt"`
+	Attempts          int       `json:"attempts"`
+	IdClient          string    `json:"idClient"`
+	Email             string    `json:"email"`
+	FirstName         string    `json:"firstName"`
+	RestaurantName    string    `json:"restaurantName"`
+	TimeFrom          time.Time `json:"timeFrom"`
+	NumberOfPeople    int       `json:"numberOfPeople"`
+	ReservationStatus string    `json:"reservationStatus"` // order status for takeaway emails
+	ReservationEmails bool      `json:"reservationEmails"`
+	ReminderEmails    bool      `json:"reminderEmails"`
+}
+
+// ClientEmailPreferences lets a client opt out of reservation emails
+type ClientEmailPreferences struct {
+	IdClient          string `json:"idClient"`
+	ReservationEmails bool   `json:"reservationEmails"`
+	ReminderEmails    bool   `json:"reminderEmails"`
+}
+
+// ReservationHistoryEntry is one recorded change of a reservation
+type ReservationHistoryEntry struct {
+	IdHistory              string     `json:"idHistory"`
+	IdReservation          string     `json:"idReservation"`
+	IdRestaurant           string     `json:"idRestaurant,omitempty"`
+	Action                 string     `json:"action"`
+	Actor                  string     `json:"actor"`
+	PreviousTimeFrom       *time.Time `json:"previousTimeFrom,omitempty"`
+	NewTimeFrom            *time.Time `json:"newTimeFrom,omitempty"`
+	PreviousNumberOfPeople *int       `json:"previousNumberOfPeople,omitempty"`
+	NewNumberOfPeople      *int       `json:"newNumberOfPeople,omitempty"`
+	PreviousStatus         string     `json:"previousStatus,omitempty"`
+	NewStatus              string     `json:"newStatus,omitempty"`
+	CreatedAt              time.Time  `json:"createdAt"`
+}
+
+// DashboardEvent is pushed to the restaurant dashboard websocket
+type DashboardEvent struct {
+	Type         string      `json:"type"`
+	IdRestaurant string      `json:"idRestaurant"`
+	Payload      interface{} `json:"payload"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+// ReservationGuest is a friend invited to a group reservation
+type ReservationGuest struct {
+	IdReservationGuest string     `json:"idReservationGuest"`
+	IdReservation      string     `json:"idReservation"`
+	IdClient           string     `json:"idClient"`
+	Username           string     `json:"username"`
+	FirstName          string     `json:"firstName"`
+	LastName           string     `json:"lastName"`
+	Status             string     `json:"status"`
+	InvitedAt          time.Time  `json:"invitedAt"`
+	RespondedAt        *time.Time `json:"respondedAt,omitempty"`
+}
+
+// BillingSettings are the per-restaurant rates applied to bills, in percent
+type BillingSettings struct {
+	IdRestaurant      string  `json:"idRestaurant"`
+	TaxRate           float64 `json:"taxRate"`
+	ServiceChargeRate float64 `json:"serviceChargeRate"`
+}
+
+// BillLine is one order line of a bill and the participant it is attributed to
+type BillLine struct {
+	IdFood    string  `json:"idFood"`
+	Name      string  `json:"name"`
+	UnitPrice float64 `json:"unitPrice"`
+	Quantity  int     `json:"quantity"`
+	Subtotal  float64 `json:"subtotal"`
+	IdClient  string  `json:"idClient"`
+}
+
+// BillShare is what one participant of a reservation owes
+type BillShare struct {
+	IdClient string     `json:"idClient"`
+	Username string     `json:"username"`
+	IsHost   bool       `json:"isHost"`
+	Items    []BillLine `json:"items,omitempty"`
+	Subtotal float64    `json:"subtotal"`
+	Amount   float64    `json:"amount"`
+}
+
+type Bill struct {
+	IdBill            string      `json:"idBill,omitempty"`
+	IdReservation     string      `json:"idReservation"`
+	IdRestaurant      string      `json:"idRestaurant"`
+	Mode              string      `json:"mode"`
+	Lines             []BillLine  `json:"lines"`
+	Subtotal          float64     `json:"subtotal"`
+	ServiceChargeRate float64     `json:"serviceChargeRate"`
+	ServiceCharge     float64     `json:"serviceCharge"`
+	TaxRate           float64     `json:"taxRate"`
+	Tax               float64     `json:"tax"`
+	Total             float64     `json:"total"`
+	Shares            []BillShare `json:"shares"`
+	CreatedAt         *time.Time  `json:"createdAt,omitempty"`
+}
+
+// PaymentIntent is a payment of an order or an activity booking
+type PaymentIntent struct {
+	IdPayment         string    `json:"idPayment"`
+	TargetType        string    `json:"targetType"`
+	IdTarget          string    `json:"idTarget"`
+	IdClient          string    `json:"idClient"`
+	Amount            float64   `json:"amount"`
+	AmountRefunded    float64   `json:"amountRefunded"`
+	Currency          string    `json:"currency"`
+	Status            string    `json:"status"`
+	Provider          string    `json:"provider"`
+	ProviderReference string    `json:"providerReference"`
+	ClientSecret      string    `json:"clientSecret,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+type PaymentRefund struct {
+	IdRefund          string    `json:"idRefund"`
+	IdPayment         string    `json:"idPayment"`
+	Amount            float64   `json:"amount"`
+	Reason            string    `json:"reason"`
+	Status            string    `json:"status"`
+	ProviderReference string    `json:"providerReference"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// ProviderIntent is what a payment provider returns when an intent is opened
+type ProviderIntent struct {
+	Reference    string
+	ClientSecret string
+	Status       string
+}
+
+// StoredImage is where an image store put an uploaded image
+type StoredImage struct {
+	URL          string
+	ThumbnailURL string
+	PublicID     string
+}
+
+type ProviderRefund struct {
+	Reference string
+	Status    string
+}
+
+// PaymentWebhookEvent is a verified provider callback
+type PaymentWebhookEvent struct {
+	IdEvent   string    `json:"id"`
+	Type      string    `json:"type"`
+	Reference string    `json:"reference"`
+	Amount    float64   `json:"amount"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DepositPolicy decides which reservations need a deposit and what is kept when the client does not come
+type DepositPolicy struct {
+	IdRestaurant               string  `json:"idRestaurant"`
+	Enabled                    bool    `json:"enabled"`
+	MinPartySize               int     `json:"minPartySize"`  // 0 disables the party size rule
+	PeakDays                   []int   `json:"peakDays"`      // time.Weekday values, 0 is Sunday
+	PeakStartTime              string  `json:"peakStartTime"` // "HH:MM"
+	PeakEndTime                string  `json:"peakEndTime"`
+	AmountPerPerson            float64 `json:"amountPerPerson"`
+	LateCancellationFeePercent float64 `json:"lateCancellationFeePercent"`
+	NoShowFeePercent           float64 `json:"noShowFeePercent"`
+}
+
+type ReservationDeposit struct {
+	IdReservation string     `json:"idReservation"`
+	IdRestaurant  string     `json:"idRestaurant"`
+	Amount        float64    `json:"amount"`
+	Reason        string     `json:"reason"` // "party_size" or "peak_slot"
+	Status        string     `json:"status"`
+	IdPayment     *string    `json:"idPayment,omitempty"`
+	Outcome       string     `json:"outcome,omitempty"`
+	FeeAmount     float64    `json:"feeAmount"`
+	RefundAmount  float64    `json:"refundAmount"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	SettledAt     *time.Time `json:"settledAt,omitempty"`
+}
+
+// KitchenTicket is an active order as shown on the kitchen display
+type KitchenTicket struct {
+	IdOrder            string                 `json:"idOrder"`
+	IdReservation      string                 `json:"idReservation,omitempty"`
+	IdTableSession     string                 `json:"idTableSession,omitempty"` // walk-in order placed from a table QR code
+	OrderType          string                 `json:"orderType"`                // "dine_in" or "takeaway"
+	PickupTime         *time.Time             `json:"pickupTime,omitempty"`
+	IdRestaurant       string                 `json:"idRestaurant"`
+	IdTable            *string                `json:"idTable,omitempty"`
+	NumberOfPeople     int                    `json:"numberOfPeople"`
+	Status             string                 `json:"status"`
+	CreatedAt          time.Time              `json:"createdAt"`
+	PreparingAt        *time.Time             `json:"preparingAt,omitempty"`
+	ReadyAt            *time.Time             `json:"readyAt,omitempty"`
+	ElapsedSeconds     int64                  `json:"elapsedSeconds"`     // since the order was placed
+	PreparationSeconds int64                  `json:"preparationSeconds"` // since preparation started, until ready
+	Categories         []KitchenCategoryGroup `json:"categories"`
+}
+
+type KitchenCategoryGroup struct {
+	IdCategory string        `json:"idCategory"`
+	Category   string        `json:"category"`
+	Items      []KitchenLine `json:"items"`
+}
+
+type KitchenLine struct {
+	IdOrderFood string            `json:"idOrderFood,omitempty"`
+	IdFood      string            `json:"idFood"`
+	Name        string            `json:"name"`
+	Quantity    int               `json:"quantity"`
+	Options     []OrderLineOption `json:"options"`
+	Components  []ComboItem       `json:"components"` // what goes with a combo
+}
+
+type TableQrCode struct {
+	IdTable      string `json:"idTable"`
+	IdRestaurant string `json:"idRestaurant"`
+	Token        string `json:"token"`
+}
+
+// TableSession groups the orders of walk-in guests seated at a table
+type TableSession struct {
+	IdTableSession string               `json:"idTableSession"`
+	IdRestaurant   string               `json:"idRestaurant"`
+	IdTable        string               `json:"idTable"`
+	Status         string               `json:"status"` // "open" or "closed"
+	OpenedAt       time.Time            `json:"openedAt"`
+	ClosedAt       *time.Time           `json:"closedAt,omitempty"`
+	Total          float64              `json:"total"`
+	Orders         []ClientOrderSummary `json:"orders"`
+}
+
+// TakeawaySettings limits how many takeaway orders a restaurant accepts per pickup window
+type TakeawaySettings struct {
+	IdRestaurant       string `json:"idRestaurant"`
+	Enabled            bool   `json:"enabled"`
+	WindowMinutes      int    `json:"windowMinutes"`
+	MaxOrdersPerWindow int    `json:"maxOrdersPerWindow"`
+	MinLeadMinutes     int    `json:"minLeadMinutes"`
+	FirstPickupTime    string `json:"firstPickupTime"` // "HH:MM"
+	LastPickupTime     string `json:"lastPickupTime"`
+}
+
+type TakeawayWindow struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Capacity  int       `json:"capacity"`
+	Booked    int       `json:"booked"`
+	Available bool      `json:"available"`
+}
+
+type TakeawayOrder struct {
+	IdOrder        string    `json:"idOrder"`
+	IdRestaurant   string    `json:"idRestaurant"`
+	RestaurantName string    `json:"restaurantName"`
+	IdClient       string    `json:"idClient"`
+	FirstName      string    `json:"firstName"`
+	LastName       string    `json:"lastName"`
+	PickupTime     time.Time `json:"pickupTime"`
+	Status         string    `json:"status"`
+	TotalPrice     float64   `json:"totalPrice"`
+	ItemCount      int       `json:"itemCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// MenuSchedule is a window during which a menu is served
+type MenuSchedule struct {
+	IdSchedule string    `json:"idSchedule"`
+	IdMenu     string    `json:"idMenu"`
+	Label      string    `json:"label"`     // "breakfast", "lunch", "dinner"...
+	StartTime  string    `json:"startTime"` // "HH:MM"
+	EndTime    string    `json:"endTime"`   // "HH:MM", before StartTime for windows crossing midnight
+	Weekdays   []int     `json:"weekdays"`  // time.Weekday values, empty means every day
+	ValidFrom  *string   `json:"validFrom,omitempty"`
+	ValidTo    *string   `json:"validTo,omitempty"`
+	Priority   int       `json:"priority"` // the highest priority wins when schedules overlap
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ActiveMenu tells which menu is served right now and why
+type ActiveMenu struct {
+	IdMenu     string     `json:"idMenu"`
+	Name       string     `json:"name"`
+	Source     string     `json:"source"` // "override", "schedule" or "default"
+	IdSchedule string     `json:"idSchedule,omitempty"`
+	Label      string     `json:"label,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+}
+
+// FoodOptionGroup is a choice offered on a food, e.g. size, extras or sauces
+type FoodOptionGroup struct {
+	IdGroup   string       `json:"idGroup"`
+	IdFood    string       `json:"idFood"`
+	Name      string       `json:"name"`
+	MinSelect int          `json:"minSelect"`
+	MaxSelect int          `json:"maxSelect"`
+	SortIndex int          `json:"sortIndex"`
+	Options   []FoodOption `json:"options"`
+}
+
+type FoodOption struct {
+	IdOption   string  `json:"idOption"`
+	IdGroup    string  `json:"idGroup"`
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"priceDelta"`
+	Available  bool    `json:"available"`
+}
+
+// ComboItem is a food served as part of a combo or set menu
+type ComboItem struct {
+	IdFood   string `json:"idFood"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+// FoodCustomization is what a client can pick on a food and what comes with it
+type FoodCustomization struct {
+	IdFood       string            `json:"idFood"`
+	OptionGroups []FoodOptionGroup `json:"optionGroups"`
+	ComboItems   []ComboItem       `json:"comboItems"`
+}
+
+// OrderLineOption is an option chosen on an order line, as it was when the order was placed
+type OrderLineOption struct {
+	IdOption   string  `json:"idOption"`
+	GroupName  string  `json:"groupName"`
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"priceDelta"`
+}
+
+// FoodTag is an allergen a food contains or a dietary label it meets
+type FoodTag struct {
+	IdTag string `json:"idTag"`
+	Name  string `json:"name"`
+	Kind  string `json:"kind"` // "allergen" or "dietary"
+}
+
+// ClientDietaryPreferences are the allergens a client avoids and the dietary labels they need
+type ClientDietaryPreferences struct {
+	IdClient         string   `json:"idClient"`
+	AvoidAllergens   []string `json:"avoidAllergens"`
+	RequireLabels    []string `json:"requireLabels"`
+	HideIncompatible bool     `json:"hideIncompatible"` // hide incompatible foods rather than flag them
+}
+
+// Ingredient is an item of a restaurant's stock
+type Ingredient struct {
+	IdIngredient      string    `json:"idIngredient"`
+	IdRestaurant      string    `json:"idRestaurant"`
+	Name              string    `json:"name"`
+	Unit              string    `json:"unit"`
+	Stock             float64   `json:"stock"`
+	LowStockThreshold float64   `json:"lowStockThreshold"`
+	LowStock          bool      `json:"lowStock"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// RecipeItem is how much of an ingredient one portion of a food uses
+type RecipeItem struct {
+	IdIngredient string  `json:"idIngredient"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+}
+
+// StockMovement is a change of an ingredient's stock
+type StockMovement struct {
+	IdMovement   string    `json:"idMovement"`
+	IdIngredient string    `json:"idIngredient"`
+	Ingredient   string    `json:"ingredient"`
+	Kind         string    `json:"kind"`     // "restock", "consumption" or "adjustment"
+	Quantity     float64   `json:"quantity"` // negative when stock goes down
+	IdOrder      *string   `json:"idOrder,omitempty"`
+	Note         string    `json:"note"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ShiftSettings are the staff scheduling rules of a restaurant
+type ShiftSettings struct {
+	IdRestaurant        string `json:"idRestaurant"`
+	MinRestHours        int    `json:"minRestHours"`        // between two shifts of the same worker
+	ClockInEarlyMinutes int    `json:"clockInEarlyMinutes"` // a clock-in this early still belongs to the coming shift
+}
+
+// ShiftTemplate is a recurring weekly shift that can be assigned to workers
+type ShiftTemplate struct {
+	IdTemplate   string    `json:"idTemplate"`
+	IdRestaurant string    `json:"idRestaurant"`
+	Label        string    `json:"label"`     // "morning", "evening"...
+	StartTime    string    `json:"startTime"` // "HH:MM"
+	EndTime      string    `json:"endTime"`   // "HH:MM", before StartTime for shifts ending the next day
+	Weekdays     []int     `json:"weekdays"`  // time.Weekday values, empty means every day
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// WorkerShift is a shift assigned to a worker
+type WorkerShift struct {
+	IdShift            string    `json:"idShift"`
+	IdRestaurant       string    `json:"idRestaurant"`
+	IdRestaurantWorker string    `json:"idRestaurantWorker"`
+	FirstName          string    `json:"firstName"`
+	LastName           string    `json:"lastName"`
+	IdTemplate         *string   `json:"idTemplate,omitempty"`
+	StartsAt           time.Time `json:"startsAt"`
+	EndsAt             time.Time `json:"endsAt"`
+	Note               string    `json:"note"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// ShiftConflict is a scheduling rule broken by two shifts of the same worker
+type ShiftConflict struct {
+	IdRestaurantWorker string    `json:"idRestaurantWorker"`
+	Kind               string    `json:"kind"` // "double_shift" when they overlap, "min_rest" when the break between them is too short
+	IdShift            string    `json:"idShift,omitempty"`
+	IdOtherShift       string    `json:"idOtherShift"`
+	StartsAt           time.Time `json:"startsAt"`
+	EndsAt             time.Time `json:"endsAt"`
+	OtherStartsAt      time.Time `json:"otherStartsAt"`
+	OtherEndsAt        time.Time `json:"otherEndsAt"`
+}
+
+// ShiftSwap is a request to hand a shift over to another worker
+type ShiftSwap struct {
+	IdSwap     string     `json:"idSwap"`
+	IdShift    string     `json:"idShift"`
+	FromWorker string     `json:"fromWorker"`
+	ToWorker   string     `json:"toWorker"`
+	Status     string     `json:"status"` // "pending", "accepted", "rejected" or "cancelled"
+	Note       string     `json:"note"`
+	StartsAt   time.Time  `json:"startsAt"`
+	EndsAt     time.Time  `json:"endsAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// WorkerClock is one clock-in/clock-out record of a worker
+type WorkerClock struct {
+	IdClock            string     `json:"idClock"`
+	IdRestaurantWorker string     `json:"idRestaurantWorker"`
+	IdRestaurant       string     `json:"idRestaurant"`
+	IdShift            *string    `json:"idShift,omitempty"`
+	ClockIn            time.Time  `json:"clockIn"`
+	ClockOut           *time.Time `json:"clockOut,omitempty"`
+	Hours              float64    `json:"hours"` // up to now while the worker is still clocked in
+}
+
+// Timesheet sums up the hours of a worker over a period
+type Timesheet struct {
+	IdRestaurantWorker string        `json:"idRestaurantWorker"`
+	From               string        `json:"from"`
+	To                 string        `json:"to"`
+	ScheduledHours     float64       `json:"scheduledHours"`
+	WorkedHours        float64       `json:"workedHours"`
+	Records            []WorkerClock `json:"records"`
+}
+
+// OnShiftWorker is a worker scheduled or clocked in right now
+type OnShiftWorker struct {
+	IdRestaurantWorker string     `json:"idRestaurantWorker"`
+	FirstName          string     `json:"firstName"`
+	LastName           string     `json:"lastName"`
+	Image              *string    `json:"image"`
+	IdShift            *string    `json:"idShift,omitempty"`
+	ShiftStartsAt      *time.Time `json:"shiftStartsAt,omitempty"`
+	ShiftEndsAt        *time.Time `json:"shiftEndsAt,omitempty"`
+	ClockedInAt        *time.Time `json:"clockedInAt,omitempty"`
+	Status             string     `json:"status"` // "working", "not_clocked_in" or "unscheduled"
+}
+
+// ModeratedReview is a review in the moderation queue, with the open flags raised against it
+type ModeratedReview struct {
+	IdRating         string     `json:"idRating"`
+	RatingType       string     `json:"ratingType"` // "restaurant", "activity" or "worker"
+	IdTarget         string     `json:"idTarget"`
+	TargetName       string     `json:"targetName"`
+	IdClient         string     `json:"idClient"`
+	FirstName        string     `json:"firstName"`
+	LastName         string     `json:"lastName"`
+	RatingValue      int        `json:"rating"`
+	Comment          string     `json:"comment"`
+	Reply            *string    `json:"reply,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	ModerationStatus string     `json:"moderationStatus"` // "visible" or "hidden"
+	ModerationNote   *string    `json:"moderationNote,omitempty"`
+	ModeratedAt      *time.Time `json:"moderatedAt,omitempty"`
+	OpenFlags        int        `json:"openFlags"` // flags raised against the text of the review
+	FlagReasons      []string   `json:"flagReasons"`
+
+	Photos []ReviewPhoto `json:"photos"`
+}
+
+// ReviewPhoto is a photo attached to a review
+type ReviewPhoto struct {
+	IdPhoto      string    `json:"idPhoto"`
+	IdRating     string    `json:"idRating"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	SortIndex    int       `json:"sortIndex"`
+	OpenFlags    int       `json:"openFlags,omitempty"` // only filled in the moderation queue
+	CreatedAt    time.Time `json:"createdAt"`
+	PublicID     string    `json:"-"`
+}
+
+// RevenueReport is the revenue of a restaurant over a date range, next to the range of the same length just before.
+// Orders count on the day they were placed, unless cancelled.
+type RevenueReport struct {
+	IdRestaurant string            `json:"idRestaurant"`
+	From         string            `json:"from"` // YYYY-MM-DD, both days included
+	To           string            `json:"to"`
+	GroupBy      string            `json:"groupBy"` // "day", "week" (starting on Monday) or "month"
+	Summary      RevenueSummary    `json:"summary"`
+	Previous     RevenueSummary    `json:"previous"`
+	Change       RevenueChange     `json:"change"`
+	Periods      []RevenuePeriod   `json:"periods"`
+	ByHour       []RevenueHour     `json:"byHour"`
+	ByFood       []FoodRevenue     `json:"byFood"`
+	ByCategory   []CategoryRevenue `json:"byCategory"`
+}
+
+type RevenueSummary struct {
+	From            string  `json:"from"`
+	To              string  `json:"to"`
+	Revenue         float64 `json:"revenue"`
+	Orders          int     `json:"orders"`
+	AverageTicket   float64 `json:"averageTicket"`
+	ItemsSold       int     `json:"itemsSold"`
+	DineInRevenue   float64 `json:"dineInRevenue"`
+	TakeawayRevenue float64 `json:"takeawayRevenue"`
+}
+
+// RevenueChange is the change from the previous period in percent, null when there was nothing to compare to
+type RevenueChange struct {
+	Revenue       *float64 `json:"revenue"`
+	Orders        *float64 `json:"orders"`
+	AverageTicket *float64 `json:"averageTicket"`
+}
+
+type RevenuePeriod struct {
+	Start         string  `json:"start"` // first day of the period
+	End           string  `json:"end"`   // last day, cut to the report range
+	Revenue       float64 `json:"revenue"`
+	Orders        int     `json:"orders"`
+	AverageTicket float64 `json:"averageTicket"`
+}
+
+type RevenueHour struct {
+	Hour          int     `json:"hour"`
+	Revenue       float64 `json:"revenue"`
+	Orders        int     `json:"orders"`
+	AverageTicket float64 `json:"averageTicket"`
+}
+
+// FoodRevenue is what the lines of a food brought, at the price paid. Share is the percent of the revenue of all lines.
+type FoodRevenue struct {
+	IdFood        string  `json:"idFood"`
+	Name          string  `json:"name"`
+	IdCategory    string  `json:"idCategory"`
+	NameCategorie string  `json:"nameCategorie"`
+	Quantity      int     `json:"quantity"`
+	Revenue       float64 `json:"revenue"`
+	Share         float64 `json:"share"`
+}
+
+type CategoryRevenue struct {
+	IdCategory    string  `json:"idCategory"`
+	NameCategorie string  `json:"nameCategorie"`
+	Quantity      int     `json:"quantity"`
+	Revenue       float64 `json:"revenue"`
+	Share         float64 `json:"share"`
+}
+
+// ListFilter narrows the lists of reservations, orders and bookings and their exports. To is exclusive, the
+// day after the last one asked for, and an empty Status keeps every status.
+type ListFilter struct {
+	From   *time.Time
+	To     *time.Time
+	Status string
+}
+
+// One order of a restaurant in an export
+type RestaurantOrderRow struct {
+	IdOrder    string    `json:"idOrder"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Source     string    `json:"source"` // reservation, table or takeaway
+	ClientName string    `json:"clientName"`
+	IdTable    string    `json:"idTable"`
+	Items      int       `json:"items"`
+	TotalPrice float64   `json:"totalPrice"`
+	Status     string    `json:"status"`
+}
+
+// DigestSettings are the digest emails an admin receives and when they are sent
+type DigestSettings struct {
+	IdProfile string `json:"idProfile"`
+	Daily     bool   `json:"daily"`
+	Weekly    bool   `json:"weekly"`
+	SendHour  int    `json:"sendHour"`  // local hour, 0 to 23
+	WeeklyDay int    `json:"weeklyDay"` // day of the weekly digest, 0 is Sunday
+}
+
+// DigestRecipient is an admin with the restaurants and activities their digest is about
+type DigestRecipient struct {
+	IdProfile       string
+	FirstName       string
+	Email           string
+	Restaurants     []DigestRestaurant
+	IdAdminActivity string // empty when the profile runs no activities
+	Settings        DigestSettings
+}
+
+type DigestRestaurant struct {
+	IdRestaurant string
+	Name         string
+}
+
+// AdminDigest is the content of a daily or weekly digest email
+type AdminDigest struct {
+	Frequency   string             `json:"frequency"` // daily or weekly
+	FirstName   string             `json:"firstName"`
+	From        string             `json:"from"` // days covered by the revenue, both included
+	To          string             `json:"to"`
+	GeneratedAt time.Time          `json:"generatedAt"`
+	Restaurants []RestaurantDigest `json:"restaurants"`
+	Activities  *ActivityDigest    `json:"activities,omitempty"`
+}
+
+type RestaurantDigest struct {
+	IdRestaurant string                   `json:"idRestaurant"`
+	Name         string                   `json:"name"`
+	Today        *RestaurantTodaySummary  `json:"today"`
+	Reservations *ReservationStatsAndList `json:"reservations"`
+	Ratings      *RestaurantRatingStats   `json:"ratings"`
+	Revenue      RevenueSummary           `json:"revenue"`
+}
+
+type ActivityDigest struct {
+	IdAdminActivity string         `json:"idAdminActivity"`
+	Stats           *ActivityStats `json:"stats"`
+}
+
+// DemandPoint is the demand of one hour of one day: covers, orders or bookings
+type DemandPoint struct {
+	Day   string  `json:"day"` // YYYY-MM-DD
+	Hour  int     `json:"hour"`
+	Value float64 `json:"value"`
+}
+
+// DemandForecast is the expected demand of a restaurant or an activity over the days to come
+type DemandForecast struct {
+	IdRestaurant string           `json:"idRestaurant,omitempty"`
+	IdActivity   string           `json:"idActivity,omitempty"`
+	From         string           `json:"from"`
+	To           string           `json:"to"`
+	HistoryWeeks int              `json:"historyWeeks"`
+	Series       []ForecastSeries `json:"series"`
+}
+
+// ForecastSeries is the forecast of one measure of demand, day by day and hour by hour
+type ForecastSeries struct {
+	Metric string        `json:"metric"` // covers, orders or bookings
+	Total  float64       `json:"total"`
+	Days   []ForecastDay `json:"days"`
+}
+
+type ForecastDay struct {
+	Date    string         `json:"date"`
+	Weekday string         `json:"weekday"`
+	Total   float64        `json:"total"`
+	Slots   []ForecastSlot `json:"slots"` // hours with some demand in the history only
+}
+
+// ForecastSlot is the expected demand of an hour, with the range it usually varies in
+type ForecastSlot struct {
+	Hour     int     `json:"hour"`
+	Expected float64 `json:"expected"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+}
+
+// ForecastAccuracy compares what was forecast for past days with what happened
+type ForecastAccuracy struct {
+	IdRestaurant string           `json:"idRestaurant,omitempty"`
+	IdActivity   string           `json:"idActivity,omitempty"`
+	From         string           `json:"from"`
+	To           string           `json:"to"`
+	HistoryWeeks int              `json:"historyWeeks"`
+	Metrics      []MetricAccuracy `json:"metrics"`
+}
+
+type MetricAccuracy struct {
+	Metric   string  `json:"metric"`
+	Forecast float64 `json:"forecast"`
+	Actual   float64 `json:"actual"`
+	// Mean absolute error per hour, over the hours forecast or with demand
+	MAE float64 `json:"mae"`
+	// Absolute errors over the actual demand and forecast over actual demand, in percent, null without demand
+	WAPE *float64      `json:"wape"`
+	Bias *float64      `json:"bias"`
+	Days []AccuracyDay `json:"days"`
+}
+
+type AccuracyDay struct {
+	Date     string  `json:"date"`
+	Forecast float64 `json:"forecast"`
+	Actual   float64 `json:"actual"`
+	Error    float64 `json:"error"` // absolute error summed over the hours of the day
+}
+
+// RestaurantSearch is what GET /restaurant/search filters and ranks the restaurants with, unset fields do not filter.
+// Distances are measured from Latitude and Longitude, prices are the average price of the foods, and a party size
+// with a time keeps the restaurants with room for the party then.
+type RestaurantSearch struct {
+	Query       string // words looked up in the name, description, location and food names
+	OpenNow     bool
+	MinRating   *float64
+	Latitude    *float64
+	Longitude   *float64
+	RadiusKm    *float64
+	DietaryTags []string // every tag is met by at least one food
+	MinPrice    *float64
+	MaxPrice    *float64
+	PartySize   int
+	At          *time.Time
+	Sort        string // relevance (the default), rating or distance
+	Page        int
+	Limit       int
+}
+
+type RestaurantSearchResult struct {
+	Restaurant
+	TotalRatings int      `json:"totalRatings"`
+	AveragePrice *float64 `json:"averagePrice"`
+	DistanceKm   *float64 `json:"distanceKm,omitempty"`
+	OpenNow      bool     `json:"openNow"`
+	MatchedFoods []string `json:"matchedFoods,omitempty"`
+	Relevance    float64  `json:"relevance"` // text match, 0 without a query
+	Score        float64  `json:"score"`     // what the results are ranked by, 0 to 1
+}
+
+type RestaurantSearchResponse struct {
+	Results     []RestaurantSearchResult `json:"results"`
+	CurrentPage int                      `json:"currentPage"`
+	TotalPages  int                      `json:"totalPages"`
+	TotalCount  int                      `json:"totalCount"`
 }
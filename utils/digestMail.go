@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	texttemplate "text/template"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// Digests list at most this many of the reservations of the day
+const digestReservationRows = 5
+
+var digestFuncs = map[string]interface{}{
+	"money": func(amount float64) string { return fmt.Sprintf("%.2f DZD", amount) },
+	"rate":  func(value float64) string { return fmt.Sprintf("%.1f", value) },
+	"title": func(frequency string) string {
+		if frequency == "daily" {
+			return "Daily digest"
+		}
+		return "Weekly digest"
+	},
+	"firstReservations": func(reservations []types.ReservationDetailsR) []types.ReservationDetailsR {
+		if len(reservations) > digestReservationRows {
+			return reservations[:digestReservationRows]
+		}
+		return reservations
+	},
+}
+
+var digestHTMLLayout = htmltemplate.Must(htmltemplate.New("digest").Funcs(digestFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>{{title .Frequency}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px; background-color: #f4f4f4;">
+    <div style="background: white; padding: 30px; border-radius: 10px;">
+        <div style="text-align: center; padding-bottom: 20px; border-bottom: 3px solid #e74c3c; margin-bottom: 30px;">
+            <div style="font-size: 32px; font-weight: bold; color: #e74c3c;">Zenciti</div>
+            <div style="font-size: 24px; color: #2c3e50;">{{title .Frequency}}</div>
+            <div style="color: #666;">{{if eq .From .To}}{{.From}}{{else}}{{.From}} to {{.To}}{{end}}</div>
+        </div>
+        <p>Hi {{.FirstName}}, here is how things are going.</p>
+        {{range .Restaurants}}
+        <h2 style="color: #2c3e50; border-bottom: 1px solid #eee;">{{.Name}}</h2>
+        <table style="width: 100%; border-collapse: collapse;">
+            <tr><td>Revenue</td><td style="text-align: right;"><strong>{{money .Revenue.Revenue}}</strong></td></tr>
+            <tr><td>Orders</td><td style="text-align: right;">{{.Revenue.Orders}} (average ticket {{money .Revenue.AverageTicket}})</td></tr>
+            <tr><td>Takeaway revenue</td><td style="text-align: right;">{{money .Revenue.TakeawayRevenue}}</td></tr>
+            {{with .Today}}
+            <tr><td>Reservations today</td><td style="text-align: right;">{{.TotalReservationsToday}} ({{.ConfirmedReservations}} confirmed, {{.PendingReservations}} pending)</td></tr>
+            <tr><td>Takeaway orders today</td><td style="text-align: right;">{{.TakeawayOrdersToday}}</td></tr>
+            {{end}}
+            {{with .Reservations}}
+            <tr><td>Upcoming reservations</td><td style="text-align: right;">{{.UpcomingReservation}}</td></tr>
+            <tr><td>Confirmation rate</td><td style="text-align: right;">{{rate .ConfirmedRate}}%</td></tr>
+            {{end}}
+            {{with .Ratings}}
+            <tr><td>Rating</td><td style="text-align: right;">{{rate .OverallAverage}} / 5 from {{.TotalRatings}} reviews</td></tr>
+            {{end}}
+        </table>
+        {{with .Reservations}}{{if .TodayReservations}}
+        <h3 style="color: #2c3e50;">Today's reservations</h3>
+        <ul>
+            {{range firstReservations .TodayReservations}}<li>{{.TimeFrom}} - {{.FirstName}} {{.LastName}}, {{.NumberOfPeople}} people</li>{{end}}
+        </ul>
+        {{end}}{{end}}
+        {{end}}
+        {{with .Activities}}{{with .Stats}}
+        <h2 style="color: #2c3e50; border-bottom: 1px solid #eee;">Activities</h2>
+        <table style="width: 100%; border-collapse: collapse;">
+            <tr><td>Bookings today</td><td style="text-align: right;"><strong>{{.BookingsToday}}</strong></td></tr>
+            <tr><td>Bookings this week</td><td style="text-align: right;">{{.BookingsThisWeek}}</td></tr>
+            <tr><td>Bookings this month</td><td style="text-align: right;">{{.BookingsThisMonth}}</td></tr>
+            <tr><td>Pending / completed / cancelled</td><td style="text-align: right;">{{.PendingBookings}} / {{.CompletedBookings}} / {{.CancelledBookings}}</td></tr>
+            <tr><td>Rating</td><td style="text-align: right;">{{rate .AverageRating}} / 5 from {{.TotalReviews}} reviews</td></tr>
+        </table>
+        {{end}}{{end}}
+        <p style="font-size: 12px; color: #888;">
+            You can change or turn off these digests from your admin settings.<br>
+            This is an automated message. Please do not reply to this email.
+        </p>
+    </div>
+</body>
+</html>`))
+
+var digestTextLayout = texttemplate.Must(texttemplate.New("digest").Funcs(digestFuncs).Parse(`{{title .Frequency}} - {{if eq .From .To}}{{.From}}{{else}}{{.From}} to {{.To}}{{end}}
+
+Hi {{.FirstName}}, here is how things are going.
+{{range .Restaurants}}
+{{.Name}}
+- Revenue: {{money .Revenue.Revenue}} from {{.Revenue.Orders}} orders (average ticket {{money .Revenue.AverageTicket}})
+- Takeaway revenue: {{money .Revenue.TakeawayRevenue}}
+{{- with .Today}}
+- Reservations today: {{.TotalReservationsToday}} ({{.ConfirmedReservations}} confirmed, {{.PendingReservations}} pending)
+- Takeaway orders today: {{.TakeawayOrdersToday}}
+{{- end}}
+{{- with .Reservations}}
+- Upcoming reservations: {{.UpcomingReservation}}, confirmation rate {{rate .ConfirmedRate}}%
+{{- end}}
+{{- with .Ratings}}
+- Rating: {{rate .OverallAverage}} / 5 from {{.TotalRatings}} reviews
+{{- end}}
+{{end}}
+{{- with .Activities}}{{with .Stats}}
+Activities
+- Bookings today: {{.BookingsToday}}, this week: {{.BookingsThisWeek}}, this month: {{.BookingsThisMonth}}
+- Pending / completed / cancelled: {{.PendingBookings}} / {{.CompletedBookings}} / {{.CancelledBookings}}
+- Rating: {{rate .AverageRating}} / 5 from {{.TotalReviews}} reviews
+{{end}}{{end}}
+You can change or turn off these digests from your admin settings.
+`))
+
+// RenderAdminDigest builds the subject, plain text and HTML bodies of an admin digest email
+func RenderAdminDigest(digest types.AdminDigest) (string, string, string, error) {
+	subject := "Zenciti - Your weekly digest"
+	if digest.Frequency == "daily" {
+		subject = "Zenciti - Your daily digest for " + digest.To
+	}
+
+	var text, html bytes.Buffer
+	if err := digestTextLayout.Execute(&text, digest); err != nil {
+		return "", "", "", fmt.Errorf("error rendering %s digest: %v", digest.Frequency, err)
+	}
+	if err := digestHTMLLayout.Execute(&html, digest); err != nil {
+		return "", "", "", fmt.Errorf("error rendering %s digest: %v", digest.Frequency, err)
+	}
+	return subject, text.String(), html.String(), nil
+}
+
+// SendAdminDigest renders and sends an admin digest email
+func SendAdminDigest(email string, digest types.AdminDigest) error {
+	subject, textBody, htmlBody, err := RenderAdminDigest(digest)
+	if err != nil {
+		return err
+	}
+	if err := sendMultipartEmail(email, subject, textBody, htmlBody); err != nil {
+		return err
+	}
+	log.Printf("Admin %s digest sent to %s", digest.Frequency, email)
+	return nil
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// Exported CSV files are flushed to the client every so many rows
+const exportFlushRows = 200
+
+// Exporter writes a table to a response as its rows are produced, in CSV or PDF
+type Exporter interface {
+	WriteRow(values ...string) error
+	Close() error
+}
+
+// ExportFormat reads ?format, "csv" by default
+func ExportFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "pdf" {
+		return "", fmt.Errorf("format must be csv or pdf")
+	}
+	return format, nil
+}
+
+var fileNamePattern = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// NewExporter sends the headers of a download named after name and the current day, and returns the writer
+// of its rows. filters describes the rows exported, it is printed under the title of a PDF.
+func NewExporter(w http.ResponseWriter, format, name, title, filters string, columns []string) Exporter {
+	fileName := fileNamePattern.ReplaceAllString(name, "-") + "-" + time.Now().Format("2006-01-02") + "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.WriteHeader(http.StatusOK)
+		return &pdfExporter{table: NewPDFTable(w, title, PDFSubtitle(filters), columns)}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	// The byte order mark makes spreadsheets read the file as UTF-8
+	w.Write([]byte("\xEF\xBB\xBF"))
+	exporter := &csvExporter{csv: csv.NewWriter(w), w: w}
+	exporter.WriteRow(columns...)
+	return exporter
+}
+
+type csvExporter struct {
+	csv  *csv.Writer
+	w    http.ResponseWriter
+	rows int
+}
+
+func (e *csvExporter) WriteRow(values ...string) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = csvCell(value)
+	}
+	if err := e.csv.Write(cells); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		e.csv.Flush()
+		if flusher, ok := e.w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	return e.csv.Error()
+}
+
+// Helper function to keep spreadsheets from running a cell as a formula. Comments and names come from clients, a
+// leading quote makes a value starting like a formula show as text.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (e *csvExporter) Close() error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+type pdfExporter struct {
+	table *PDFTable
+}
+
+func (e *pdfExporter) WriteRow(values ...string) error {
+	return e.table.WriteRow(values)
+}
+
+func (e *pdfExporter) Close() error {
+	return e.table.Close()
+}
+
+// ParseListFilter reads the ?from and ?to days (YYYY-MM-DD, both included) and the ?status shared by the lists of
+// reservations, orders and bookings and by their exports
+func ParseListFilter(r *http.Request) (types.ListFilter, error) {
+	var filter types.ListFilter
+	query := r.URL.Query()
+	if value := query.Get("from"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("from must use the YYYY-MM-DD format")
+		}
+		filter.From = &day
+	}
+	if value := query.Get("to"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("to must use the YYYY-MM-DD format")
+		}
+		next := day.AddDate(0, 0, 1)
+		filter.To = &next
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, fmt.Errorf("from must not be after to")
+	}
+	filter.Status = query.Get("status")
+	return filter, nil
+}
+
+// ListFilterClause turns a filter into the conditions to add to a WHERE, on the given time and status columns
+func ListFilterClause(filter types.ListFilter, timeColumn, statusColumn string) (string, []interface{}) {
+	var clause strings.Builder
+	var args []interface{}
+	if filter.From != nil {
+		clause.WriteString(" AND " + timeColumn + " >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		clause.WriteString(" AND " + timeColumn + " < ?")
+		args = append(args, *filter.To)
+	}
+	if filter.Status != "" {
+		clause.WriteString(" AND " + statusColumn + " = ?")
+		args = append(args, filter.Status)
+	}
+	return clause.String(), args
+}
+
+// DescribeListFilter tells which rows a filter keeps, for the header of an export
+func DescribeListFilter(filter types.ListFilter) string {
+	var parts []string
+	if filter.From != nil {
+		parts = append(parts, "from "+filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		parts = append(parts, "to "+filter.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	if filter.Status != "" {
+		parts = append(parts, "status "+filter.Status)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ExportTime formats the times of exported rows
+func ExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
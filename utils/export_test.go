@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+func TestListFilterClause(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		filter     types.ListFilter
+		wantClause string
+		wantArgs   []interface{}
+	}{
+		{"no filter", types.ListFilter{}, "", nil},
+		{"from", types.ListFilter{From: &from}, " AND r.timeFrom >= ?", []interface{}{from}},
+		{"to", types.ListFilter{To: &to}, " AND r.timeFrom < ?", []interface{}{to}},
+		{"status", types.ListFilter{Status: "confirmed"}, " AND r.status = ?", []interface{}{"confirmed"}},
+		{
+			"everything",
+			types.ListFilter{From: &from, To: &to, Status: "confirmed"},
+			" AND r.timeFrom >= ? AND r.timeFrom < ? AND r.status = ?",
+			[]interface{}{from, to, "confirmed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, args := ListFilterClause(tt.filter, "r.timeFrom", "r.status")
+			if clause != tt.wantClause || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("ListFilterClause = %q, %v, want %q, %v", clause, args, tt.wantClause, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCSVExporterEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Great pizza", "Great pizza"},
+		{"=HYPERLINK(\"http://evil.example\",\"click\")", "'=HYPERLINK(\"http://evil.example\",\"click\")"},
+		{"+33 6 12 34 56 78", "'+33 6 12 34 56 78"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"a=b", "a=b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			exporter := &csvExporter{csv: csv.NewWriter(rec), w: rec}
+			if err := exporter.WriteRow(tt.value, "12.50"); err != nil {
+				t.Fatalf("WriteRow: %v", err)
+			}
+			if err := exporter.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			record, err := csv.NewReader(strings.NewReader(rec.Body.String())).Read()
+			if err != nil {
+				t.Fatalf("reading the row back: %v", err)
+			}
+			if record[0] != tt.want || record[1] != "12.50" {
+				t.Errorf("cells = %q, want %q and %q", record, tt.want, "12.50")
+			}
+		})
+	}
+}
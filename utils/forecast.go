@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+const (
+	ForecastDays        = 14
+	DefaultHistoryWeeks = 8
+	MaxHistoryWeeks     = 52
+	MaxAccuracyDays     = 56
+	// Each week back counts this much less than the one after it
+	forecastDecay = 0.8
+)
+
+// ForecastWeeks reads ?weeks, how many weeks of history a forecast is made from
+func ForecastWeeks(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("weeks")
+	if value == "" {
+		return DefaultHistoryWeeks, nil
+	}
+	weeks, err := strconv.Atoi(value)
+	if err != nil || weeks < 1 || weeks > MaxHistoryWeeks {
+		return 0, fmt.Errorf("weeks must be between 1 and %d", MaxHistoryWeeks)
+	}
+	return weeks, nil
+}
+
+// AccuracyDays reads ?days, how many past days the accuracy of a forecast is measured over
+func AccuracyDays(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return ForecastDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > MaxAccuracyDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", MaxAccuracyDays)
+	}
+	return days, nil
+}
+
+// Today is the midnight forecasts start from
+func Today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// ForecastDemand forecasts days of demand from start (a midnight) with the history of the weeks before it. The
+// demand of an hour is the weighted average of the same hour on the same weekday over those weeks, the recent
+// ones weighing more, and its range is one standard deviation around it. Days without demand count as zeros.
+func ForecastDemand(metric string, history []types.DemandPoint, start time.Time, days, weeks int) types.ForecastSeries {
+	values := make(map[string]map[int]float64)
+	for _, point := range history {
+		if values[point.Day] == nil {
+			values[point.Day] = make(map[int]float64)
+		}
+		values[point.Day][point.Hour] += point.Value
+	}
+
+	series := types.ForecastSeries{Metric: metric, Days: make([]types.ForecastDay, 0, days)}
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		forecastDay := types.ForecastDay{
+			Date:    day.Format("2006-01-02"),
+			Weekday: day.Weekday().String(),
+			Slots:   []types.ForecastSlot{},
+		}
+
+		// The same weekday in each week of history, most recent first. Days of the second week ahead are
+		// forecast from the same days as the first one.
+		var samples []map[int]float64
+		hours := make(map[int]bool)
+		for week := 1; week <= weeks; week++ {
+			past := start.AddDate(0, 0, i%7-7*week)
+			sample := values[past.Format("2006-01-02")]
+			samples = append(samples, sample)
+			for hour := range sample {
+				hours[hour] = true
+			}
+		}
+
+		for _, hour := range sortedHours(hours) {
+			expected, deviation := weightedStats(samples, hour)
+			slot := types.ForecastSlot{
+				Hour:     hour,
+				Expected: roundDemand(expected),
+				Low:      roundDemand(math.Max(0, expected-deviation)),
+				High:     roundDemand(expected + deviation),
+			}
+			forecastDay.Slots = append(forecastDay.Slots, slot)
+			forecastDay.Total += slot.Expected
+		}
+		forecastDay.Total = roundDemand(forecastDay.Total)
+		series.Total += forecastDay.Total
+		series.Days = append(series.Days, forecastDay)
+	}
+	series.Total = roundDemand(series.Total)
+	return series
+}
+
+// ForecastAccuracy compares a forecast with the demand there actually was on its days
+func ForecastAccuracy(forecast types.ForecastSeries, actual []types.DemandPoint) types.MetricAccuracy {
+	actuals := make(map[string]map[int]float64)
+	for _, point := range actual {
+		if actuals[point.Day] == nil {
+			actuals[point.Day] = make(map[int]float64)
+		}
+		actuals[point.Day][point.Hour] += point.Value
+	}
+
+	accuracy := types.MetricAccuracy{Metric: forecast.Metric, Days: make([]types.AccuracyDay, 0, len(forecast.Days))}
+	var absError float64
+	var slots int
+	for _, day := range forecast.Days {
+		// Every hour forecast or with demand
+		expected := make(map[int]float64)
+		for _, slot := range day.Slots {
+			expected[slot.Hour] = slot.Expected
+		}
+		hours := make(map[int]bool)
+		for hour := range expected {
+			hours[hour] = true
+		}
+		for hour := range actuals[day.Date] {
+			hours[hour] = true
+		}
+
+		accuracyDay := types.AccuracyDay{Date: day.Date, Forecast: day.Total}
+		for hour := range hours {
+			value := actuals[day.Date][hour]
+			accuracyDay.Actual += value
+			accuracyDay.Error += math.Abs(expected[hour] - value)
+		}
+		slots += len(hours)
+		absError += accuracyDay.Error
+		accuracy.Forecast += accuracyDay.Forecast
+		accuracy.Actual += accuracyDay.Actual
+		accuracyDay.Actual = roundDemand(accuracyDay.Actual)
+		accuracyDay.Error = roundDemand(accuracyDay.Error)
+		accuracy.Days = append(accuracy.Days, accuracyDay)
+	}
+
+	if slots > 0 {
+		accuracy.MAE = roundDemand(absError / float64(slots))
+	}
+	if accuracy.Actual > 0 {
+		wape := roundDemand(absError / accuracy.Actual * 100)
+		bias := roundDemand((accuracy.Forecast - accuracy.Actual) / accuracy.Actual * 100)
+		accuracy.WAPE, accuracy.Bias = &wape, &bias
+	}
+	accuracy.Forecast = roundDemand(accuracy.Forecast)
+	accuracy.Actual = roundDemand(accuracy.Actual)
+	return accuracy
+}
+
+// Helper function to get the weighted mean and standard deviation of an hour over weekly samples, most recent first
+func weightedStats(samples []map[int]float64, hour int) (float64, float64) {
+	var sum, weights float64
+	weight := 1.0
+	for _, sample := range samples {
+		sum += weight * sample[hour]
+		weights += weight
+		weight *= forecastDecay
+	}
+	if weights == 0 {
+		return 0, 0
+	}
+	mean := sum / weights
+
+	var variance float64
+	weight = 1.0
+	for _, sample := range samples {
+		variance += weight * (sample[hour] - mean) * (sample[hour] - mean)
+		weight *= forecastDecay
+	}
+	return mean, math.Sqrt(variance / weights)
+}
+
+func sortedHours(hours map[int]bool) []int {
+	sorted := make([]int, 0, len(hours))
+	for hour := range hours {
+		sorted = append(sorted, hour)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
+
+func roundDemand(value float64) float64 {
+	return math.Round(value*100) / 100
+}
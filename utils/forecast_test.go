@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+func TestForecastDemand(t *testing.T) {
+	// 2024-05-13 is a Monday, the two weeks of history are the Mondays before it
+	start := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		history []types.DemandPoint
+		want    []types.ForecastSlot
+	}{
+		{"no history", nil, []types.ForecastSlot{}},
+		{
+			"steady demand",
+			[]types.DemandPoint{{Day: "2024-05-06", Hour: 12, Value: 10}, {Day: "2024-04-29", Hour: 12, Value: 10}},
+			[]types.ForecastSlot{{Hour: 12, Expected: 10, Low: 10, High: 10}},
+		},
+		{
+			"recent weeks weigh more and missing days count as zeros",
+			[]types.DemandPoint{{Day: "2024-05-06", Hour: 12, Value: 10}},
+			[]types.ForecastSlot{{Hour: 12, Expected: 5.56, Low: 0.59, High: 10.52}},
+		},
+		{
+			"points of an hour add up and hours are sorted",
+			[]types.DemandPoint{
+				{Day: "2024-05-06", Hour: 20, Value: 4},
+				{Day: "2024-05-06", Hour: 12, Value: 2},
+				{Day: "2024-05-06", Hour: 12, Value: 2},
+				{Day: "2024-04-29", Hour: 20, Value: 4},
+				{Day: "2024-04-29", Hour: 12, Value: 4},
+			},
+			[]types.ForecastSlot{{Hour: 12, Expected: 4, Low: 4, High: 4}, {Hour: 20, Expected: 4, Low: 4, High: 4}},
+		},
+		{"other weekdays are left out", []types.DemandPoint{{Day: "2024-05-07", Hour: 12, Value: 10}}, []types.ForecastSlot{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := ForecastDemand("covers", tt.history, start, 8, 2)
+			if len(series.Days) != 8 {
+				t.Fatalf("got %d days, want 8", len(series.Days))
+			}
+			first, nextWeek := series.Days[0], series.Days[7]
+			if first.Date != "2024-05-13" || first.Weekday != "Monday" {
+				t.Errorf("first day = %s %s, want 2024-05-13 Monday", first.Date, first.Weekday)
+			}
+			if !reflect.DeepEqual(first.Slots, tt.want) {
+				t.Errorf("slots = %+v, want %+v", first.Slots, tt.want)
+			}
+			if !reflect.DeepEqual(nextWeek.Slots, first.Slots) {
+				t.Errorf("the next Monday = %+v, want the same as the first %+v", nextWeek.Slots, first.Slots)
+			}
+		})
+	}
+}
+
+func TestForecastAccuracy(t *testing.T) {
+	forecast := types.ForecastSeries{
+		Metric: "covers",
+		Total:  14,
+		Days: []types.ForecastDay{{
+			Date:  "2024-05-13",
+			Total: 14,
+			Slots: []types.ForecastSlot{{Hour: 12, Expected: 10}, {Hour: 13, Expected: 4}},
+		}},
+	}
+	pct := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name     string
+		actual   []types.DemandPoint
+		wantMAE  float64
+		wantWAPE *float64
+		wantBias *float64
+		wantErr  float64
+	}{
+		{
+			"hours forecast or with demand",
+			[]types.DemandPoint{{Day: "2024-05-13", Hour: 12, Value: 8}, {Day: "2024-05-13", Hour: 14, Value: 2}},
+			2.67, pct(80), pct(40), 8,
+		},
+		{
+			"exact",
+			[]types.DemandPoint{{Day: "2024-05-13", Hour: 12, Value: 10}, {Day: "2024-05-13", Hour: 13, Value: 4}},
+			0, pct(0), pct(0), 0,
+		},
+		{"no demand", nil, 7, nil, nil, 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ForecastAccuracy(forecast, tt.actual)
+			if got.MAE != tt.wantMAE || !reflect.DeepEqual(got.WAPE, tt.wantWAPE) || !reflect.DeepEqual(got.Bias, tt.wantBias) {
+				t.Errorf("ForecastAccuracy = MAE %v WAPE %v bias %v, want %v %v %v",
+					got.MAE, got.WAPE, got.Bias, tt.wantMAE, tt.wantWAPE, tt.wantBias)
+			}
+			if len(got.Days) != 1 || got.Days[0].Error != tt.wantErr {
+				t.Errorf("days = %+v, want one day with an error of %v", got.Days, tt.wantErr)
+			}
+		})
+	}
+}
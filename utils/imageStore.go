@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/wael-boudissaa/zencitiBackend/types"
+)
+
+// Images larger than this are refused
+const MaxImageSize = 5 << 20
+
+// Accepted image types, by the content type sniffed from the file, with the extension they are saved with
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// Public IDs are paths of letters, digits, dashes and underscores, like "foods/<idFood>"
+var publicIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+
+// NewImageStore returns the image store picked by IMAGE_STORE: "cloudinary" (the default, which needs
+// CLOUDINARY_URL) or "local"
+func NewImageStore() (types.ImageStore, error) {
+	switch name := os.Getenv("IMAGE_STORE"); name {
+	case "", "cloudinary":
+		url, err := cloudinaryURL()
+		if err != nil {
+			return nil, err
+		}
+		return NewCloudinaryImageStore(url)
+	case "local":
+		dir := os.Getenv("IMAGE_STORE_DIR")
+		if dir == "" {
+			dir = "static"
+		}
+		return NewLocalImageStore(dir, os.Getenv("IMAGE_BASE_URL")), nil
+	default:
+		return nil, fmt.Errorf("unknown image store %s", name)
+	}
+}
+
+// ImageID is the public ID of the image of an entity, e.g. ImageID("restaurants", idRestaurant)
+func ImageID(kind string, ids ...string) string {
+	return kind + "/" + strings.Join(ids, "/")
+}
+
+// ReadImage reads an uploaded image and returns it with its content type. The type is sniffed from the
+// content, the one declared by the client is not trusted. Errors about the image itself start with "invalid image".
+func ReadImage(file io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading image: %v", err)
+	}
+	if len(data) > MaxImageSize {
+		return nil, "", fmt.Errorf("invalid image: larger than %d MB", MaxImageSize>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := imageExtensions[contentType]; !ok {
+		return nil, "", fmt.Errorf("invalid image: must be a JPEG, PNG, WebP or GIF file")
+	}
+	return data, contentType, nil
+}
+
+// SaveImage checks an uploaded image and saves it under publicID
+func SaveImage(store types.ImageStore, publicID string, file io.Reader) (*types.StoredImage, error) {
+	data, contentType, err := ReadImage(file)
+	if err != nil {
+		return nil, err
+	}
+	return store.Save(publicID, data, contentType)
+}
+
+// ImageErrorStatus is the status code to answer with when SaveImage fails
+func ImageErrorStatus(err error) int {
+	if strings.HasPrefix(err.Error(), "invalid image") {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// DeleteReplacedImage deletes the image an entity had before it was given the one saved under publicID.
+// Images of another store or already overwritten in place are left alone, and failures are only logged
+// since the entity already points to its new image.
+func DeleteReplacedImage(store types.ImageStore, oldURL, publicID string) {
+	oldID, ok := store.PublicID(oldURL)
+	if !ok || oldID == publicID {
+		return
+	}
+	if err := store.Delete(oldID); err != nil {
+		log.Printf("Error deleting replaced image %s: %v", oldID, err)
+	}
+}
+
+// DeleteImages cleans up images that were saved for a change that did not go through, failures are only logged
+func DeleteImages(store types.ImageStore, publicIDs ...string) {
+	for _, publicID := range publicIDs {
+		if err := store.Delete(publicID); err != nil {
+			log.Printf("Error deleting image %s: %v", publicID, err)
+		}
+	}
+}
+
+// CloudinaryImageStore keeps images on Cloudinary and serves thumbnails with a transformation
+type CloudinaryImageStore struct {
+	cld       *cloudinary.Cloudinary
+	thumbnail string
+}
+
+func NewCloudinaryImageStore(url string) (*CloudinaryImageStore, error) {
+	cld, err := cloudinary.NewFromURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing Cloudinary: %v", err)
+	}
+	cld.Config.URL.Secure = true
+	return &CloudinaryImageStore{cld: cld, thumbnail: "c_fill,w_320,h_320,q_auto,f_auto"}, nil
+}
+
+func (s *CloudinaryImageStore) Name() string {
+	return "cloudinary"
+}
+
+func (s *CloudinaryImageStore) Save(publicID string, data []byte, contentType string) (*types.StoredImage, error) {
+	if !publicIDPattern.MatchString(publicID) {
+		return nil, fmt.Errorf("bad image public ID %s", publicID)
+	}
+	resp, err := s.cld.Upload.Upload(context.Background(), bytes.NewReader(data), uploader.UploadParams{
+		PublicID:       publicID,
+		UniqueFilename: api.Bool(false),
+		Overwrite:      api.Bool(true),
+		Invalidate:     api.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error uploading image %s: %v", publicID, err)
+	}
+	if resp.Error.Message != "" {
+		return nil, fmt.Errorf("error uploading image %s: %s", publicID, resp.Error.Message)
+	}
+	return &types.StoredImage{
+		URL:          resp.SecureURL,
+		ThumbnailURL: strings.Replace(resp.SecureURL, "/upload/", "/upload/"+s.thumbnail+"/", 1),
+		PublicID:     publicID,
+	}, nil
+}
+
+func (s *CloudinaryImageStore) Delete(publicID string) error {
+	resp, err := s.cld.Upload.Destroy(context.Background(), uploader.DestroyParams{
+		PublicID:   publicID,
+		Invalidate: api.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting image %s: %v", publicID, err)
+	}
+	if resp.Result != "ok" && resp.Result != "not found" {
+		return fmt.Errorf("error deleting image %s: %s %s", publicID, resp.Result, resp.Error.Message)
+	}
+	return nil
+}
+
+// PublicID reads the public ID back from a delivery URL of the account:
+// https://res.cloudinary.com/<cloud>/image/upload/v1712345678/<publicID>.jpg
+func (s *CloudinaryImageStore) PublicID(url string) (string, bool) {
+	url = strings.Replace(url, "http://", "https://", 1)
+	rest, found := strings.CutPrefix(url, "https://res.cloudinary.com/"+s.cld.Config.Cloud.CloudName+"/image/upload/")
+	if !found {
+		return "", false
+	}
+	if version, after, ok := strings.Cut(rest, "/"); ok && strings.HasPrefix(version, "v") {
+		if _, err := strconv.Atoi(version[1:]); err == nil {
+			rest = after
+		}
+	}
+	publicID := strings.TrimSuffix(rest, path.Ext(rest))
+	return publicID, publicID != ""
+}
+
+// LocalImageStore keeps images on the local disk, under the uploads folder of the directory served as static files
+type LocalImageStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalImageStore saves images in <dir>/uploads. baseURL is put in front of their paths, leave it empty for
+// URLs relative to the API.
+func NewLocalImageStore(dir, baseURL string) *LocalImageStore {
+	return &LocalImageStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *LocalImageStore) Name() string {
+	return "local"
+}
+
+// Helper function to get the path of an image without its extension
+func (s *LocalImageStore) basePath(publicID string) string {
+	return filepath.Join(s.dir, "uploads", filepath.FromSlash(publicID))
+}
+
+func (s *LocalImageStore) Save(publicID string, data []byte, contentType string) (*types.StoredImage, error) {
+	if !publicIDPattern.MatchString(publicID) {
+		return nil, fmt.Errorf("bad image public ID %s", publicID)
+	}
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	base := s.basePath(publicID)
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating image folder: %v", err)
+	}
+
+	// Write next to the target and rename, so that the previous image is served until the new one is complete
+	tmp, err := os.CreateTemp(filepath.Dir(base), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("error saving image %s: %v", publicID, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("error saving image %s: %v", publicID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("error saving image %s: %v", publicID, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return nil, fmt.Errorf("error saving image %s: %v", publicID, err)
+	}
+	if err := os.Rename(tmp.Name(), base+ext); err != nil {
+		return nil, fmt.Errorf("error saving image %s: %v", publicID, err)
+	}
+
+	// The previous image may have had another type
+	if err := s.removeFiles(base, base+ext); err != nil {
+		return nil, err
+	}
+
+	// The version busts caches since the path stays the same when an image is replaced
+	url := s.baseURL + "/uploads/" + publicID + ext + "?v=" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	return &types.StoredImage{URL: url, ThumbnailURL: url, PublicID: publicID}, nil
+}
+
+func (s *LocalImageStore) Delete(publicID string) error {
+	if !publicIDPattern.MatchString(publicID) {
+		return fmt.Errorf("bad image public ID %s", publicID)
+	}
+	return s.removeFiles(s.basePath(publicID), "")
+}
+
+// Helper function to remove the files saved under a base path, whatever their extension, except keep
+func (s *LocalImageStore) removeFiles(base, keep string) error {
+	for _, ext := range imageExtensions {
+		if base+ext == keep {
+			continue
+		}
+		if err := os.Remove(base + ext); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error deleting image %s: %v", base+ext, err)
+		}
+	}
+	return nil
+}
+
+func (s *LocalImageStore) PublicID(url string) (string, bool) {
+	rest, found := strings.CutPrefix(url, s.baseURL+"/uploads/")
+	if !found {
+		return "", false
+	}
+	rest, _, _ = strings.Cut(rest, "?")
+	publicID := strings.TrimSuffix(rest, path.Ext(rest))
+	return publicID, publicIDPattern.MatchString(publicID)
+}
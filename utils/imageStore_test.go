@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestLocalImageStorePublicID(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		url     string
+		want    string
+		wantOK  bool
+	}{
+		{"relative url", "", "/uploads/foods/abc-123.jpg?v=lx2k", "foods/abc-123", true},
+		{"absolute url", "https://cdn.example.com/", "https://cdn.example.com/uploads/restaurants/r1.png?v=1", "restaurants/r1", true},
+		{"without version", "", "/uploads/workers/w1.webp", "workers/w1", true},
+		{"nested id", "", "/uploads/reviews/r1/p1.jpg", "reviews/r1/p1", true},
+		{"another host", "https://cdn.example.com", "https://other.example.com/uploads/foods/f1.jpg", "", false},
+		{"cloudinary url", "", "https://res.cloudinary.com/demo/image/upload/v1/foods/f1.jpg", "", false},
+		{"path traversal", "", "/uploads/../secret.jpg", "../secret", false},
+		{"empty", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewLocalImageStore(t.TempDir(), tt.baseURL)
+			got, ok := store.PublicID(tt.url)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("PublicID(%q) = %q, %v, want %q, %v", tt.url, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Landscape A4 in points, Helvetica text
+const (
+	pdfPageWidth  = 842.0
+	pdfPageHeight = 595.0
+	pdfMargin     = 36.0
+	pdfFontSize   = 8.0
+	pdfRowHeight  = 13.0
+	pdfTitleSize  = 14.0
+)
+
+// Widths of the Helvetica glyphs from ' ' to '~' in thousandths of the font size, the others count as 556
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// PDFTable writes a table as a PDF document, one page at a time, so that long reports are streamed to the client
+// instead of being built in memory. Column widths are fitted to the rows of the first page.
+type PDFTable struct {
+	w        *countingWriter
+	title    string
+	subtitle string
+	columns  []string
+	widths   []float64
+	pending  [][]string // rows of the first page, kept until the widths are known
+	page     bytes.Buffer
+	y        float64
+	offsets  map[int]int64
+	pages    []int
+	nextID   int
+	started  bool
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Objects 1 to 4 are the catalog, the page tree (written last) and the two fonts
+const pdfFirstFreeObject = 5
+
+func NewPDFTable(w io.Writer, title, subtitle string, columns []string) *PDFTable {
+	return &PDFTable{
+		w:        &countingWriter{w: w},
+		title:    title,
+		subtitle: subtitle,
+		columns:  columns,
+		offsets:  make(map[int]int64),
+		nextID:   pdfFirstFreeObject,
+	}
+}
+
+// Helper function to get how many rows fit on a page, the first one also carries the title
+func (t *PDFTable) rowsPerPage(first bool) int {
+	height := pdfPageHeight - 2*pdfMargin - 2*pdfRowHeight // header and footer
+	if first {
+		height -= 2.5 * pdfRowHeight
+	}
+	return int(height / pdfRowHeight)
+}
+
+func (t *PDFTable) WriteRow(values []string) error {
+	if t.widths == nil {
+		t.pending = append(t.pending, values)
+		if len(t.pending) < t.rowsPerPage(true) {
+			return nil
+		}
+		return t.flushPending()
+	}
+	if t.y-pdfRowHeight < pdfMargin+pdfRowHeight {
+		if err := t.endPage(); err != nil {
+			return err
+		}
+		t.beginPage(false)
+	}
+	t.drawRow(values, false)
+	return nil
+}
+
+// Helper function to fit the columns to the first rows and draw them
+func (t *PDFTable) flushPending() error {
+	t.widths = pdfColumnWidths(t.columns, t.pending)
+	if err := t.start(); err != nil {
+		return err
+	}
+	t.beginPage(true)
+	for _, row := range t.pending {
+		t.drawRow(row, false)
+	}
+	t.pending = nil
+	return nil
+}
+
+// Close draws what is left and writes the page tree and the cross-reference table
+func (t *PDFTable) Close() error {
+	if t.widths == nil {
+		if err := t.flushPending(); err != nil {
+			return err
+		}
+	}
+	if err := t.endPage(); err != nil {
+		return err
+	}
+
+	kids := make([]string, len(t.pages))
+	for i, id := range t.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	if err := t.writeObject(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(t.pages))); err != nil {
+		return err
+	}
+
+	xref := t.w.n
+	var b strings.Builder
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", t.nextID)
+	for id := 1; id < t.nextID; id++ {
+		fmt.Fprintf(&b, "%010d 00000 n \n", t.offsets[id])
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", t.nextID, xref)
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+func (t *PDFTable) start() error {
+	if t.started {
+		return nil
+	}
+	t.started = true
+	if _, err := io.WriteString(t.w, "%PDF-1.4\n"); err != nil {
+		return err
+	}
+	if err := t.writeObject(1, "<< /Type /Catalog /Pages 2 0 R >>"); err != nil {
+		return err
+	}
+	if err := t.writeObject(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"); err != nil {
+		return err
+	}
+	return t.writeObject(4, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+}
+
+func (t *PDFTable) writeObject(id int, body string) error {
+	t.offsets[id] = t.w.n
+	_, err := fmt.Fprintf(t.w, "%d 0 obj\n%s\nendobj\n", id, body)
+	return err
+}
+
+func (t *PDFTable) beginPage(first bool) {
+	t.page.Reset()
+	t.y = pdfPageHeight - pdfMargin
+	if first {
+		t.y -= pdfTitleSize
+		t.text(pdfMargin, t.y, true, pdfTitleSize, t.title)
+		t.y -= pdfRowHeight
+		t.text(pdfMargin, t.y, false, pdfFontSize, t.subtitle)
+		t.y -= pdfRowHeight
+	}
+	t.drawRow(t.columns, true)
+	// Rule under the header
+	fmt.Fprintf(&t.page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, t.y+3, pdfPageWidth-pdfMargin, t.y+3)
+}
+
+func (t *PDFTable) endPage() error {
+	if t.page.Len() == 0 {
+		return nil
+	}
+	t.text(pdfMargin, pdfMargin-pdfRowHeight/2, false, pdfFontSize, fmt.Sprintf("Page %d", len(t.pages)+1))
+
+	contentID := t.nextID
+	pageID := t.nextID + 1
+	t.nextID += 2
+	content := fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", t.page.Len(), t.page.String())
+	if err := t.writeObject(contentID, content); err != nil {
+		return err
+	}
+	err := t.writeObject(pageID, fmt.Sprintf(
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+		pdfPageWidth, pdfPageHeight, contentID))
+	if err != nil {
+		return err
+	}
+	t.pages = append(t.pages, pageID)
+	t.page.Reset()
+	return nil
+}
+
+func (t *PDFTable) drawRow(values []string, bold bool) {
+	t.y -= pdfRowHeight
+	x := pdfMargin
+	for i, width := range t.widths {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		t.text(x, t.y, bold, pdfFontSize, pdfFit(value, width-4))
+		x += width
+	}
+}
+
+func (t *PDFTable) text(x, y float64, bold bool, size float64, value string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&t.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(value))
+}
+
+// Helper function to share the page width between the columns, in proportion to the text they hold
+func pdfColumnWidths(columns []string, rows [][]string) []float64 {
+	needed := make([]float64, len(columns))
+	for i, column := range columns {
+		needed[i] = pdfTextWidth(column) + 8
+	}
+	for _, row := range rows {
+		for i := range columns {
+			if i < len(row) {
+				if width := pdfTextWidth(row[i]) + 8; width > needed[i] {
+					needed[i] = width
+				}
+			}
+		}
+	}
+
+	// A long text column is cut rather than squeezing the others
+	available := pdfPageWidth - 2*pdfMargin
+	total := 0.0
+	for i, width := range needed {
+		needed[i] = math.Min(width, available/3)
+		total += needed[i]
+	}
+	widths := make([]float64, len(columns))
+	for i, width := range needed {
+		widths[i] = width * available / total
+	}
+	return widths
+}
+
+func pdfTextWidth(value string) float64 {
+	width := 0
+	for _, r := range value {
+		if r >= ' ' && r <= '~' {
+			width += helveticaWidths[r-' ']
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * pdfFontSize / 1000
+}
+
+// Helper function to cut a text to a width, marking the cut with dots
+func pdfFit(value string, width float64) string {
+	if pdfTextWidth(value) <= width {
+		return value
+	}
+	runes := []rune(value)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// Helper function to encode a text for a PDF string in WinAnsiEncoding, what it cannot show becomes '?'
+func pdfEscape(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '€':
+			b.WriteString("\\200")
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// PDFSubtitle is the line under the title of an exported report
+func PDFSubtitle(filters string) string {
+	subtitle := "Generated on " + time.Now().Format("2006-01-02 15:04")
+	if filters != "" {
+		subtitle += " - " + filters
+	}
+	return subtitle
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPdfEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Table 4", "Table 4"},
+		{"(a) b\\c", `\(a\) b\\c`},
+		{"Crème brûlée", `Cr\350me br\373l\351e`},
+		{"12 €", `12 \200`},
+		{"寿司", "??"},
+		{"tab\there", "tab?here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := pdfEscape(tt.value); got != tt.want {
+				t.Errorf("pdfEscape(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPdfFit(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		width   float64
+		wantCut bool
+	}{
+		{"fits", "Pizza", 100, false},
+		{"exact width", "Pizza", pdfTextWidth("Pizza"), false},
+		{"too wide", "A very long comment about the pizza", 40, true},
+		{"accents", "Crème brûlée maison au caramel", 30, true},
+		{"no room", "Pizza", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pdfFit(tt.value, tt.width)
+			if !tt.wantCut {
+				if got != tt.value {
+					t.Errorf("pdfFit(%q, %v) = %q, want it unchanged", tt.value, tt.width, got)
+				}
+				return
+			}
+			kept, cut := strings.CutSuffix(got, "...")
+			if !cut || !strings.HasPrefix(tt.value, kept) {
+				t.Errorf("pdfFit(%q, %v) = %q, want a prefix followed by dots", tt.value, tt.width, got)
+			}
+			if kept != "" && pdfTextWidth(got) > tt.width {
+				t.Errorf("pdfFit(%q, %v) = %q, %v wide", tt.value, tt.width, got, pdfTextWidth(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Words masked in reviews and replies, in English and French. Variants are listed rather than matched
+// with wildcards so that words merely containing one of them are left alone.
+var profaneWords = []string{
+	"fuck", "fucking", "fucker", "motherfucker", "shit", "shitty", "bullshit", "bitch", "bitches",
+	"asshole", "bastard", "cunt", "dick", "dickhead", "whore", "slut", "wanker", "twat",
+	"merde", "putain", "pute", "connard", "connasse", "salope", "salaud", "encule", "enculé", "batard", "bâtard",
+	"nique", "niquer", "fdp", "ntm",
+}
+
+var profanityPattern = regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}])(` + strings.Join(quoteWords(profaneWords), "|") + `)($|[^\p{L}\p{N}])`)
+
+func quoteWords(words []string) []string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	return quoted
+}
+
+// FilterProfanity masks the profane words of a text, keeping their first letter ("s***"), and tells
+// whether anything was masked
+func FilterProfanity(text string) (string, bool) {
+	filtered := false
+	// Matches share their delimiters, so adjacent words need a second pass
+	for i := 0; i < 2; i++ {
+		text = profanityPattern.ReplaceAllStringFunc(text, func(match string) string {
+			parts := profanityPattern.FindStringSubmatch(match)
+			word := []rune(parts[2])
+			filtered = true
+			return parts[1] + string(word[0]) + strings.Repeat("*", len(word)-1) + parts[3]
+		})
+	}
+	return text, filtered
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestFilterProfanity(t *testing.T) {
+	tests := []struct {
+		text         string
+		want         string
+		wantFiltered bool
+	}{
+		{"Great food, friendly staff", "Great food, friendly staff", false},
+		{"The service was shit.", "The service was s***.", true},
+		{"SHIT service", "S*** service", true},
+		{"shit shit shit", "s*** s*** s***", true},
+		{"Quel connard ce serveur", "Quel c****** ce serveur", true},
+		{"Un vrai bâtard", "Un vrai b*****", true},
+		{"Scunthorpe and dickens are fine", "Scunthorpe and dickens are fine", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, filtered := FilterProfanity(tt.text)
+			if got != tt.want || filtered != tt.wantFiltered {
+				t.Errorf("FilterProfanity(%q) = %q, %v, want %q, %v", tt.text, got, filtered, tt.want, tt.wantFiltered)
+			}
+		})
+	}
+}
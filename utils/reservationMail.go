@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	texttemplate "text/template"
+	"time"
+)
+
+// ReservationEmailData is what the client reservation templates are rendered with
+type ReservationEmailData struct {
+	FirstName      string
+	RestaurantName string
+	TimeFrom       time.Time
+	NumberOfPeople int
+	IdReservation  string
+	IdOrder        string // takeaway emails are about an order rather than a reservation
+}
+
+type reservationTemplate struct {
+	subject string
+	heading string
+	text    string
+}
+
+var reservationTemplates = map[string]reservationTemplate{
+	"received": {
+		subject: "Zenciti - We received your reservation at {{.RestaurantName}}",
+		heading: "Reservation received",
+		text:    "Hi {{.FirstName}}, your table for {{.NumberOfPeople}} at {{.RestaurantName}} on {{.TimeFrom.Format \"Monday 02 January 2006 at 15:04\"}} has been requested. We will let you know as soon as the restaurant confirms it.",
+	},
+	"confirmed": {
+		subject: "Zenciti - Your reservation at {{.RestaurantName}} is confirmed",
+		heading: "Reservation confirmed",
+		text:    "Hi {{.FirstName}}, {{.RestaurantName}} confirmed your table for {{.NumberOfPeople}} on {{.TimeFrom.Format \"Monday 02 January 2006 at 15:04\"}}. Enjoy your meal!",
+	},
+	"cancelled": {
+		subject: "Zenciti - Your reservation at {{.RestaurantName}} was cancelled",
+		heading: "Reservation cancelled",
+		text:    "Hi {{.FirstName}}, your reservation for {{.NumberOfPeople}} at {{.RestaurantName}} on {{.TimeFrom.Format \"Monday 02 January 2006 at 15:04\"}} has been cancelled.",
+	},
+	"reminder": {
+		subject: "Zenciti - Reminder: {{.RestaurantName}} at {{.TimeFrom.Format \"15:04\"}}",
+		heading: "See you soon",
+		text:    "Hi {{.FirstName}}, this is a reminder of your table for {{.NumberOfPeople}} at {{.RestaurantName}} on {{.TimeFrom.Format \"Monday 02 January 2006 at 15:04\"}}.",
+	},
+	"takeaway_ready": {
+		subject: "Zenciti - Your order at {{.RestaurantName}} is ready",
+		heading: "Ready for pickup",
+		text:    "Hi {{.FirstName}}, your takeaway order at {{.RestaurantName}} is ready. You asked to pick it up at {{.TimeFrom.Format \"15:04\"}}.",
+	},
+}
+
+var reservationHTMLLayout = htmltemplate.Must(htmltemplate.New("reservation").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Heading}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px; background-color: #f4f4f4;">
+    <div style="background: white; padding: 30px; border-radius: 10px;">
+        <div style="text-align: center; padding-bottom: 20px; border-bottom: 3px solid #e74c3c; margin-bottom: 30px;">
+            <div style="font-size: 32px; font-weight: bold; color: #e74c3c;">Zenciti</div>
+            <div style="font-size: 24px; color: #2c3e50;">{{.Heading}}</div>
+        </div>
+        <p>{{.Body}}</p>
+        <p style="font-size: 12px; color: #888;">{{.ReferenceLabel}}: {{.Reference}}</p>
+        <p style="font-size: 12px; color: #888;">
+            You can turn these emails off from your notification settings in the Zenciti app.<br>
+            This is an automated message. Please do not reply to this email.
+        </p>
+    </div>
+</body>
+</html>`))
+
+// RenderReservationEmail builds the subject, plain text and HTML bodies of a client reservation email
+func RenderReservationEmail(kind string, data ReservationEmailData) (string, string, string, error) {
+	tpl, ok := reservationTemplates[kind]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown reservation email type %s", kind)
+	}
+
+	subject, err := renderText(tpl.subject, data)
+	if err != nil {
+		return "", "", "", err
+	}
+	body, err := renderText(tpl.text, data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var html bytes.Buffer
+	referenceLabel, reference := "Reservation reference", data.IdReservation
+	if data.IdOrder != "" {
+		referenceLabel, reference = "Order reference", data.IdOrder
+	}
+	err = reservationHTMLLayout.Execute(&html, map[string]interface{}{
+		"Heading":        tpl.heading,
+		"Body":           body,
+		"ReferenceLabel": referenceLabel,
+		"Reference":      reference,
+	})
+	if err != nil {
+		return "", "", "", fmt.Errorf("error rendering %s email: %v", kind, err)
+	}
+
+	return subject, body, html.String(), nil
+}
+
+// SendReservationEmail renders and sends a client reservation email
+func SendReservationEmail(kind, email string, data ReservationEmailData) error {
+	subject, textBody, htmlBody, err := RenderReservationEmail(kind, data)
+	if err != nil {
+		return err
+	}
+	if err := sendMultipartEmail(email, subject, textBody, htmlBody); err != nil {
+		return err
+	}
+	log.Printf("Reservation %s email sent to %s", kind, email)
+	return nil
+}
+
+func renderText(text string, data interface{}) (string, error) {
+	tpl, err := texttemplate.New("text").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing email template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering email template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// Helper function to read the account platform emails are sent with from SMTP_USER and SMTP_PASSWORD, on
+// SMTP_HOST:SMTP_PORT (Gmail by default)
+func smtpAccount() (string, string, string, string, error) {
+	user, password := os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD")
+	if user == "" || password == "" {
+		return "", "", "", "", fmt.Errorf("SMTP_USER and SMTP_PASSWORD must be set to send emails")
+	}
+	host, port := os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT")
+	if host == "" {
+		host = "smtp.gmail.com"
+	}
+	if port == "" {
+		port = "587"
+	}
+	return user, password, host, port, nil
+}
+
+// Helper function to build a plain text + HTML message, its parts separated by a random boundary
+func buildMultipartMessage(email, subject, textBody, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	parts := []struct{ contentType, content string }{
+		{`text/plain; charset="UTF-8"`, textBody},
+		{`text/html; charset="UTF-8"`, htmlBody},
+	}
+	for _, part := range parts {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, fmt.Errorf("error building email: %v", err)
+		}
+		if _, err := partWriter.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("error building email: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error building email: %v", err)
+	}
+
+	header := fmt.Sprintf("To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/alternative; boundary=\"%s\"\r\n"+
+		"\r\n",
+		email, mime.QEncoding.Encode("UTF-8", subject), writer.Boundary())
+	return append([]byte(header), body.Bytes()...), nil
+}
+
+// Helper function to send a plain text + HTML email with the platform account
+func sendMultipartEmail(email, subject, textBody, htmlBody string) error {
+	from, password, host, port, err := smtpAccount()
+	if err != nil {
+		return err
+	}
+	message, err := buildMultipartMessage(email, subject, textBody, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	auth := smtp.PlainAuth("", from, password, host)
+	if err := smtp.SendMail(host+":"+port, auth, from, []string{email}, message); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildMultipartMessage(t *testing.T) {
+	raw, err := buildMultipartMessage("client@example.com", "Réservation confirmée", "plain body", "<p>html body</p>")
+	if err != nil {
+		t.Fatalf("buildMultipartMessage: %v", err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Réservation confirmée" {
+		t.Errorf("subject = %q (%v), want %q", subject, err, "Réservation confirmée")
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q (%v), want multipart/alternative", mediaType, err)
+	}
+	if params["boundary"] == "" || params["boundary"] == "boundary123" {
+		t.Errorf("boundary = %q, want a random one", params["boundary"])
+	}
+
+	want := []struct{ contentType, body string }{
+		{"text/plain", "plain body"},
+		{"text/html", "<p>html body</p>"},
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for _, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("reading %s part: %v", w.contentType, err)
+		}
+		body, _ := io.ReadAll(part)
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), w.contentType) || string(body) != w.body {
+			t.Errorf("part = %q %q, want %s %q", part.Header.Get("Content-Type"), body, w.contentType, w.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected two parts, got more (%v)", err)
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// CreateTableToken signs a new QR code token for a restaurant table.
+// The token reads "<idTable>.<nonce>.<signature>"; a new nonce invalidates printed codes.
+func CreateTableToken(idTable string) (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating table token: %v", err)
+	}
+	payload := idTable + "." + hex.EncodeToString(nonce)
+	return payload + "." + signTablePayload(payload), nil
+}
+
+// ParseTableToken checks the signature of a table token and returns the table it was issued for
+func ParseTableToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", fmt.Errorf("invalid table code")
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(signTablePayload(payload)), []byte(parts[2])) {
+		return "", fmt.Errorf("invalid table code")
+	}
+	return parts[0], nil
+}
+
+func signTablePayload(payload string) string {
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write([]byte("table:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}